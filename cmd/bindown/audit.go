@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type auditCmd struct {
+	Dependency []string `kong:"arg,optional,name=dependency,help='dependencies to audit. default is all dependencies',predictor=bin"`
+	OfflineDB  string   `kong:"name=offline-db,type=path,help='directory or zip file of OSV records to use instead of querying osv.dev'"`
+	Fail       bool     `kong:"name=fail,help='exit with an error when there are known vulnerabilities'"`
+}
+
+func (c *auditCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	reports, err := config.AuditVulnerabilities(ctx, &bindown.AuditVulnerabilitiesOpts{
+		Dependencies: c.Dependency,
+		OfflineDB:    c.OfflineDB,
+	})
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, report := range reports {
+		if report.Skipped != "" {
+			fmt.Fprintf(ctx.stdout, "%s: skipped: %s\n", report.Dependency, report.Skipped)
+			continue
+		}
+		desc := fmt.Sprintf("%s %s (%s)", report.Dependency, report.Version, report.Package)
+		if len(report.Vulnerabilities) == 0 {
+			fmt.Fprintf(ctx.stdout, "%s: no known vulnerabilities\n", desc)
+			continue
+		}
+		count += len(report.Vulnerabilities)
+		fmt.Fprintf(ctx.stdout, "%s: known vulnerabilities:\n", desc)
+		for _, vuln := range report.Vulnerabilities {
+			fmt.Fprintf(ctx.stdout, "  %s: %s\n", vuln.ID, vuln.Summary)
+		}
+	}
+	if !c.Fail || count == 0 {
+		return nil
+	}
+	if count == 1 {
+		return fmt.Errorf("found 1 vulnerability")
+	}
+	return fmt.Errorf("found %d vulnerabilities", count)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_auditCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  fixed:
+    url: https://github.com/example/tool/releases/download/v{{ .version }}/tool
+    vars:
+      version: 1.2.0
+  tool:
+    url: https://github.com/example/tool/releases/download/v{{ .version }}/tool
+    vars:
+      version: 1.1.0
+  unknown:
+    url: https://example.com/unknown
+`)
+	dbDir := filepath.Join(runner.tmpDir, "osv")
+	require.NoError(t, os.MkdirAll(filepath.Join(dbDir, "GO"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dbDir, "GO", "GO-2024-0001.json"), []byte(`{
+  "id": "GO-2024-0001",
+  "summary": "bad things",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/example/tool"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]
+  }]
+}`), 0o600))
+
+	wantStdout := `fixed 1.2.0 (Go github.com/example/tool): no known vulnerabilities
+tool 1.1.0 (Go github.com/example/tool): known vulnerabilities:
+  GO-2024-0001: bad things
+unknown: skipped: no osv package`
+
+	result := runner.run("audit", "--offline-db", dbDir)
+	result.assertState(resultState{stdout: wantStdout})
+
+	result = runner.run("audit", "--offline-db", dbDir, "--fail")
+	result.assertState(resultState{
+		stdout: wantStdout,
+		stderr: "cmd: error: found 1 vulnerability",
+		exit:   1,
+	})
+
+	result = runner.run("audit", "fixed", "--offline-db", dbDir, "--fail")
+	result.assertState(resultState{stdout: "fixed 1.2.0 (Go github.com/example/tool): no known vulnerabilities"})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type bundleCmd struct {
+	Dependency []string         `kong:"arg,optional,name=dependency,help='dependencies to bundle. default is all dependencies',predictor=bin"`
+	System     []bindown.System `kong:"name=system,default=${system_default},help='systems to bundle',predictor=allSystems"`
+	AllSystems bool             `kong:"name=all-systems,help='bundle all systems each dependency supports'"`
+	Output     string           `kong:"type=path,default=bindown-bundle.tar.gz,help='file to write the bundle to'"`
+}
+
+func (c *bundleCmd) Run(ctx *runContext) (errOut error) {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	systems := c.System
+	if c.AllSystems {
+		systems = nil
+	}
+	err = os.MkdirAll(filepath.Dir(c.Output), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(c.Output)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, file.Close()) }()
+	err = config.Bundle(file, &bindown.BundleOpts{
+		Dependencies: c.Dependency,
+		Systems:      systems,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "wrote bundle to %s\n", c.Output)
+	return nil
+}
+
+type unbundleCmd struct {
+	Bundle string         `kong:"arg,type=existingfile,help='bundle file created by bindown bundle'"`
+	Dir    string         `kong:"type=path,default=.,help='directory to write the bundled config to'"`
+	System bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
+}
+
+func (c *unbundleCmd) Run(ctx *runContext) (errOut error) {
+	file, err := os.Open(c.Bundle)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, file.Close()) }()
+	_, err = bindown.Unbundle(ctx, file, &bindown.UnbundleOpts{
+		Dir:    c.Dir,
+		Cache:  ctx.rootCmd.CacheDir,
+		System: c.System,
+		Stdout: ctx.stdout,
+	})
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_bundleCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+	bundle := filepath.Join(runner.tmpDir, "out", "bundle.tar.gz")
+	result := runner.run("bundle", "--system", "linux/amd64", "--output", bundle)
+	result.assertState(resultState{stdout: "wrote bundle to " + bundle})
+
+	// unbundle somewhere else with the server stopped
+	server.Close()
+	unbundleDir := filepath.Join(runner.tmpDir, "airgapped")
+	unbundler := newCmdRunner(t)
+	unbundler.configFile = ""
+	result = unbundler.run("unbundle", bundle, "--dir", unbundleDir, "--system", "linux/amd64")
+	wantBin := filepath.Join(unbundleDir, "bin", "foo")
+	result.assertState(resultState{stdout: "installed foo to " + wantBin})
+	testutil.AssertFile(t, wantBin, true, false)
+	got, err := os.ReadFile(filepath.Join(unbundleDir, ".bindown.yaml"))
+	require.NoError(t, err)
+	want, err := os.ReadFile(runner.configFile)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+
+	t.Run("missing system", func(t *testing.T) {
+		result := unbundler.run("unbundle", bundle, "--dir", unbundleDir, "--system", "darwin/arm64")
+		result.assertState(resultState{
+			stderr: "cmd: error: bundle does not contain foo for darwin/arm64",
+			exit:   1,
+		})
+	})
+
+	t.Run("conflicting config", func(t *testing.T) {
+		dir := filepath.Join(runner.tmpDir, "conflict")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".bindown.yaml"), []byte("{}"), 0o644))
+		result := unbundler.run("unbundle", bundle, "--dir", dir, "--system", "linux/amd64")
+		result.assertState(resultState{
+			stderr: "cmd: error: .*already exists with different content",
+			exit:   1,
+		})
+	})
+}
+
+func Test_bundleCmd_lock(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/v1.0.0/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/v1.0.0/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s/foo/v{{.version}}/fooinroot.tar.gz
+    vars:
+      version: latest
+`, server.URL))
+	lockData := fmt.Sprintf(`dependencies:
+  foo:
+    version: 1.0.0
+    downloads:
+      linux/amd64:
+        url: %s
+        checksum: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL)
+	require.NoError(t, os.WriteFile(filepath.Join(runner.tmpDir, "bindown.lock"), []byte(lockData), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(runner.tmpDir, "bindown.lock.sig"), []byte("signature"), 0o644))
+	bundle := filepath.Join(runner.tmpDir, "out", "bundle.tar.gz")
+	result := runner.run("bundle", "--system", "linux/amd64", "--output", bundle)
+	result.assertState(resultState{stdout: "wrote bundle to " + bundle})
+
+	server.Close()
+	unbundleDir := filepath.Join(runner.tmpDir, "airgapped")
+	unbundler := newCmdRunner(t)
+	unbundler.configFile = ""
+	result = unbundler.run("unbundle", bundle, "--dir", unbundleDir, "--system", "linux/amd64")
+	wantBin := filepath.Join(unbundleDir, "bin", "foo")
+	result.assertState(resultState{stdout: "installed foo to " + wantBin})
+	testutil.AssertFile(t, wantBin, true, false)
+	got, err := os.ReadFile(filepath.Join(unbundleDir, "bindown.lock"))
+	require.NoError(t, err)
+	require.Equal(t, lockData, string(got))
+	got, err = os.ReadFile(filepath.Join(unbundleDir, "bindown.lock.sig"))
+	require.NoError(t, err)
+	require.Equal(t, "signature", string(got))
+}
@@ -1,15 +1,103 @@
 package main
 
+import (
+	"fmt"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
 type cacheCmd struct {
-	Clear cacheClearCmd `kong:"cmd,help='clear the cache'"`
+	Clear   cacheClearCmd   `kong:"cmd,help='clear the cache'"`
+	Key     cacheKeyCmd     `kong:"cmd,help='print a hash of the resolved dependencies for use as a CI cache key'"`
+	Verify  cacheVerifyCmd  `kong:"cmd,help='check cached downloads and extracted files against their checksums'"`
+	Prewarm cachePrewarmCmd `kong:"cmd,help='download dependencies to the cache without installing them'"`
 }
 
-type cacheClearCmd struct{}
+type cacheClearCmd struct {
+	KeepExtracted bool `kong:"name=keep-extracted,help='only remove downloads. installs reuse extracted files without downloading them again'"`
+}
 
 func (c *cacheClearCmd) Run(ctx *runContext) error {
 	config, err := loadConfigFile(ctx, false)
 	if err != nil {
 		return err
 	}
+	if c.KeepExtracted {
+		return config.ClearDownloads()
+	}
 	return config.ClearCache()
 }
+
+type cacheKeyCmd struct {
+	Dependency []string         `kong:"arg,optional,name=dependency,help='dependencies to include. default is all dependencies',predictor=bin"`
+	System     []bindown.System `kong:"name=system,default=${system_default},help='systems to include',predictor=allSystems"`
+	AllSystems bool             `kong:"name=all-systems,help='include all systems each dependency supports'"`
+}
+
+func (c *cacheKeyCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	systems := c.System
+	if c.AllSystems {
+		systems = nil
+	}
+	key, err := config.CacheKey(c.Dependency, systems)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.stdout, key)
+	return nil
+}
+
+type cacheVerifyCmd struct {
+	Jobs int `kong:"name=jobs,help=${jobs_help}"`
+}
+
+func (c *cacheVerifyCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	checked, problems, err := config.VerifyCache(&bindown.VerifyCacheOpts{Jobs: c.Jobs})
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(ctx.stdout, problem.String())
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(ctx.stdout, "verified %d cache entries\n", checked)
+	}
+	return foundProblems(len(problems))
+}
+
+type cachePrewarmCmd struct {
+	Dependency           []string         `kong:"arg,optional,name=dependency,help='dependencies to cache. default is all dependencies',predictor=bin"`
+	System               []bindown.System `kong:"name=system,default=${system_default},help='systems to cache',predictor=allSystems"`
+	AllSystems           bool             `kong:"name=all-systems,help='cache all systems each dependency supports'"`
+	Extract              bool             `kong:"name=extract,help='extract downloads in the cache too'"`
+	AllowMissingChecksum bool             `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+	Jobs                 int              `kong:"name=jobs,help='how many dependencies to download at once. default is the number of cpus'"`
+}
+
+func (c *cachePrewarmCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	systems := c.System
+	if c.AllSystems {
+		systems = nil
+	}
+	return writeTrustedChecksums(ctx, config, func() error {
+		return config.PrewarmCache(c.Dependency, systems, &bindown.PrewarmCacheOpts{
+			Extract:              c.Extract,
+			AllowMissingChecksum: c.AllowMissingChecksum,
+			Jobs:                 c.Jobs,
+			Stdout:               ctx.stdout,
+			Stderr:               ctx.stderr,
+		})
+	})
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/willabides/bindown/v4/internal/testutil"
 )
 
@@ -33,6 +36,35 @@ url_checksums:
 		assert.NoDirExists(t, extractDir)
 	})
 
+	t.Run("keep extracted", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		dlFile := filepath.Join(runner.tmpDir, "fooinroot.tar.gz")
+		data, err := os.ReadFile(servePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dlFile, data, 0o600))
+		server := testutil.ServeFile(t, dlFile, "/foo/fooinroot.tar.gz", "")
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s/foo/fooinroot.tar.gz
+url_checksums:
+  %s/foo/fooinroot.tar.gz: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, server.URL, server.URL))
+		result := runner.run("install", "foo")
+		require.Equal(t, 0, result.exitVal)
+		result = runner.run("cache", "clear", "--keep-extracted")
+		result.assertState(resultState{})
+		assert.NoDirExists(t, filepath.Join(runner.cache, "downloads"))
+		assert.DirExists(t, filepath.Join(runner.cache, "extracts"))
+
+		// the download is gone from the server too, so this only works with the extracted files
+		require.NoError(t, os.Remove(dlFile))
+		other := filepath.Join(runner.tmpDir, "other", "foo")
+		result = runner.run("install", "foo", "--output", other)
+		result.assertState(resultState{stdout: "installed foo to " + other})
+		assert.FileExists(t, other)
+	})
+
 	t.Run("does nothing if cache is empty", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(`{}`)
@@ -48,8 +80,112 @@ url_checksums:
 			wantStdErr = `The system cannot find the file specified`
 		}
 		result.assertState(resultState{
-			exit:   1,
+			exit:   exitConfigError,
 			stderr: wantStdErr,
 		})
 	})
 }
+
+func Test_cacheKeyCmd(t *testing.T) {
+	config := `
+systems: [linux/amd64, darwin/arm64]
+dependencies:
+  foo:
+    url: https://example.com/foo-{{.os}}-{{.arch}}
+url_checksums:
+  https://example.com/foo-linux-amd64: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(config)
+	result := runner.run("cache", "key", "--system", "linux/amd64")
+	require.Equal(t, 0, result.exitVal)
+	key := strings.TrimSpace(result.stdOut.String())
+	assert.Regexp(t, `^[0-9a-f]{64}$`, key)
+
+	// reordering the config doesn't change the key
+	runner.writeConfigYaml(`
+url_checksums:
+  https://example.com/foo-linux-amd64: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: https://example.com/foo-{{.os}}-{{.arch}}
+systems: [darwin/arm64, linux/amd64]
+`)
+	result = runner.run("cache", "key", "--system", "linux/amd64")
+	result.assertState(resultState{stdout: key})
+
+	// other systems are not included unless requested
+	allResult := runner.run("cache", "key", "--all-systems")
+	require.Equal(t, 0, allResult.exitVal)
+	assert.NotEqual(t, key, strings.TrimSpace(allResult.stdOut.String()))
+
+	// changing a checksum changes the key
+	runner.writeConfigYaml(strings.ReplaceAll(config, "27dcce", "00dcce"))
+	result = runner.run("cache", "key", "--system", "linux/amd64")
+	require.Equal(t, 0, result.exitVal)
+	assert.NotEqual(t, key, strings.TrimSpace(result.stdOut.String()))
+}
+
+func Test_cacheVerifyCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  bar:
+    url: %s/bar.tar.gz
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, server.URL, depURL))
+
+	result := runner.run("cache", "verify")
+	result.assertState(resultState{stdout: "verified 0 cache entries"})
+
+	result = runner.run("extract", "foo")
+	require.Equal(t, 0, result.exitVal)
+	extractDir := result.getExtractDir()
+	result = runner.run("cache", "verify", "--jobs", "2")
+	result.assertState(resultState{stdout: "verified 2 cache entries"})
+
+	dlFiles, err := filepath.Glob(filepath.Join(runner.cache, "downloads", "*", "fooinroot.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, dlFiles, 1)
+	require.NoError(t, os.Chmod(filepath.Dir(dlFiles[0]), 0o755))
+	require.NoError(t, os.WriteFile(dlFiles[0], []byte("corrupted"), 0o644))
+	require.NoError(t, os.Chmod(extractDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(extractDir, "foo"), []byte("corrupted"), 0o755))
+	result = runner.run("cache", "verify")
+	require.Equal(t, 1, result.exitVal)
+	require.Contains(t, result.stdOut.String(), fmt.Sprintf(
+		"%s: expected checksum 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3, got ", dlFiles[0],
+	))
+	require.Contains(t, result.stdOut.String(), extractDir+": expected checksum ")
+	require.Contains(t, result.stdErr.String(), "found 2 problems")
+}
+
+func Test_cachePrewarmCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    systems: [linux/amd64, darwin/arm64]
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+
+	result := runner.run("cache", "prewarm", "--system", "linux/amd64", "--system", "windows/amd64", "--extract")
+	result.assertState(resultState{stdout: "cached foo for linux/amd64"})
+	result = runner.run("cache", "verify")
+	result.assertState(resultState{stdout: "verified 2 cache entries"})
+	require.NoDirExists(t, filepath.Join(runner.tmpDir, "bin"))
+
+	result = runner.run("cache", "prewarm", "--all-systems")
+	result.assertState(resultState{stdout: "cached foo for linux/amd64\ncached foo for darwin/arm64"})
+}
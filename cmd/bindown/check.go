@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type checkCmd struct {
+	System        bindown.System `kong:"name=system,default=${system_default},help='system to check installed dependencies for',predictor=allSystems"`
+	SkipInstalled bool           `kong:"name=skip-installed,help='only check url_checksums. does not download anything'"`
+	Versions      bool           `kong:"name=versions,help=${check_versions_help}"`
+}
+
+func (c *checkCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	drifts, err := config.Check(&bindown.CheckOpts{
+		System:        c.System,
+		SkipInstalled: c.SkipInstalled,
+		Versions:      c.Versions,
+	})
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		fmt.Fprintln(ctx.stdout, "in sync with", config.Filename)
+		return nil
+	}
+	for _, drift := range drifts {
+		fmt.Fprintln(ctx.stdout, drift.String())
+	}
+	return foundProblems(len(drifts))
+}
+
+type verifyCmd struct {
+	System bindown.System `kong:"name=system,default=${system_default},help='system to verify installed dependencies for',predictor=allSystems"`
+}
+
+func (c *verifyCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	drifts, err := config.Verify(&bindown.VerifyOpts{System: c.System})
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		fmt.Fprintln(ctx.stdout, "installed bins match bin_checksums")
+		return nil
+	}
+	for _, drift := range drifts {
+		fmt.Fprintln(ctx.stdout, drift.String())
+	}
+	return foundProblems(len(drifts))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/bindown"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_checkCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  bar:
+    url: %s/bar.tar.gz
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+  https://example.com/stale.tar.gz: deadbeef
+`, depURL, server.URL, depURL))
+	fooBin := filepath.Join(runner.tmpDir, "bin", "foo")
+	barBin := filepath.Join(runner.tmpDir, "bin", "bar")
+
+	result := runner.run("check", "--skip-installed")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf(`- %s/bar.tar.gz: no checksum for bar on %s
++ https://example.com/stale.tar.gz: checksum is not used by any dependency`, server.URL, bindown.CurrentSystem),
+		stderr: "cmd: error: found 2 problems",
+		exit:   1,
+	})
+
+	result = runner.run("check")
+	require.Equal(t, 1, result.exitVal)
+	require.Contains(t, result.stdOut.String(), fmt.Sprintf("- %s: bar is not installed\n", barBin))
+	require.Contains(t, result.stdOut.String(), fmt.Sprintf("- %s: foo is not installed\n", fooBin))
+	require.Contains(t, result.stdErr.String(), "found 4 problems")
+
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+	result = runner.run("install", "foo")
+	require.Equal(t, 0, result.exitVal)
+	result = runner.run("check")
+	result.assertState(resultState{stdout: "in sync with " + runner.configFile})
+
+	require.NoError(t, os.WriteFile(fooBin, []byte("tampered"), 0o755))
+	result = runner.run("check")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("~ %s: foo does not match %s", fooBin, depURL),
+		stderr: "cmd: error: found 1 problem",
+		exit:   1,
+	})
+}
+
+func Test_verifyCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	config := fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL)
+	runner.writeConfigYaml(config)
+	fooBin := filepath.Join(runner.tmpDir, "bin", "foo")
+
+	result := runner.run("verify")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("- %s: foo is not installed", fooBin),
+		stderr: "cmd: error: found 1 problem",
+		exit:   1,
+	})
+
+	result = runner.run("install", "foo")
+	require.Equal(t, 0, result.exitVal)
+	result = runner.run("verify")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("- %s: no bin checksum for foo on %s", fooBin, bindown.CurrentSystem),
+		stderr: "cmd: error: found 1 problem",
+		exit:   1,
+	})
+
+	result = runner.run("checksums", "add", "--bin", "--system", string(bindown.CurrentSystem))
+	require.Equal(t, 0, result.exitVal)
+	runner.assertConfigYaml(config + fmt.Sprintf(`bin_checksums:
+  %s#foo: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL))
+	result = runner.run("verify")
+	result.assertState(resultState{stdout: "installed bins match bin_checksums"})
+
+	require.NoError(t, os.WriteFile(fooBin, []byte("tampered"), 0o755))
+	result = runner.run("verify")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("~ %s: foo does not match its bin checksum", fooBin),
+		stderr: "cmd: error: found 1 problem",
+		exit:   1,
+	})
+
+	// installing verifies the extracted bin
+	runner.writeConfigYaml(config + fmt.Sprintf(`bin_checksums:
+  %s#foo: "0000000000000000000000000000000000000000000000000000000000000000"
+`, depURL))
+	result = runner.run("install", "foo", "--force")
+	require.Equal(t, exitChecksumMismatch, result.exitVal)
+	require.Contains(t, result.stdErr.String(), `cmd: error: bin checksum mismatch in "foo" extracted for foo`)
+}
+
+func Test_checkCmd_versions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script")
+	}
+	runner := newCmdRunner(t)
+	script := filepath.Join(runner.tmpDir, "foo-script")
+	content := []byte("#!/bin/sh\necho foo version 1.2.0\n")
+	require.NoError(t, os.WriteFile(script, content, 0o755))
+	sum := sha256.Sum256(content)
+	server := testutil.ServeFile(t, script, "/foo", "")
+	depURL := server.URL + "/foo"
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    vars:
+      version: 1.2.0
+url_checksums:
+  %s: %s
+`, depURL, depURL, hex.EncodeToString(sum[:])))
+	fooBin := filepath.Join(runner.tmpDir, "bin", "foo")
+	result := runner.run("install", "foo")
+	require.Equal(t, 0, result.exitVal)
+
+	result = runner.run("check", "--versions")
+	result.assertState(resultState{stdout: "in sync with " + runner.configFile})
+
+	// a stale copy earlier in PATH
+	pathDir := filepath.Join(runner.tmpDir, "path")
+	require.NoError(t, os.Mkdir(pathDir, 0o755))
+	stale := filepath.Join(pathDir, "foo")
+	require.NoError(t, os.WriteFile(stale, []byte("#!/bin/sh\necho foo version 1.1.0\n"), 0o755))
+	t.Setenv("PATH", pathDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	result = runner.run("check", "--versions", "--skip-installed")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("~ %s: foo on PATH shadows %s and does not report version 1.2.0: foo version 1.1.0", stale, fooBin),
+		stderr: "cmd: error: found 1 problem",
+		exit:   1,
+	})
+
+	// the installed bin is replaced
+	require.NoError(t, os.WriteFile(fooBin, []byte("#!/bin/sh\nexit 2\n"), 0o755))
+	result = runner.run("check", "--versions", "--skip-installed")
+	require.Equal(t, 1, result.exitVal)
+	require.Contains(t, result.stdOut.String(), fmt.Sprintf("~ %s: foo does not report version 1.2.0: version command failed: exit status 2\n", fooBin))
+
+	result = runner.run("check", "--versions", "--system", "plan9/arm")
+	result.assertState(resultState{
+		stderr: "cmd: error: can only check versions for " + string(bindown.CurrentSystem),
+		exit:   1,
+	})
+}
@@ -1,46 +1,87 @@
 package main
 
 import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/willabides/bindown/v4/internal/bindown"
 )
 
 type checksumsCmd struct {
-	Add   addChecksumsCmd   `kong:"cmd,help=${add_checksums_help}"`
-	Prune pruneChecksumsCmd `kong:"cmd,help=${prune_checksums_help}"`
-	Sync  syncChecksumsCmd  `kong:"cmd,help=${sync_checksums_help}"`
+	Add    addChecksumsCmd    `kong:"cmd,help=${add_checksums_help}"`
+	Prune  pruneChecksumsCmd  `kong:"cmd,help=${prune_checksums_help}"`
+	Sync   syncChecksumsCmd   `kong:"cmd,help=${sync_checksums_help}"`
+	Repair repairChecksumsCmd `kong:"cmd,help=${repair_checksums_help}"`
+	List   listChecksumsCmd   `kong:"cmd,help=${list_checksums_help}"`
 }
 
 type addChecksumsCmd struct {
 	Dependency []string         `kong:"help=${checksums_dep_help},predictor=bin"`
 	Systems    []bindown.System `kong:"name=system,help=${systems_help},predictor=allSystems"`
+	Bin        bool             `kong:"name=bin,help='also add checksums of the extracted bins to bin_checksums'"`
 }
 
 func (d *addChecksumsCmd) Run(ctx *runContext) error {
+	// --cache is only used for extracting bins and shouldn't be written to the config
+	cacheDir := ctx.rootCmd.CacheDir
+	ctx.rootCmd.CacheDir = ""
 	config, err := loadConfigFile(ctx, true)
 	if err != nil {
 		return err
 	}
-	err = config.AddChecksums(d.Dependency, d.Systems)
-	if err != nil {
+	return config.Batch(ctx.rootCmd.JSONConfig, func() error {
+		err := config.AddChecksums(d.Dependency, d.Systems)
+		if err != nil || !d.Bin {
+			return err
+		}
+		fileCache := config.Cache
+		if cacheDir != "" {
+			config.Cache = cacheDir
+		}
+		err = config.AddBinChecksums(d.Dependency, d.Systems)
+		config.Cache = fileCache
 		return err
-	}
-	return config.WriteFile(ctx.rootCmd.JSONConfig)
+	})
 }
 
-type pruneChecksumsCmd struct{}
+type pruneChecksumsCmd struct {
+	DryRun bool `kong:"name=dry-run,help='report the checksums that would be removed without changing the config'"`
+}
 
 func (d *pruneChecksumsCmd) Run(ctx *runContext) error {
 	config, err := loadConfigFile(ctx, true)
 	if err != nil {
 		return err
 	}
-	err = config.PruneChecksums()
+	if d.DryRun {
+		var pruned []bindown.PrunedChecksum
+		pruned, err = config.PlanPruneChecksums()
+		if err != nil {
+			return err
+		}
+		writePrunedChecksums(ctx, pruned, "would remove")
+		return nil
+	}
+	pruned, err := config.PruneChecksums()
 	if err != nil {
 		return err
 	}
+	writePrunedChecksums(ctx, pruned, "removed")
 	return config.WriteFile(ctx.rootCmd.JSONConfig)
 }
 
+func writePrunedChecksums(ctx *runContext, pruned []bindown.PrunedChecksum, verb string) {
+	for _, p := range pruned {
+		field := "url_checksums"
+		if p.Bin {
+			field = "bin_checksums"
+		}
+		fmt.Fprintf(ctx.stdout, "%s %s from %s: %s\n", verb, p.Key, field, p.Reason)
+	}
+}
+
 type syncChecksumsCmd struct{}
 
 func (d *syncChecksumsCmd) Run(ctx *runContext) error {
@@ -48,13 +89,105 @@ func (d *syncChecksumsCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
-	err = config.PruneChecksums()
+	return config.Batch(ctx.rootCmd.JSONConfig, func() error {
+		_, err := config.PruneChecksums()
+		if err != nil {
+			return err
+		}
+		return config.AddChecksums(nil, nil)
+	})
+}
+
+type repairChecksumsCmd struct {
+	Dependency  string           `kong:"arg,help='dependency to repair',predictor=bin"`
+	Systems     []bindown.System `kong:"name=system,help=${systems_help},predictor=allSystems"`
+	Yes         bool             `kong:"short=y,help='update the checksums without confirming'"`
+	GithubToken string           `kong:"hidden,env='GITHUB_TOKEN'"`
+	GitlabToken string           `kong:"hidden,env='GITLAB_TOKEN'"`
+}
+
+func (d *repairChecksumsCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, true)
 	if err != nil {
 		return err
 	}
-	err = config.AddChecksums(nil, nil)
+	repairs, err := config.PlanChecksumRepair(ctx, d.Dependency, &bindown.ChecksumRepairOpts{
+		Systems:     d.Systems,
+		GitHubToken: d.GithubToken,
+		GitLabToken: d.GitlabToken,
+	})
 	if err != nil {
 		return err
 	}
+	if len(repairs) == 0 {
+		fmt.Fprintf(ctx.stdout, "checksums for %s match their downloads\n", d.Dependency)
+		return nil
+	}
+	for _, repair := range repairs {
+		released := "unknown"
+		if !repair.ReleaseDate.IsZero() {
+			released = repair.ReleaseDate.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(ctx.stdout, "%s for %s: %s\n", repair.Dependency, repair.System, repair.URL)
+		fmt.Fprintf(ctx.stdout, "  old checksum: %s\n", repair.OldChecksum)
+		fmt.Fprintf(ctx.stdout, "  new checksum: %s\n", repair.NewChecksum)
+		fmt.Fprintf(ctx.stdout, "  released: %s\n", released)
+	}
+	if !d.Yes {
+		ok := false
+		err = survey.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("Only continue if upstream replaced the release files on purpose. Update %d checksums?", len(repairs)),
+		}, &ok, survey.WithStdio(ctx.stdin, ctx.stdout, nil))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("canceled")
+		}
+	}
+	config.RepairChecksums(repairs)
 	return config.WriteFile(ctx.rootCmd.JSONConfig)
 }
+
+type listChecksumsCmd struct {
+	Dependency  string           `kong:"arg,help='dependency to list checksums for',predictor=bin"`
+	Systems     []bindown.System `kong:"name=system,help=${systems_help},predictor=allSystems"`
+	MissingOnly bool             `kong:"name=missing-only,help='only list systems without a checksum and fail when there are any'"`
+}
+
+func (d *listChecksumsCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	checksums, err := config.ListChecksums(d.Dependency, d.Systems)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(ctx.stdout, 0, 0, 1, ' ', 0)
+	missing := 0
+	for _, sc := range checksums.Systems {
+		checksum := sc.Checksum
+		if checksum == "" {
+			checksum = "missing"
+			missing++
+		} else if d.MissingOnly {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", sc.System, sc.URL, checksum)
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	if d.MissingOnly {
+		if missing > 0 {
+			return fmt.Errorf("%s is missing checksums for %d of %d systems", d.Dependency, missing, len(checksums.Systems))
+		}
+		return nil
+	}
+	for _, key := range checksums.Stale {
+		fmt.Fprintf(ctx.stdout, "stale: %s\n", key)
+	}
+	return nil
+}
@@ -62,7 +62,7 @@ dependencies:
 		result := runner.run("checksums", "add", "--system", "darwin/amd64")
 		result.assertState(resultState{
 			stderr: "cmd: error: failed downloading",
-			exit:   1,
+			exit:   exitNetworkError,
 		})
 	})
 
@@ -93,10 +93,99 @@ dependencies:
     url: foo
 `)
 		result := runner.run("checksums", "prune")
-		result.assertState(resultState{})
+		result.assertState(resultState{
+			stdout: "removed baz from url_checksums: no dependency uses it",
+		})
 		want := map[string]string{
 			"foo": "bar",
 		}
 		require.Equal(t, want, runner.getConfigFile().URLChecksums)
 	})
+
+	t.Run("dry run with dropped systems", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+systems: [linux/amd64]
+url_checksums:
+  foo-linux-amd64: deadbeef
+  foo-darwin-amd64: deadbeef
+  foo-darwin-arm64: deadbeef
+  old: deadbeef
+bin_checksums:
+  foo-darwin-amd64#foo: deadbeef
+dependencies:
+  foo:
+    url: foo-{{ .os }}-{{ .arch }}
+`)
+		before := runner.getConfigFile()
+		result := runner.run("checksums", "prune", "--dry-run")
+		result.assertState(resultState{
+			stdout: `would remove foo-darwin-amd64 from url_checksums: only used for systems that are not supported: foo on darwin/amd64
+would remove foo-darwin-arm64 from url_checksums: only used for systems that are not supported: foo on darwin/arm64
+would remove old from url_checksums: no dependency uses it
+would remove foo-darwin-amd64#foo from bin_checksums: only used for systems that are not supported: foo on darwin/amd64`,
+		})
+		require.Equal(t, before, runner.getConfigFile())
+	})
+}
+
+func Test_repairChecksumsCmd(t *testing.T) {
+	server := testutil.ServeFile(t, testdataPath("downloadables/fooinroot.tar.gz"), "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+systems: [linux/amd64]
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: deadbeef
+`, depURL, depURL))
+	result := runner.run("checksums", "repair", "foo", "--yes")
+	result.assertState(resultState{
+		stdout: `foo for linux/amd64: ` + depURL + `
+  old checksum: deadbeef
+  new checksum: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+  released: unknown`,
+	})
+	require.Equal(t, map[string]string{
+		depURL: "27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3",
+	}, runner.getConfigFile().URLChecksums)
+
+	result = runner.run("checksums", "repair", "foo")
+	result.assertState(resultState{stdout: "checksums for foo match their downloads"})
+}
+
+func Test_listChecksumsCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+systems: [darwin/arm64, linux/amd64]
+dependencies:
+  foo:
+    url: https://example.com/v{{.version}}/foo-{{.version}}-{{.os}}-{{.arch}}.tar.gz
+    vars:
+      version: 1.2.0
+url_checksums:
+  https://example.com/v1.2.0/foo-1.2.0-linux-amd64.tar.gz: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+  https://example.com/v1.1.0/foo-1.1.0-linux-amd64.tar.gz: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+  https://example.com/unrelated.tar.gz: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`)
+	result := runner.run("checksums", "list", "foo")
+	result.assertState(resultState{
+		stdout: `
+darwin/arm64 https://example.com/v1.2.0/foo-1.2.0-darwin-arm64.tar.gz missing
+linux/amd64  https://example.com/v1.2.0/foo-1.2.0-linux-amd64.tar.gz  27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+stale: https://example.com/v1.1.0/foo-1.1.0-linux-amd64.tar.gz
+`,
+	})
+
+	result = runner.run("checksums", "list", "foo", "--missing-only")
+	result.assertState(resultState{
+		stdout: "darwin/arm64 https://example.com/v1.2.0/foo-1.2.0-darwin-arm64.tar.gz missing",
+		stderr: "cmd: error: foo is missing checksums for 1 of 2 systems",
+		exit:   1,
+	})
+
+	result = runner.run("checksums", "list", "foo", "--missing-only", "--system", "linux/amd64")
+	result.assertState(resultState{})
 }
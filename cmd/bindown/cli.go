@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"reflect"
+	"runtime"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/mattn/go-isatty"
 	"github.com/willabides/bindown/v4/internal/bindown"
 	"github.com/willabides/kongplete"
 )
@@ -20,6 +26,7 @@ var kongVars = kong.Vars{
 	"install_help":                    `download, extract and install a dependency`,
 	"wrap_help":                       `create a wrapper script for a dependency`,
 	"system_default":                  string(bindown.CurrentSystem),
+	"docker_system_default":           "linux/" + runtime.GOARCH,
 	"system_help":                     `target system in the format of <os>/<architecture>`,
 	"systems_help":                    `target systems in the format of <os>/<architecture>`,
 	"add_checksums_help":              `add checksums to the config file`,
@@ -30,24 +37,73 @@ var kongVars = kong.Vars{
 	"config_install_completions_help": `install shell completions`,
 	"config_extract_path_help":        `output path to directory where the downloaded archive is extracted`,
 	"install_force_help":              `force install even if it already exists`,
+	"force_reinstall_all_help":        `clear the cache and reinstall all dependencies, downloading and verifying everything again. use this when the cache may have been tampered with`,
 	"output_help":                     `where to write the file. this is a directory unless a single dependency is selected and the path isn't an existing directory`,
 	"download_force_help":             `force download even if the file already exists`,
 	"allow_missing_checksum":          `allow missing checksums`,
 	"download_help":                   `download a dependency but don't extract or install it`,
 	"extract_help":                    `download and extract a dependency but don't install it`,
+	"download_output_help":            `where to copy the download instead of leaving it in the cache. this is a directory unless a single dependency is selected and the path isn't an existing directory`,
+	"extract_output_help":             `directory to copy the extracted files to instead of leaving them in the cache. each dependency gets a subdirectory when extracting multiple dependencies`,
 	"checksums_dep_help":              `name of the dependency to update`,
 	"all_deps_help":                   `select all dependencies`,
 	"dependency_help":                 `name of dependency`,
 	"install_to_cache_help":           `install to cache instead of install dir`,
+	"install_to_dir_help":             `directory to install to. unlike --output, this is always a directory and each dependency gets its default bin name`,
 	"install_wrapper_help":            `install a wrapper script instead of the binary`,
 	"install_bindown_help":            `path to bindown executable to use in wrapper`,
+	"install_systems_help":            `target system in the format of <os>/<architecture>. when more than one system is given, each is installed to --system-path`,
+	"all_systems_help":                `install for all systems supported by each dependency. installs to --system-path`,
+	"system_path_default":             bindown.DefaultSystemPath,
+	"system_path_help":                `template for the path relative to the output directory where each system is installed when installing for multiple systems`,
+	"stream_help":                     `extract tar archives while they download instead of caching the download first`,
+	"install_watch_help":              `keep running and install again whenever the config file changes`,
+	"add_to_ci_path_help":             `add the install directory to PATH for later steps of the CI job. supports GitHub Actions, Azure Pipelines and GitLab CI. GitLab CI jobs get PATH in the dotenv file named by BINDOWN_DOTENV (default bindown.env)`,
+	"no_color_help":                   `disable colored output. color is also disabled when NO_COLOR is set or stdout is not a terminal`,
+	"strict_help":                     `reject override matchers and substitutions that refer to unknown vars. this is also enabled by "strict: true" in the config`,
+	"schema_url_default":              bindown.DefaultSchemaURL,
+	"template_source_pin_commit_help": `change the branch or tag in a raw.githubusercontent.com url to the commit it points to and record the digest`,
+	"install_metrics_file_help":       `write the download size, cache hits and timing of each dependency to this file as json`,
+	"dependency_add_from_help":        `copy the dependency from another config file or url instead of a template, along with its templates and checksums. the template argument is the dependency's name there`,
+	"dependency_add_from_json_help":   `add the dependency described by a json object in this file, or "-" for stdin, instead of a template. it has the same properties as a dependency in the config file`,
+	"list_checksums_help":             `show the url and checksum of a dependency for each system it supports along with stale url_checksums left behind by its old versions`,
+	"repair_checksums_help":           `download a dependency again and update url_checksums that no longer match, like after upstream replaced the files of a release. shows the old and new checksums and asks for confirmation first`,
+	"environment_help":                `label for where bindown is running like ci or airgapped. overrides can match it with the environment key`,
+	"debug_http_help":                 `log the headers and timing of every http request to stderr with credentials redacted`,
+	"init_help":                       `create a config file. it is empty unless --profile is given`,
+	"init_profile_help":               "seed the config with the tools for a stack. one of " + strings.Join(bindown.ProfileNames(), ", "),
+	"init_systems_help":               `systems the config supports. checksums are only added for these systems`,
+	"debug_http_file_help":            `append the --debug-http log to this file instead of stderr. implies --debug-http`,
+	"validate_record_help":            `record the response to every http request in this directory for --replay`,
+	"validate_replay_help":            `answer http requests with the responses recorded by --record in this directory instead of making them`,
+	"require_signed_lock_help":        `refuse to install unless bindown.lock has a valid ssh or minisign signature by one of the lock public keys. only urls and checksums from bindown.lock are used`,
+	"lock_public_key_help":            `a public key bindown.lock can be signed with. overrides lock_public_keys from the config, which can be changed along with the lock`,
+	"install_no_manifest_help":        `don't write bindown-manifest.json to the install directory after install --all`,
+	"install_universal_help":          `on macOS, install a universal binary made from the darwin/amd64 and darwin/arm64 builds of dependencies that have both`,
+	"install_from_journal_help":       `resume the install --all or multi-dependency install that last failed. installs the dependencies, system and output recorded in its journal, skipping what was already installed`,
+	"allow_extract_command_help":      `allow dependencies to extract downloads with their extract_command`,
+	"jobs_help":                       `how many downloads to check at once. default is the number of cpus`,
+	"dependency_docs_help":            `list dependencies with their versions, descriptions and homepages. dependencies without them get them from their templates`,
+	"completion_tools_help":           `install shell completion scripts for dependencies by running their completion_command`,
+	"completion_shell_help":           `shell to install completions for. one of bash, zsh or fish. default is the shell in SHELL`,
+	"validate_urls_only_help":         `check that each url exists with a HEAD request instead of downloading and installing`,
+	"completion_dir_help":             `directory to write completion scripts to. default is the directory bash or fish loads completions from. required for zsh`,
+	"porcelain_help":                  `make install, download, extract and wrap write json lines with a stable format to stdout, one for each dependency they handle. their messages go to stderr`,
+	"check_versions_help":             `run each installed dependency's validate_command, or "<bin> --version", and report the ones that don't print the version var. also checks the bin found on PATH`,
 }
 
 type rootCmd struct {
-	JSONConfig bool   `kong:"name=json,help='treat config file as json instead of yaml'"`
-	Configfile string `kong:"type=path,help=${configfile_help},env='BINDOWN_CONFIG_FILE'"`
-	CacheDir   string `kong:"name=cache,type=path,help=${cache_help},env='BINDOWN_CACHE'"`
-	Quiet      bool   `kong:"short='q',help='suppress output to stdout'"`
+	JSONConfig          bool   `kong:"name=json,help='treat config file as json instead of yaml'"`
+	Configfile          string `kong:"type=path,help=${configfile_help},env='BINDOWN_CONFIG_FILE'"`
+	CacheDir            string `kong:"name=cache,type=path,help=${cache_help},env='BINDOWN_CACHE'"`
+	Quiet               bool   `kong:"short='q',help='suppress output to stdout'"`
+	Porcelain           bool   `kong:"name=porcelain,help=${porcelain_help},env='BINDOWN_PORCELAIN'"`
+	NoColor             bool   `kong:"name=no-color,help=${no_color_help}"`
+	Strict              bool   `kong:"name=strict,help=${strict_help},env='BINDOWN_STRICT'"`
+	Environment         string `kong:"name=environment,help=${environment_help},env='BINDOWN_ENVIRONMENT'"`
+	AllowExtractCommand bool   `kong:"name=allow-extract-command,help=${allow_extract_command_help},env='BINDOWN_ALLOW_EXTRACT_COMMAND'"`
+	DebugHTTP           bool   `kong:"name=debug-http,help=${debug_http_help},env='BINDOWN_DEBUG_HTTP'"`
+	DebugHTTPFile       string `kong:"name=debug-http-file,type=path,help=${debug_http_file_help}"`
 
 	Download        downloadCmd        `kong:"cmd,help=${download_help}"`
 	Extract         extractCmd         `kong:"cmd,help=${extract_help}"`
@@ -59,9 +115,23 @@ type rootCmd struct {
 	TemplateSource  templateSourceCmd  `kong:"cmd,help='manage template sources'"`
 	SupportedSystem supportedSystemCmd `kong:"cmd,help='manage supported systems'"`
 	Checksums       checksumsCmd       `kong:"cmd,help='manage checksums'"`
-	Init            initCmd            `kong:"cmd,help='create an empty config file'"`
+	Init            initCmd            `kong:"cmd,help=${init_help}"`
 	Cache           cacheCmd           `kong:"cmd,help='manage the cache'"`
 	Bootstrap       bootstrapCmd       `kong:"cmd,help='create bootstrap script for bindown'"`
+	Generate        generateCmd        `kong:"cmd,help='generate build tool integrations'"`
+	Bundle          bundleCmd          `kong:"cmd,help='create an archive of the config and downloads for installing without network access'"`
+	Unbundle        unbundleCmd        `kong:"cmd,help='install dependencies from a bundle without network access'"`
+	BuildReleaseDir buildReleaseDirCmd `kong:"cmd,name=build-release-dir,help='install dependencies with their licenses and provenance into a directory for redistributing'"`
+	Doctor          doctorCmd          `kong:"cmd,help='check the config and environment for problems'"`
+	Check           checkCmd           `kong:"cmd,help='check that installed dependencies and checksums match the config'"`
+	Verify          verifyCmd          `kong:"cmd,help='check installed bins against bin_checksums without downloading'"`
+	Audit           auditCmd           `kong:"cmd,help='look up known vulnerabilities in the pinned versions of dependencies'"`
+	Env             envCmd             `kong:"cmd,help='print shell exports for the env vars dependencies set'"`
+	Exec            execCmd            `kong:"cmd,help='install dependencies and run a command with them in PATH'"`
+	Config          configCmd          `kong:"cmd,help='manage the config file'"`
+	Search          searchCmd          `kong:"cmd,help='search templates by name or description'"`
+	Completion      completionCmd      `kong:"cmd,help='install shell completions for dependencies'"`
+	Lock            lockCmd            `kong:"cmd,help='resolve dependencies with version latest to the newest release in bindown.lock'"`
 
 	Version            versionCmd                   `kong:"cmd,help='show bindown version'"`
 	InstallCompletions kongplete.InstallCompletions `kong:"cmd,help=${config_install_completions_help}"`
@@ -93,27 +163,68 @@ var defaultConfigFilenames = []string{
 	".bindown.json",
 }
 
-func loadConfigFile(ctx *runContext, noDefaultDirs bool) (*bindown.Config, error) {
-	filename := ctx.rootCmd.Configfile
-	if filename == "" {
-		for _, configFilename := range defaultConfigFilenames {
-			info, err := os.Stat(configFilename)
-			if err == nil && !info.IsDir() {
-				filename = configFilename
-				break
-			}
+// configFilename returns the config file from --configfile or the first of defaultConfigFilenames that exists.
+func configFilename(ctx *runContext) string {
+	if ctx.rootCmd.Configfile != "" {
+		return ctx.rootCmd.Configfile
+	}
+	for _, filename := range defaultConfigFilenames {
+		info, err := os.Stat(filename)
+		if err == nil && !info.IsDir() {
+			return filename
 		}
 	}
-	configFile, err := bindown.NewConfig(ctx, filename, noDefaultDirs)
+	return ""
+}
+
+func loadConfigFile(ctx *runContext, noDefaultDirs bool) (*bindown.Config, error) {
+	configFile, err := bindown.NewConfig(ctx, configFilename(ctx), noDefaultDirs)
 	if err != nil {
+		if bindown.FailureKindOf(err) == bindown.FailureUnknown {
+			err = &bindown.ConfigError{Err: err}
+		}
 		return nil, err
 	}
 	if ctx.rootCmd.CacheDir != "" {
 		configFile.Cache = ctx.rootCmd.CacheDir
 	}
+	configFile.Environment = ctx.rootCmd.Environment
+	configFile.AllowExtractCommand = ctx.rootCmd.AllowExtractCommand
+	if ctx.rootCmd.Strict || configFile.Strict {
+		err = configFile.CheckStrict()
+		if err != nil {
+			return nil, err
+		}
+	}
+	resolveDependencyArgs(ctx, configFile)
 	return configFile, nil
 }
 
+// resolveDependencyArgs replaces the dependency names in the command's args and flags with the configured names they
+// refer to, so "GolangCI_Lint" works for "golangci-lint". Names that don't resolve are left for the command to report.
+func resolveDependencyArgs(ctx *runContext, config *bindown.Config) {
+	if ctx.kongCtx == nil || ctx.kongCtx.Selected() == nil {
+		return
+	}
+	values := ctx.kongCtx.Selected().Positional
+	for _, flag := range ctx.kongCtx.Flags() {
+		values = append(values, flag.Value)
+	}
+	for _, value := range values {
+		if value.Tag.Get("predictor") != "bin" || !value.Target.CanAddr() {
+			continue
+		}
+		switch target := value.Target.Addr().Interface().(type) {
+		case *string:
+			*target = config.ResolveDependencyName(*target)
+		case *[]string:
+			for i := range *target {
+				(*target)[i] = config.ResolveDependencyName((*target)[i])
+			}
+		}
+	}
+}
+
 // fileWriter covers terminal.FileWriter. Needed for survey
 type fileWriter interface {
 	io.Writer
@@ -135,11 +246,21 @@ type fileReader interface {
 }
 
 type runContext struct {
-	parent  context.Context
-	stdin   fileReader
-	stdout  fileWriter
-	stderr  fileWriter
-	rootCmd *rootCmd
+	parent context.Context
+	stdin  fileReader
+	stdout fileWriter
+	stderr fileWriter
+	// ciStdout is stdout before --quiet discards it. CI logging commands and the commands bindown exec runs have to go
+	// to it.
+	ciStdout fileWriter
+	rootCmd  *rootCmd
+	// porcelain is set by --porcelain.
+	porcelain *bindown.Porcelain
+	// messages is where commands that write porcelain records write their messages. It is stderr with --porcelain so
+	// stdout only gets the records. Other commands keep writing their output to stdout.
+	messages fileWriter
+	// kongCtx is the parsed command line. loadConfigFile uses it to resolve dependency names.
+	kongCtx *kong.Context
 }
 
 func newRunContext(ctx context.Context) *runContext {
@@ -164,6 +285,18 @@ func (r *runContext) Value(key any) any {
 	return r.parent.Value(key)
 }
 
+// color returns whether output to stdout should be colored
+func (r *runContext) color() bool {
+	if r.rootCmd.NoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if _, ok := r.stdout.(SimpleFileWriter); ok {
+		return false
+	}
+	fd := r.stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
 type runOpts struct {
 	stdin       fileReader
 	stdout      fileWriter
@@ -177,6 +310,7 @@ func Run(ctx context.Context, args []string, opts *runOpts) {
 	if opts == nil {
 		opts = &runOpts{}
 	}
+	bindown.RunningVersion = getVersion()
 	var root rootCmd
 	runCtx := newRunContext(ctx)
 	runCtx.rootCmd = &root
@@ -192,6 +326,7 @@ func Run(ctx context.Context, args []string, opts *runOpts) {
 	if runCtx.stderr == nil {
 		runCtx.stderr = os.Stderr
 	}
+	runCtx.ciStdout = runCtx.stdout
 
 	kongOptions := []kong.Option{
 		kong.HelpOptions{Compact: true},
@@ -199,6 +334,7 @@ func Run(ctx context.Context, args []string, opts *runOpts) {
 		kongVars,
 		kong.UsageOnError(),
 		kong.Writers(runCtx.stdout, runCtx.stderr),
+		kong.TypeMapper(reflect.TypeOf(bindown.System("")), systemMapper),
 	}
 	if opts.exitHandler != nil {
 		kongOptions = append(kongOptions, kong.Exit(opts.exitHandler))
@@ -212,14 +348,66 @@ func Run(ctx context.Context, args []string, opts *runOpts) {
 
 	kongCtx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
+	if err != nil {
+		// only reached when the exit handler doesn't exit
+		return
+	}
+	runCtx.kongCtx = kongCtx
+	if root.Porcelain {
+		runCtx.porcelain = bindown.NewPorcelain(runCtx.stdout)
+	}
 	if root.Quiet {
 		runCtx.stdout = SimpleFileWriter{io.Discard}
 		kongCtx.Stdout = io.Discard
 	}
+	runCtx.messages = runCtx.stdout
+	if root.Porcelain && !root.Quiet {
+		runCtx.messages = runCtx.stderr
+	}
+	if root.DebugHTTP || root.DebugHTTPFile != "" {
+		var debugOut io.Writer = runCtx.stderr
+		if root.DebugHTTPFile != "" {
+			var f *os.File
+			f, err = os.OpenFile(root.DebugHTTPFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+			kongCtx.FatalIfErrorf(err)
+			if err != nil {
+				return
+			}
+			defer func() { _ = f.Close() }()
+			debugOut = f
+		}
+		bindown.SetHTTPDebug(debugOut)
+		defer bindown.SetHTTPDebug(nil)
+	}
 	err = kongCtx.Run()
-	kongCtx.FatalIfErrorf(err)
+	if err == nil {
+		return
+	}
+	var cmdExitErr *commandExitError
+	if errors.As(err, &cmdExitErr) {
+		kongCtx.Exit(cmdExitErr.code)
+		return
+	}
+	kongCtx.Errorf("%s", err.Error())
+	writeFailureSummary(runCtx.stderr, err)
+	kongCtx.Exit(exitCode(err))
 }
 
+// systemMapper decodes --system values with bindown.ParseSystem so aliases like macos/x86_64 are accepted.
+var systemMapper = kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+	var val string
+	err := ctx.Scan.PopValueInto("system", &val)
+	if err != nil {
+		return err
+	}
+	system, err := bindown.ParseSystem(val)
+	if err != nil {
+		return err
+	}
+	target.SetString(string(system))
+	return nil
+})
+
 func runCompletion(ctx context.Context, parser *kong.Kong) {
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
@@ -227,6 +415,7 @@ func runCompletion(ctx context.Context, parser *kong.Kong) {
 		kongplete.WithPredictor("bin", binCompleter(ctx)),
 		kongplete.WithPredictor("wrap_bin", wrapBinCompleter(ctx)),
 		kongplete.WithPredictor("allSystems", allSystemsCompleter),
+		kongplete.WithPredictor("profile", profileCompleter),
 		kongplete.WithPredictor("templateSource", templateSourceCompleter(ctx)),
 		kongplete.WithPredictor("system", systemCompleter(ctx)),
 		kongplete.WithPredictor("localTemplate", localTemplateCompleter(ctx)),
@@ -235,7 +424,11 @@ func runCompletion(ctx context.Context, parser *kong.Kong) {
 	)
 }
 
-type initCmd struct{}
+type initCmd struct {
+	Profile       string           `kong:"name=profile,help=${init_profile_help},predictor=profile"`
+	System        []bindown.System `kong:"name=system,help=${init_systems_help},predictor=allSystems"`
+	SkipChecksums bool             `kong:"name=skipchecksums,help='do not add checksums for the profile dependencies'"`
+}
 
 func (c *initCmd) Run(ctx *runContext) error {
 	for _, filename := range defaultConfigFilenames {
@@ -258,6 +451,23 @@ func (c *initCmd) Run(ctx *runContext) error {
 	}
 	cfg := &bindown.Config{
 		Filename: file.Name(),
+		Systems:  c.System,
+	}
+	if c.Profile != "" {
+		var added []string
+		added, err = cfg.AddProfile(ctx, c.Profile)
+		if err != nil {
+			return errors.Join(err, os.Remove(configfile))
+		}
+		if !c.SkipChecksums {
+			err = cfg.AddChecksums(added, c.System)
+			if err != nil {
+				return errors.Join(err, os.Remove(configfile))
+			}
+		}
+		for _, name := range added {
+			fmt.Fprintf(ctx.stdout, "added %s %s\n", name, cfg.Dependencies[name].Vars["version"])
+		}
 	}
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
@@ -274,13 +484,26 @@ func (c fmtCmd) Run(ctx *runContext, cli *rootCmd) error {
 }
 
 type installCmd struct {
-	Dependency           []string       `kong:"arg,name=dependency,help=${dependency_help},predictor=bin"`
-	All                  bool           `kong:"help=${all_deps_help}"`
-	Force                bool           `kong:"help=${install_force_help}"`
-	Output               string         `kong:"type=path,name=output,type=file,help=${output_help}"`
-	System               bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
-	AllowMissingChecksum bool           `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
-	ToCache              bool           `kong:"name=to-cache,help=${install_to_cache_help}"`
+	Dependency           []string         `kong:"arg,name=dependency,help=${dependency_help},predictor=bin"`
+	All                  bool             `kong:"help=${all_deps_help}"`
+	Force                bool             `kong:"help=${install_force_help}"`
+	Output               string           `kong:"type=path,name=output,type=file,help=${output_help}"`
+	System               []bindown.System `kong:"name=system,default=${system_default},help=${install_systems_help},predictor=allSystems"`
+	AllSystems           bool             `kong:"name=all-systems,help=${all_systems_help}"`
+	SystemPath           string           `kong:"name=system-path,default=${system_path_default},help=${system_path_help}"`
+	AllowMissingChecksum bool             `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+	ToCache              bool             `kong:"name=to-cache,help=${install_to_cache_help}"`
+	ToDir                string           `kong:"name=to-dir,type=path,help=${install_to_dir_help}"`
+	Stream               bool             `kong:"name=stream,help=${stream_help}"`
+	AddToCIPath          bool             `kong:"name=add-to-ci-path,help=${add_to_ci_path_help}"`
+	Watch                bool             `kong:"name=watch,help=${install_watch_help}"`
+	ForceReinstallAll    bool             `kong:"name=force-reinstall-all,help=${force_reinstall_all_help}"`
+	MetricsFile          string           `kong:"name=metrics-file,type=path,help=${install_metrics_file_help}"`
+	FromJournal          bool             `kong:"name=from-journal,help=${install_from_journal_help}"`
+	Universal            bool             `kong:"name=universal,help=${install_universal_help}"`
+	NoManifest           bool             `kong:"name=no-manifest,help=${install_no_manifest_help}"`
+	RequireSignedLock    bool             `kong:"name=require-signed-lock,help=${require_signed_lock_help},env='BINDOWN_REQUIRE_SIGNED_LOCK'"`
+	LockPublicKey        []string         `kong:"name=lock-public-key,help=${lock_public_key_help},env='BINDOWN_LOCK_PUBLIC_KEY'"`
 
 	// hidden options to be removed
 	Wrapper     bool   `kong:"hidden,name=wrapper"`
@@ -296,6 +519,9 @@ func (d *installCmd) Run(ctx *runContext) error {
 		if d.Force {
 			return fmt.Errorf("cannot use --force and --wrapper together")
 		}
+		if d.ToDir != "" {
+			return fmt.Errorf("cannot use --to-dir and --wrapper together")
+		}
 		cmd := &wrapCmd{
 			Dependency:           d.Dependency,
 			All:                  d.All,
@@ -305,19 +531,177 @@ func (d *installCmd) Run(ctx *runContext) error {
 		}
 		return cmd.Run(ctx)
 	}
+	if d.ToDir != "" {
+		if d.Output != "" {
+			return fmt.Errorf("cannot use --to-dir and --output together")
+		}
+		if d.ToCache {
+			return fmt.Errorf("cannot use --to-dir and --to-cache together")
+		}
+	}
+	if d.AddToCIPath {
+		if d.ToCache {
+			return fmt.Errorf("cannot use --to-cache and --add-to-ci-path together")
+		}
+		if d.AllSystems || len(d.System) > 1 {
+			return fmt.Errorf("cannot use --add-to-ci-path when installing for multiple systems")
+		}
+	}
+	if d.Universal {
+		if d.ToCache {
+			return fmt.Errorf("cannot use --to-cache and --universal together")
+		}
+		if d.AllSystems || len(d.System) > 1 {
+			return fmt.Errorf("cannot use --universal when installing for multiple systems")
+		}
+	}
+	if d.ForceReinstallAll {
+		if len(d.Dependency) > 0 {
+			return fmt.Errorf("cannot use --force-reinstall-all with dependency names")
+		}
+		if d.Watch {
+			return fmt.Errorf("cannot use --force-reinstall-all and --watch together")
+		}
+		d.All = true
+		d.Force = true
+	}
+	if d.FromJournal {
+		if d.All || len(d.Dependency) > 0 {
+			return fmt.Errorf("cannot use --from-journal with --all or dependency names")
+		}
+		if d.Watch {
+			return fmt.Errorf("cannot use --from-journal and --watch together")
+		}
+	}
+	if !d.Watch {
+		return d.install(ctx)
+	}
+	filename := configFilename(ctx)
+	if filename == "" || strings.Contains(filename, "://") {
+		return fmt.Errorf("--watch requires a local config file")
+	}
+	return watchConfig(ctx, filename, ctx.stderr, func() error {
+		return d.install(ctx)
+	})
+}
+
+func (d *installCmd) install(ctx *runContext) error {
 	config, err := loadConfigFile(ctx, false)
 	if err != nil {
 		return err
 	}
+	if d.RequireSignedLock {
+		err = config.RequireSignedLock(&bindown.VerifyLockSignatureOpts{PublicKeys: d.LockPublicKey})
+		if err != nil {
+			return err
+		}
+	}
+	if d.ForceReinstallAll {
+		// nothing from the cache can be trusted, so start from an empty one
+		err = config.ClearCache()
+		if err != nil {
+			return err
+		}
+	}
 
-	return config.InstallDependencies(d.Dependency, d.System, &bindown.ConfigInstallDependenciesOpts{
+	opts := &bindown.ConfigInstallDependenciesOpts{
 		Output:               d.Output,
 		Force:                d.Force,
 		AllowMissingChecksum: d.AllowMissingChecksum,
 		ToCache:              d.ToCache,
-		Stdout:               ctx.stdout,
+		Stdout:               ctx.messages,
 		AllDeps:              d.All,
+		Stream:               d.Stream,
+		SystemPath:           d.SystemPath,
+		Color:                ctx.color(),
+		Stderr:               ctx.stderr,
+		AddToCIPath:          d.AddToCIPath,
+		CIStdout:             ctx.ciStdout,
+		Porcelain:            ctx.porcelain,
+		Universal:            d.Universal,
+		Manifest:             d.All && !d.NoManifest,
+	}
+	if d.ToDir != "" {
+		opts.Output = d.ToDir
+		opts.OutputIsDir = true
+	}
+	if d.MetricsFile != "" {
+		opts.Metrics = &bindown.InstallMetrics{}
+	}
+	multiSystem := d.AllSystems || len(d.System) > 1
+	// keep a journal when installing several dependencies so a run after a failure can skip what was installed
+	opts.Journal = !multiSystem && !d.ToCache && (d.All || len(d.Dependency) > 1)
+	if d.FromJournal {
+		if multiSystem || d.ToCache {
+			return fmt.Errorf("cannot use --from-journal with --to-cache or multiple systems")
+		}
+		journalPath := config.InstallJournalPath()
+		var journal *bindown.InstallJournal
+		journal, err = bindown.ReadInstallJournal(journalPath)
+		if err != nil {
+			return err
+		}
+		if journal == nil {
+			return fmt.Errorf("no install journal at %s", journalPath)
+		}
+		d.Dependency = journal.Dependencies
+		d.System = []bindown.System{journal.System}
+		opts.Output = journal.Output
+		opts.Journal = true
+	}
+	err = writeTrustedChecksums(ctx, config, func() error {
+		if d.AllSystems {
+			return config.InstallDependenciesForSystems(d.Dependency, nil, opts)
+		}
+		if len(d.System) > 1 {
+			return config.InstallDependenciesForSystems(d.Dependency, d.System, opts)
+		}
+		system := bindown.CurrentSystem
+		if len(d.System) == 1 {
+			system = d.System[0]
+		}
+		return config.InstallDependencies(d.Dependency, system, opts)
 	})
+	if opts.Metrics == nil {
+		return err
+	}
+	// write metrics for failed installs too so slow or failing downloads can be tracked down
+	return errors.Join(err, writeMetricsFile(d.MetricsFile, opts.Metrics))
+}
+
+func writeMetricsFile(filename string, metrics *bindown.InstallMetrics) error {
+	if metrics.Dependencies == nil {
+		metrics.Dependencies = []*bindown.DependencyMetrics{}
+	}
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
+
+// writeTrustedChecksums runs fn and then writes the config if fn added checksums under the trust-on-first-use
+// checksum policy. The config is written even when fn fails so checksums for successful downloads aren't lost.
+func writeTrustedChecksums(ctx *runContext, config *bindown.Config, fn func() error) error {
+	before := maps.Clone(config.URLChecksums)
+	err := fn()
+	if maps.Equal(before, config.URLChecksums) {
+		return err
+	}
+	// config has default dirs and flags filled in, so write the added checksums to a fresh copy
+	fileConfig, loadErr := bindown.NewConfig(ctx, config.Filename, true)
+	if loadErr != nil {
+		return errors.Join(err, loadErr)
+	}
+	if fileConfig.URLChecksums == nil {
+		fileConfig.URLChecksums = map[string]string{}
+	}
+	for u, sum := range config.URLChecksums {
+		if before[u] == "" {
+			fileConfig.URLChecksums[u] = sum
+		}
+	}
+	return errors.Join(err, fileConfig.WriteFile(ctx.rootCmd.JSONConfig))
 }
 
 type wrapCmd struct {
@@ -346,11 +730,12 @@ func (d *wrapCmd) Run(ctx *runContext) error {
 		Output:               d.Output,
 		AllowMissingChecksum: d.AllowMissingChecksum,
 		BindownExec:          d.BindownExec,
-		Stdout:               ctx.stdout,
+		Stdout:               ctx.messages,
 		AllDeps:              d.All,
 		BindownTag:           tag,
 		BindownWrapped:       os.Getenv("BINDOWN_WRAPPED"),
 		BaseURL:              d.BaseURL,
+		Porcelain:            ctx.porcelain,
 	})
 }
 
@@ -360,6 +745,7 @@ type downloadCmd struct {
 	Force                bool           `kong:"help=${download_force_help}"`
 	System               bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
 	AllowMissingChecksum bool           `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+	Output               string         `kong:"type=path,help=${download_output_help}"`
 }
 
 func (d *downloadCmd) Run(ctx *runContext) error {
@@ -367,11 +753,16 @@ func (d *downloadCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
-	return config.DownloadDependencies(d.Dependency, d.System, &bindown.ConfigDownloadDependenciesOpts{
-		Force:                d.Force,
-		AllowMissingChecksum: d.AllowMissingChecksum,
-		AllDeps:              d.All,
-		Stdout:               ctx.stdout,
+	return writeTrustedChecksums(ctx, config, func() error {
+		return config.DownloadDependencies(d.Dependency, d.System, &bindown.ConfigDownloadDependenciesOpts{
+			Force:                d.Force,
+			AllowMissingChecksum: d.AllowMissingChecksum,
+			AllDeps:              d.All,
+			Stdout:               ctx.messages,
+			Stderr:               ctx.stderr,
+			Output:               d.Output,
+			Porcelain:            ctx.porcelain,
+		})
 	})
 }
 
@@ -380,6 +771,8 @@ type extractCmd struct {
 	All                  bool           `kong:"help=${all_deps_help}"`
 	System               bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
 	AllowMissingChecksum bool           `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+	Stream               bool           `kong:"name=stream,help=${stream_help}"`
+	Output               string         `kong:"type=path,help=${extract_output_help}"`
 }
 
 func (d *extractCmd) Run(ctx *runContext) error {
@@ -387,9 +780,15 @@ func (d *extractCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
-	return config.ExtractDependencies(d.Dependency, d.System, &bindown.ConfigExtractDependenciesOpts{
-		AllowMissingChecksum: d.AllowMissingChecksum,
-		AllDeps:              d.All,
-		Stdout:               ctx.stdout,
+	return writeTrustedChecksums(ctx, config, func() error {
+		return config.ExtractDependencies(d.Dependency, d.System, &bindown.ConfigExtractDependenciesOpts{
+			AllowMissingChecksum: d.AllowMissingChecksum,
+			AllDeps:              d.All,
+			Stream:               d.Stream,
+			Stdout:               ctx.messages,
+			Stderr:               ctx.stderr,
+			Output:               d.Output,
+			Porcelain:            ctx.porcelain,
+		})
 	})
 }
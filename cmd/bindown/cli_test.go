@@ -1,16 +1,21 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/bindown"
 	"github.com/willabides/bindown/v4/internal/cache"
 	"github.com/willabides/bindown/v4/internal/testutil"
 )
@@ -33,8 +38,8 @@ func Test_fmtCmd(t *testing.T) {
 		runner.writeConfigYaml(`{"systems": [ "darwin/amd64", "linux/386" ]`)
 		result := runner.run("format")
 		result.assertState(resultState{
-			stderr: "cmd: error: config is not valid yaml (or json)",
-			exit:   1,
+			stderr: "cmd: error: config is not valid yaml (or json): line 1: did not find expected ',' or '}'",
+			exit:   exitConfigError,
 		})
 	})
 
@@ -55,6 +60,36 @@ func Test_fmtCmd(t *testing.T) {
 	})
 }
 
+func Test_strict(t *testing.T) {
+	config := `
+dependencies:
+  foo:
+    url: https://example.com/foo
+    overrides:
+      - matcher:
+          ossss: [windows]
+        dependency:
+          url: https://example.com/foo.exe
+`
+	t.Run("flag", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(config)
+		result := runner.run("dependency", "list")
+		result.assertState(resultState{stdout: "foo"})
+		result = runner.run("--strict", "dependency", "list")
+		require.Equal(t, exitConfigError, result.exitVal)
+		require.Contains(t, result.stdErr.String(), `/dependencies/foo/overrides/0/matcher/ossss: matcher key "ossss" is not os, arch or a known var`)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml("strict: true\n" + config)
+		result := runner.run("dependency", "list")
+		require.Equal(t, exitConfigError, result.exitVal)
+		require.Contains(t, result.stdErr.String(), `matcher key "ossss"`)
+	})
+}
+
 func Test_initCmd(t *testing.T) {
 	t.Run("default file", func(t *testing.T) {
 		runner := newCmdRunner(t)
@@ -68,6 +103,46 @@ func Test_initCmd(t *testing.T) {
 		require.Equal(t, "{}\n", string(content))
 	})
 
+	t.Run("profile", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.cache = ""
+		runner.configFile = ""
+		testInDir(t, runner.tmpDir)
+		result := runner.run("init", "--profile", "shell", "--system", "linux/amd64", "--skipchecksums")
+		result.assertState(resultState{
+			stdout: "added shellcheck 0.10.0\nadded shfmt 3.8.0",
+		})
+		content, err := os.ReadFile(".bindown.yaml")
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(content), `systems:
+  - linux/amd64
+dependencies:
+  shellcheck:
+    template: builtin#shellcheck
+    vars:
+      version: 0.10.0
+  shfmt:
+    template: builtin#shfmt
+    vars:
+      version: 3.8.0
+templates:
+  builtin#shellcheck:
+`), string(content))
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.cache = ""
+		runner.configFile = ""
+		testInDir(t, runner.tmpDir)
+		result := runner.run("init", "--profile", "cobol")
+		result.assertState(resultState{
+			stderr: `cmd: error: no profile named "cobol". available profiles are go, k8s, shell, terraform`,
+			exit:   1,
+		})
+		require.NoFileExists(t, ".bindown.yaml")
+	})
+
 	t.Run("default file already exists", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.cache = ""
@@ -172,6 +247,33 @@ url_checksums:
 		assertExtractSuccess(t, result)
 	})
 
+	t.Run("output", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  bar:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL, depURL))
+		outDir := filepath.Join(runner.tmpDir, "work", "foo")
+		result := runner.run("extract", "foo", "--output", outDir)
+		result.assertState(resultState{stdout: "extracted foo to " + outDir})
+		require.FileExists(t, filepath.Join(outDir, "foo"))
+
+		// extracting again replaces the files
+		result = runner.run("extract", "foo", "--output", outDir)
+		result.assertState(resultState{stdout: "extracted foo to " + outDir})
+
+		outDir = filepath.Join(runner.tmpDir, "work", "all")
+		result = runner.run("extract", "--all", "--output", outDir)
+		require.Equal(t, 0, result.exitVal)
+		require.FileExists(t, filepath.Join(outDir, "foo", "foo"))
+		require.FileExists(t, filepath.Join(outDir, "bar", "foo"))
+	})
+
 	t.Run("invalid cache", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(fmt.Sprintf(`
@@ -229,6 +331,20 @@ url_checksums:
 		assertExtractSuccess(t, result)
 	})
 
+	t.Run("stream", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+		result := runner.run("extract", "foo", "--stream")
+		assertExtractSuccess(t, result)
+		assert.NoDirExists(t, filepath.Join(runner.cache, "downloads"))
+	})
+
 	t.Run("does not overwrite invalid cache", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(fmt.Sprintf(`
@@ -300,6 +416,27 @@ dependencies:
 		assertDownloadSuccess(t, result)
 	})
 
+	t.Run("output", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %s
+`, depURL, depURL))
+		outFile := filepath.Join(runner.tmpDir, "work", "foo.tar.gz")
+		result := runner.run("download", "foo", "--output", outFile)
+		result.assertState(resultState{stdout: "downloaded foo to " + outFile})
+		require.FileExists(t, outFile)
+
+		// an existing directory gets the download's file name
+		outDir := filepath.Join(runner.tmpDir, "work")
+		result = runner.run("download", "foo", "--output", outDir)
+		result.assertState(resultState{stdout: "downloaded foo to " + filepath.Join(outDir, "fooinroot.tar.gz")})
+		require.FileExists(t, filepath.Join(outDir, "fooinroot.tar.gz"))
+	})
+
 	t.Run("no url", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(`
@@ -309,7 +446,7 @@ dependencies:
 		result := runner.run("download", "foo")
 		result.assertState(resultState{
 			stderr: `cmd: error: dependency "foo" has no URL`,
-			exit:   1,
+			exit:   exitConfigError,
 		})
 	})
 
@@ -323,7 +460,7 @@ dependencies:
 		result := runner.run("download", "foo")
 		result.assertState(resultState{
 			stderr: `cmd: error: error applying template`,
-			exit:   1,
+			exit:   exitConfigError,
 		})
 	})
 
@@ -337,7 +474,7 @@ dependencies:
 		result := runner.run("download", "foo")
 		result.assertState(resultState{
 			stderr: `cmd: error: no checksum configured for foo`,
-			exit:   1,
+			exit:   exitConfigError,
 		})
 	})
 
@@ -362,7 +499,7 @@ dependencies:
 		result := runner.run("download", "foo", "--allow-missing-checksum")
 		result.assertState(resultState{
 			stderr: `cmd: error: failed downloading`,
-			exit:   1,
+			exit:   exitNetworkError,
 		})
 	})
 
@@ -420,6 +557,139 @@ url_checksums:
 		testutil.AssertFile(t, wantBin, true, false)
 	})
 
+	t.Run("to-dir", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		toDir := filepath.Join(runner.tmpDir, "tools")
+		result := runner.run("install", "foo", "--to-dir", toDir)
+		result.assertState(resultState{
+			stdout: `installed foo to`,
+		})
+		testutil.AssertFile(t, filepath.Join(toDir, "foo"), true, false)
+
+		result = runner.run("install", "foo", "--to-dir", toDir, "--output", "foo")
+		result.assertState(resultState{
+			stderr: `cmd: error: cannot use --to-dir and --output together`,
+			exit:   1,
+		})
+	})
+
+	t.Run("normalized name", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo-bar:
+    url: %s
+    bin: foo
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		result := runner.run("install", "Foo_Bar")
+		result.assertState(resultState{
+			stdout: `installed foo-bar to`,
+		})
+		testutil.AssertFile(t, filepath.Join(runner.tmpDir, "bin", "foo"), true, false)
+
+		result = runner.run("install", "foo-baz")
+		result.assertState(resultState{
+			stderr: `cmd: error: no dependency configured with the name "foo-baz". did you mean "foo-bar"?`,
+			exit:   2,
+		})
+	})
+
+	t.Run("manifest", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    license: MIT
+    vars:
+      version: 1.2.3
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		manifestFile := filepath.Join(runner.tmpDir, "bin", "bindown-manifest.json")
+		result := runner.run("install", "foo")
+		require.Equal(t, 0, result.exitVal)
+		require.NoFileExists(t, manifestFile)
+
+		result = runner.run("install", "--all", "--system", "linux/amd64")
+		require.Equal(t, 0, result.exitVal)
+		got, err := os.ReadFile(manifestFile)
+		require.NoError(t, err)
+		require.JSONEq(t, fmt.Sprintf(`{
+  "version": 1,
+  "system": "linux/amd64",
+  "tools": [
+    {
+      "name": "foo",
+      "version": "1.2.3",
+      "source_url": %q,
+      "checksum": "sha256:f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41",
+      "license": "MIT",
+      "path": "foo"
+    }
+  ]
+}`, depURL), string(got))
+
+		require.NoError(t, os.Remove(manifestFile))
+		result = runner.run("install", "--all", "--no-manifest")
+		require.Equal(t, 0, result.exitVal)
+		require.NoFileExists(t, manifestFile)
+	})
+
+	t.Run("checksum policy", func(t *testing.T) {
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		config := fmt.Sprintf(`
+checksum_policy: %%s
+dependencies:
+  foo:
+    url: %s
+`, depURL)
+
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(config, "require-checksum"))
+		result := runner.run("install", "foo")
+		result.assertState(resultState{
+			stderr: "cmd: error: no checksum configured for foo " + depURL,
+			exit:   exitConfigError,
+		})
+
+		runner.writeConfigYaml(fmt.Sprintf(config, "allow-missing-with-warning"))
+		result = runner.run("install", "foo")
+		result.assertState(resultState{
+			stdout: `installed foo to`,
+			stderr: "warning: no checksum configured for foo " + depURL,
+		})
+		runner.assertConfigYaml(fmt.Sprintf(config, "allow-missing-with-warning"))
+
+		runner.writeConfigYaml(fmt.Sprintf(config, "trust-on-first-use"))
+		result = runner.run("install", "foo", "--force")
+		result.assertState(resultState{stdout: `installed foo to`})
+		runner.assertConfigYaml(fmt.Sprintf(config, "trust-on-first-use") + fmt.Sprintf(`
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL))
+	})
+
 	t.Run("link raw file", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		servePath := testdataPath("downloadables/rawfile/foo")
@@ -461,6 +731,247 @@ url_checksums:
 		testutil.AssertFile(t, wantBin, true, false)
 	})
 
+	t.Run("debug http", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		result := runner.run("install", "foo", "--debug-http")
+		result.assertState(resultState{
+			stdout: `installed foo to`,
+			stderr: `(?s)> \[1\] GET ` + regexp.QuoteMeta(depURL) + `\n.*< \[1\] 200 OK in \d+ms\n.*< \[1\] read \d+ bytes in \d+ms`,
+		})
+
+		logFile := filepath.Join(runner.tmpDir, "http.log")
+		result = runner.run("install", "foo", "--force", "--debug-http-file", logFile)
+		result.assertState(resultState{stdout: `installed foo to`})
+		got, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "> [1] GET "+depURL+"\n")
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		fooFile := filepath.Join(runner.tmpDir, "foo.tar.gz")
+		data, err := os.ReadFile(testdataPath("downloadables/fooinroot.tar.gz"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(fooFile, data, 0o600))
+		fooServer := testutil.ServeFile(t, fooFile, "/foo/fooinroot.tar.gz", "")
+		barServer := testutil.ServeFile(t, testdataPath("downloadables/fooinroot.tar.gz"), "/bar/fooinroot.tar.gz", "")
+		fooURL := fooServer.URL + "/foo/fooinroot.tar.gz"
+		barURL := barServer.URL + "/bar/fooinroot.tar.gz"
+		writeConfig := func(barChecksum string) {
+			t.Helper()
+			runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  bar:
+    url: %s
+    archive_path: foo
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+  %s: %s
+`, fooURL, barURL, fooURL, barURL, barChecksum))
+		}
+		writeConfig("deadbeef")
+		journalFile := filepath.Join(runner.cache, "install-journal.json")
+
+		result := runner.run("install", "--all")
+		require.NotZero(t, result.exitVal)
+		require.Contains(t, result.stdOut.String(), "installed foo to")
+		require.Contains(t, result.stdOut.String(), "failed bar")
+		journal, err := bindown.ReadInstallJournal(journalFile)
+		require.NoError(t, err)
+		require.Equal(t, []string{"bar", "foo"}, journal.Dependencies)
+		require.Equal(t, []string{"foo"}, bindown.MapKeys(journal.Installed))
+		require.Equal(t, []string{"bar"}, bindown.MapKeys(journal.Failed))
+
+		// a changed bin or --force reinstalls foo even though the journal has it
+		fooBin := filepath.Join(runner.tmpDir, "bin", "foo")
+		require.NoError(t, os.WriteFile(fooBin, []byte("truncated"), 0o755))
+		result = runner.run("install", "--all")
+		require.Contains(t, result.stdOut.String(), "installed foo to")
+		result = runner.run("install", "--all")
+		require.Contains(t, result.stdOut.String(), "skipped foo:")
+		result = runner.run("install", "--all", "--force")
+		require.Contains(t, result.stdOut.String(), "installed foo to")
+
+		// foo can't be downloaded or extracted again, so it can only be skipped because of the journal
+		require.NoError(t, os.Remove(fooFile))
+		require.NoError(t, os.RemoveAll(filepath.Join(runner.cache, "downloads")))
+		require.NoError(t, os.RemoveAll(filepath.Join(runner.cache, "extracts")))
+		writeConfig("27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3")
+		result = runner.run("install", "--from-journal")
+		result.assertState(resultState{stdout: `installed bar to .*\nskipped foo: .* is up to date`})
+		require.NoFileExists(t, journalFile)
+
+		result = runner.run("install", "--from-journal")
+		result.assertState(resultState{
+			stderr: "cmd: error: no install journal at " + journalFile,
+			exit:   1,
+		})
+
+		result = runner.run("install", "--from-journal", "foo")
+		result.assertState(resultState{
+			stderr: "cmd: error: cannot use --from-journal with --all or dependency names",
+			exit:   1,
+		})
+	})
+
+	t.Run("metrics file", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+		metricsFile := filepath.Join(runner.tmpDir, "metrics.json")
+		readMetrics := func() *bindown.DependencyMetrics {
+			t.Helper()
+			data, err := os.ReadFile(metricsFile)
+			require.NoError(t, err)
+			var got bindown.InstallMetrics
+			require.NoError(t, json.Unmarshal(data, &got))
+			require.Len(t, got.Dependencies, 1)
+			return got.Dependencies[0]
+		}
+
+		result := runner.run("install", "foo", "--metrics-file", metricsFile)
+		result.assertState(resultState{stdout: `installed foo to`})
+		got := readMetrics()
+		require.Equal(t, "foo", got.Name)
+		require.Equal(t, bindown.CurrentSystem, got.System)
+		require.Equal(t, depURL, got.URL)
+		require.False(t, got.DownloadCached)
+		require.False(t, got.ExtractCached)
+		require.Equal(t, int64(179), got.DownloadBytes)
+		require.Empty(t, got.Error)
+
+		result = runner.run("install", "foo", "--metrics-file", metricsFile)
+		result.assertState(resultState{stdout: `skipped foo: .* is up to date`})
+		got = readMetrics()
+		require.True(t, got.DownloadCached)
+		require.True(t, got.ExtractCached)
+		require.Equal(t, int64(179), got.DownloadBytes)
+
+		missingURL := ts.URL + "/missing.tar.gz"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b4
+`, missingURL, missingURL))
+		result = runner.run("install", "foo", "--metrics-file", metricsFile)
+		require.NotZero(t, result.exitVal)
+		got = readMetrics()
+		require.Equal(t, missingURL, got.URL)
+		require.Equal(t, "failed downloading "+missingURL, got.Error)
+	})
+
+	t.Run("force reinstall all", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  bar:
+    url: %s
+    archive_path: foo
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL, depURL))
+		result := runner.run("install", "foo")
+		result.assertState(resultState{stdout: `installed foo to`})
+		fooBin := filepath.Join(runner.tmpDir, "bin", "foo")
+		want, err := os.ReadFile(fooBin)
+		require.NoError(t, err)
+
+		// tamper with the extracted bin in the cache
+		var poisoned int
+		err = filepath.WalkDir(filepath.Join(runner.cache, "extracts"), func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.Name() != "foo" || d.IsDir() {
+				return err
+			}
+			poisoned++
+			require.NoError(t, os.Chmod(path, 0o755))
+			return os.WriteFile(path, []byte("poisoned"), 0o755)
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, poisoned)
+
+		result = runner.run("install", "--force-reinstall-all")
+		result.assertState(resultState{stdout: "(?s)installed bar to.*installed foo to"})
+		for _, bin := range []string{"foo", "bar"} {
+			got, err := os.ReadFile(filepath.Join(runner.tmpDir, "bin", bin))
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+
+		result = runner.run("install", "foo", "--force-reinstall-all")
+		result.assertState(resultState{
+			stderr: "cmd: error: cannot use --force-reinstall-all with dependency names",
+			exit:   1,
+		})
+	})
+
+	t.Run("multiple systems", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		result := runner.run("install", "foo", "--system", "linux/amd64", "--system", "darwin/arm64")
+		result.assertState(resultState{
+			stdout: `installed foo for darwin/arm64 to`,
+		})
+		testutil.AssertFile(t, filepath.Join(runner.tmpDir, "bin", "linux-amd64", "foo"), true, false)
+		testutil.AssertFile(t, filepath.Join(runner.tmpDir, "bin", "darwin-arm64", "foo"), true, false)
+	})
+
+	t.Run("all systems with system path", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+systems: [linux/amd64, windows/amd64]
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		result := runner.run("install", "foo", "--all-systems", "--system-path", "{{.os}}/{{.arch}}/{{.name}}")
+		result.assertState(resultState{
+			stdout: `installed foo for windows/amd64 to`,
+		})
+		testutil.AssertFile(t, filepath.Join(runner.tmpDir, "bin", "linux", "amd64", "foo"), true, false)
+		testutil.AssertFile(t, filepath.Join(runner.tmpDir, "bin", "windows", "amd64", "foo"), true, false)
+	})
+
 	t.Run("wrong checksum", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		servePath := testdataPath("downloadables/fooinroot.tar.gz")
@@ -474,10 +985,192 @@ url_checksums:
   %q: "0000000000000000000000000000000000000000000000000000000000000000"
 `, depURL, depURL))
 		result := runner.run("install", "foo")
-		require.Equal(t, 1, result.exitVal)
+		require.Equal(t, exitChecksumMismatch, result.exitVal)
 		require.True(t, strings.HasPrefix(result.stdErr.String(), `cmd: error: checksum mismatch in downloaded file`))
 		require.NoFileExists(t, filepath.Join(runner.tmpDir, "bin", "foo"))
 	})
+
+	t.Run("unsupported system", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: https://example.com/foo
+    systems: [linux/amd64]
+`)
+		result := runner.run("install", "foo", "--system", "windows/arm64")
+		result.assertState(resultState{
+			stderr: "cmd: error: foo does not support windows/arm64",
+			exit:   exitUnsupportedSystem,
+		})
+	})
+
+	t.Run("windows/arm64 fallback", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		server := testutil.ServeFile(t, testdataPath("downloadables/rawfile/foo"), "/foo/foo", "")
+		depURL := server.URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %q
+    systems: [windows/amd64]
+url_checksums:
+  %q: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		result := runner.run("install", "foo", "--system", "windows/arm64")
+		require.Equal(t, 0, result.exitVal, result.stdErr.String())
+		require.Equal(t, "warning: foo doesn't support windows/arm64. using its windows/amd64 build, which runs with emulation\n", result.stdErr.String())
+	})
+
+	t.Run("failure summary", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		depURL := testutil.ServeFile(t, servePath, "/foo/foo", "").URL + "/foo/foo"
+		badURL := testutil.ServeFile(t, servePath, "/bad/foo", "").URL + "/bad/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  bad-checksum:
+    url: %s
+  foo:
+    url: %s
+  windows-only:
+    url: https://example.com/foo.exe
+    systems: [windows/amd64]
+url_checksums:
+  %s: "0000000000000000000000000000000000000000000000000000000000000000"
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, badURL, depURL, badURL, depURL))
+		result := runner.run("install", "--all", "--system", "linux/amd64")
+		require.Equal(t, exitError, result.exitVal)
+		require.Contains(t, result.stdOut.String(), "installed foo to")
+		require.Contains(t, result.stdErr.String(), `
+failed dependencies: 2
+  bad-checksum: checksum mismatch (exit code 4)
+  windows-only: unsupported system (exit code 5)
+`)
+
+		result = runner.run("install", "foo", "windows-only", "--system", "linux/amd64")
+		require.Equal(t, exitUnsupportedSystem, result.exitVal)
+		require.Contains(t, result.stdErr.String(), `
+failed dependencies: 1
+  windows-only: unsupported system (exit code 5)
+`)
+	})
+
+	t.Run("porcelain", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		servePath := testdataPath("downloadables/rawfile/foo")
+		depURL := testutil.ServeFile(t, servePath, "/foo/foo", "").URL + "/foo/foo"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+  windows-only:
+    url: https://example.com/foo.exe
+    systems: [windows/amd64]
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+		wantBin := filepath.Join(runner.tmpDir, "bin", "foo")
+		result := runner.run("install", "--all", "--system", "linux/amd64", "--porcelain")
+		require.Equal(t, exitUnsupportedSystem, result.exitVal)
+		require.Equal(t, fmt.Sprintf(`{"v":1,"action":"installed","dependency":"foo","system":"linux/amd64","path":%q}
+{"v":1,"action":"failed","dependency":"windows-only","system":"linux/amd64","error":"windows-only does not support linux/amd64"}
+`, wantBin), result.stdOut.String())
+		require.Contains(t, result.stdErr.String(), "installed foo to")
+		require.Contains(t, result.stdErr.String(), "failed windows-only")
+
+		result = runner.run("install", "foo", "--porcelain", "--quiet")
+		result.assertState(resultState{
+			stdout: fmt.Sprintf(`^\{"v":1,"action":"skipped","dependency":"foo","system":%q,"path":%q\}$`, bindown.CurrentSystem, regexp.QuoteMeta(wantBin)),
+		})
+
+		// commands that don't write records keep their output on stdout
+		result = runner.run("dependency", "list", "--porcelain")
+		result.assertState(resultState{stdout: "foo\nwindows-only"})
+	})
+
+	t.Run("add to ci path", func(t *testing.T) {
+		servePath := testdataPath("downloadables/rawfile/foo")
+		depURL := testutil.ServeFile(t, servePath, "/foo/foo", "").URL + "/foo/foo"
+		setupRunner := func(t *testing.T) *cmdRunner {
+			t.Helper()
+			for _, env := range []string{"GITHUB_PATH", "TF_BUILD", "GITLAB_CI", "BINDOWN_DOTENV"} {
+				t.Setenv(env, "")
+			}
+			runner := newCmdRunner(t)
+			runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+			return runner
+		}
+
+		t.Run("github actions", func(t *testing.T) {
+			runner := setupRunner(t)
+			githubPath := filepath.Join(t.TempDir(), "github_path")
+			require.NoError(t, os.WriteFile(githubPath, []byte("/existing\n"), 0o600))
+			t.Setenv("GITHUB_PATH", githubPath)
+			result := runner.run("install", "foo", "--add-to-ci-path")
+			result.assertState(resultState{stdout: `installed foo to`})
+			got, err := os.ReadFile(githubPath)
+			require.NoError(t, err)
+			require.Equal(t, "/existing\n"+filepath.Join(runner.tmpDir, "bin")+"\n", string(got))
+		})
+
+		t.Run("azure pipelines", func(t *testing.T) {
+			runner := setupRunner(t)
+			t.Setenv("TF_BUILD", "True")
+			result := runner.run("install", "foo", "--add-to-ci-path")
+			require.Equal(t, 0, result.exitVal)
+			require.Contains(t, result.stdOut.String(), "##vso[task.prependpath]"+filepath.Join(runner.tmpDir, "bin")+"\n")
+			// the logging command still goes to stdout when other output is quieted
+			result = runner.run("install", "foo", "--add-to-ci-path", "--quiet")
+			require.Equal(t, 0, result.exitVal)
+			require.Equal(t, "##vso[task.prependpath]"+filepath.Join(runner.tmpDir, "bin")+"\n", result.stdOut.String())
+		})
+
+		t.Run("gitlab ci", func(t *testing.T) {
+			runner := setupRunner(t)
+			dotenv := filepath.Join(t.TempDir(), "build.env")
+			require.NoError(t, os.WriteFile(dotenv, []byte("FOO=bar\nPATH=/old\n"), 0o600))
+			t.Setenv("GITLAB_CI", "true")
+			t.Setenv("BINDOWN_DOTENV", dotenv)
+			t.Setenv("PATH", "/usr/bin")
+			result := runner.run("install", "foo", "--add-to-ci-path")
+			result.assertState(resultState{stdout: `installed foo to`})
+			got, err := os.ReadFile(dotenv)
+			require.NoError(t, err)
+			require.Equal(t, "FOO=bar\nPATH="+filepath.Join(runner.tmpDir, "bin")+":/old\n", string(got))
+
+			// without a PATH in the file, the current PATH is kept after the new dirs
+			require.NoError(t, os.WriteFile(dotenv, []byte("FOO=bar\n"), 0o600))
+			result = runner.run("install", "foo", "--add-to-ci-path", "--force")
+			result.assertState(resultState{stdout: `installed foo to`})
+			got, err = os.ReadFile(dotenv)
+			require.NoError(t, err)
+			require.Equal(t, "FOO=bar\nPATH="+filepath.Join(runner.tmpDir, "bin")+":/usr/bin\n", string(got))
+		})
+
+		t.Run("no ci", func(t *testing.T) {
+			runner := setupRunner(t)
+			result := runner.run("install", "foo", "--add-to-ci-path")
+			require.Equal(t, exitError, result.exitVal)
+			require.Contains(t, result.stdErr.String(), "cmd: error: could not detect a supported CI system")
+		})
+
+		t.Run("to cache", func(t *testing.T) {
+			runner := setupRunner(t)
+			result := runner.run("install", "foo", "--add-to-ci-path", "--to-cache")
+			result.assertState(resultState{
+				stderr: "cmd: error: cannot use --to-cache and --add-to-ci-path together",
+				exit:   exitError,
+			})
+		})
+	})
 }
 
 func Test_wrapCmd(t *testing.T) {
@@ -538,4 +1231,35 @@ url_checksums:
 		testutil.AssertFile(t, bindown, true, false)
 		testutil.CheckGoldenDir(t, outputDir, filepath.FromSlash("testdata/golden/wrap/wrap-bindown"))
 	})
+
+	t.Run("env", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		script := filepath.Join(t.TempDir(), "printenv")
+		scriptContent := "#!/bin/sh\necho \"$GREETING $GREETED\"\n"
+		require.NoError(t, os.WriteFile(script, []byte(scriptContent), 0o755))
+		sum := sha256.Sum256([]byte(scriptContent))
+		depURL := testutil.ServeFile(t, script, "/printenv", "").URL + "/printenv"
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  printenv:
+    url: %s
+    env:
+      GREETING: hello
+      GREETED: "{{ .os }} world's"
+url_checksums:
+    %s: %s
+`, depURL, depURL, hex.EncodeToString(sum[:])))
+		outputDir := filepath.Join(runner.tmpDir, "output")
+		wrapper := filepath.Join(outputDir, "printenv")
+		result := runner.run("wrap", "printenv", "--bindown", testutil.BindownBin(), "--output", wrapper)
+		result.assertState(resultState{stdout: wrapper})
+		content, err := os.ReadFile(wrapper)
+		require.NoError(t, err)
+		require.Contains(t, string(content), `env "printenv"`)
+
+		cmd := exec.Command("sh", "-c", filepath.ToSlash(wrapper))
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		require.Equal(t, "hello "+runtime.GOOS+" world's", strings.TrimSpace(string(out)))
+	})
 }
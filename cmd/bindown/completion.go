@@ -149,7 +149,15 @@ func binCompleter(ctx context.Context) complete.PredictFunc {
 		if cfg == nil {
 			return []string{}
 		}
-		return complete.PredictSet(cfg.DependencyNames()...).Predict(a)
+		// matches the way commands resolve names, so "golangci_" completes to golangci-lint
+		prefix := bindown.NormalizeDependencyName(a.Last)
+		names := []string{}
+		for _, name := range cfg.DependencyNames() {
+			if strings.HasPrefix(bindown.NormalizeDependencyName(name), prefix) {
+				names = append(names, name)
+			}
+		}
+		return names
 	}
 }
 
@@ -174,3 +182,7 @@ func systemCompleter(ctx context.Context) complete.PredictFunc {
 var allSystemsCompleter = complete.PredictFunc(func(a complete.Args) []string {
 	return append([]string{"current"}, strings.Split(bindown.GoDists, "\n")...)
 })
+
+var profileCompleter = complete.PredictFunc(func(a complete.Args) []string {
+	return bindown.ProfileNames()
+})
@@ -87,6 +87,9 @@ func Test_binCompleter(t *testing.T) {
 	got = binCompleter(ctx).Predict(complete.Args{})
 	slices.Sort(got)
 	require.Equal(t, []string{"golangci-lint", "goreleaser"}, got)
+
+	got = binCompleter(ctx).Predict(complete.Args{Last: "GolangCI_"})
+	require.Equal(t, []string{"golangci-lint"}, got)
 }
 
 // inDir runs f in the given directory.
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type configCmd struct {
+	Schema          configSchemaCmd          `kong:"cmd,help='print the json schema for config files'"`
+	AddSchemaHeader configAddSchemaHeaderCmd `kong:"cmd,help='add a yaml-language-server modeline to the config file so editors can validate and autocomplete it'"`
+	DiffSystem      configDiffSystemCmd      `kong:"cmd,help='show how the resolved url, bin and archive_path of dependencies differ between two systems'"`
+}
+
+type configSchemaCmd struct {
+	ID string `kong:"name=id,help='replace the schema $id. use this when serving the schema from somewhere other than the default url'"`
+}
+
+func (c *configSchemaCmd) Run(ctx *runContext) error {
+	_, err := ctx.stdout.Write(bindown.JSONSchema(c.ID))
+	return err
+}
+
+type configAddSchemaHeaderCmd struct {
+	URL string `kong:"name=url,default=${schema_url_default},help='url or path of the json schema'"`
+}
+
+func (c *configAddSchemaHeaderCmd) Run(ctx *runContext) error {
+	ctx.rootCmd.CacheDir = ""
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	if ctx.rootCmd.JSONConfig || filepath.Ext(config.Filename) == ".json" {
+		return fmt.Errorf("schema headers can only be added to yaml config files")
+	}
+	config.SchemaURL = c.URL
+	return config.WriteFile(false)
+}
+
+type configDiffSystemCmd struct {
+	SystemA      bindown.System `kong:"arg,name=system-a,help='first system to compare',predictor=allSystems"`
+	SystemB      bindown.System `kong:"arg,name=system-b,help='second system to compare',predictor=allSystems"`
+	Dependencies []string       `kong:"name=dependency,help='dependencies to compare. default is all',predictor=bin"`
+}
+
+func (c *configDiffSystemCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	diffs, err := config.DiffSystems(c.SystemA, c.SystemB, &bindown.DiffSystemsOpts{
+		Dependencies: c.Dependencies,
+	})
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintf(ctx.stdout, "no differences between %s and %s\n", c.SystemA, c.SystemB)
+		return nil
+	}
+	fmt.Fprintf(ctx.stdout, "--- %s\n+++ %s\n", c.SystemA, c.SystemB)
+	for _, diff := range diffs {
+		fmt.Fprintln(ctx.stdout, diff.String())
+	}
+	return nil
+}
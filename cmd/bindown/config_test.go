@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_configSchemaCmd(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		result := runner.run("config", "schema")
+		require.Equal(t, 0, result.exitVal)
+		var schema map[string]any
+		require.NoError(t, json.Unmarshal(result.stdOut.Bytes(), &schema))
+		require.Equal(t, "https://willabides.github.io/bindown/bindown.schema.json", schema["$id"])
+	})
+
+	t.Run("id", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		result := runner.run("config", "schema", "--id", "https://example.com/bindown.schema.json")
+		require.Equal(t, 0, result.exitVal)
+		var schema map[string]any
+		require.NoError(t, json.Unmarshal(result.stdOut.Bytes(), &schema))
+		require.Equal(t, "https://example.com/bindown.schema.json", schema["$id"])
+	})
+}
+
+func Test_configAddSchemaHeaderCmd(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+systems: [darwin/amd64]
+`)
+		result := runner.run("config", "add-schema-header")
+		result.assertState(resultState{})
+		assertConfigContent(t, runner, `# yaml-language-server: $schema=https://willabides.github.io/bindown/bindown.schema.json
+systems:
+  - darwin/amd64
+`)
+
+		// the header survives rewriting the config
+		result = runner.run("format")
+		result.assertState(resultState{})
+		assertConfigContent(t, runner, `# yaml-language-server: $schema=https://willabides.github.io/bindown/bindown.schema.json
+systems:
+  - darwin/amd64
+`)
+
+		result = runner.run("config", "add-schema-header", "--url", "./bindown.schema.json")
+		result.assertState(resultState{})
+		assertConfigContent(t, runner, `# yaml-language-server: $schema=./bindown.schema.json
+systems:
+  - darwin/amd64
+`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.configFile = filepath.Join(runner.tmpDir, "bindown.json")
+		require.NoError(t, os.WriteFile(runner.configFile, []byte(`{"systems": ["darwin/amd64"]}`), 0o600))
+		result := runner.run("config", "add-schema-header")
+		result.assertState(resultState{
+			stderr: "cmd: error: schema headers can only be added to yaml config files",
+			exit:   1,
+		})
+	})
+}
+
+func assertConfigContent(t *testing.T, runner *cmdRunner, want string) {
+	t.Helper()
+	got, err := os.ReadFile(runner.configFile)
+	require.NoError(t, err)
+	require.Equal(t, want, string(got))
+}
+
+func Test_configDiffSystemCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: https://example.com/foo-{{ .os }}-{{ .arch }}.tar.gz
+    archive_path: foo
+    overrides:
+      - matcher:
+          os: [windows]
+        dependency:
+          archive_path: foo.exe
+      # copied from the windows override without updating the archive_path
+      - matcher:
+          os: [darwin]
+        dependency:
+          archive_path: foo.exe
+  bar:
+    url: https://example.com/bar.tar.gz
+    systems: [linux/amd64]
+  baz:
+    url: https://example.com/baz.tar.gz
+`)
+	result := runner.run("config", "diff-system", "linux/amd64", "darwin/arm64")
+	result.assertState(resultState{
+		stdout: `--- linux/amd64
++++ darwin/arm64
+bar systems
+  - supported
+  + unsupported
+foo url
+  - https://example.com/foo-linux-amd64.tar.gz
+  + https://example.com/foo-darwin-arm64.tar.gz
+foo archive_path
+  - foo
+  + foo.exe
+`,
+	})
+
+	result = runner.run("config", "diff-system", "linux/amd64", "linux/arm64", "--dependency", "baz")
+	result.assertState(resultState{
+		stdout: "no differences between linux/amd64 and linux/arm64",
+	})
+}
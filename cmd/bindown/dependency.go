@@ -3,8 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/willabides/bindown/v4/internal/bindown"
@@ -15,11 +19,16 @@ type dependencyCmd struct {
 	List               dependencyListCmd               `kong:"cmd,help='list configured dependencies'"`
 	Add                dependencyAddCmd                `kong:"cmd,help='add a template-based dependency'"`
 	AddByUrls          dependencyAddByUrlsCmd          `kong:"cmd,help='add a dependency by urls'"`
+	AddByURL           dependencyAddByURLCmd           `kong:"cmd,name=add-by-url,help='add a dependency from one release url by inferring a url template'"`
 	AddByGithubRelease dependencyAddByGithubReleaseCmd `kong:"cmd,help='add a dependency by github release'"`
 	Remove             dependencyRemoveCmd             `kong:"cmd,help='remove a dependency'"`
 	Info               dependencyInfoCmd               `kong:"cmd,help='info about a dependency'"`
+	Docs               dependencyDocsCmd               `kong:"cmd,help=${dependency_docs_help}"`
+	Resolve            dependencyResolveCmd            `kong:"cmd,help='show a dependency after templates, overrides and vars are applied'"`
 	ShowConfig         dependencyShowConfigCmd         `kong:"cmd,help='show dependency config'"`
 	UpdateVars         dependencyUpdateVarsCmd         `kong:"cmd,help='update dependency vars'"`
+	Versions           dependencyVersionsCmd           `kong:"cmd,help='list upstream versions of a dependency from its GitHub or GitLab tags'"`
+	Update             dependencyUpdateCmd             `kong:"cmd,help='update dependencies to their latest upstream versions'"`
 	Validate           dependencyValidateCmd           `kong:"cmd,help='validate that installs work'"`
 }
 
@@ -35,29 +44,25 @@ func (c *dependencyUpdateVarsCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
-	if len(c.Set) > 0 {
-		err = config.SetDependencyVars(c.Dependency, c.Set)
-		if err != nil {
-			return err
+	return config.Batch(ctx.rootCmd.JSONConfig, func() error {
+		if len(c.Set) > 0 {
+			err = config.SetDependencyVars(c.Dependency, c.Set)
+			if err != nil {
+				return err
+			}
 		}
-	}
-	if len(c.Unset) > 0 {
-		err = config.UnsetDependencyVars(c.Dependency, c.Unset)
-		if err != nil {
-			return err
+		if len(c.Unset) > 0 {
+			err = config.UnsetDependencyVars(c.Dependency, c.Unset)
+			if err != nil {
+				return err
+			}
 		}
-	}
-	missingVars, err := config.MissingDependencyVars(c.Dependency)
-	if err != nil {
-		return err
-	}
-	if len(missingVars) == 0 && !c.SkipChecksums {
-		err = config.AddChecksums([]string{c.Dependency}, nil)
-		if err != nil {
+		missingVars, err := config.MissingDependencyVars(c.Dependency)
+		if err != nil || len(missingVars) > 0 || c.SkipChecksums {
 			return err
 		}
-	}
-	return config.WriteFile(ctx.rootCmd.JSONConfig)
+		return config.AddChecksums([]string{c.Dependency}, nil)
+	})
 }
 
 type dependencyShowConfigCmd struct {
@@ -80,6 +85,118 @@ func (c *dependencyShowConfigCmd) Run(ctx *runContext) error {
 	return bindown.EncodeYaml(ctx.stdout, cfg.Dependencies[c.Dependency])
 }
 
+type dependencyResolveCmd struct {
+	Dependency string         `kong:"arg,predictor=bin"`
+	System     bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
+	Explain    bool           `kong:"help='show each template, override, substitution and interpolation that was applied'"`
+}
+
+func (c *dependencyResolveCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	steps, dep, err := cfg.ExplainDependency(c.Dependency, c.System)
+	if err != nil {
+		return err
+	}
+	if c.Explain {
+		for _, step := range steps {
+			fmt.Fprintln(ctx.stdout, step)
+		}
+		fmt.Fprintln(ctx.stdout, "result:")
+	}
+	dep.Template = nil
+	dep.Systems = nil
+	if ctx.rootCmd.JSONConfig {
+		encoder := json.NewEncoder(ctx.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dep)
+	}
+	return bindown.EncodeYaml(ctx.stdout, dep)
+}
+
+type dependencyVersionsCmd struct {
+	Dependency  string `kong:"arg,predictor=bin"`
+	Prereleases bool   `kong:"help='include prerelease versions'"`
+	GithubToken string `kong:"hidden,env='GITHUB_TOKEN'"`
+	GitlabToken string `kong:"hidden,env='GITLAB_TOKEN'"`
+}
+
+func (c *dependencyVersionsCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	versions, err := cfg.DependencyVersions(ctx, c.Dependency, &bindown.DependencyVersionsOpts{
+		Prereleases: c.Prereleases,
+		GitHubToken: c.GithubToken,
+		GitLabToken: c.GitlabToken,
+	})
+	if err != nil {
+		return err
+	}
+	current := cfg.Dependencies[c.Dependency].Vars["version"]
+	for _, version := range versions {
+		if version == current {
+			fmt.Fprintf(ctx.stdout, "%s (current)\n", version)
+			continue
+		}
+		fmt.Fprintln(ctx.stdout, version)
+	}
+	return nil
+}
+
+type dependencyUpdateCmd struct {
+	Dependency    []string `kong:"arg,optional,predictor=bin"`
+	All           bool     `kong:"help='update every dependency with a GitHub or GitLab release url'"`
+	Prereleases   bool     `kong:"help='allow updating to prerelease versions'"`
+	SkipChecksums bool     `kong:"name=skipchecksums,help='do not update checksums'"`
+	GithubToken   string   `kong:"hidden,env='GITHUB_TOKEN'"`
+	GitlabToken   string   `kong:"hidden,env='GITLAB_TOKEN'"`
+}
+
+func (c *dependencyUpdateCmd) Run(ctx *runContext) error {
+	if len(c.Dependency) == 0 && !c.All {
+		return fmt.Errorf("specify dependencies to update or use --all")
+	}
+	if len(c.Dependency) > 0 && c.All {
+		return fmt.Errorf("cannot specify dependencies with --all")
+	}
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	var updates []bindown.DependencyUpdate
+	err = config.Batch(ctx.rootCmd.JSONConfig, func() error {
+		updates, err = config.UpdateDependencies(ctx, c.Dependency, &bindown.UpdateDependenciesOpts{
+			DependencyVersionsOpts: bindown.DependencyVersionsOpts{
+				Prereleases: c.Prereleases,
+				GitHubToken: c.GithubToken,
+				GitLabToken: c.GitlabToken,
+			},
+			SkipChecksums: c.SkipChecksums,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		fmt.Fprintln(ctx.stdout, "all dependencies are up to date")
+		return nil
+	}
+	// markdown list for pasting into a pull request description
+	for _, u := range updates {
+		links := fmt.Sprintf("[release notes](%s)", u.ReleaseURL)
+		if u.CompareURL != "" {
+			links += fmt.Sprintf(", [changes](%s)", u.CompareURL)
+		}
+		fmt.Fprintf(ctx.stdout, "- %s: %s -> %s (%s)\n", u.Name, u.OldVersion, u.NewVersion, links)
+	}
+	return nil
+}
+
 type dependencyInfoCmd struct {
 	Dependency string           `kong:"arg,predictor=bin"`
 	Systems    []bindown.System `kong:"name=system,help=${systems_help},predictor=allSystems"`
@@ -125,19 +242,77 @@ func (c *dependencyInfoCmd) Run(ctx *runContext) error {
 	return bindown.EncodeYaml(ctx.stdout, result)
 }
 
-type dependencyListCmd struct{}
+type dependencyListCmd struct {
+	Detail bool `kong:"help='show the version, template, systems and missing checksums of each dependency. output is json with --json'"`
+}
 
 func (c *dependencyListCmd) Run(ctx *runContext) error {
 	cfg, err := loadConfigFile(ctx, true)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintln(ctx.stdout, strings.Join(cfg.DependencyNames(), "\n"))
-	return nil
+	if !c.Detail {
+		fmt.Fprintln(ctx.stdout, strings.Join(cfg.DependencyNames(), "\n"))
+		return nil
+	}
+	details, err := cfg.DependencyDetails()
+	if err != nil {
+		return err
+	}
+	if ctx.rootCmd.JSONConfig {
+		encoder := json.NewEncoder(ctx.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(details)
+	}
+	return bindown.EncodeYaml(ctx.stdout, details)
+}
+
+type dependencyDocsCmd struct {
+	Markdown bool `kong:"help='write a markdown table for a README'"`
+}
+
+func (c *dependencyDocsCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	docs, err := cfg.DependencyDocs()
+	if err != nil {
+		return err
+	}
+	if ctx.rootCmd.JSONConfig {
+		encoder := json.NewEncoder(ctx.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(docs)
+	}
+	if c.Markdown {
+		fmt.Fprintln(ctx.stdout, "| Tool | Version | Description |")
+		fmt.Fprintln(ctx.stdout, "|------|---------|-------------|")
+		for _, doc := range docs {
+			name := doc.Name
+			if doc.Homepage != "" {
+				name = fmt.Sprintf("[%s](%s)", doc.Name, doc.Homepage)
+			}
+			description := strings.ReplaceAll(doc.Description, "|", `\|`)
+			fmt.Fprintf(ctx.stdout, "| %s | %s | %s |\n", name, doc.Version, description)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(ctx.stdout, 0, 0, 2, ' ', 0)
+	for _, doc := range docs {
+		fields := []string{doc.Name, doc.Version, doc.Description, doc.Homepage}
+		// empty trailing fields would pad the line with spaces
+		for fields[len(fields)-1] == "" {
+			fields = fields[:len(fields)-1]
+		}
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+	return w.Flush()
 }
 
 type dependencyRemoveCmd struct {
 	Dependency string `kong:"arg,predictor=bin"`
+	Prune      bool   `kong:"help='also remove checksums and templates that are no longer used by any dependency'"`
 }
 
 func (c *dependencyRemoveCmd) Run(ctx *runContext) error {
@@ -145,13 +320,10 @@ func (c *dependencyRemoveCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
-	if cfg.Dependencies == nil {
-		return fmt.Errorf("no dependency named %q", c.Dependency)
-	}
-	if _, ok := cfg.Dependencies[c.Dependency]; !ok {
-		return fmt.Errorf("no dependency named %q", c.Dependency)
+	err = cfg.RemoveDependency(c.Dependency, c.Prune)
+	if err != nil {
+		return err
 	}
-	delete(cfg.Dependencies, c.Dependency)
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
 
@@ -163,6 +335,8 @@ type dependencyAddCmd struct {
 	AcceptDefaults   bool              `kong:"short=y,help='accept default values for vars'"`
 	SkipRequiredVars bool              `kong:"name=skipvars,help='do not prompt for required vars. implies --skipchecksums'"`
 	SkipChecksums    bool              `kong:"name=skipchecksums,help='do not add checksums for this dependency'"`
+	From             string            `kong:"name=from,help=${dependency_add_from_help}"`
+	FromJSON         string            `kong:"name=from-json,help=${dependency_add_from_json_help}"`
 }
 
 func (c *dependencyAddCmd) Run(ctx *runContext) error {
@@ -170,6 +344,12 @@ func (c *dependencyAddCmd) Run(ctx *runContext) error {
 	if err != nil {
 		return err
 	}
+	if c.FromJSON != "" {
+		return c.addFromJSON(ctx, config)
+	}
+	if c.From != "" {
+		return c.copyFrom(ctx, config)
+	}
 	tmpl := c.Template
 	if tmpl == "" {
 		tmpl = c.Name
@@ -228,6 +408,71 @@ func (c *dependencyAddCmd) Run(ctx *runContext) error {
 	return config.WriteFile(ctx.rootCmd.JSONConfig)
 }
 
+// copyFrom copies the dependency from the config in c.From. Template is the dependency's name there.
+func (c *dependencyAddCmd) copyFrom(ctx *runContext, config *bindown.Config) error {
+	if c.TemplateSource != "" {
+		return fmt.Errorf("cannot use --source and --from together")
+	}
+	if len(c.Vars) > 0 {
+		return fmt.Errorf("cannot use --var and --from together")
+	}
+	srcName := c.Template
+	if srcName == "" {
+		srcName = c.Name
+	}
+	err := config.CopyDependency(ctx, c.From, srcName, &bindown.CopyDependencyOpts{
+		DependencyName: c.Name,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "Copied dependency %q from %s\n", c.Name, c.From)
+	if !c.SkipChecksums {
+		// only looks up checksums the other config didn't have
+		err = config.AddChecksums([]string{c.Name}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return config.WriteFile(ctx.rootCmd.JSONConfig)
+}
+
+// addFromJSON adds the dependency described by the json in c.FromJSON, which is a file or "-" for stdin.
+func (c *dependencyAddCmd) addFromJSON(ctx *runContext, config *bindown.Config) error {
+	switch {
+	case c.Template != "":
+		return fmt.Errorf("cannot use a template and --from-json together")
+	case c.TemplateSource != "":
+		return fmt.Errorf("cannot use --source and --from-json together")
+	case len(c.Vars) > 0:
+		return fmt.Errorf("cannot use --var and --from-json together")
+	case c.From != "":
+		return fmt.Errorf("cannot use --from and --from-json together")
+	}
+	var data []byte
+	var err error
+	if c.FromJSON == "-" {
+		data, err = io.ReadAll(ctx.stdin)
+	} else {
+		data, err = os.ReadFile(c.FromJSON)
+	}
+	if err != nil {
+		return err
+	}
+	err = config.AddDependencyFromJSON(ctx, c.Name, data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "Added dependency %q\n", c.Name)
+	if !c.SkipChecksums {
+		err = config.AddChecksums([]string{c.Name}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return config.WriteFile(ctx.rootCmd.JSONConfig)
+}
+
 func (c *dependencyAddCmd) promptForVars(ctx *runContext, config *bindown.Config, dep *bindown.Dependency, varVals map[string][]string) error {
 	if c.SkipRequiredVars {
 		return nil
@@ -323,6 +568,71 @@ func (c *dependencyAddByUrlsCmd) Run(ctx *runContext) error {
 	return config.WriteFile(ctx.rootCmd.JSONConfig)
 }
 
+type dependencyAddByURLCmd struct {
+	URL          string `kong:"arg,help='release URL for one system'"`
+	Name         string `kong:"name=name,help='dependency name. default is inferred from the url'"`
+	Version      string `kong:"name=version,help='dependency version. default is inferred from the url'"`
+	Homepage     string `kong:"name=homepage,help='dependency homepage'"`
+	Description  string `kong:"name=description,help='dependency description'"`
+	Yes          bool   `kong:"name=yes,short=y,help='add the dependency without confirming the inferred template'"`
+	Force        bool   `kong:"name=force,help='overwrite existing dependency'"`
+	Experimental bool   `kong:"required,name=experimental,help='enable experimental features',env='BINDOWN_EXPERIMENTAL'"`
+}
+
+func (c *dependencyAddByURLCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	inferred, err := builddep.InferURLTemplate(ctx, c.URL, c.Version, config.Systems)
+	if err != nil {
+		return err
+	}
+	name := c.Name
+	if name == "" {
+		name = inferred.Name
+	}
+	if name == "" {
+		return fmt.Errorf("could not infer a dependency name from %s. use --name", c.URL)
+	}
+	if config.Dependencies != nil && config.Dependencies[name] != nil && !c.Force {
+		return fmt.Errorf("dependency %q already exists", name)
+	}
+	fmt.Fprintf(ctx.stdout, "name: %s\nversion: %s\nurl: %s\n", name, inferred.Version, inferred.Template)
+	for _, varName := range []string{"os", "arch"} {
+		subs := inferred.Substitutions[varName]
+		keys := bindown.MapKeys(subs)
+		slices.Sort(keys)
+		for _, k := range keys {
+			fmt.Fprintf(ctx.stdout, "substitution: %s %s -> %s\n", varName, k, subs[k])
+		}
+	}
+	systems := bindown.MapKeys(inferred.URLs)
+	slices.Sort(systems)
+	fmt.Fprintln(ctx.stdout, "found urls:")
+	for _, system := range systems {
+		fmt.Fprintf(ctx.stdout, "  %s: %s\n", system, inferred.URLs[system])
+	}
+	if !c.Yes {
+		ok := false
+		err = survey.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("Add %s with these urls?", name),
+			Default: true,
+		}, &ok, survey.WithStdio(ctx.stdin, ctx.stdout, nil))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("canceled")
+		}
+	}
+	err = builddep.AddDependency(ctx, config, name, inferred.Version, c.Homepage, c.Description, inferred.SortedURLs())
+	if err != nil {
+		return err
+	}
+	return config.WriteFile(ctx.rootCmd.JSONConfig)
+}
+
 type dependencyAddByGithubReleaseCmd struct {
 	Release      string `kong:"arg,help='github release URL or \"owner/repo(@tag)\"'"`
 	Name         string `kong:"name to use instead of repo name"`
@@ -388,12 +698,26 @@ func (c *dependencyAddByGithubReleaseCmd) Run(ctx *runContext) error {
 type dependencyValidateCmd struct {
 	Dependency string           `kong:"arg,predictor=bin"`
 	Systems    []bindown.System `kong:"name=system,predictor=allSystems"`
+	Jobs       int              `kong:"name=jobs,help=${jobs_help}"`
+	URLsOnly   bool             `kong:"name=urls-only,help=${validate_urls_only_help}"`
+	Record     string           `kong:"name=record,type=path,help=${validate_record_help}"`
+	Replay     string           `kong:"name=replay,type=path,help=${validate_replay_help},env='BINDOWN_VALIDATE_REPLAY'"`
 }
 
 func (d dependencyValidateCmd) Run(ctx *runContext) error {
+	if d.Record != "" && d.Replay != "" {
+		return fmt.Errorf("cannot use --record and --replay together")
+	}
 	config, err := loadConfigFile(ctx, false)
 	if err != nil {
 		return err
 	}
-	return config.Validate(d.Dependency, d.Systems)
+	if d.Record != "" || d.Replay != "" {
+		bindown.SetHTTPFixtures(d.Record+d.Replay, d.Replay != "")
+		defer bindown.SetHTTPFixtures("", false)
+	}
+	return config.Validate(d.Dependency, d.Systems, &bindown.ValidateOpts{
+		Jobs:     d.Jobs,
+		URLsOnly: d.URLsOnly,
+	})
 }
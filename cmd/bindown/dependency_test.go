@@ -2,13 +2,19 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/Netflix/go-expect"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/bindown"
 	"github.com/willabides/bindown/v4/internal/testutil"
 )
 
@@ -113,7 +119,7 @@ url_checksums: {foo: "0000"}
 			args:   []string{"dependency", "update-vars", "dep1", "--set", "foo=bar"},
 			wantState: resultState{
 				stderr: `cmd: error: Get "https:": http: no Host in request URL`,
-				exit:   1,
+				exit:   exitNetworkError,
 			},
 		},
 		{
@@ -294,6 +300,248 @@ dep1
 dep2
 `,
 	})
+
+	t.Run("detail", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+systems: [darwin/arm64, linux/amd64]
+templates:
+  base:
+    url: https://example.com/{{.version}}/{{.name}}-{{.os}}-{{.arch}}
+    required_vars: [version]
+dependencies:
+  dep1:
+    template: base
+    vars:
+      name: dep1
+      version: 1.2.3
+  dep2:
+    url: https://example.com/dep2
+    systems: [linux/amd64]
+  dep3:
+    template: base
+    vars:
+      name: dep3
+url_checksums:
+  https://example.com/1.2.3/dep1-linux-amd64: deadbeef
+  https://example.com/dep2: deadbeef
+`)
+		result := runner.run("dependency", "list", "--detail")
+		result.assertState(resultState{
+			stdout: `
+- name: dep1
+  version: 1.2.3
+  template: base
+  systems:
+    - darwin/arm64
+    - linux/amd64
+  missing_checksums:
+    - darwin/arm64
+- name: dep2
+  systems:
+    - linux/amd64
+- name: dep3
+  template: base
+  systems:
+    - darwin/arm64
+    - linux/amd64
+  missing_checksums:
+    - darwin/arm64
+    - linux/amd64
+  missing_vars:
+    - version
+`,
+		})
+
+		result = runner.run("dependency", "list", "--detail", "--json")
+		result.assertState(resultState{
+			stdout: `
+[
+  {
+    "name": "dep1",
+    "version": "1.2.3",
+    "template": "base",
+    "systems": [
+      "darwin/arm64",
+      "linux/amd64"
+    ],
+    "missing_checksums": [
+      "darwin/arm64"
+    ]
+  },
+  {
+    "name": "dep2",
+    "systems": [
+      "linux/amd64"
+    ]
+  },
+  {
+    "name": "dep3",
+    "template": "base",
+    "systems": [
+      "darwin/arm64",
+      "linux/amd64"
+    ],
+    "missing_checksums": [
+      "darwin/arm64",
+      "linux/amd64"
+    ],
+    "missing_vars": [
+      "version"
+    ]
+  }
+]
+`,
+		})
+	})
+}
+
+func Test_dependencyDocsCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+templates:
+  base:
+    url: https://example.com/{{.version}}/{{.name}}
+    homepage: https://example.com/tools
+    description: A tool from example.com
+dependencies:
+  jq:
+    url: https://example.com/jq
+    description: Command-line JSON processor | filter
+    homepage: https://jqlang.github.io/jq
+    vars:
+      version: 1.7.1
+  mytool:
+    template: base
+    description: Deploys the app
+    vars:
+      version: 2.0.0
+  other:
+    template: base
+  bare:
+    url: https://example.com/bare
+`)
+	result := runner.run("dependency", "docs")
+	result.assertState(resultState{
+		stdout: `
+bare
+jq      1.7.1  Command-line JSON processor | filter  https://jqlang.github.io/jq
+mytool  2.0.0  Deploys the app                       https://example.com/tools
+other          A tool from example.com               https://example.com/tools
+`,
+	})
+
+	result = runner.run("dependency", "docs", "--markdown")
+	result.assertState(resultState{
+		stdout: `
+| Tool | Version | Description |
+|------|---------|-------------|
+| bare |  |  |
+| [jq](https://jqlang.github.io/jq) | 1.7.1 | Command-line JSON processor \\| filter |
+| [mytool](https://example.com/tools) | 2.0.0 | Deploys the app |
+| [other](https://example.com/tools) |  | A tool from example.com |
+`,
+	})
+}
+
+func Test_dependencyResolveCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+templates:
+  base:
+    url: https://example.com/{{.name}}-{{.os}}-{{.arch}}.{{.ext}}
+    vars:
+      ext: tar.gz
+    overrides:
+      - matcher:
+          os: [windows]
+        dependency:
+          vars:
+            ext: zip
+    substitutions:
+      arch:
+        amd64: x86_64
+dependencies:
+  foo:
+    template: base
+    vars:
+      name: foo
+    overrides:
+      - matcher:
+          arch: [arm64]
+        dependency:
+          url: https://example.com/arm/{{.name}}
+`)
+
+	t.Run("explain", func(t *testing.T) {
+		result := runner.run("dependency", "resolve", "foo", "--system", "windows/amd64", "--explain")
+		result.assertState(resultState{stdout: `template "base"
+  url: "https://example.com/{{.name}}-{{.os}}-{{.arch}}.{{.ext}}"
+  vars.ext: "tar.gz"
+  substitutions.arch: "amd64" -> "x86_64"
+  overrides: 1
+dependency "foo"
+  vars.name: "foo"
+  overrides: 1
+override 0 matching {os=windows}
+  vars.ext: "zip"
+override 1 matching {arch=arm64}: no match
+substitution arch: "amd64" -> "x86_64"
+interpolated url: "https://example.com/{{.name}}-{{.os}}-{{.arch}}.{{.ext}}" -> "https://example.com/foo-windows-x86_64.zip"
+result:
+url: https://example.com/foo-windows-x86_64.zip
+vars:
+  arch: x86_64
+  ext: zip
+  name: foo
+  os: windows
+substitutions:
+  arch:
+    amd64: x86_64`})
+	})
+
+	t.Run("without explain", func(t *testing.T) {
+		result := runner.run("dependency", "resolve", "foo", "--system", "linux/arm64")
+		result.assertState(resultState{stdout: `url: https://example.com/arm/foo
+vars:
+  arch: arm64
+  ext: tar.gz
+  name: foo
+  os: linux
+substitutions:
+  arch:
+    amd64: x86_64`})
+	})
+
+	t.Run("system aliases", func(t *testing.T) {
+		result := runner.run("dependency", "resolve", "foo", "--system", "macOS/aarch64")
+		result.assertState(resultState{stdout: `url: https://example.com/arm/foo
+vars:
+  arch: arm64
+  ext: tar.gz
+  name: foo
+  os: darwin
+substitutions:
+  arch:
+    amd64: x86_64`})
+	})
+
+	t.Run("invalid system", func(t *testing.T) {
+		result := runner.run("dependency", "resolve", "foo", "--system", "linux")
+		result.assertState(resultState{
+			stdout: `(?s)Usage: .*`,
+			stderr: `cmd: error: --system: invalid system "linux". systems are in the form os/arch like linux/amd64`,
+			exit:   1,
+		})
+	})
+
+	t.Run("missing dependency", func(t *testing.T) {
+		result := runner.run("dependency", "resolve", "bar")
+		result.assertState(resultState{
+			stderr: `cmd: error: no dependency configured with the name "bar"`,
+			exit:   1,
+		})
+	})
 }
 
 func Test_dependencyRemoveCmd(t *testing.T) {
@@ -349,6 +597,47 @@ dependencies:
 	}
 }
 
+func Test_dependencyRemoveCmd_prune(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+systems: [linux/amd64]
+templates:
+  base:
+    url: https://example.com/{{.name}}-{{.os}}
+  tmpl1:
+    template: base
+    vars:
+      name: dep1
+  tmpl2:
+    url: https://example.com/dep2
+  unused:
+    url: https://example.com/unused
+dependencies:
+  dep1:
+    template: tmpl1
+  dep2:
+    template: tmpl2
+  dep3:
+    url: https://example.com/dep2
+url_checksums:
+  https://example.com/dep1-linux: "1111111111111111111111111111111111111111111111111111111111111111"
+  https://example.com/dep2: "2222222222222222222222222222222222222222222222222222222222222222"
+  https://example.com/orphan: "3333333333333333333333333333333333333333333333333333333333333333"
+`)
+	result := runner.run("dependency", "remove", "dep1", "--prune")
+	result.assertState(resultState{})
+	result = runner.run("dependency", "remove", "dep2", "--prune")
+	result.assertState(resultState{})
+	cfg := runner.getConfigFile()
+	require.Equal(t, []string{"dep3"}, bindown.MapKeys(cfg.Dependencies))
+	// only checksums and templates used by the removed dependencies are pruned
+	require.ElementsMatch(t, []string{"unused"}, bindown.MapKeys(cfg.Templates))
+	require.ElementsMatch(t, []string{
+		"https://example.com/dep2",
+		"https://example.com/orphan",
+	}, bindown.MapKeys(cfg.URLChecksums))
+}
+
 func Test_dependencyAddCmd(t *testing.T) {
 	t.Run("from existing template", func(t *testing.T) {
 		runner := newCmdRunner(t)
@@ -375,6 +664,107 @@ dependencies:
 		require.Equal(t, wantDep, cfg.Dependencies["dep1"])
 	})
 
+	t.Run("from other config", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`{}`)
+		otherFile := filepath.Join(runner.tmpDir, "other.yaml")
+		err := os.WriteFile(otherFile, []byte(`
+systems: ["linux/amd64", "darwin/amd64"]
+dependencies:
+  foo:
+    template: tmpl
+    vars: {version: "1.2.3"}
+templates:
+  tmpl:
+    url: foo-{{ .os }}-{{ .arch }}-{{ .version }}
+url_checksums:
+  foo-linux-amd64-1.2.3: deadbeef
+  foo-darwin-amd64-1.2.3: deadbeef
+  foo-linux-amd64-1.0.0: deadbeef
+`), 0o600)
+		require.NoError(t, err)
+		result := runner.run("dependency", "add", "myfoo", "foo", "--from", otherFile, "--skipchecksums")
+		result.assertState(resultState{
+			stdout: `Copied dependency "myfoo" from ` + regexp.QuoteMeta(otherFile),
+		})
+		want := mustConfigFromYAML(t, `
+dependencies:
+  myfoo:
+    template: tmpl
+    vars: {version: "1.2.3"}
+templates:
+  tmpl:
+    url: foo-{{ .os }}-{{ .arch }}-{{ .version }}
+url_checksums:
+  foo-linux-amd64-1.2.3: deadbeef
+  foo-darwin-amd64-1.2.3: deadbeef
+`)
+		cfg := runner.getConfigFile()
+		require.Equal(t, want.Dependencies, cfg.Dependencies)
+		require.Equal(t, want.Templates, cfg.Templates)
+		require.Equal(t, want.URLChecksums, cfg.URLChecksums)
+
+		result = runner.run("dependency", "add", "bar", "--from", otherFile, "--var=version=2")
+		result.assertState(resultState{
+			exit:   1,
+			stderr: `cmd: error: cannot use --var and --from together`,
+		})
+	})
+
+	t.Run("from json", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+systems: ["linux/amd64", "darwin/amd64"]
+templates:
+  tmpl:
+    url: foo-{{ .os }}-{{ .arch }}-{{ .version }}
+url_checksums:
+  foo-linux-amd64-1.2.3: deadbeef
+  foo-darwin-amd64-1.2.3: deadbeef
+`)
+		jsonFile := filepath.Join(runner.tmpDir, "dep.json")
+		err := os.WriteFile(jsonFile, []byte(`{"template": "tmpl", "vars": {"version": "1.2.3"}}`), 0o600)
+		require.NoError(t, err)
+		result := runner.run("dependency", "add", "dep1", "--from-json", jsonFile)
+		result.assertState(resultState{
+			stdout: `Added dependency "dep1"`,
+		})
+		runner.stdin = strings.NewReader(`{"url": "https://example.com/bar", "bin": "bar"}`)
+		result = runner.run("dependency", "add", "dep2", "--from-json", "-", "--skipchecksums")
+		result.assertState(resultState{
+			stdout: `Added dependency "dep2"`,
+		})
+		want := mustConfigFromYAML(t, `
+dependencies:
+  dep1:
+    template: tmpl
+    vars: {version: "1.2.3"}
+  dep2:
+    url: https://example.com/bar
+    bin: bar
+`)
+		cfg := runner.getConfigFile()
+		require.Equal(t, want.Dependencies, cfg.Dependencies)
+
+		runner.stdin = strings.NewReader(`{"template": "missing"}`)
+		result = runner.run("dependency", "add", "dep3", "--from-json", "-")
+		result.assertState(resultState{
+			exit:   2,
+			stderr: `cmd: error: dependency "dep3" uses template "missing", which isn't in the config`,
+		})
+
+		runner.stdin = strings.NewReader(`{"url": 12}`)
+		result = runner.run("dependency", "add", "dep3", "--from-json", "-")
+		require.Equal(t, 2, result.exitVal)
+		require.Contains(t, result.stdErr.String(), "/dependencies/dep3/url: expected string")
+
+		result = runner.run("dependency", "add", "dep3", "tmpl", "--from-json", jsonFile)
+		result.assertState(resultState{
+			exit:   1,
+			stderr: `cmd: error: cannot use a template and --from-json together`,
+		})
+	})
+
 	t.Run("from missing template", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(`{}`)
@@ -385,6 +775,17 @@ dependencies:
 		})
 	})
 
+	t.Run("from builtin template", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`{}`)
+		result := runner.run("dependency", "add", "jq", "--var=version=1.7.1", "--skipchecksums")
+		require.Equal(t, 0, result.exitVal)
+		require.Contains(t, result.stdOut.String(), `Adding dependency "jq" from template builtin#jq`)
+		cfg := runner.getConfigFile()
+		require.Equal(t, "builtin#jq", *cfg.Dependencies["jq"].Template)
+		require.NotNil(t, cfg.Templates["builtin#jq"])
+	})
+
 	t.Run("from missing source", func(t *testing.T) {
 		runner := newCmdRunner(t)
 		runner.writeConfigYaml(`{}`)
@@ -602,4 +1003,201 @@ url_checksums:
 		result := runner.run("dependency", "validate", "foo")
 		result.assertState(resultState{})
 	})
+
+	t.Run("urls only", func(t *testing.T) {
+		server := testutil.ServeFiles(t, map[string]string{
+			"/foo/v1.2.3/foo-darwin-amd64.tar.gz": testdataPath("downloadables/runnable.tar.gz"),
+		})
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+systems:
+- darwin/amd64
+- linux/amd64
+dependencies:
+  foo:
+    url: "%s/foo/v{{ .version }}/foo-{{ .os }}-{{ .arch }}.tar.gz"
+    vars:
+      version: 1.2.3
+`, server.URL))
+		result := runner.run("dependency", "validate", "foo", "--urls-only", "--system", "darwin/amd64")
+		result.assertState(resultState{})
+		result = runner.run("dependency", "validate", "foo", "--urls-only")
+		result.assertState(resultState{
+			stderr: fmt.Sprintf("cmd: error: linux/amd64: %s/foo/v1.2.3/foo-linux-amd64.tar.gz returned 404 Not Found", server.URL),
+			exit:   3,
+		})
+
+		runner.writeConfigYaml(fmt.Sprintf(`
+download_command: [curl, -fsSL, -o, "{{ .output }}", "{{ .url }}"]
+dependencies:
+  foo:
+    url: "%s/foo/v{{ .version }}/foo-{{ .os }}-{{ .arch }}.tar.gz"
+    vars:
+      version: 1.2.3
+`, server.URL))
+		result = runner.run("dependency", "validate", "foo", "--urls-only", "--system", "darwin/amd64")
+		result.assertState(resultState{
+			stderr: "cmd: error: --urls-only can't be used with download_command. validate without it to run the download command",
+			exit:   2,
+		})
+	})
+
+	t.Run("record and replay", func(t *testing.T) {
+		server := testutil.ServeFiles(t, map[string]string{
+			"/foo/v1.2.3/foo-linux-amd64.tar.gz": testdataPath("downloadables/runnable.tar.gz"),
+		})
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: "%s/foo/v{{ .version }}/foo-{{ .os }}-{{ .arch }}.tar.gz"
+    archive_path: bin/runnable.sh
+    vars:
+      version: 1.2.3
+url_checksums:
+  "%s/foo/v1.2.3/foo-linux-amd64.tar.gz": fb2fe41a34b77ee180def0cb9a222d8776a6e581106009b64f35983da291ab6e
+`, server.URL, server.URL))
+		fixtures := filepath.Join(runner.tmpDir, "fixtures")
+		result := runner.run("dependency", "validate", "foo", "--system", "linux/amd64", "--record", fixtures)
+		result.assertState(resultState{})
+
+		server.Close()
+		result = runner.run("dependency", "validate", "foo", "--system", "linux/amd64", "--replay", fixtures)
+		result.assertState(resultState{})
+
+		result = runner.run("dependency", "validate", "foo", "--system", "linux/amd64", "--record", fixtures, "--replay", fixtures)
+		result.assertState(resultState{
+			stderr: "cmd: error: cannot use --record and --replay together",
+			exit:   1,
+		})
+	})
+}
+
+func Test_dependencyVersionsCmd(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Ftool/repository/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(`[{"name": "v1.0.0"}, {"name": "v1.1.0"}, {"name": "v2.0.0-beta.1"}]`))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  tool:
+    url: %s/group/tool/-/releases/v{{.version}}/downloads/tool.tar.gz
+    vars:
+      version: 1.0.0
+`, ts.URL))
+	result := runner.run("dependency", "versions", "tool")
+	result.assertState(resultState{
+		stdout: "1.1.0\n1.0.0 (current)",
+	})
+	result = runner.run("dependency", "versions", "tool", "--prereleases")
+	result.assertState(resultState{
+		stdout: "2.0.0-beta.1\n1.1.0\n1.0.0 (current)",
+	})
+}
+
+func Test_dependencyUpdateCmd(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Ftool/repository/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(`[{"name": "v1.0.0"}, {"name": "v1.1.0"}]`))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("all", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  tool:
+    url: %s/group/tool/-/releases/v{{.version}}/downloads/tool.tar.gz
+    vars:
+      version: 1.0.0
+  other:
+    url: https://example.com/other
+`, ts.URL))
+		result := runner.run("dependency", "update", "--all", "--skipchecksums")
+		result.assertState(resultState{
+			stdout: fmt.Sprintf(
+				"- tool: 1.0.0 -> 1.1.0 ([release notes](%s/group/tool/-/releases/v1.1.0), [changes](%s/group/tool/-/compare/v1.0.0...v1.1.0))",
+				ts.URL, ts.URL,
+			),
+		})
+		require.Equal(t, "1.1.0", runner.getConfigFile().Dependencies["tool"].Vars["version"])
+
+		result = runner.run("dependency", "update", "tool", "--skipchecksums")
+		result.assertState(resultState{
+			stdout: "all dependencies are up to date",
+		})
+	})
+
+	t.Run("no dependencies", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`{}`)
+		result := runner.run("dependency", "update")
+		result.assertState(resultState{
+			stderr: "cmd: error: specify dependencies to update or use --all",
+			exit:   1,
+		})
+	})
+}
+
+func Test_dependencyAddByURLCmd(t *testing.T) {
+	foo := testdataPath("downloadables/rawfile/foo")
+	server := testutil.ServeFiles(t, map[string]string{
+		"/v1.2.3/foo_1.2.3_darwin_x86_64": foo,
+		"/v1.2.3/foo_1.2.3_linux_x86_64":  foo,
+	})
+	url := server.URL + "/v1.2.3/foo_1.2.3_linux_x86_64"
+	config := `
+systems:
+- darwin/amd64
+- linux/amd64
+`
+
+	t.Run("yes", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(config)
+		result := runner.run("dependency", "add-by-url", url, "--experimental", "--yes")
+		result.assertState(resultState{
+			stdout: fmt.Sprintf(`name: foo
+version: 1.2.3
+url: %[1]s/v{{.version}}/foo_{{.version}}_{{.os}}_{{.arch}}
+substitution: arch amd64 -> x86_64
+found urls:
+  darwin/amd64: %[1]s/v1.2.3/foo_1.2.3_darwin_x86_64
+  linux/amd64: %[1]s/v1.2.3/foo_1.2.3_linux_x86_64
+`, server.URL),
+		})
+		cfg := runner.getConfigFile()
+		require.Equal(t, "1.2.3", cfg.Dependencies["foo"].Vars["version"])
+		require.Len(t, cfg.URLChecksums, 2)
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(config)
+		ex := func(console *expect.Console) {
+			_, err := console.ExpectString("Add foo with these urls?")
+			require.NoError(t, err)
+			_, err = console.SendLine("n")
+			require.NoError(t, err)
+			_, err = console.ExpectString("canceled")
+			require.NoError(t, err)
+			require.NoError(t, console.Close())
+		}
+		result := runner.runExpect(ex, "dependency", "add-by-url", url, "--experimental")
+		result.assertState(resultState{
+			stdout: `(?s)found urls:.*cmd: error: canceled`,
+			exit:   1,
+		})
+		require.Nil(t, runner.getConfigFile().Dependencies)
+	})
 }
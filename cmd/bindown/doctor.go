@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type doctorCmd struct {
+	SkipNetwork bool `kong:"name=skip-network,help='skip checking that dependency hosts are reachable'"`
+}
+
+func (c *doctorCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		printDoctorCheck(ctx, bindown.DoctorCheck{
+			Name:    "config",
+			Status:  bindown.DoctorFail,
+			Message: err.Error(),
+			Fix:     "create a config with `bindown init` or set --configfile",
+		})
+		return fmt.Errorf("found 1 problem")
+	}
+	printDoctorCheck(ctx, bindown.DoctorCheck{
+		Name:    "config",
+		Status:  bindown.DoctorOK,
+		Message: config.Filename,
+	})
+	checks := config.Doctor(ctx, &bindown.DoctorOpts{
+		SkipNetwork: c.SkipNetwork,
+	})
+	problems := 0
+	for _, check := range checks {
+		printDoctorCheck(ctx, check)
+		if check.Status == bindown.DoctorFail {
+			problems++
+		}
+	}
+	return foundProblems(problems)
+}
+
+// foundProblems returns an error reporting the number of problems or nil when there are none
+func foundProblems(problems int) error {
+	switch problems {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("found 1 problem")
+	default:
+		return fmt.Errorf("found %d problems", problems)
+	}
+}
+
+func printDoctorCheck(ctx *runContext, check bindown.DoctorCheck) {
+	fmt.Fprintf(ctx.stdout, "%-4s  %s: %s\n", check.Status, check.Name, check.Message)
+	if check.Fix != "" {
+		fmt.Fprintf(ctx.stdout, "      fix: %s\n", check.Fix)
+	}
+}
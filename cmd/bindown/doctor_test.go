@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_doctorCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+
+	t.Run("healthy", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+		t.Setenv("PATH", filepath.Join(runner.tmpDir, "bin"))
+		result := runner.run("doctor")
+		assert.Equal(t, 0, result.exitVal)
+		stdout := result.stdOut.String()
+		assert.Contains(t, stdout, "ok    config: "+runner.configFile)
+		assert.Contains(t, stdout, "ok    dependencies: all dependencies are valid for their systems")
+		assert.Contains(t, stdout, "ok    cache: "+runner.cache+" is writable")
+		assert.Contains(t, stdout, "ok    PATH: ")
+		assert.Contains(t, stdout, "ok    network: "+server.URL+" is reachable")
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: http://127.0.0.1:1/foo
+`)
+		t.Setenv("PATH", "")
+		result := runner.run("doctor")
+		result.assertState(resultState{
+			stdout: `(?s)warn  dependencies: missing checksum for foo on .*
+      fix: run ` + "`bindown checksums add --all`" + `.*warn  PATH: .* is not in PATH.*fail  network: http://127.0.0.1:1 is unreachable`,
+			stderr: `cmd: error: found 1 problem`,
+			exit:   1,
+		})
+	})
+
+	t.Run("skip network", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: http://127.0.0.1:1/foo
+`)
+		result := runner.run("doctor", "--skip-network")
+		assert.Equal(t, 0, result.exitVal)
+		assert.NotContains(t, result.stdOut.String(), "network")
+	})
+
+	t.Run("missing config", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		result := runner.run("doctor")
+		result.assertState(resultState{
+			stdout: `fail  config: .*\n      fix: create a config with ` + "`bindown init`" + ` or set --configfile`,
+			stderr: `cmd: error: found 1 problem`,
+			exit:   1,
+		})
+	})
+}
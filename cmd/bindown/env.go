@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type envCmd struct {
+	Dependency []string       `kong:"arg,optional,name=dependency,help='dependencies to export env vars for. default is all dependencies',predictor=bin"`
+	System     bindown.System `kong:"name=system,default=${system_default},help=${system_help},predictor=allSystems"`
+}
+
+func (c *envCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	env, err := config.DependencyEnv(c.Dependency, c.System)
+	if err != nil {
+		return err
+	}
+	keys := bindown.MapKeys(env)
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(ctx.stdout, "export %s=%s\n", key, shellQuote(env[key]))
+	}
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a posix shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
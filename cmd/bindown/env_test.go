@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_envCmd(t *testing.T) {
+	t.Run("exports", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    template: foo-tmpl
+    url: https://example.com/foo-{{ .os }}
+    env:
+      FOO_HOME: /opt/foo-{{ .version }}
+    vars:
+      version: 1.2.3
+  bar:
+    url: https://example.com/bar
+    env:
+      BAR_GREETING: "it's bar"
+      SHARED: same
+templates:
+  foo-tmpl:
+    url: https://example.com/foo
+    env:
+      FOO_PLUGINS: "{{ .os }}/{{ .arch }}"
+      SHARED: same
+`)
+		result := runner.run("env", "--system", "linux/amd64")
+		result.assertState(resultState{
+			stdout: `
+export BAR_GREETING='it'\''s bar'
+export FOO_HOME='/opt/foo-1.2.3'
+export FOO_PLUGINS='linux/amd64'
+export SHARED='same'
+`,
+		})
+
+		result = runner.run("env", "bar", "--system", "linux/amd64")
+		result.assertState(resultState{
+			stdout: `
+export BAR_GREETING='it'\''s bar'
+export SHARED='same'
+`,
+		})
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: https://example.com/foo
+    env:
+      SHARED: foo
+  bar:
+    url: https://example.com/bar
+    env:
+      SHARED: bar
+`)
+		result := runner.run("env")
+		result.assertState(resultState{
+			stderr: "cmd: error: bar and foo export different values for SHARED",
+			exit:   exitConfigError,
+		})
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`
+dependencies:
+  foo:
+    url: https://example.com/foo
+    env:
+      NOT-VALID: foo
+`)
+		result := runner.run("env", "foo")
+		result.assertState(resultState{
+			stderr: `cmd: error: foo: invalid env var name "NOT-VALID"`,
+			exit:   exitConfigError,
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type execCmd struct {
+	With                 []string `kong:"name=with,required,help='dependency to install and add to PATH. may be repeated',predictor=bin"`
+	AllowMissingChecksum bool     `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+	Command              []string `kong:"arg,name=command,passthrough,help='command to run with its arguments'"`
+}
+
+func (c *execCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	system := bindown.CurrentSystem
+	err = writeTrustedChecksums(ctx, config, func() error {
+		return config.InstallDependencies(c.With, system, &bindown.ConfigInstallDependenciesOpts{
+			AllowMissingChecksum: c.AllowMissingChecksum,
+			Stderr:               ctx.stderr,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	var binDirs []string
+	for _, name := range c.With {
+		var installPath string
+		installPath, err = config.DependencyInstallPath(name, system)
+		if err != nil {
+			return err
+		}
+		binDir, err := filepath.Abs(filepath.Dir(installPath))
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(binDirs, binDir) {
+			binDirs = append(binDirs, binDir)
+		}
+	}
+	depEnv, err := config.DependencyEnv(c.With, system)
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	for key, val := range depEnv {
+		env = append(env, key+"="+val)
+	}
+	env = append(env, "PATH="+strings.Join(append(binDirs, os.Getenv("PATH")), string(filepath.ListSeparator)))
+
+	// exec.Command looks up the command in bindown's PATH, so look in the bin dirs first
+	cmdPath := c.Command[0]
+	if !strings.ContainsAny(cmdPath, `/\`) {
+		for _, dir := range binDirs {
+			p, lookErr := exec.LookPath(filepath.Join(dir, cmdPath))
+			if lookErr == nil {
+				cmdPath = p
+				break
+			}
+		}
+	}
+	cmd := exec.CommandContext(ctx, cmdPath, c.Command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = ctx.stdin
+	// --quiet discards bindown's own output, but the command's output belongs on stdout
+	cmd.Stdout = ctx.ciStdout
+	cmd.Stderr = ctx.stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &commandExitError{code: exitErr.ExitCode()}
+	}
+	return err
+}
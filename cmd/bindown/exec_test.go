@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_execCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	script := filepath.Join(t.TempDir(), "greet")
+	scriptContent := "#!/bin/sh\necho \"$GREETING $*\"\n"
+	require.NoError(t, os.WriteFile(script, []byte(scriptContent), 0o755))
+	sum := sha256.Sum256([]byte(scriptContent))
+	depURL := testutil.ServeFile(t, script, "/greet", "").URL + "/greet"
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  greet:
+    url: %s
+    env:
+      GREETING: hello
+url_checksums:
+  %s: %s
+`, depURL, depURL, hex.EncodeToString(sum[:])))
+
+	// the runner appends --configfile and --cache to the command line, which would pass them to the command
+	execRunner := *runner
+	execRunner.configFile, execRunner.cache = "", ""
+	execRunner.stdin = strings.NewReader("")
+	runExec := func(args ...string) *runCmdResult {
+		t.Helper()
+		args = append([]string{"exec", "--configfile", runner.configFile, "--cache", runner.cache}, args...)
+		return execRunner.run(args...)
+	}
+
+	t.Run("runs dependency", func(t *testing.T) {
+		result := runExec("--with", "greet", "--", "greet", "--world")
+		result.assertState(resultState{stdout: "hello --world"})
+		require.FileExists(t, filepath.Join(runner.tmpDir, "bin", "greet"))
+	})
+
+	t.Run("path", func(t *testing.T) {
+		result := runExec("--with", "greet", "--", "sh", "-c", "greet from sh")
+		result.assertState(resultState{stdout: "hello from sh"})
+	})
+
+	t.Run("quiet and porcelain", func(t *testing.T) {
+		for _, flag := range []string{"-q", "--porcelain"} {
+			result := execRunner.run(flag, "exec", "--configfile", runner.configFile, "--cache", runner.cache,
+				"--with", "greet", "--", "greet", "--world")
+			result.assertState(resultState{stdout: "hello --world"})
+		}
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		result := runExec("--with", "greet", "--", "sh", "-c", "exit 7")
+		result.assertState(resultState{exit: 7})
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		result := runExec("--with", "missing", "--", "true")
+		result.assertState(resultState{
+			stderr: `cmd: error: no dependency configured with the name "missing"`,
+			exit:   exitConfigError,
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+// exit codes for failures scripts may want to handle differently
+const (
+	exitError             = 1
+	exitConfigError       = 2
+	exitNetworkError      = 3
+	exitChecksumMismatch  = 4
+	exitUnsupportedSystem = 5
+)
+
+var failureExitCodes = map[bindown.FailureKind]int{
+	bindown.FailureUnknown:           exitError,
+	bindown.FailureConfig:            exitConfigError,
+	bindown.FailureNetwork:           exitNetworkError,
+	bindown.FailureChecksum:          exitChecksumMismatch,
+	bindown.FailureUnsupportedSystem: exitUnsupportedSystem,
+}
+
+// exitCode returns the exit code for err. When several dependencies failed, it is the code they have in common or
+// exitError when they failed for different reasons.
+func exitCode(err error) int {
+	depErrs := bindown.DependencyErrors(err)
+	if len(depErrs) == 0 {
+		return failureExitCodes[bindown.FailureKindOf(err)]
+	}
+	code := failureExitCodes[bindown.FailureKindOf(depErrs[0])]
+	for _, depErr := range depErrs[1:] {
+		if failureExitCodes[bindown.FailureKindOf(depErr)] != code {
+			return exitError
+		}
+	}
+	return code
+}
+
+// commandExitError is returned when a command run by bindown exits with a non-zero code. bindown exits with the same
+// code without reporting an error of its own.
+type commandExitError struct {
+	code int
+}
+
+func (e *commandExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.code)
+}
+
+// writeFailureSummary writes a line for each dependency that failed in err with the kind of failure and its exit
+// code. It writes nothing when err isn't from an operation on multiple dependencies.
+func writeFailureSummary(w io.Writer, err error) {
+	depErrs := bindown.DependencyErrors(err)
+	if len(depErrs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nfailed dependencies: %d\n", len(depErrs))
+	for _, depErr := range depErrs {
+		kind := bindown.FailureKindOf(depErr)
+		fmt.Fprintf(w, "  %s: %s (exit code %d)\n", depErr.Dependency, kind, failureExitCodes[kind])
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type generateCmd struct {
+	Makefile   generateMakefileCmd   `kong:"cmd,help='generate Makefile targets that install dependencies on demand'"`
+	Justfile   generateJustfileCmd   `kong:"cmd,help='generate justfile recipes that install dependencies on demand'"`
+	Taskfile   generateTaskfileCmd   `kong:"cmd,help='generate Taskfile tasks that install dependencies on demand'"`
+	Magefile   generateMagefileCmd   `kong:"cmd,help='generate mage targets that install dependencies on demand'"`
+	Dockerfile generateDockerfileCmd `kong:"cmd,help='generate a multi-stage Dockerfile fragment that installs dependencies'"`
+	Installer  generateInstallerCmd  `kong:"cmd,help='generate a standalone shell script that installs a dependency without bindown'"`
+	Envrc      generateEnvrcCmd      `kong:"cmd,help='generate a direnv .envrc that adds installed dependencies to PATH'"`
+	Go         generateGoCmd         `kong:"cmd,help='generate a go file with go:generate directives that install dependencies and constants with their paths'"`
+}
+
+// generateFlags are the flags shared by generate subcommands
+type generateFlags struct {
+	Dependency  []string       `kong:"arg,optional,name=dependency,help='dependencies to include. default is all dependencies',predictor=bin"`
+	System      bindown.System `kong:"name=system,default=${system_default},help='system used to resolve install paths',predictor=allSystems"`
+	Output      string         `kong:"type=path,help='file to write. writes to stdout if not set'"`
+	BindownExec string         `kong:"name=bindown,default=bindown,help='bindown command for the generated file to run'"`
+}
+
+// run loads the config and writes the output of gen to stdout or the output file
+func (f *generateFlags) run(ctx *runContext, gen func(*bindown.Config, io.Writer, *bindown.GenerateOpts) error) error {
+	return f.runWithOpts(ctx, &bindown.GenerateOpts{}, gen)
+}
+
+// runWithOpts is like run but starts from opts instead of empty options
+func (f *generateFlags) runWithOpts(
+	ctx *runContext,
+	opts *bindown.GenerateOpts,
+	gen func(*bindown.Config, io.Writer, *bindown.GenerateOpts) error,
+) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	dir := "."
+	if f.Output != "" {
+		dir = filepath.Dir(f.Output)
+	}
+	opts.Dependencies = f.Dependency
+	opts.System = f.System
+	opts.Dir = dir
+	opts.BindownExec = f.BindownExec
+	var buf bytes.Buffer
+	err = gen(config, &buf, opts)
+	if err != nil {
+		return err
+	}
+	if f.Output == "" {
+		_, err = fmt.Fprint(ctx.stdout, buf.String())
+		return err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Output, buf.Bytes(), 0o644)
+}
+
+type generateMakefileCmd struct {
+	generateFlags `kong:"embed"`
+}
+
+func (c *generateMakefileCmd) Run(ctx *runContext) error {
+	return c.run(ctx, (*bindown.Config).GenerateMakefile)
+}
+
+type generateJustfileCmd struct {
+	generateFlags `kong:"embed"`
+}
+
+func (c *generateJustfileCmd) Run(ctx *runContext) error {
+	return c.run(ctx, (*bindown.Config).GenerateJustfile)
+}
+
+type generateTaskfileCmd struct {
+	generateFlags `kong:"embed"`
+}
+
+func (c *generateTaskfileCmd) Run(ctx *runContext) error {
+	return c.run(ctx, (*bindown.Config).GenerateTaskfile)
+}
+
+type generateMagefileCmd struct {
+	generateFlags `kong:"embed"`
+}
+
+func (c *generateMagefileCmd) Run(ctx *runContext) error {
+	return c.run(ctx, (*bindown.Config).GenerateMagefile)
+}
+
+type generateEnvrcCmd struct {
+	generateFlags  `kong:"embed"`
+	InstallMissing bool `kong:"name=install-missing,help='install missing dependencies when direnv loads the .envrc'"`
+}
+
+func (c *generateEnvrcCmd) Run(ctx *runContext) error {
+	return c.runWithOpts(ctx, &bindown.GenerateOpts{
+		InstallMissing: c.InstallMissing,
+	}, (*bindown.Config).GenerateEnvrc)
+}
+
+type generateGoCmd struct {
+	generateFlags `kong:"embed"`
+	Package       string `kong:"name=package,help='package name for the generated file. default is the name of its directory'"`
+}
+
+func (c *generateGoCmd) Run(ctx *runContext) error {
+	return c.runWithOpts(ctx, &bindown.GenerateOpts{
+		Package: c.Package,
+	}, (*bindown.Config).GenerateGoPackage)
+}
+
+type generateDockerfileCmd struct {
+	Dependency []string       `kong:"arg,optional,name=dependency,help='dependencies to include. default is all dependencies',predictor=bin"`
+	System     bindown.System `kong:"name=system,default=${docker_system_default},help='system to install dependencies for',predictor=allSystems"`
+	Output     string         `kong:"type=path,help='file to write. writes to stdout if not set. paths are relative to its directory, which should be the docker build context'"`
+	BindownTag string         `kong:"name=bindown-tag,help='bindown release to install in the image. default is the running version'"`
+	BaseImage  string         `kong:"name=base-image,default=alpine:3,help='image to install dependencies in'"`
+}
+
+func (c *generateDockerfileCmd) Run(ctx *runContext) error {
+	tag := c.BindownTag
+	if tag == "" {
+		tag = getVersion()
+	}
+	if tag == "" {
+		return fmt.Errorf("--bindown-tag is required")
+	}
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	flags := generateFlags{
+		Dependency: c.Dependency,
+		System:     c.System,
+		Output:     c.Output,
+	}
+	return flags.runWithOpts(ctx, &bindown.GenerateOpts{
+		BindownTag: tag,
+		BaseImage:  c.BaseImage,
+	}, (*bindown.Config).GenerateDockerfile)
+}
+
+type generateInstallerCmd struct {
+	Dependency string `kong:"arg,name=dependency,help='dependency to install',predictor=bin"`
+	Output     string `kong:"type=path,help='file to write. writes to stdout if not set'"`
+	BinDir     string `kong:"name=bin-dir,default=./bin,help='default install directory for the generated script'"`
+}
+
+func (c *generateInstallerCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = config.GenerateInstaller(&buf, &bindown.GenerateOpts{
+		Dependencies: []string{c.Dependency},
+		BinDir:       c.BinDir,
+	})
+	if err != nil {
+		return err
+	}
+	if c.Output == "" {
+		_, err = fmt.Fprint(ctx.stdout, buf.String())
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.Output), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.Output, buf.Bytes(), 0o755)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_generateMakefileCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  jq:
+    url: https://example.com/jq
+    install_path: "{{.name}}-{{.version}}"
+    vars:
+      version: "1.6"
+  yq:
+    url: https://example.com/yq
+`)
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "makefile")
+	result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+BINDOWN ?= bindown
+BINDOWN_CONFIG ?= .bindown.yaml
+
+bin/jq-1.6: $(BINDOWN_CONFIG)
+	$(BINDOWN) install jq --configfile $(BINDOWN_CONFIG)
+	@touch $@
+
+bin/yq: $(BINDOWN_CONFIG)
+	$(BINDOWN) install yq --configfile $(BINDOWN_CONFIG)
+	@touch $@
+
+.PHONY: bindown-install
+bindown-install: bin/jq-1.6 bin/yq
+`})
+}
+
+func Test_generateMakefileCmd_skippedInstall(t *testing.T) {
+	makeExec, err := exec.LookPath("make")
+	if err != nil {
+		t.Skip("make is not installed")
+	}
+	runner := newCmdRunner(t)
+	servePath := testdataPath("downloadables/rawfile/foo")
+	depURL := testutil.ServeFile(t, servePath, "/foo/foo", "").URL + "/foo/foo"
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, depURL, depURL))
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "makefile", "--output", "Makefile")
+	result.assertState(resultState{})
+	result = runner.run("install", "foo")
+	result.assertState(resultState{stdout: "installed foo"})
+
+	// the config changed after foo was installed, but foo is still up to date, so bindown skips it
+	target := filepath.Join(runner.tmpDir, "bin", "foo")
+	hourAgo := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(target, hourAgo, hourAgo))
+	require.NoError(t, os.Chtimes(runner.configFile, hourAgo.Add(time.Minute), hourAgo.Add(time.Minute)))
+	runMake := func(args ...string) error {
+		t.Helper()
+		cmd := exec.Command(makeExec, append([]string{"BINDOWN=" + testutil.BindownBin()}, args...)...)
+		cmd.Dir = runner.tmpDir
+		out, err := cmd.CombinedOutput()
+		t.Log(string(out))
+		return err
+	}
+	require.NoError(t, runMake("bindown-install"))
+	targetInfo, err := os.Stat(target)
+	require.NoError(t, err)
+	configInfo, err := os.Stat(runner.configFile)
+	require.NoError(t, err)
+	require.True(t, targetInfo.ModTime().After(configInfo.ModTime()))
+	// make -q exits 0 when the target is up to date
+	require.NoError(t, runMake("-q", "bin/foo"))
+}
+
+func Test_generateJustfileCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  jq:
+    url: https://example.com/jq
+  yq:
+    url: https://example.com/yq
+`)
+	output := filepath.Join(runner.tmpDir, "sub", "justfile")
+	result := runner.run("generate", "justfile", "jq", "--output", output, "--bindown", "script/bindown")
+	result.assertState(resultState{})
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, `# Code generated by bindown. DO NOT EDIT.
+
+bindown := env_var_or_default("BINDOWN", "script/bindown")
+bindown_config := env_var_or_default("BINDOWN_CONFIG", "../.bindown.yaml")
+
+# install jq to ../bin/jq if it is missing
+jq:
+    @test -e ../bin/jq || {{bindown}} install jq --configfile {{bindown_config}}
+
+# install all dependencies that are missing
+bindown-install: jq
+`, string(got))
+}
+
+func Test_generateEnvrcCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  jq:
+    url: https://example.com/jq
+  yq:
+    url: https://example.com/yq
+  tool:
+    url: https://example.com/tool
+    install_path: "tools/{{.name}}"
+`)
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "envrc")
+	result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+watch_file .bindown.yaml
+PATH_add bin
+PATH_add bin/tools
+`})
+
+	result = runner.run("generate", "envrc", "--install-missing", "--bindown", "script/bindown")
+	result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+watch_file .bindown.yaml
+PATH_add bin
+PATH_add bin/tools
+
+# install dependencies that are missing
+[ -e bin/jq ] || script/bindown install jq --configfile .bindown.yaml
+[ -e bin/tools/tool ] || script/bindown install tool --configfile .bindown.yaml
+[ -e bin/yq ] || script/bindown install yq --configfile .bindown.yaml
+`})
+}
+
+func Test_generateTaskfileCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  jq:
+    url: https://example.com/jq
+`)
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "taskfile")
+	result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+version: "3"
+
+vars:
+  BINDOWN: bindown
+  BINDOWN_CONFIG: .bindown.yaml
+
+tasks:
+  jq:
+    desc: install jq to bin/jq
+    cmds:
+      - "{{.BINDOWN}} install jq --configfile {{.BINDOWN_CONFIG}}"
+    sources:
+      - "{{.BINDOWN_CONFIG}}"
+    generates:
+      - bin/jq
+  bindown-install:
+    desc: install all dependencies that are missing or out of date
+    deps:
+      - jq
+`})
+}
+
+func Test_generateMagefileCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  golangci-lint:
+    url: https://example.com/golangci-lint
+`)
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "magefile")
+	result.assertState(resultState{
+		stdout: `(?s)^//go:build mage.*` +
+			`func \(Bindown\) All\(\) \{\s+mg.Deps\(Bindown.GolangciLint\)\s+\}.*` +
+			`func \(Bindown\) GolangciLint\(\) error \{\s+return bindownInstall\("golangci-lint", "bin/golangci-lint"\)`,
+	})
+}
+
+func Test_generateDockerfileCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  jq:
+    url: https://example.com/jq
+  yq:
+    url: https://example.com/yq
+`)
+	testInDir(t, runner.tmpDir)
+
+	t.Run("arm64", func(t *testing.T) {
+		result := runner.run("generate", "dockerfile", "--bindown-tag", "4.8.0", "--system", "linux/arm64")
+		result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+FROM alpine:3 AS bindown
+ARG BINDOWN_TAG=v4.8.0
+RUN wget -qO- https://github.com/WillAbides/bindown/releases/download/${BINDOWN_TAG}/bootstrap-bindown.sh | sh -s -- -b /usr/local/bin
+WORKDIR /bindown
+COPY .bindown.yaml .bindown.yaml
+RUN bindown install --configfile .bindown.yaml --system linux/arm64 jq yq
+
+# Copy the installed dependencies into your final stage with:
+#   COPY --from=bindown-bin / /
+FROM scratch AS bindown-bin
+COPY --from=bindown /bindown/bin/jq /usr/local/bin/jq
+COPY --from=bindown /bindown/bin/yq /usr/local/bin/yq
+`})
+	})
+
+	t.Run("lock file and fragments", func(t *testing.T) {
+		lockFile := filepath.Join(runner.tmpDir, "bindown.lock")
+		fragmentDir := filepath.Join(runner.tmpDir, "bindown.d")
+		t.Cleanup(func() {
+			require.NoError(t, os.Remove(lockFile))
+			require.NoError(t, os.RemoveAll(fragmentDir))
+		})
+		require.NoError(t, os.WriteFile(lockFile, []byte("dependencies: {}\n"), 0o644))
+		require.NoError(t, os.MkdirAll(fragmentDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(fragmentDir, "zq.yaml"), []byte(`
+dependencies:
+  zq:
+    url: https://example.com/zq
+`), 0o644))
+		result := runner.run("generate", "dockerfile", "--bindown-tag", "4.8.0", "--system", "linux/arm64")
+		result.assertState(resultState{stdout: `# Code generated by bindown. DO NOT EDIT.
+
+FROM alpine:3 AS bindown
+ARG BINDOWN_TAG=v4.8.0
+RUN wget -qO- https://github.com/WillAbides/bindown/releases/download/${BINDOWN_TAG}/bootstrap-bindown.sh | sh -s -- -b /usr/local/bin
+WORKDIR /bindown
+COPY .bindown.yaml .bindown.yaml
+COPY bindown.lock bindown.lock
+COPY bindown.d bindown.d
+RUN bindown install --configfile .bindown.yaml --system linux/arm64 jq yq zq
+
+# Copy the installed dependencies into your final stage with:
+#   COPY --from=bindown-bin / /
+FROM scratch AS bindown-bin
+COPY --from=bindown /bindown/bin/jq /usr/local/bin/jq
+COPY --from=bindown /bindown/bin/yq /usr/local/bin/yq
+COPY --from=bindown /bindown/bin/zq /usr/local/bin/zq
+`})
+	})
+
+	t.Run("no tag", func(t *testing.T) {
+		result := runner.run("generate", "dockerfile")
+		result.assertState(resultState{
+			stderr: `cmd: error: --bindown-tag is required`,
+			exit:   1,
+		})
+	})
+}
+
+func Test_generateInstallerCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	system := runtime.GOOS + "/" + runtime.GOARCH
+
+	t.Run("missing checksum", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    systems: [%s]
+`, depURL, system))
+		result := runner.run("generate", "installer", "foo")
+		result.assertState(resultState{
+			stderr: fmt.Sprintf("no checksum configured for foo on %s. run `bindown checksums add foo` first", system),
+			exit:   1,
+		})
+	})
+
+	t.Run("install", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("installer scripts need a posix shell")
+		}
+		_, curlErr := exec.LookPath("curl")
+		_, wgetErr := exec.LookPath("wget")
+		if curlErr != nil && wgetErr != nil {
+			t.Skip("installer scripts need curl or wget")
+		}
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    systems: [%s]
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, system, depURL))
+		script := filepath.Join(runner.tmpDir, "install-foo.sh")
+		result := runner.run("generate", "installer", "foo", "--output", script)
+		result.assertState(resultState{})
+		testutil.AssertFile(t, script, true, false)
+
+		binDir := filepath.Join(runner.tmpDir, "tools")
+		cmd := exec.Command(script, "-b", binDir)
+		cmd.Dir = runner.tmpDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+	})
+}
+
+func Test_generateGoCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+dependencies:
+  golangci-lint:
+    url: https://example.com/golangci-lint
+  jq:
+    url: https://example.com/jq
+`)
+	testInDir(t, runner.tmpDir)
+	result := runner.run("generate", "go", "--output", "tools/tools.go")
+	result.assertState(resultState{})
+	got, err := os.ReadFile(filepath.Join(runner.tmpDir, "tools", "tools.go"))
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by bindown. DO NOT EDIT.
+
+// Package tools locates the tools bindown installs. Run "go generate" in this directory to install them.
+package tools
+
+//go:generate bindown install golangci-lint --configfile ../.bindown.yaml
+//go:generate bindown install jq --configfile ../.bindown.yaml
+
+// Install paths of the tools relative to this file's directory. "go generate" runs in the same directory.
+const (
+	// GolangciLint is where bindown installs golangci-lint.
+	GolangciLint = "../bin/golangci-lint"
+	// Jq is where bindown installs jq.
+	Jq = "../bin/jq"
+)
+`, string(got))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type lockCmd struct {
+	Dependency  []string `kong:"arg,optional,predictor=bin,help='dependencies to lock. default is all dependencies with version latest'"`
+	Prereleases bool     `kong:"help='allow locking to prerelease versions'"`
+	GithubToken string   `kong:"hidden,env='GITHUB_TOKEN'"`
+	GitlabToken string   `kong:"hidden,env='GITLAB_TOKEN'"`
+}
+
+func (c *lockCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	// errors are ignored because a missing lock file is the same as an empty one here
+	before, _ := os.ReadFile(config.LockFilePath())
+	updates, err := config.Lock(ctx, c.Dependency, &bindown.LockOpts{
+		DependencyVersionsOpts: bindown.DependencyVersionsOpts{
+			Prereleases: c.Prereleases,
+			GitHubToken: c.GithubToken,
+			GitLabToken: c.GitlabToken,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	after, err := os.ReadFile(config.LockFilePath())
+	if err != nil {
+		return err
+	}
+	signatures := config.LockSignatures()
+	if len(signatures) > 0 && !bytes.Equal(before, after) {
+		fmt.Fprintf(ctx.stderr, "%s changed, so it needs to be signed again to update %s\n", bindown.LockFileName, strings.Join(signatures, ", "))
+	}
+	if len(updates) == 0 {
+		fmt.Fprintf(ctx.stdout, "%s is up to date\n", bindown.LockFileName)
+		return nil
+	}
+	// markdown list like "dependency update"
+	for _, u := range updates {
+		// pinned dependencies don't have a release to link to
+		links := ""
+		if u.ReleaseURL != "" {
+			links = fmt.Sprintf(" ([release notes](%s)", u.ReleaseURL)
+			if u.CompareURL != "" {
+				links += fmt.Sprintf(", [changes](%s)", u.CompareURL)
+			}
+			links += ")"
+		}
+		if u.OldVersion == "" {
+			fmt.Fprintf(ctx.stdout, "- %s: %s%s\n", u.Name, u.NewVersion, links)
+			continue
+		}
+		fmt.Fprintf(ctx.stdout, "- %s: %s -> %s%s\n", u.Name, u.OldVersion, u.NewVersion, links)
+	}
+	return nil
+}
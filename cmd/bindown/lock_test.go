@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lockCmd(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Ftool/repository/tags":
+			body = `[{"name": "v1.0.0"}, {"name": "v1.1.0"}]`
+		case "/group/tool/-/releases/v1.1.0/downloads/tool":
+			body = "tool 1.1.0"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(body))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+systems: [linux/amd64]
+dependencies:
+  tool:
+    url: %s/group/tool/-/releases/v{{.version}}/downloads/tool
+    vars:
+      version: latest
+`, ts.URL))
+	result := runner.run("dependency", "info", "tool")
+	result.assertState(resultState{
+		stderr: `cmd: error: dependency "tool" tracks the latest version but isn't in bindown.lock. run "bindown lock"`,
+		exit:   2,
+	})
+
+	result = runner.run("lock")
+	result.assertState(resultState{
+		stdout: fmt.Sprintf("- tool: 1.1.0 ([release notes](%s/group/tool/-/releases/v1.1.0))", ts.URL),
+	})
+	dep, err := runner.getConfigFile().BuildDependency("tool", "linux/amd64")
+	require.NoError(t, err)
+	require.Equal(t, "1.1.0", dep.Vars["version"])
+
+	result = runner.run("lock", "tool")
+	result.assertState(resultState{
+		stdout: "bindown.lock is up to date",
+	})
+
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIC0+o99o28/+HBsP8lr+JLQ+tR1qyHTIbJ6mWwcew5We"
+	result = runner.run("install", "tool", "--require-signed-lock", "--lock-public-key", key)
+	result.assertState(resultState{
+		stderr: "cmd: error: bindown.lock isn't signed. sign it with ssh-keygen to create bindown.lock.sig or minisign to create bindown.lock.minisig",
+		exit:   2,
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(runner.tmpDir, "bindown.lock.sig"), []byte("not a signature"), 0o644))
+	result = runner.run("install", "tool", "--require-signed-lock", "--lock-public-key", key)
+	result.assertState(resultState{
+		stderr: "cmd: error: bindown.lock.sig: not an ssh signature",
+		exit:   2,
+	})
+}
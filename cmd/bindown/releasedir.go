@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type buildReleaseDirCmd struct {
+	Dependency           []string       `kong:"arg,optional,name=dependency,help='dependencies to include. default is all dependencies or the ones with --tag',predictor=bin"`
+	System               bindown.System `kong:"name=system,default=${system_default},help='system to build the directory for',predictor=allSystems"`
+	Dest                 string         `kong:"required,type=path,help='directory to build'"`
+	Tag                  []string       `kong:"name=tag,help='include dependencies with this tag'"`
+	AllowMissingChecksum bool           `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+}
+
+func (c *buildReleaseDirCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	return config.BuildReleaseDir(c.Dependency, c.System, c.Dest, &bindown.BuildReleaseDirOpts{
+		Tags:                 c.Tag,
+		Stdout:               ctx.stdout,
+		Color:                ctx.color(),
+		Stderr:               ctx.stderr,
+		AllowMissingChecksum: c.AllowMissingChecksum,
+	})
+}
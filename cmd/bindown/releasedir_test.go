@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_buildReleaseDirCmd(t *testing.T) {
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    tags: [toolbox]
+  bar:
+    url: %s
+    archive_path: foo
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL, depURL))
+	dest := filepath.Join(runner.tmpDir, "dist", "linux-amd64")
+	result := runner.run("build-release-dir", "--system", "linux/amd64", "--dest", dest, "--tag", "toolbox")
+	result.assertState(resultState{stdout: "installed foo to " + filepath.Join(dest, "bin", "foo")})
+	testutil.AssertFile(t, filepath.Join(dest, "bin", "foo"), true, false)
+	require.NoFileExists(t, filepath.Join(dest, "bin", "bar"))
+	require.FileExists(t, filepath.Join(dest, "provenance.json"))
+}
@@ -11,6 +11,7 @@ type supportedSystemCmd struct {
 	List   supportedSystemListCmd    `kong:"cmd,help='list supported systems'"`
 	Add    supportedSystemAddCmd     `kong:"cmd,help='add a supported system'"`
 	Remove supportedSystemsRemoveCmd `kong:"cmd,help='remove a supported system'"`
+	Audit  supportedSystemAuditCmd   `kong:"cmd,help='check that every dependency has a url and checksum for every supported system'"`
 }
 
 type supportedSystemListCmd struct{}
@@ -85,3 +86,24 @@ func (c *supportedSystemAddCmd) Run(ctx *runContext) error {
 	}
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
+
+type supportedSystemAuditCmd struct{}
+
+func (c *supportedSystemAuditCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	gaps, err := cfg.AuditSystems()
+	if err != nil {
+		return err
+	}
+	if len(gaps) == 0 {
+		fmt.Fprintf(ctx.stdout, "all dependencies support %d systems\n", len(cfg.Systems))
+		return nil
+	}
+	for _, gap := range gaps {
+		fmt.Fprintln(ctx.stdout, gap.String())
+	}
+	return foundProblems(len(gaps))
+}
@@ -121,3 +121,68 @@ func Test_supportedSystemAddCmd(t *testing.T) {
 		})
 	}
 }
+
+func Test_supportedSystemAuditCmd(t *testing.T) {
+	for _, td := range []struct {
+		name   string
+		config string
+		state  resultState
+	}{
+		{
+			name: "no gaps",
+			config: `
+systems: [darwin/amd64, linux/amd64]
+dependencies:
+  foo:
+    url: foo-{{ .os }}
+url_checksums:
+  foo-darwin: deadbeef
+  foo-linux: deadbeef
+`,
+			state: resultState{stdout: "all dependencies support 2 systems"},
+		},
+		{
+			name: "gaps",
+			config: `
+systems: [darwin/amd64, linux/amd64, windows/arm64]
+dependencies:
+  bar:
+    template: bar
+  foo:
+    url: foo-{{ .os }}
+url_checksums:
+  bar-darwin: deadbeef
+  bar-linux: deadbeef
+  foo-darwin: deadbeef
+templates:
+  bar:
+    url: bar-{{ .os }}
+    systems: [darwin/amd64, linux/amd64]
+`,
+			state: resultState{
+				stdout: strings.Join([]string{
+					"bar windows/arm64: bar has no windows/arm64 artifact",
+					"foo linux/amd64: no checksum for foo-linux",
+					"foo windows/arm64: no checksum for foo-windows",
+				}, "\n"),
+				stderr: "cmd: error: found 3 problems",
+				exit:   1,
+			},
+		},
+		{
+			name:   "no systems",
+			config: `dependencies: {foo: {url: foo}}`,
+			state: resultState{
+				stderr: "cmd: error: the config has no supported systems to audit",
+				exit:   1,
+			},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			runner := newCmdRunner(t)
+			runner.writeConfigYaml(td.config)
+			result := runner.run("supported-system", "audit")
+			result.assertState(td.state)
+		})
+	}
+}
@@ -12,6 +12,7 @@ type templateCmd struct {
 	Remove           templateRemoveCmd           `kong:"cmd,help='remove a template'"`
 	UpdateFromSource templateUpdateFromSourceCmd `kong:"cmd,help='update a template from source'"`
 	UpdateVars       templateUpdateVarCmd        `kong:"cmd,help='update template vars'"`
+	Lint             templateLintCmd             `kong:"cmd,help='check templates for vars and substitutions that are never used or never set'"`
 }
 
 type templateUpdateVarCmd struct {
@@ -90,6 +91,29 @@ func (c *templateListCmd) Run(ctx *runContext) error {
 	return nil
 }
 
+type templateLintCmd struct {
+	Template []string `kong:"arg,optional,help='templates to check. default is all templates',predictor=localTemplate"`
+	Source   string   `kong:"help='check the templates in this template source instead of the config',predictor=templateSource"`
+}
+
+func (c *templateLintCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	problems, err := cfg.LintTemplates(ctx, &bindown.LintTemplatesOpts{
+		Source:    c.Source,
+		Templates: c.Template,
+	})
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(ctx.stdout, problem.String())
+	}
+	return foundProblems(len(problems))
+}
+
 type templateRemoveCmd struct {
 	Template string `kong:"arg,predictor=localTemplate"`
 }
@@ -109,3 +133,41 @@ func (c *templateRemoveCmd) Run(ctx *runContext) error {
 	delete(cfg.Templates, c.Template)
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
+
+type searchCmd struct {
+	Query  string   `kong:"arg,help='text to find in template names and descriptions'"`
+	Source []string `kong:"name=source,help='only search these template sources. use \"builtin\" for the templates that ship with bindown',predictor=templateSource"`
+}
+
+func (c *searchCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	results, err := config.SearchTemplates(ctx, c.Query, &bindown.SearchTemplatesOpts{
+		Sources: c.Source,
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no templates match %q", c.Query)
+	}
+	names := make([]string, len(results))
+	width := 0
+	for i, result := range results {
+		names[i] = result.Name
+		if result.Source != "" {
+			names[i] = result.Source + "#" + result.Name
+		}
+		width = max(width, len(names[i]))
+	}
+	for i, result := range results {
+		if result.Description == "" {
+			fmt.Fprintln(ctx.stdout, names[i])
+			continue
+		}
+		fmt.Fprintf(ctx.stdout, "%-*s  %s\n", width, names[i], result.Description)
+	}
+	return nil
+}
@@ -122,6 +122,29 @@ templates:
 	})
 }
 
+func Test_templateLintCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+templates:
+  good:
+    url: foo-{{ .os }}-{{ .version }}
+    required_vars: [version]
+  bad:
+    url: foo-{{ .os }}-{{ .verison }}
+    required_vars: [version]
+`)
+	result := runner.run("template", "lint", "good")
+	result.assertState(resultState{})
+
+	result = runner.run("template", "lint")
+	result.assertState(resultState{
+		stdout: `/templates/bad/url: undefined var "verison"
+/templates/bad/required_vars/0: required var "version" is never used`,
+		stderr: `cmd: error: found 2 problems`,
+		exit:   1,
+	})
+}
+
 func Test_templateListCmd(t *testing.T) {
 	remoteConfig := `
 systems: ["linux/amd64", "darwin/amd64"]
@@ -233,3 +256,24 @@ templates:
 		require.Equal(t, 0, len(configFile.Templates))
 	})
 }
+
+func Test_searchCmd(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`{}`)
+		result := runner.run("search", "shellcheck")
+		result.assertState(resultState{
+			stdout: "builtin#shellcheck  A static analysis tool for shell scripts",
+		})
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		runner := newCmdRunner(t)
+		runner.writeConfigYaml(`{}`)
+		result := runner.run("search", "nothing-is-called-this")
+		result.assertState(resultState{
+			stderr: `cmd: error: no templates match "nothing-is-called-this"`,
+			exit:   1,
+		})
+	})
+}
@@ -12,6 +12,9 @@ type templateSourceCmd struct {
 	List   templateSourceListCmd   `kong:"cmd,help='list configured template sources'"`
 	Add    templateSourceAddCmd    `kong:"cmd,help='add a template source'"`
 	Remove templateSourceRemoveCmd `kong:"cmd,help='remove a template source'"`
+	Pin    templateSourcePinCmd    `kong:"cmd,help='record the digest of a template source so templates are only copied from it while its content is unchanged'"`
+	Export templateSourceExportCmd `kong:"cmd,help='write a template source to a local file that templates are copied from instead of the source'"`
+	Sync   templateSourceSyncCmd   `kong:"cmd,help='refresh the local files written by template-source export from their sources'"`
 }
 
 type templateSourceListCmd struct{}
@@ -25,14 +28,21 @@ func (c *templateSourceListCmd) Run(ctx *runContext) error {
 	sourceNames := bindown.MapKeys(cfg.TemplateSources)
 	slices.Sort(sourceNames)
 	for _, name := range sourceNames {
-		fmt.Fprintln(w, name+"\t"+cfg.TemplateSources[name])
+		line := name + "\t" + cfg.TemplateSources[name]
+		if digest := cfg.TemplateSourceDigests[name]; digest != "" {
+			line += "\t" + digest
+		}
+		fmt.Fprintln(w, line)
 	}
 	return w.Flush()
 }
 
 type templateSourceAddCmd struct {
-	Name   string `kong:"arg"`
-	Source string `kong:"arg"`
+	Name        string `kong:"arg"`
+	Source      string `kong:"arg"`
+	Pin         bool   `kong:"help='record the digest of the source like template-source pin does'"`
+	Commit      bool   `kong:"help=${template_source_pin_commit_help}"`
+	GithubToken string `kong:"hidden,env='GITHUB_TOKEN'"`
 }
 
 func (c *templateSourceAddCmd) Run(ctx *runContext) error {
@@ -48,6 +58,15 @@ func (c *templateSourceAddCmd) Run(ctx *runContext) error {
 		return fmt.Errorf("template source already exists")
 	}
 	cfg.TemplateSources[c.Name] = c.Source
+	if c.Pin || c.Commit {
+		_, err = cfg.PinTemplateSource(ctx, c.Name, &bindown.PinTemplateSourceOpts{
+			Commit:      c.Commit,
+			GitHubToken: c.GithubToken,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
 
@@ -68,5 +87,83 @@ func (c *templateSourceRemoveCmd) Run(ctx *runContext) error {
 		return fmt.Errorf("no template source named %q", c.Name)
 	}
 	delete(cfg.TemplateSources, c.Name)
+	delete(cfg.TemplateSourceDigests, c.Name)
+	delete(cfg.TemplateSourceMirrors, c.Name)
 	return cfg.WriteFile(ctx.rootCmd.JSONConfig)
 }
+
+type templateSourcePinCmd struct {
+	Name        string `kong:"arg,predictor=templateSource"`
+	Commit      bool   `kong:"help=${template_source_pin_commit_help}"`
+	GithubToken string `kong:"hidden,env='GITHUB_TOKEN'"`
+}
+
+func (c *templateSourcePinCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	digest, err := cfg.PinTemplateSource(ctx, c.Name, &bindown.PinTemplateSourceOpts{
+		Commit:      c.Commit,
+		GitHubToken: c.GithubToken,
+	})
+	if err != nil {
+		return err
+	}
+	err = cfg.WriteFile(ctx.rootCmd.JSONConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "pinned %s to %s\n", c.Name, digest)
+	return nil
+}
+
+type templateSourceExportCmd struct {
+	Source string `kong:"arg,predictor=templateSource"`
+	File   string `kong:"arg,type=path,help='file to write. commit it with the config'"`
+}
+
+func (c *templateSourceExportCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	err = cfg.ExportTemplateSource(ctx, c.Source, c.File)
+	if err != nil {
+		return err
+	}
+	err = cfg.WriteFile(ctx.rootCmd.JSONConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "exported %s to %s\n", c.Source, cfg.TemplateSourceMirrors[c.Source])
+	return nil
+}
+
+type templateSourceSyncCmd struct {
+	Source []string `kong:"arg,optional,predictor=templateSource,help='template sources to sync. default is every exported template source'"`
+}
+
+func (c *templateSourceSyncCmd) Run(ctx *runContext) error {
+	cfg, err := loadConfigFile(ctx, true)
+	if err != nil {
+		return err
+	}
+	sources := c.Source
+	if len(sources) == 0 {
+		sources = bindown.MapKeys(cfg.TemplateSourceMirrors)
+		slices.Sort(sources)
+	}
+	for _, name := range sources {
+		changed, err := cfg.SyncTemplateSource(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Fprintf(ctx.stdout, "%s is up to date\n", cfg.TemplateSourceMirrors[name])
+			continue
+		}
+		fmt.Fprintf(ctx.stdout, "updated %s from %s\n", cfg.TemplateSourceMirrors[name], name)
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -123,3 +127,60 @@ func Test_templateSourceRemoveCmd(t *testing.T) {
 		})
 	}
 }
+
+func Test_templateSourcePinCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	srcFile := filepath.Join(runner.tmpDir, "template-source.yaml")
+	srcContent := []byte("templates:\n  foo:\n    url: https://example.com/foo\n")
+	require.NoError(t, os.WriteFile(srcFile, srcContent, 0o600))
+	sum := sha256.Sum256(srcContent)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	runner.writeConfigYaml(`template_sources: {origin: ` + srcFile + `}`)
+
+	result := runner.run("template-source", "pin", "origin")
+	result.assertState(resultState{stdout: "pinned origin to " + digest})
+	require.Equal(t, map[string]string{"origin": digest}, runner.getConfigFile().TemplateSourceDigests)
+
+	result = runner.run("template-source", "list")
+	result.assertState(resultState{stdout: "origin " + srcFile + " " + digest})
+
+	require.NoError(t, os.WriteFile(srcFile, append(srcContent, "# changed\n"...), 0o600))
+	result = runner.run("dependency", "add", "foo", "foo", "--source", "origin")
+	result.assertState(resultState{
+		stderr: `template source "origin" has changed`,
+		exit:   2,
+	})
+
+	result = runner.run("template-source", "remove", "origin")
+	result.assertState(resultState{})
+	require.Empty(t, runner.getConfigFile().TemplateSourceDigests)
+}
+
+func Test_templateSourceExportCmd(t *testing.T) {
+	runner := newCmdRunner(t)
+	srcFile := filepath.Join(t.TempDir(), "template-source.yaml")
+	srcContent := []byte("templates:\n  foo:\n    url: https://example.com/foo\n")
+	require.NoError(t, os.WriteFile(srcFile, srcContent, 0o600))
+	runner.writeConfigYaml(`template_sources: {origin: ` + srcFile + `}`)
+	mirror := filepath.Join(runner.tmpDir, "templates", "origin.yaml")
+
+	result := runner.run("template-source", "export", "origin", mirror)
+	result.assertState(resultState{stdout: "exported origin to templates/origin.yaml"})
+	require.Equal(t, map[string]string{"origin": "templates/origin.yaml"}, runner.getConfigFile().TemplateSourceMirrors)
+	got, err := os.ReadFile(mirror)
+	require.NoError(t, err)
+	require.Equal(t, srcContent, got)
+
+	result = runner.run("template-source", "sync")
+	result.assertState(resultState{stdout: "templates/origin.yaml is up to date"})
+
+	require.NoError(t, os.WriteFile(srcFile, append(srcContent, "# changed\n"...), 0o600))
+	result = runner.run("template-source", "sync")
+	result.assertState(resultState{stdout: "updated templates/origin.yaml from origin"})
+
+	// the mirror is used when the source is gone
+	require.NoError(t, os.Remove(srcFile))
+	result = runner.run("dependency", "add", "foo", "foo", "--source", "origin", "--skipchecksums")
+	result.assertState(resultState{stdout: `Adding dependency "foo" from template origin#foo`})
+	require.NotNil(t, runner.getConfigFile().Dependencies["foo"])
+}
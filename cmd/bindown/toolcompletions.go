@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+type completionCmd struct {
+	Tools completionToolsCmd `kong:"cmd,help=${completion_tools_help}"`
+}
+
+type completionToolsCmd struct {
+	Dependency           []string `kong:"arg,optional,name=dependency,help='dependencies to install completions for. default is all dependencies with a completion_command',predictor=bin"`
+	Shell                string   `kong:"name=shell,help=${completion_shell_help}"`
+	Dir                  string   `kong:"name=dir,type=path,help=${completion_dir_help}"`
+	AllowMissingChecksum bool     `kong:"name=allow-missing-checksum,help=${allow_missing_checksum}"`
+}
+
+func (c *completionToolsCmd) Run(ctx *runContext) error {
+	config, err := loadConfigFile(ctx, false)
+	if err != nil {
+		return err
+	}
+	shell := c.Shell
+	if shell == "" {
+		if os.Getenv("SHELL") == "" {
+			return fmt.Errorf("SHELL isn't set. use --shell")
+		}
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+	dir := c.Dir
+	if dir == "" {
+		dir, err = defaultCompletionsDir(shell)
+		if err != nil {
+			return err
+		}
+	}
+	return config.InstallToolCompletions(c.Dependency, &bindown.InstallToolCompletionsOpts{
+		Shell:                shell,
+		Dir:                  dir,
+		Stdout:               ctx.stdout,
+		Color:                ctx.color(),
+		Stderr:               ctx.stderr,
+		AllowMissingChecksum: c.AllowMissingChecksum,
+	})
+}
+
+// defaultCompletionsDir returns the per-user directory shell loads completion scripts from without any setup. zsh only
+// loads them from directories in fpath, so it has no default.
+func defaultCompletionsDir(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch shell {
+	case "bash":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "bash-completion", "completions"), nil
+	case "fish":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "fish", "completions"), nil
+	default:
+		return "", fmt.Errorf("there is no default completions directory for %s. use --dir with a directory in fpath", shell)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_completionToolsCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell command")
+	}
+	servePath := testdataPath("downloadables/fooinroot.tar.gz")
+	server := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := server.URL + "/foo/fooinroot.tar.gz"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(fmt.Sprintf(`
+dependencies:
+  foo:
+    url: %s
+    completion_command: [sh, -c, "echo complete {{ .shell }}"]
+url_checksums:
+  %s: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, depURL, depURL))
+	dir := filepath.Join(runner.tmpDir, "completions")
+
+	t.Run("shell from SHELL", func(t *testing.T) {
+		t.Setenv("SHELL", "/usr/local/bin/fish")
+		result := runner.run("completion", "tools", "--dir", dir)
+		result.assertState(resultState{stdout: "installed foo completions to " + filepath.Join(dir, "foo.fish")})
+		got, err := os.ReadFile(filepath.Join(dir, "foo.fish"))
+		require.NoError(t, err)
+		require.Equal(t, "complete fish\n", string(got))
+	})
+
+	t.Run("zsh needs dir", func(t *testing.T) {
+		result := runner.run("completion", "tools", "--shell", "zsh")
+		result.assertState(resultState{
+			stderr: `there is no default completions directory for zsh. use --dir with a directory in fpath`,
+			exit:   1,
+		})
+	})
+}
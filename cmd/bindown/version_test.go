@@ -9,3 +9,18 @@ func Test_versionCmd(t *testing.T) {
 		stdout: "bindown: version unknown",
 	})
 }
+
+func Test_minBindownVersion(t *testing.T) {
+	orig := Version
+	t.Cleanup(func() { Version = orig })
+	Version = "4.1.0"
+	runner := newCmdRunner(t)
+	runner.writeConfigYaml(`
+min_bindown_version: 4.2.0
+`)
+	result := runner.run("dependency", "list")
+	result.assertState(resultState{
+		stderr: "cmd: error: this config requires a newer bindown. please upgrade bindown to >= 4.2.0 (this is 4.1.0)",
+		exit:   exitConfigError,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+// watchInterval is how often watchConfig checks the config file for changes.
+var watchInterval = time.Second
+
+// watchConfig runs fn and then runs it again each time the content of filename or the files in its
+// bindown.ConfigFragmentDir changes until ctx is done. Errors from fn are written to stderr instead of ending the watch
+// so a bad edit can be fixed without restarting.
+func watchConfig(ctx context.Context, filename string, stderr io.Writer, fn func() error) error {
+	run := func() []byte {
+		err := fn()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+		}
+		// read after fn so changes fn makes, like adding checksums, don't trigger another run
+		content, err := configContent(filename)
+		if err != nil {
+			return nil
+		}
+		return content
+	}
+	last := run()
+	fmt.Fprintf(stderr, "watching %s for changes\n", filename)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		content, err := configContent(filename)
+		// editors often replace the file on save, so a missing file is treated as unchanged until it is back
+		if err != nil || bytes.Equal(content, last) {
+			continue
+		}
+		fmt.Fprintf(stderr, "%s changed\n", filename)
+		last = run()
+	}
+}
+
+// configContent returns the content of filename followed by the names and content of the files in its
+// bindown.ConfigFragmentDir.
+func configContent(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fragments, err := bindown.ConfigFragments(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, fragment := range fragments {
+		data, err := os.ReadFile(fragment)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, fragment...)
+		content = append(content, data...)
+	}
+	return content, nil
+}
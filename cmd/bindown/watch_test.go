@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_watchConfig(t *testing.T) {
+	origInterval := watchInterval
+	watchInterval = 10 * time.Millisecond
+	t.Cleanup(func() { watchInterval = origInterval })
+
+	filename := filepath.Join(t.TempDir(), "bindown.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte("v1"), 0o600))
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan string, 10)
+	var stderr bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := watchConfig(ctx, filename, &stderr, func() error {
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			runs <- string(content)
+			if string(content) == "bad" {
+				return errors.New("bad config")
+			}
+			// changes made by fn itself don't trigger another run
+			return os.WriteFile(filename, append(content, '!'), 0o600)
+		})
+		require.NoError(t, err)
+	}()
+	waitRun := func() string {
+		t.Helper()
+		select {
+		case got := <-runs:
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for run")
+			return ""
+		}
+	}
+
+	require.Equal(t, "v1", waitRun())
+	require.NoError(t, os.WriteFile(filename, []byte("bad"), 0o600))
+	require.Equal(t, "bad", waitRun())
+	require.NoError(t, os.WriteFile(filename, []byte("v2"), 0o600))
+	require.Equal(t, "v2", waitRun())
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	wg.Wait()
+	require.Empty(t, runs)
+	require.Equal(t, "watching "+filename+" for changes\n"+
+		filename+" changed\n"+
+		"error: bad config\n"+
+		filename+" changed\n", stderr.String())
+}
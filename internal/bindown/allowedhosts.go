@@ -0,0 +1,66 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// checkAllowedHost returns an error when the host of the url in field isn't in the config's AllowedHosts. Any host is
+// allowed when AllowedHosts is empty. s3:// and gs:// urls name a bucket instead of a host, so they are checked when
+// they are presigned.
+func (c *Config) checkAllowedHost(depName, field, rawURL string) error {
+	if len(c.AllowedHosts) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "s3" || u.Scheme == "gs" {
+		return nil
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%s %s for %q has no host, so it can't match allowed_hosts", field, rawURL, depName)
+	}
+	if !allowedHost(c.AllowedHosts, u.Hostname()) {
+		return fmt.Errorf("%s host %q for %q is not in allowed_hosts", field, u.Hostname(), depName)
+	}
+	return nil
+}
+
+// allowedHost reports whether host matches one of allowedHosts. Any host is allowed when allowedHosts is empty.
+func allowedHost(allowedHosts []string, host string) bool {
+	if len(allowedHosts) == 0 {
+		return true
+	}
+	for _, allowed := range allowedHosts {
+		if hostMatches(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequestHost returns an error when req, the request made to download dlURL, goes to a host that isn't in
+// allowed_hosts. Presigned urls and release asset api requests can go to a different host than dlURL.
+func (dl *downloader) checkRequestHost(dlURL string, req *http.Request) error {
+	if dl == nil || allowedHost(dl.allowedHosts, req.URL.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("%s is downloaded from host %q, which is not in allowed_hosts", redactURL(dlURL), req.URL.Hostname())
+}
+
+// checkRedirect is the CheckRedirect of dl's http clients. Every redirect has to go to a host in allowed_hosts, or an
+// allowed host could send the download anywhere.
+func (dl *downloader) checkRedirect(req *http.Request, via []*http.Request) error {
+	// the same limit as the default policy
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if dl == nil || allowedHost(dl.allowedHosts, req.URL.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("%s redirected to host %q, which is not in allowed_hosts", redactURL(via[0].URL.String()), req.URL.Hostname())
+}
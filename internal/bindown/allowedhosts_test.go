@@ -0,0 +1,107 @@
+package bindown
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_AllowedHosts(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+allowed_hosts:
+  - github.com
+  - "*.example.com"
+dependencies:
+  foo:
+    url: https://github.com/foo/foo/releases/download/v1/foo-{{.os}}
+    overrides:
+      - matcher:
+          os: [windows]
+        dependency:
+          url: https://evil.test/foo.exe
+  bar:
+    url: https://dl.EXAMPLE.com/bar-{{.os}}
+    zsync_url: https://example.com/bar.zsync
+  local:
+    url: /tmp/local
+`)
+	_, err := cfg.BuildDependency("foo", "linux/amd64")
+	require.NoError(t, err)
+
+	_, err = cfg.BuildDependency("foo", "windows/amd64")
+	require.EqualError(t, err, `url host "evil.test" for "foo" is not in allowed_hosts`)
+	require.IsType(t, &ConfigError{}, err)
+
+	_, err = cfg.BuildDependency("bar", "linux/amd64")
+	require.EqualError(t, err, `zsync_url host "example.com" for "bar" is not in allowed_hosts`)
+
+	_, err = cfg.BuildDependency("local", "linux/amd64")
+	require.EqualError(t, err, `url /tmp/local for "local" has no host, so it can't match allowed_hosts`)
+
+	cfg.AllowedHosts = nil
+	_, err = cfg.BuildDependency("foo", "windows/amd64")
+	require.NoError(t, err)
+}
+
+func TestConfig_AllowedHosts_request(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	cfg := mustConfigFromYAML(t, `
+allowed_hosts:
+  - example.com
+dependencies:
+  signed:
+    url: s3://bucket/foo
+    presign_command: [echo, "https://evil.test/foo?X-Amz-Signature=secret"]
+  good:
+    url: s3://bucket/foo
+    presign_command: [echo, "https://example.com/foo"]
+`)
+	dep, err := cfg.BuildDependency("signed", "linux/amd64")
+	require.NoError(t, err)
+	_, err = dep.downloader.newRequest(context.Background(), http.MethodGet, dep.url)
+	require.EqualError(t, err, `s3://bucket/foo is downloaded from host "evil.test", which is not in allowed_hosts`)
+
+	dep, err = cfg.BuildDependency("good", "linux/amd64")
+	require.NoError(t, err)
+	_, err = dep.downloader.newRequest(context.Background(), http.MethodGet, dep.url)
+	require.NoError(t, err)
+
+	cfg.DownloadCommand = []string{"curl", "-o", "{{.output}}", "{{.url}}"}
+	_, err = cfg.BuildDependency("good", "linux/amd64")
+	require.EqualError(t, err, "download_command can't be used with allowed_hosts because the hosts it downloads from can't be checked")
+}
+
+func TestConfig_AllowedHosts_redirect(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/allowed":
+			http.Redirect(w, r, "/foo", http.StatusFound)
+		case "/elsewhere":
+			// localhost is the same server under a host that isn't allowed
+			u, err := url.Parse(ts.URL)
+			require.NoError(t, err)
+			http.Redirect(w, r, "http://localhost:"+u.Port()+"/foo", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte("foo"))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	dl := &downloader{allowedHosts: []string{"127.0.0.1"}}
+
+	resp, err := dl.httpClient().Get(ts.URL + "/allowed")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = dl.httpClient().Get(ts.URL + "/elsewhere?token=secret")
+	require.ErrorContains(t, err, `redirected to host "localhost", which is not in allowed_hosts`)
+	require.NotContains(t, err.Error(), "secret")
+}
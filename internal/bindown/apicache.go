@@ -0,0 +1,71 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/willabides/bindown/v4/internal/cache"
+)
+
+// defaultAPICacheTTL is how long api responses are cached when api_cache_ttl isn't set.
+const defaultAPICacheTTL = 10 * time.Minute
+
+const apiResponseFile = "response.json"
+
+// apiCache caches api responses in the bindown cache for a limited time. A nil apiCache doesn't cache.
+type apiCache struct {
+	cache *cache.Cache
+	ttl   time.Duration
+}
+
+// apiCache returns the cache for api responses. It returns nil when caching is disabled.
+func (c *Config) apiCache() (*apiCache, error) {
+	ttl := defaultAPICacheTTL
+	if c.APICacheTTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(c.APICacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api_cache_ttl: %w", err)
+		}
+	}
+	if ttl <= 0 || c.Cache == "" {
+		return nil, nil
+	}
+	return &apiCache{
+		cache: &cache.Cache{Root: filepath.Join(c.Cache, "api")},
+		ttl:   ttl,
+	}, nil
+}
+
+// get returns the cached response for key when it is younger than the ttl. Otherwise, it calls fetch and caches the
+// result. Failed fetches aren't cached.
+func (a *apiCache) get(key string, fetch func() ([]byte, error)) (_ []byte, errOut error) {
+	if a == nil {
+		return fetch()
+	}
+	validate := func(dir string) error {
+		info, err := os.Stat(filepath.Join(dir, apiResponseFile))
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) > a.ttl {
+			return fmt.Errorf("cached response is older than %s", a.ttl)
+		}
+		return nil
+	}
+	populate := func(dir string) error {
+		data, err := fetch()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, apiResponseFile), data, 0o644)
+	}
+	dir, unlock, err := a.cache.Dir(cacheKey(key), validate, populate)
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, unlock)
+	return os.ReadFile(filepath.Join(dir, apiResponseFile))
+}
@@ -0,0 +1,81 @@
+package bindown
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func isAppImage(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".appimage")
+}
+
+// unsquashfsCommand returns the command that extracts the squashfs image starting at offset in appImagePath to dir.
+var unsquashfsCommand = func(appImagePath string, offset int64, dir string) *exec.Cmd {
+	return exec.Command("unsquashfs", "-no-xattrs", "-o", strconv.FormatInt(offset, 10), "-d", dir, appImagePath)
+}
+
+// extractAppImage extracts the squashfs image embedded in the AppImage at appImagePath to extractDir. The AppImage
+// isn't run, so it works for AppImages built for other architectures.
+func extractAppImage(appImagePath, extractDir string) error {
+	offset, err := appImageOffset(appImagePath)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(extractDir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(extractDir), 0o750)
+	if err != nil {
+		return err
+	}
+	cmd := unsquashfsCommand(appImagePath, offset, extractDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("extracting %s requires %s from squashfs-tools", filepath.Base(appImagePath), filepath.Base(cmd.Path))
+	}
+	return fmt.Errorf("failed extracting %s with %s: %w\n%s", filepath.Base(appImagePath), filepath.Base(cmd.Path), err, out)
+}
+
+// appImageOffset returns where the squashfs image starts in an AppImage. The AppImage runtime is an ELF executable,
+// and the image is appended right after its section header table.
+func appImageOffset(appImagePath string) (_ int64, errOut error) {
+	file, err := os.Open(appImagePath)
+	if err != nil {
+		return 0, err
+	}
+	defer deferErr(&errOut, file.Close)
+	header := make([]byte, 64)
+	_, err = io.ReadFull(file, header)
+	if err != nil || string(header[:4]) != "\x7fELF" {
+		return 0, fmt.Errorf("%s is not an AppImage", filepath.Base(appImagePath))
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[5] == 2 {
+		order = binary.BigEndian
+	}
+	var shoff, shentsize, shnum int64
+	switch header[4] {
+	case 1:
+		shoff = int64(order.Uint32(header[0x20:]))
+		shentsize = int64(order.Uint16(header[0x2e:]))
+		shnum = int64(order.Uint16(header[0x30:]))
+	case 2:
+		shoff = int64(order.Uint64(header[0x28:]))
+		shentsize = int64(order.Uint16(header[0x3a:]))
+		shnum = int64(order.Uint16(header[0x3c:]))
+	default:
+		return 0, fmt.Errorf("%s is not an AppImage", filepath.Base(appImagePath))
+	}
+	return shoff + shentsize*shnum, nil
+}
@@ -0,0 +1,97 @@
+package bindown
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+// fakeAppImage returns an ELF64 header with a section header table ending at 192 followed by "squashfs".
+func fakeAppImage() []byte {
+	data := make([]byte, 192)
+	copy(data, "\x7fELF\x02\x01\x01")
+	binary.LittleEndian.PutUint64(data[0x28:], 64)
+	binary.LittleEndian.PutUint16(data[0x3a:], 64)
+	binary.LittleEndian.PutUint16(data[0x3c:], 2)
+	return append(data, "squashfs"...)
+}
+
+func Test_appImageOffset(t *testing.T) {
+	dir := t.TempDir()
+	appImage := filepath.Join(dir, "tool.AppImage")
+	require.NoError(t, os.WriteFile(appImage, fakeAppImage(), 0o644))
+	offset, err := appImageOffset(appImage)
+	require.NoError(t, err)
+	require.Equal(t, int64(192), offset)
+
+	require.NoError(t, os.WriteFile(appImage, []byte("#!/bin/sh\n"), 0o644))
+	_, err = appImageOffset(appImage)
+	require.EqualError(t, err, "tool.AppImage is not an AppImage")
+}
+
+func TestConfig_InstallDependencies_appImage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	content := fakeAppImage()
+	appImage := filepath.Join(dir, "tool-1.0-x86_64.AppImage")
+	require.NoError(t, os.WriteFile(appImage, content, 0o644))
+	sum, err := fileChecksum(appImage)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, appImage, "/tool-1.0-x86_64.AppImage", "")
+	depURL := ts.URL + "/tool-1.0-x86_64.AppImage"
+	binDir := filepath.Join(dir, "bin")
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+dependencies:
+  tool:
+    url: %q
+url_checksums:
+  %q: %s
+`, binDir, filepath.Join(dir, "cache"), depURL, depURL, sum))
+
+	t.Run("install as is", func(t *testing.T) {
+		err := config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		require.NoError(t, err)
+		require.Equal(t, content, got)
+		info, err := os.Stat(filepath.Join(binDir, "tool"))
+		require.NoError(t, err)
+		require.NotZero(t, info.Mode()&0o100)
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		var gotOffset int64
+		orig := unsquashfsCommand
+		t.Cleanup(func() { unsquashfsCommand = orig })
+		unsquashfsCommand = func(_ string, offset int64, dir string) *exec.Cmd {
+			gotOffset = offset
+			return exec.Command("sh", "-c", `mkdir -p "$1/usr/bin" && echo inner > "$1/usr/bin/tool"`, "sh", dir)
+		}
+		dep := config.Dependencies["tool"]
+		dep.ExtractAppImage = ptr(true)
+		dep.ArchivePath = ptr("usr/bin/tool")
+		err := config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.NoError(t, err)
+		require.Equal(t, int64(192), gotOffset)
+		got, err := os.ReadFile(filepath.Join(binDir, "tool"))
+		require.NoError(t, err)
+		require.Equal(t, "inner\n", string(got))
+
+		unsquashfsCommand = func(appImagePath string, _ int64, dir string) *exec.Cmd {
+			return exec.Command("bindown-unsquashfs-does-not-exist", dir, appImagePath)
+		}
+		err = config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.EqualError(t, err, "extracting tool-1.0-x86_64.AppImage requires bindown-unsquashfs-does-not-exist from squashfs-tools")
+	})
+}
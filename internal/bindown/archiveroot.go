@@ -0,0 +1,74 @@
+package bindown
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// extractedBin returns the path of the dependency's bin in extractDir. When archive_path isn't set and there is nothing
+// at the default path, the bin is detected with detectBin.
+func (d *Dependency) extractedBin(extractDir string) string {
+	bin := d.caseInsensitiveBin(extractDir)
+	if d.ArchivePath != nil || d.archivePath() != d.binName() {
+		return bin
+	}
+	if _, err := os.Lstat(bin); err == nil {
+		return bin
+	}
+	if detected := d.detectBin(extractDir); detected != "" {
+		return detected
+	}
+	return bin
+}
+
+// detectBin looks for the most likely bin in extractDir for a dependency without archive_path. That is the only file
+// named like the bin, or else the only executable file. It returns "" when there is no such file.
+func (d *Dependency) detectBin(extractDir string) string {
+	windows := d.system.OS() == "windows"
+	var named, executables []string
+	err := filepath.WalkDir(extractDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		name := entry.Name()
+		if windows {
+			name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+		}
+		if strings.EqualFold(name, d.binName()) {
+			named = append(named, path)
+		}
+		if windows {
+			if strings.EqualFold(filepath.Ext(entry.Name()), ".exe") {
+				executables = append(executables, path)
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm()&0o111 != 0 {
+			executables = append(executables, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return ""
+	}
+	if len(named) > 1 {
+		// prefer the executable one when several files are named like the bin
+		named = slices.DeleteFunc(named, func(path string) bool {
+			return !slices.Contains(executables, path)
+		})
+	}
+	switch {
+	case len(named) == 1:
+		return named[0]
+	case len(named) == 0 && len(executables) == 1:
+		return executables[0]
+	}
+	return ""
+}
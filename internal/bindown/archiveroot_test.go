@@ -0,0 +1,82 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_InstallDependencies_detectBin(t *testing.T) {
+	ts := testutil.ServeFile(t, filepath.Join("testdata", "downloadables", "runnable.tar.gz"), "/runnable.tar.gz", "")
+	dir := t.TempDir()
+	cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+install_dir: %q
+dependencies:
+  runnable:
+    url: %s/runnable.tar.gz
+`, filepath.Join(dir, "cache"), filepath.Join(dir, "bin"), ts.URL))
+	opts := &ConfigInstallDependenciesOpts{AllowMissingChecksum: true}
+	err := cfg.InstallDependencies([]string{"runnable"}, "linux/amd64", opts)
+	require.NoError(t, err)
+	testutil.AssertFile(t, filepath.Join(cfg.InstallDir, "runnable"), true, false)
+}
+
+func TestDependency_detectBin(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]os.FileMode) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, mode := range files {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+			require.NoError(t, os.WriteFile(file, []byte(name), mode))
+		}
+		return dir
+	}
+	dep := &Dependency{built: true, name: "foo", system: "linux/amd64"}
+
+	for _, td := range []struct {
+		name  string
+		files map[string]os.FileMode
+		want  string
+	}{
+		{
+			name:  "named",
+			files: map[string]os.FileMode{"foo-1.0/foo": 0o644, "foo-1.0/tool": 0o755},
+			want:  "foo-1.0/foo",
+		},
+		{
+			name:  "executable named",
+			files: map[string]os.FileMode{"foo-1.0/foo": 0o755, "docs/FOO": 0o644},
+			want:  "foo-1.0/foo",
+		},
+		{
+			name:  "single executable",
+			files: map[string]os.FileMode{"dist/foo-cli": 0o755, "README.md": 0o644},
+			want:  "dist/foo-cli",
+		},
+		{
+			name:  "several executables",
+			files: map[string]os.FileMode{"foo-cli": 0o755, "foo-server": 0o755},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			dir := writeFiles(t, td.files)
+			want := ""
+			if td.want != "" {
+				want = filepath.Join(dir, filepath.FromSlash(td.want))
+			}
+			require.Equal(t, want, dep.detectBin(dir))
+		})
+	}
+
+	t.Run("windows", func(t *testing.T) {
+		dir := writeFiles(t, map[string]os.FileMode{"bin/Foo.exe": 0o644, "bin/foo.dll": 0o644})
+		windowsDep := &Dependency{built: true, name: "foo", system: "windows/amd64"}
+		require.Equal(t, filepath.Join(dir, "bin", "Foo.exe"), windowsDep.detectBin(dir))
+	})
+}
@@ -0,0 +1,51 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// AttestationConfig describes the GitHub artifact attestation a dependency's downloads must have.
+type AttestationConfig struct {
+	// The repository that built the release assets in the form owner/repo. It may use the dependency's vars.
+	Repository string `json:"repository" yaml:"repository"`
+
+	// The workflow that must have signed the attestation in the form owner/repo/.github/workflows/release.yml. It may
+	// use the dependency's vars. Default is any workflow in repository.
+	SignerWorkflow string `json:"signer_workflow,omitempty" yaml:"signer_workflow,omitempty"`
+}
+
+// verifyAttestation verifies the build provenance attestation for filename with the gh cli. It does nothing when dep
+// doesn't configure an attestation.
+func verifyAttestation(dep *Dependency, filename string) error {
+	dep.mustBeBuilt()
+	if dep.Attestation == nil {
+		return nil
+	}
+	if dep.Attestation.Repository == "" {
+		return fmt.Errorf("attestation for %s has no repository", dep.name)
+	}
+	ghPath, err := exec.LookPath("gh")
+	if err != nil {
+		return fmt.Errorf("verifying attestations for %s requires the gh cli: %w", dep.name, err)
+	}
+	args := []string{"attestation", "verify", filename, "--repo", dep.Attestation.Repository}
+	if dep.Attestation.SignerWorkflow != "" {
+		args = append(args, "--signer-workflow", dep.Attestation.SignerWorkflow)
+	}
+	if hostname := dep.downloader.attestationHostname(dep.url); hostname != "" {
+		args = append(args, "--hostname", hostname)
+	}
+	ctx, cancel := dep.downloader.context()
+	defer cancel()
+	output, err := exec.CommandContext(ctx, ghPath, args...).CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("attestation verification failed for %s: %w\n%s", dep.url, err, output)
+		}
+		return err
+	}
+	return nil
+}
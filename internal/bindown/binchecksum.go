@@ -0,0 +1,150 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// binChecksumKey is the dependency's key in bin_checksums. It is the url_checksums key and the archive path
+// separated by "#".
+func (d *Dependency) binChecksumKey() string {
+	d.mustBeBuilt()
+	return d.checksumKey + "#" + d.archivePath()
+}
+
+// verifyBinChecksum returns a *ChecksumMismatchError when dep has a bin checksum that extractBin doesn't match.
+func verifyBinChecksum(dep *Dependency, extractBin string) error {
+	dep.mustBeBuilt()
+	if dep.binChecksum == "" {
+		return nil
+	}
+	got, err := fileChecksum(extractBin)
+	if err != nil {
+		return err
+	}
+	if got != dep.binChecksum {
+		return &ChecksumMismatchError{File: dep.archivePath(), Want: dep.binChecksum, Got: got, Dependency: dep.name}
+	}
+	return nil
+}
+
+// AddBinChecksums downloads and extracts dependencies and adds the checksums of their bins to BinChecksums. Default
+// is all dependencies on all of their systems. The downloads need checksums in URLChecksums.
+func (c *Config) AddBinChecksums(dependencies []string, systems []System) (errOut error) {
+	if len(dependencies) == 0 {
+		dependencies = c.DependencyNames()
+	}
+	cacheDir := c.Cache
+	if cacheDir == "" {
+		tmpDir, err := os.MkdirTemp("", "bindown")
+		if err != nil {
+			return err
+		}
+		defer deferErr(&errOut, func() error {
+			return os.RemoveAll(tmpDir)
+		})
+		cacheDir = tmpDir
+	}
+	for _, depName := range dependencies {
+		depSystems := systems
+		if len(depSystems) == 0 {
+			var err error
+			depSystems, err = c.DependencySystems(depName)
+			if err != nil {
+				return err
+			}
+		}
+		for _, system := range depSystems {
+			err := c.addBinChecksum(depName, system, cacheDir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) addBinChecksum(depName string, system System, cacheDir string) (errOut error) {
+	dep, err := c.BuildDependency(depName, system)
+	if err != nil {
+		return err
+	}
+	if c.BinChecksums[dep.binChecksumKey()] != "" {
+		return nil
+	}
+	extractDir, unlock, err := downloadAndExtract(dep, c.dependencyCacheDir(dep, cacheDir), false, false, false)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, unlock)
+	sum, err := fileChecksum(dep.extractedBin(extractDir))
+	if err != nil {
+		return err
+	}
+	if c.BinChecksums == nil {
+		c.BinChecksums = map[string]string{}
+	}
+	c.BinChecksums[dep.binChecksumKey()] = sum
+	return nil
+}
+
+// VerifyOpts provides options for Config.Verify
+type VerifyOpts struct {
+	// System to verify installed dependencies for. Default is CurrentSystem.
+	System System
+}
+
+// Verify compares installed bins to bin_checksums without downloading anything. It reports dependencies that aren't
+// installed, have no bin checksum or don't match it.
+func (c *Config) Verify(opts *VerifyOpts) ([]Drift, error) {
+	if opts == nil {
+		opts = &VerifyOpts{}
+	}
+	system := opts.System
+	if system == "" {
+		system = CurrentSystem
+	}
+	var drifts []Drift
+	for _, name := range c.DependencyNames() {
+		systems, err := c.DependencySystems(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(systems, system) {
+			continue
+		}
+		dep, err := c.BuildDependency(name, system)
+		if err != nil {
+			return nil, err
+		}
+		installPath, err := dep.installPath(c.installPathTemplate(dep))
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(c.InstallDir, installPath)
+		drift := Drift{Dependency: name, Subject: target}
+		var got string
+		switch {
+		case !FileExists(target):
+			drift.Kind = DriftMissing
+			drift.Message = name + " is not installed"
+		case dep.binChecksum == "":
+			drift.Kind = DriftMissing
+			drift.Message = fmt.Sprintf("no bin checksum for %s on %s", name, system)
+		default:
+			got, err = fileChecksum(target)
+			if err != nil {
+				return nil, err
+			}
+			if got == dep.binChecksum {
+				continue
+			}
+			drift.Kind = DriftChanged
+			drift.Message = fmt.Sprintf("%s does not match its bin checksum", name)
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
@@ -0,0 +1,36 @@
+package bindown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_verifyBinChecksum(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+dependencies:
+  foo:
+    url: https://example.com/foo.tar.gz
+    archive_path: bin/foo
+`)
+	dep, err := cfg.BuildDependency("foo", "linux/amd64")
+	require.NoError(t, err)
+	bin := filepath.Join(t.TempDir(), "foo")
+	require.NoError(t, os.WriteFile(bin, []byte("foo"), 0o755))
+	sum, err := fileChecksum(bin)
+	require.NoError(t, err)
+
+	require.NoError(t, verifyBinChecksum(dep, bin))
+
+	dep.binChecksum = sum
+	require.NoError(t, verifyBinChecksum(dep, bin))
+
+	dep.binChecksum = "deadbeef"
+	err = verifyBinChecksum(dep, bin)
+	require.EqualError(t, err, `bin checksum mismatch in "bin/foo" extracted for foo
+wanted: deadbeef
+got: `+sum)
+	require.Equal(t, FailureChecksum, FailureKindOf(err))
+}
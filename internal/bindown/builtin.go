@@ -0,0 +1,92 @@
+package bindown
+
+import (
+	"context"
+	_ "embed"
+	"slices"
+	"strings"
+)
+
+// BuiltinTemplateSource is the template source for the curated templates that ship with bindown. A template source
+// with the same name in the config takes precedence.
+const BuiltinTemplateSource = "builtin"
+
+//go:embed builtin-templates.yaml
+var builtinTemplatesYAML []byte
+
+func builtinTemplates(ctx context.Context) (*Config, error) {
+	return ConfigFromYAML(ctx, builtinTemplatesYAML)
+}
+
+// TemplateSearchResult is a template found by SearchTemplates.
+type TemplateSearchResult struct {
+	// Source is the template source the template is in. It is empty for templates in the config.
+	Source      string
+	Name        string
+	Description string
+}
+
+// SearchTemplatesOpts options for Config.SearchTemplates
+type SearchTemplatesOpts struct {
+	// Sources limits the search to these template sources. Use "" for the config's own templates. The default is the
+	// config's templates, the builtin templates and every template source in the config.
+	Sources []string
+}
+
+// SearchTemplates returns templates whose name or description contains query. Matching is case-insensitive.
+func (c *Config) SearchTemplates(ctx context.Context, query string, opts *SearchTemplatesOpts) ([]TemplateSearchResult, error) {
+	if opts == nil {
+		opts = &SearchTemplatesOpts{}
+	}
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = append(sources, "", BuiltinTemplateSource)
+		for _, src := range sortedKeys(c.TemplateSources) {
+			if src != BuiltinTemplateSource {
+				sources = append(sources, src)
+			}
+		}
+	}
+	query = strings.ToLower(query)
+	var results []TemplateSearchResult
+	for _, src := range sources {
+		srcCfg := c
+		if src != "" {
+			var err error
+			srcCfg, err = c.templateSourceConfig(ctx, src)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range srcCfg.templatesList() {
+			tmpl := srcCfg.Templates[name]
+			var description string
+			if tmpl != nil && tmpl.Description != nil {
+				description = *tmpl.Description
+			}
+			if !strings.Contains(strings.ToLower(name), query) &&
+				!strings.Contains(strings.ToLower(description), query) {
+				continue
+			}
+			results = append(results, TemplateSearchResult{
+				Source:      src,
+				Name:        name,
+				Description: description,
+			})
+		}
+	}
+	// exact name matches first
+	slices.SortStableFunc(results, func(a, b TemplateSearchResult) int {
+		aExact := strings.ToLower(a.Name) == query
+		bExact := strings.ToLower(b.Name) == query
+		switch {
+		case aExact == bExact:
+			return 0
+		case aExact:
+			return -1
+		default:
+			return 1
+		}
+	})
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package bindown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_builtinTemplates(t *testing.T) {
+	ctx := context.Background()
+	builtin, err := builtinTemplates(ctx)
+	require.NoError(t, err)
+	require.NoError(t, builtin.CheckStrict())
+	for _, name := range builtin.templatesList() {
+		t.Run(name, func(t *testing.T) {
+			cfg := mustConfigFromYAML(t, `{}`)
+			dep, _, err := cfg.AddDependencyFromTemplate(ctx, name, &AddDependencyFromTemplateOpts{
+				Vars: map[string]string{"version": "1.2.3"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, BuiltinTemplateSource+"#"+name, *dep.Template)
+			tmpl := builtin.Templates[name]
+			require.NotNil(t, tmpl.Description)
+			require.NotEmpty(t, tmpl.Systems)
+			for _, system := range tmpl.Systems {
+				built, err := cfg.BuildDependency(name, system)
+				require.NoError(t, err, system)
+				require.NotEmpty(t, built.url, system)
+			}
+		})
+	}
+}
+
+func TestConfig_SearchTemplates(t *testing.T) {
+	ctx := context.Background()
+	cfg := mustConfigFromYAML(t, `
+templates:
+  jqish:
+    description: my own json tool
+    url: https://example.com/jqish
+`)
+	results, err := cfg.SearchTemplates(ctx, "JQ", nil)
+	require.NoError(t, err)
+	require.Equal(t, []TemplateSearchResult{
+		{Source: "builtin", Name: "jq", Description: "Command-line JSON processor"},
+		{Name: "jqish", Description: "my own json tool"},
+	}, results)
+
+	results, err = cfg.SearchTemplates(ctx, "json", &SearchTemplatesOpts{Sources: []string{""}})
+	require.NoError(t, err)
+	require.Equal(t, []TemplateSearchResult{
+		{Name: "jqish", Description: "my own json tool"},
+	}, results)
+
+	_, err = cfg.SearchTemplates(ctx, "json", &SearchTemplatesOpts{Sources: []string{"nope"}})
+	require.EqualError(t, err, `no template source named "nope"`)
+}
+
+func TestConfig_AddProfile(t *testing.T) {
+	ctx := context.Background()
+	profiles, err := Profiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, profiles)
+	for _, profile := range profiles {
+		t.Run(profile.Name, func(t *testing.T) {
+			require.NotEmpty(t, profile.Description)
+			cfg := mustConfigFromYAML(t, `{}`)
+			added, err := cfg.AddProfile(ctx, profile.Name)
+			require.NoError(t, err)
+			require.Equal(t, sortedKeys(profile.Dependencies), added)
+			for _, name := range added {
+				require.Equal(t, profile.Dependencies[name], cfg.Dependencies[name].Vars["version"])
+				missing, err := cfg.MissingDependencyVars(name)
+				require.NoError(t, err)
+				require.Empty(t, missing)
+			}
+		})
+	}
+
+	t.Run("existing dependency", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  shfmt:
+    url: https://example.com/shfmt
+`)
+		added, err := cfg.AddProfile(ctx, "shell")
+		require.NoError(t, err)
+		require.Equal(t, []string{"shellcheck"}, added)
+		require.Equal(t, "https://example.com/shfmt", *cfg.Dependencies["shfmt"].URL)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `{}`)
+		_, err := cfg.AddProfile(ctx, "cobol")
+		require.EqualError(t, err, `no profile named "cobol". available profiles are go, k8s, shell, terraform`)
+	})
+}
@@ -0,0 +1,327 @@
+package bindown
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+const bundleManifestName = "bindown-bundle.json"
+
+// bundleManifest describes the contents of a bundle
+type bundleManifest struct {
+	// Config is the name of the config file in the bundle
+	Config string `json:"config"`
+	// Lock is the name of the lock file in the bundle. It is empty when the config has no lock file.
+	Lock string `json:"lock,omitempty"`
+	// LockSignatures are the names of the lock file's signatures in the bundle
+	LockSignatures []string `json:"lock_signatures,omitempty"`
+	// Dependencies maps dependency names to the systems the bundle has downloads for
+	Dependencies map[string][]System `json:"dependencies"`
+}
+
+// BundleOpts provides options for Config.Bundle
+type BundleOpts struct {
+	// Dependencies to bundle. Default is all dependencies.
+	Dependencies []string
+	// Systems to bundle. Default is each dependency's DependencySystems.
+	Systems []System
+}
+
+// Bundle writes a tar.gz archive to w containing the config file, the lock file and its signatures, and the downloads
+// for deps on systems. The archive can be installed without network access by Unbundle.
+func (c *Config) Bundle(w io.Writer, opts *BundleOpts) (errOut error) {
+	if opts == nil {
+		opts = &BundleOpts{}
+	}
+	deps := opts.Dependencies
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	configData, err := os.ReadFile(c.Filename)
+	if err != nil {
+		return err
+	}
+	// a config split into files in ConfigFragmentDir is bundled as one file
+	if len(c.fragments) > 0 {
+		var buf bytes.Buffer
+		err = c.encode(&buf, filepath.Ext(c.Filename) == ".json")
+		if err != nil {
+			return err
+		}
+		configData = buf.Bytes()
+	}
+	manifest := bundleManifest{
+		Config:       filepath.Base(c.Filename),
+		Dependencies: map[string][]System{},
+	}
+	gzWriter := gzip.NewWriter(w)
+	defer deferErr(&errOut, gzWriter.Close)
+	tarWriter := tar.NewWriter(gzWriter)
+	defer deferErr(&errOut, tarWriter.Close)
+
+	added := map[string]bool{}
+	for _, name := range deps {
+		systems := opts.Systems
+		if len(systems) == 0 {
+			systems, err = c.DependencySystems(name)
+			if err != nil {
+				return err
+			}
+		}
+		for _, system := range systems {
+			var dep *Dependency
+			dep, err = c.BuildDependency(name, system)
+			if err != nil {
+				return err
+			}
+			manifest.Dependencies[name] = append(manifest.Dependencies[name], system)
+			var entry string
+			entry, err = bundleDownloadPath(dep)
+			if err != nil {
+				return err
+			}
+			if added[entry] {
+				continue
+			}
+			added[entry] = true
+			var dlFile string
+			var unlock func() error
+			dlFile, _, unlock, err = downloadDependency(dep, c.downloadsCache(dep), false, false)
+			if err != nil {
+				return err
+			}
+			err = errors.Join(addFileToTar(tarWriter, entry, dlFile), unlock())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = addBytesToTar(tarWriter, manifest.Config, configData)
+	if err != nil {
+		return err
+	}
+	if FileExists(c.LockFilePath()) {
+		manifest.Lock = LockFileName
+		err = addFileToTar(tarWriter, manifest.Lock, c.LockFilePath())
+		if err != nil {
+			return err
+		}
+		for _, sig := range c.LockSignatures() {
+			name := filepath.Base(sig)
+			manifest.LockSignatures = append(manifest.LockSignatures, name)
+			err = addFileToTar(tarWriter, name, sig)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	manifestData, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return addBytesToTar(tarWriter, bundleManifestName, manifestData)
+}
+
+// bundleDownloadPath returns the path of a dependency's download in a bundle
+func bundleDownloadPath(dep *Dependency) (string, error) {
+	if dep.checksum == "" {
+		return "", fmt.Errorf("no checksum configured for %s %s", dep.name, dep.url)
+	}
+	dlFile, err := urlFilename(dep.url)
+	if err != nil {
+		return "", err
+	}
+	return path.Join("downloads", dep.checksum, dlFile), nil
+}
+
+func addFileToTar(tw *tar.Writer, name, filename string) (errOut error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, file.Close)
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+func addBytesToTar(tw *tar.Writer, name string, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// UnbundleOpts provides options for Unbundle
+type UnbundleOpts struct {
+	// Dir is the directory the bundled config file is written to. Default is the current directory.
+	Dir string
+	// Cache overrides the cache directory of the bundled config.
+	Cache string
+	// System to install. Default is CurrentSystem.
+	System System
+	Stdout io.Writer
+}
+
+// Unbundle writes the config and lock file from a bundle created by Config.Bundle to opts.Dir, adds the bundled
+// downloads to the cache and installs the bundled dependencies without network access. It returns the unbundled config.
+func Unbundle(ctx context.Context, bundle io.Reader, opts *UnbundleOpts) (_ *Config, errOut error) {
+	if opts == nil {
+		opts = &UnbundleOpts{}
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	system := opts.System
+	if system == "" {
+		system = CurrentSystem
+	}
+	tmpDir, err := os.MkdirTemp("", "bindown-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, func() error { return os.RemoveAll(tmpDir) })
+	err = untarStream("bundle.tar.gz", bundle, tmpDir, nil)
+	if err != nil {
+		return nil, err
+	}
+	manifestData, err := os.ReadFile(filepath.Join(tmpDir, bundleManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	var manifest bundleManifest
+	err = json.Unmarshal(manifestData, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	configFile := filepath.Join(dir, filepath.Base(manifest.Config))
+	err = writeBundledFile(filepath.Join(tmpDir, filepath.Base(manifest.Config)), configFile)
+	if err != nil {
+		return nil, err
+	}
+	// the config reads the lock file when it loads, so write the lock file and its signatures first
+	var lockFiles []string
+	if manifest.Lock != "" {
+		lockFiles = append(lockFiles, manifest.Lock)
+	}
+	lockFiles = append(lockFiles, manifest.LockSignatures...)
+	for _, name := range lockFiles {
+		name = filepath.Base(name)
+		err = writeBundledFile(filepath.Join(tmpDir, name), filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	config, err := NewConfig(ctx, configFile, false)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Cache != "" {
+		config.Cache = opts.Cache
+	}
+	deps := MapKeys(manifest.Dependencies)
+	slices.Sort(deps)
+	for _, name := range deps {
+		if !slices.Contains(manifest.Dependencies[name], system) {
+			return nil, fmt.Errorf("bundle does not contain %s for %s", name, system)
+		}
+		var dep *Dependency
+		dep, err = config.BuildDependency(name, system)
+		if err != nil {
+			return nil, err
+		}
+		err = config.cacheBundledDownload(dep, tmpDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = config.InstallDependencies(deps, system, &ConfigInstallDependenciesOpts{
+		Stdout: opts.Stdout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// writeBundledFile copies a file from an extracted bundle to target. It is an error for target to exist with
+// different content.
+func writeBundledFile(bundled, target string) error {
+	data, err := os.ReadFile(bundled)
+	if err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	existing, err := os.ReadFile(target)
+	if err == nil {
+		if string(existing) != string(data) {
+			return fmt.Errorf("%s already exists with different content", target)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+// cacheBundledDownload adds dep's download from an extracted bundle to the downloads cache
+func (c *Config) cacheBundledDownload(dep *Dependency, bundleDir string) error {
+	entry, err := bundleDownloadPath(dep)
+	if err != nil {
+		return err
+	}
+	bundled := filepath.Join(bundleDir, filepath.FromSlash(entry))
+	dlFile := path.Base(entry)
+	validate := func(dir string) error {
+		got, sumErr := fileChecksum(filepath.Join(dir, dlFile))
+		if sumErr != nil {
+			return sumErr
+		}
+		if got != dep.checksum {
+			return fmt.Errorf("expected checksum %s, got %s", dep.checksum, got)
+		}
+		return nil
+	}
+	populate := func(dir string) error {
+		return copyFile(bundled, filepath.Join(dir, dlFile))
+	}
+	_, unlock, err := c.downloadsCache(dep).Dir(cacheKey(dep.checksum), validate, populate)
+	if err != nil {
+		return err
+	}
+	return unlock()
+}
@@ -0,0 +1,131 @@
+package bindown
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// VerifyCacheOpts provides options for Config.VerifyCache
+type VerifyCacheOpts struct {
+	// Jobs is how many cache entries are hashed at once. Default is the number of CPUs.
+	Jobs int
+}
+
+// CacheProblem is a cache entry that doesn't match its checksum.
+type CacheProblem struct {
+	Dependency string
+	// Path is the cached download or extracted directory.
+	Path    string
+	Message string
+}
+
+// String formats the problem as "path: message".
+func (p CacheProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// cacheEntry is a cached download or extract to check.
+type cacheEntry struct {
+	dependency string
+	path       string
+	// checksum is the expected checksum
+	checksum string
+	// isDir means path is an extracted directory that is checked with directoryChecksum
+	isDir bool
+}
+
+// VerifyCache checks the cached downloads of the config's dependencies against url_checksums and their extracted
+// files against the checksums in their extract markers. Entries that aren't in the cache are ignored.
+// Entries are hashed concurrently. It returns the number of entries checked and the ones that don't match.
+func (c *Config) VerifyCache(opts *VerifyCacheOpts) (int, []CacheProblem, error) {
+	if opts == nil {
+		opts = &VerifyCacheOpts{}
+	}
+	entries, err := c.cacheEntries()
+	if err != nil {
+		return 0, nil, err
+	}
+	results := make([]*CacheProblem, len(entries))
+	forEachParallel(len(entries), opts.Jobs, func(i int) {
+		entry := entries[i]
+		var got string
+		var sumErr error
+		if entry.isDir {
+			got, sumErr = directoryChecksum(entry.path)
+		} else {
+			got, sumErr = fileChecksum(entry.path)
+		}
+		switch {
+		case sumErr != nil:
+			results[i] = &CacheProblem{Dependency: entry.dependency, Path: entry.path, Message: sumErr.Error()}
+		case got != entry.checksum:
+			results[i] = &CacheProblem{
+				Dependency: entry.dependency,
+				Path:       entry.path,
+				Message:    fmt.Sprintf("expected checksum %s, got %s", entry.checksum, got),
+			}
+		}
+	})
+	var problems []CacheProblem
+	for _, result := range results {
+		if result != nil {
+			problems = append(problems, *result)
+		}
+	}
+	return len(entries), problems, nil
+}
+
+// cacheEntries returns the cached downloads and extracts of every dependency on every system it supports.
+func (c *Config) cacheEntries() ([]cacheEntry, error) {
+	var entries []cacheEntry
+	seen := map[string]bool{}
+	add := func(entry cacheEntry) {
+		if seen[entry.path] {
+			return
+		}
+		seen[entry.path] = true
+		entries = append(entries, entry)
+	}
+	for _, name := range c.DependencyNames() {
+		missingVars, err := c.MissingDependencyVars(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(missingVars) > 0 {
+			continue
+		}
+		systems, err := c.DependencySystems(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, system := range systems {
+			dep, err := c.BuildDependency(name, system)
+			if err != nil {
+				return nil, err
+			}
+			if dep.checksum == "" {
+				continue
+			}
+			dlName, err := urlFilename(dep.url)
+			if err != nil {
+				return nil, err
+			}
+			cacheDir := c.dependencyCacheDir(dep, c.Cache)
+			dlKey := cacheKey(dep.checksum)
+			dlFile := filepath.Join(cacheDir, "downloads", dlKey, dlName)
+			if FileExists(dlFile) {
+				add(cacheEntry{dependency: name, path: dlFile, checksum: dep.checksum})
+			}
+			exKey := dep.extractKey(dlName)
+			marker, err := readExtractMarker(filepath.Join(cacheDir, ".extract_sums", exKey+".json"))
+			if err != nil || marker == nil {
+				continue
+			}
+			exDir := filepath.Join(cacheDir, "extracts", exKey)
+			if dirExists(exDir) {
+				add(cacheEntry{dependency: name, path: exDir, checksum: marker.Checksum, isDir: true})
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,268 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DriftKind describes how the install dir or url_checksums differ from the config.
+type DriftKind string
+
+const (
+	// DriftMissing is something the config expects that doesn't exist.
+	DriftMissing DriftKind = "missing"
+	// DriftChanged is something that exists but doesn't match the config.
+	DriftChanged DriftKind = "changed"
+	// DriftExtra is something that exists but the config doesn't use.
+	DriftExtra DriftKind = "extra"
+)
+
+var driftSymbols = map[DriftKind]string{
+	DriftMissing: "-",
+	DriftChanged: "~",
+	DriftExtra:   "+",
+}
+
+// Drift is a single difference found by Config.Check.
+type Drift struct {
+	Kind DriftKind
+	// Dependency is the dependency the drift is about. It is empty for unused checksums.
+	Dependency string
+	// Subject is the install path or url that drifted.
+	Subject string
+	Message string
+}
+
+// String formats the drift as a diff-style line. "-" marks missing, "~" changed and "+" extra.
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s: %s", driftSymbols[d.Kind], d.Subject, d.Message)
+}
+
+// CheckOpts provides options for Config.Check
+type CheckOpts struct {
+	// System to check installed dependencies for. Default is CurrentSystem.
+	System System
+	// SkipInstalled only checks url_checksums against the config. Otherwise installed bins are compared to the
+	// extracted downloads, which may need to be downloaded.
+	SkipInstalled bool
+	// Versions runs each installed dependency's version command and reports the ones that don't print the
+	// dependency's version var. The bin found on PATH is checked too. Only works for CurrentSystem.
+	Versions bool
+}
+
+// Check compares the install dir and url_checksums to the config. It reports dependencies that aren't installed or
+// don't match their download, urls that have no checksum and checksums that no dependency uses.
+func (c *Config) Check(opts *CheckOpts) ([]Drift, error) {
+	if opts == nil {
+		opts = &CheckOpts{}
+	}
+	system := opts.System
+	if system == "" {
+		system = CurrentSystem
+	}
+	if opts.Versions && system != CurrentSystem {
+		return nil, fmt.Errorf("can only check versions for %s", CurrentSystem)
+	}
+	var drifts []Drift
+	usedURLs := map[string]bool{}
+	for _, name := range c.DependencyNames() {
+		systems, err := c.DependencySystems(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, sys := range systems {
+			var dep *Dependency
+			dep, err = c.BuildDependency(name, sys)
+			if err != nil {
+				return nil, err
+			}
+			if usedURLs[dep.checksumKey] {
+				continue
+			}
+			usedURLs[dep.checksumKey] = true
+			if dep.checksum == "" {
+				drifts = append(drifts, Drift{
+					Kind:       DriftMissing,
+					Dependency: name,
+					Subject:    dep.url,
+					Message:    fmt.Sprintf("no checksum for %s on %s", name, sys),
+				})
+			}
+		}
+		if !slices.Contains(systems, system) {
+			continue
+		}
+		if !opts.SkipInstalled {
+			var drift *Drift
+			drift, err = c.checkInstalled(name, system)
+			if err != nil {
+				return nil, err
+			}
+			if drift != nil {
+				drifts = append(drifts, *drift)
+			}
+		}
+		if opts.Versions {
+			var versionDrifts []Drift
+			versionDrifts, err = c.checkVersions(name)
+			if err != nil {
+				return nil, err
+			}
+			drifts = append(drifts, versionDrifts...)
+		}
+	}
+	for _, u := range sortedKeys(c.URLChecksums) {
+		if !usedURLs[u] {
+			drifts = append(drifts, Drift{
+				Kind:    DriftExtra,
+				Subject: u,
+				Message: "checksum is not used by any dependency",
+			})
+		}
+	}
+	return drifts, nil
+}
+
+// checkInstalled returns a drift when depName isn't installed for system or doesn't match its download.
+func (c *Config) checkInstalled(depName string, system System) (_ *Drift, errOut error) {
+	dep, err := c.BuildDependency(depName, system)
+	if err != nil {
+		return nil, err
+	}
+	installPath, err := dep.installPath(c.installPathTemplate(dep))
+	if err != nil {
+		return nil, err
+	}
+	target := filepath.Join(c.InstallDir, installPath)
+	if _, err = os.Lstat(target); os.IsNotExist(err) {
+		return &Drift{
+			Kind:       DriftMissing,
+			Dependency: depName,
+			Subject:    target,
+			Message:    depName + " is not installed",
+		}, nil
+	}
+	if dep.checksum == "" {
+		// the missing checksum is already reported, and there's nothing trustworthy to compare against
+		return nil, nil
+	}
+	extractDir, unlock, err := downloadAndExtract(dep, c.dependencyCacheDir(dep, c.Cache), false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, unlock)
+	extractBin := dep.extractedBin(extractDir)
+	var ok bool
+	if dep.Link != nil && *dep.Link {
+		ok, err = isLinkedTo(target, extractBin)
+	} else {
+		ok, err = isInstalled(target, extractBin)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+	return &Drift{
+		Kind:       DriftChanged,
+		Dependency: depName,
+		Subject:    target,
+		Message:    fmt.Sprintf("%s does not match %s", depName, dep.url),
+	}, nil
+}
+
+// defaultVersionCommand is the version command for dependencies without a validate_command.
+var defaultVersionCommand = []string{"{{.path}}", "--version"}
+
+// checkVersions runs depName's version command for the installed bin and for the bin of the same name on PATH when
+// that is a different file. It returns a drift for each one whose output doesn't contain the version var. The version
+// command is validate_command, or "<bin> --version" when there isn't one. Dependencies without a version var or
+// that aren't installed are skipped.
+func (c *Config) checkVersions(depName string) ([]Drift, error) {
+	dep, err := c.BuildDependency(depName, CurrentSystem)
+	if err != nil {
+		return nil, err
+	}
+	version := dep.Vars["version"]
+	if version == "" {
+		return nil, nil
+	}
+	installPath, err := dep.installPath(c.installPathTemplate(dep))
+	if err != nil {
+		return nil, err
+	}
+	target := filepath.Join(c.InstallDir, installPath)
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		return nil, nil
+	}
+	paths := []string{target}
+	onPath, err := exec.LookPath(dep.binName())
+	if err == nil {
+		onPath, err = filepath.Abs(onPath)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(onPath)
+		if err == nil && !os.SameFile(info, targetInfo) {
+			paths = append(paths, onPath)
+		}
+	}
+	command := dep.ValidateCommand
+	if len(command) == 0 {
+		command = defaultVersionCommand
+	}
+	var drifts []Drift
+	for _, p := range paths {
+		msg := fmt.Sprintf("%s does not report version %s", depName, version)
+		if p != target {
+			msg = fmt.Sprintf("%s on PATH shadows %s and does not report version %s", depName, target, version)
+		}
+		out, err := dep.runBinCommand(command, p)
+		if err != nil {
+			drifts = append(drifts, Drift{
+				Kind:       DriftChanged,
+				Dependency: depName,
+				Subject:    p,
+				Message:    fmt.Sprintf("%s: version command failed: %v", msg, firstLine(err.Error())),
+			})
+			continue
+		}
+		if !strings.Contains(string(out), version) {
+			drifts = append(drifts, Drift{
+				Kind:       DriftChanged,
+				Dependency: depName,
+				Subject:    p,
+				Message:    fmt.Sprintf("%s: %s", msg, firstLine(strings.TrimSpace(string(out)))),
+			})
+		}
+	}
+	return drifts, nil
+}
+
+// isLinkedTo returns true when link is a symlink that resolves to target.
+func isLinkedTo(link, target string) (bool, error) {
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false, nil
+	}
+	resolved, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return false, nil
+	}
+	want, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return false, err
+	}
+	return resolved == want, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
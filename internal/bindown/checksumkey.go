@@ -0,0 +1,65 @@
+package bindown
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ChecksumKey controls how urls are turned into keys in url_checksums.
+type ChecksumKey string
+
+const (
+	// ChecksumKeyURL keys checksums by the exact url.
+	ChecksumKeyURL ChecksumKey = "url"
+	// ChecksumKeyStripQuery keys checksums by the url without its query and fragment.
+	ChecksumKeyStripQuery ChecksumKey = "strip-query"
+	// ChecksumKeyPath keys checksums by the url's path so mirrors with the same layout share checksums.
+	ChecksumKeyPath ChecksumKey = "path"
+	// ChecksumKeyTemplate keys checksums by the url template and the values of the vars it uses.
+	ChecksumKeyTemplate ChecksumKey = "template"
+)
+
+var templateVarExp = regexp.MustCompile(`\.(\w+)`)
+
+// checksumKey returns the url_checksums key for a dependency's url. urlTmpl is the url before vars are interpolated.
+func (c *Config) checksumKey(u, urlTmpl string, vars map[string]string) (string, error) {
+	switch c.ChecksumKey {
+	case "", ChecksumKeyURL:
+		return u, nil
+	case ChecksumKeyStripQuery, ChecksumKeyPath:
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return "", err
+		}
+		parsed.RawQuery = ""
+		parsed.ForceQuery = false
+		parsed.Fragment = ""
+		parsed.RawFragment = ""
+		if c.ChecksumKey == ChecksumKeyPath {
+			return parsed.EscapedPath(), nil
+		}
+		return parsed.String(), nil
+	case ChecksumKeyTemplate:
+		var used []string
+		for _, expr := range templateExprExp.FindAllString(urlTmpl, -1) {
+			for _, m := range templateVarExp.FindAllStringSubmatch(expr, -1) {
+				used = append(used, m[1])
+			}
+		}
+		sort.Strings(used)
+		var sb strings.Builder
+		sb.WriteString(urlTmpl)
+		for i, name := range used {
+			if i > 0 && used[i-1] == name {
+				continue
+			}
+			fmt.Fprintf(&sb, " %s=%s", name, vars[name])
+		}
+		return sb.String(), nil
+	default:
+		return "", fmt.Errorf("unknown checksum_key %q", c.ChecksumKey)
+	}
+}
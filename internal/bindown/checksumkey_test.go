@@ -0,0 +1,62 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_checksumKey(t *testing.T) {
+	const (
+		u       = "https://mirror.example.com/tools/foo-v1.2.3-linux.tar.gz?sig=abc#frag"
+		urlTmpl = "https://mirror.example.com/tools/foo-v{{ .version }}-{{.os}}.tar.gz?sig={{ .sig }}#frag"
+	)
+	vars := map[string]string{"version": "1.2.3", "os": "linux", "arch": "amd64", "sig": "abc"}
+	for _, td := range []struct {
+		key     ChecksumKey
+		want    string
+		wantErr string
+	}{
+		{key: "", want: u},
+		{key: ChecksumKeyURL, want: u},
+		{key: ChecksumKeyStripQuery, want: "https://mirror.example.com/tools/foo-v1.2.3-linux.tar.gz"},
+		{key: ChecksumKeyPath, want: "/tools/foo-v1.2.3-linux.tar.gz"},
+		{key: ChecksumKeyTemplate, want: urlTmpl + " os=linux sig=abc version=1.2.3"},
+		{key: "fake", wantErr: `unknown checksum_key "fake"`},
+	} {
+		t.Run(string(td.key), func(t *testing.T) {
+			cfg := &Config{ChecksumKey: td.key}
+			got, err := cfg.checksumKey(u, urlTmpl, vars)
+			if td.wantErr != "" {
+				require.EqualError(t, err, td.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, got)
+		})
+	}
+}
+
+func TestConfig_BuildDependency_checksumKey(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+checksum_key: strip-query
+systems: [linux/amd64]
+dependencies:
+  foo:
+    url: https://example.com/foo-{{ .os }}?token=xyz
+url_checksums:
+  https://example.com/foo-linux: deadbeef
+`)
+	dep, err := cfg.BuildDependency("foo", "linux/amd64")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/foo-linux?token=xyz", dep.url)
+	require.Equal(t, "deadbeef", dep.checksum)
+
+	dep, err = cfg.BuildDependency("foo", "darwin/amd64")
+	require.NoError(t, err)
+	require.Empty(t, dep.checksum)
+
+	_, err = cfg.PruneChecksums()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"https://example.com/foo-linux": "deadbeef"}, cfg.URLChecksums)
+}
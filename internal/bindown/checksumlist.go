@@ -0,0 +1,89 @@
+package bindown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SystemChecksum is a dependency's checksum for one system.
+type SystemChecksum struct {
+	System System
+	URL    string
+	// Key is the checksum's key in url_checksums.
+	Key string
+	// Checksum is empty when there is no checksum for the url in url_checksums or the lock file.
+	Checksum string
+}
+
+// DependencyChecksums is what Config.ListChecksums returns.
+type DependencyChecksums struct {
+	// Systems has an entry for each system the dependency supports.
+	Systems []SystemChecksum
+	// Stale are url_checksums keys that no dependency uses but look like the dependency's urls for another version.
+	// They are usually left behind by upgrades.
+	Stale []string
+}
+
+// ListChecksums returns the checksum of depName's url on each system it supports along with stale url_checksums
+// entries from its old versions. systems limits the list to those systems.
+func (c *Config) ListChecksums(depName string, systems []System) (*DependencyChecksums, error) {
+	if c.Dependencies[depName] == nil {
+		return nil, c.unknownDependencyError(depName)
+	}
+	depSystems, err := c.DependencySystems(depName)
+	if err != nil {
+		return nil, err
+	}
+	if len(systems) > 0 {
+		depSystems = systems
+	}
+	result := &DependencyChecksums{}
+	var versionPatterns []*regexp.Regexp
+	for _, system := range depSystems {
+		dep, err := c.BuildDependency(depName, system)
+		if err != nil {
+			return nil, err
+		}
+		result.Systems = append(result.Systems, SystemChecksum{
+			System:   system,
+			URL:      dep.url,
+			Key:      dep.checksumKey,
+			Checksum: dep.checksum,
+		})
+		if pattern := versionPattern(dep.checksumKey, dep.Vars["version"]); pattern != nil {
+			versionPatterns = append(versionPatterns, pattern)
+		}
+	}
+	if len(versionPatterns) == 0 {
+		return result, nil
+	}
+	unused, err := c.PlanPruneChecksums()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range unused {
+		if p.Bin {
+			continue
+		}
+		for _, pattern := range versionPatterns {
+			if pattern.MatchString(p.Key) {
+				result.Stale = append(result.Stale, p.Key)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// versionPattern returns a regexp matching key with any version in place of version. It returns nil when key doesn't
+// contain version.
+func versionPattern(key, version string) *regexp.Regexp {
+	if version == "" || !strings.Contains(key, version) {
+		return nil
+	}
+	parts := strings.Split(key, version)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, `[^/]+`) + "$")
+}
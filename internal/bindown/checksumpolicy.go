@@ -0,0 +1,62 @@
+package bindown
+
+import (
+	"fmt"
+	"io"
+)
+
+// ChecksumPolicy controls what bindown does when a url has no checksum in url_checksums.
+type ChecksumPolicy string
+
+const (
+	// ChecksumPolicyRequire fails downloads that have no checksum unless missing checksums are explicitly allowed.
+	ChecksumPolicyRequire ChecksumPolicy = "require-checksum"
+	// ChecksumPolicyWarn downloads files that have no checksum after writing a warning.
+	ChecksumPolicyWarn ChecksumPolicy = "allow-missing-with-warning"
+	// ChecksumPolicyTrustOnFirstUse downloads files that have no checksum and adds the computed checksum to
+	// url_checksums.
+	ChecksumPolicyTrustOnFirstUse ChecksumPolicy = "trust-on-first-use"
+)
+
+// missingChecksumAllowed returns whether dep can be downloaded when it has no checksum. allow is the caller's
+// AllowMissingChecksum option. It writes a warning to w when the policy calls for one and w isn't nil. A dependency
+// without a checksum gets it from the ChecksumService first when there is one.
+func (c *Config) missingChecksumAllowed(dep *Dependency, allow bool, w io.Writer) (bool, error) {
+	dep.mustBeBuilt()
+	err := c.lookupServiceChecksum(dep)
+	if err != nil {
+		return false, err
+	}
+	if dep.checksum != "" {
+		return allow, nil
+	}
+	switch c.ChecksumPolicy {
+	case "", ChecksumPolicyRequire:
+		return allow, nil
+	case ChecksumPolicyWarn:
+		if w != nil {
+			_, err := fmt.Fprintf(w, "warning: no checksum configured for %s %s\n", dep.name, dep.url)
+			if err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case ChecksumPolicyTrustOnFirstUse:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown checksum_policy %q", c.ChecksumPolicy)
+	}
+}
+
+// trustChecksum adds the checksum computed while downloading dep to url_checksums when the policy is
+// trust-on-first-use.
+func (c *Config) trustChecksum(dep *Dependency) {
+	// checksums from the checksum service stay there instead of being copied to every config
+	if c.ChecksumPolicy != ChecksumPolicyTrustOnFirstUse || dep.checksum == "" || dep.serviceChecksum || c.URLChecksums[dep.checksumKey] != "" {
+		return
+	}
+	if c.URLChecksums == nil {
+		c.URLChecksums = map[string]string{}
+	}
+	c.URLChecksums[dep.checksumKey] = dep.checksum
+}
@@ -0,0 +1,90 @@
+package bindown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PrunedChecksum is a checksum that PruneChecksums removes.
+type PrunedChecksum struct {
+	// Key is the checksum's key in url_checksums or bin_checksums.
+	Key string `json:"key" yaml:"key"`
+	// Bin is true for bin_checksums entries.
+	Bin bool `json:"bin,omitempty" yaml:"bin,omitempty"`
+	// Reason says why the checksum isn't needed.
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// PlanPruneChecksums returns the checksums PruneChecksums would remove without removing them. Every dependency is
+// built for every system so checksums left behind by systems that were dropped from a dependency or from the config's
+// systems can be told apart from checksums no dependency has any use for.
+func (c *Config) PlanPruneChecksums() ([]PrunedChecksum, error) {
+	used := map[string]bool{}
+	// unsupported maps keys that are only built for unsupported systems to the "dep on system" pairs that build them
+	unsupported := map[string][]string{}
+	// checksums for urls that are only used in other environments are still needed
+	environment := c.Environment
+	defer func() { c.Environment = environment }()
+	for _, env := range c.Environments() {
+		c.Environment = env
+		err := c.markPruneKeys(used, unsupported)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reason := func(key string) string {
+		if len(unsupported[key]) == 0 {
+			return "no dependency uses it"
+		}
+		return "only used for systems that are not supported: " + strings.Join(unsupported[key], ", ")
+	}
+	var pruned []PrunedChecksum
+	for _, key := range sortedKeys(c.URLChecksums) {
+		if !used[key] {
+			pruned = append(pruned, PrunedChecksum{Key: key, Reason: reason(key)})
+		}
+	}
+	for _, key := range sortedKeys(c.BinChecksums) {
+		if !used[key] {
+			pruned = append(pruned, PrunedChecksum{Key: key, Bin: true, Reason: reason(key)})
+		}
+	}
+	return pruned, nil
+}
+
+// markPruneKeys sets used for each checksum key the dependencies build for the systems they support and adds keys for
+// other systems to unsupported.
+func (c *Config) markPruneKeys(used map[string]bool, unsupported map[string][]string) error {
+	for _, depName := range c.DependencyNames() {
+		systems, err := c.DependencySystems(depName)
+		if err != nil {
+			return err
+		}
+		for _, system := range systems {
+			dep, err := c.BuildDependency(depName, system)
+			if err != nil {
+				return err
+			}
+			used[dep.checksumKey] = true
+			used[dep.binChecksumKey()] = true
+		}
+		for _, s := range strings.Fields(GoDists) {
+			system := System(s)
+			if slices.Contains(systems, system) {
+				continue
+			}
+			dep, err := c.BuildDependency(depName, system)
+			if err != nil {
+				// a dependency doesn't have to build for systems it doesn't support
+				continue
+			}
+			desc := fmt.Sprintf("%s on %s", depName, system)
+			if !slices.Contains(unsupported[dep.checksumKey], desc) {
+				unsupported[dep.checksumKey] = append(unsupported[dep.checksumKey], desc)
+				unsupported[dep.binChecksumKey()] = append(unsupported[dep.binChecksumKey()], desc)
+			}
+		}
+	}
+	return nil
+}
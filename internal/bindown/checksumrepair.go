@@ -0,0 +1,185 @@
+package bindown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChecksumRepairOpts options for Config.PlanChecksumRepair
+type ChecksumRepairOpts struct {
+	// Systems to check. Default is all the systems the dependency supports.
+	Systems []System
+	// GitHubToken is used to look up release dates in the GitHub api.
+	GitHubToken string
+	// GitLabToken is used to look up release dates in the GitLab api.
+	GitLabToken string
+}
+
+// ChecksumRepair is a url checksum that doesn't match what the url downloads now.
+type ChecksumRepair struct {
+	Dependency string
+	System     System
+	URL        string
+	// Key is the checksum's key in url_checksums.
+	Key         string
+	OldChecksum string
+	NewChecksum string
+	// BinChecksumKey is the key of the dependency's bin checksum for the system in bin_checksums. It is empty when
+	// there is none.
+	BinChecksumKey string
+	// ReleaseDate is when the GitHub or GitLab release the url downloads from was published. It is zero when it
+	// couldn't be looked up.
+	ReleaseDate time.Time
+}
+
+// PlanChecksumRepair downloads depName for each system and returns the url checksums that don't match the download.
+// Nothing is changed. This is for when upstream replaced the files of a release, so the checksums are wrong rather
+// than the downloads. Pass the result to RepairChecksums to update the config.
+func (c *Config) PlanChecksumRepair(ctx context.Context, depName string, opts *ChecksumRepairOpts) ([]ChecksumRepair, error) {
+	if opts == nil {
+		opts = &ChecksumRepairOpts{}
+	}
+	if c.Dependencies[depName] == nil {
+		return nil, c.unknownDependencyError(depName)
+	}
+	systems := opts.Systems
+	if len(systems) == 0 {
+		var err error
+		systems, err = c.DependencySystems(depName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var repairs []ChecksumRepair
+	// systems often share a url, so each url is only downloaded and looked up once
+	sums := map[string]string{}
+	dates := map[string]time.Time{}
+	for _, system := range systems {
+		dep, err := c.BuildDependency(depName, system)
+		if err != nil {
+			return nil, err
+		}
+		if dep.checksum == "" {
+			continue
+		}
+		sum, ok := sums[dep.url]
+		if !ok {
+			sum, err = getURLChecksum(dep.url, "", dep.downloader)
+			if err != nil {
+				return nil, err
+			}
+			sums[dep.url] = sum
+		}
+		if sum == dep.checksum {
+			continue
+		}
+		repair := ChecksumRepair{
+			Dependency:  depName,
+			System:      system,
+			URL:         dep.url,
+			Key:         dep.checksumKey,
+			OldChecksum: dep.checksum,
+			NewChecksum: sum,
+		}
+		if dep.binChecksum != "" {
+			repair.BinChecksumKey = dep.binChecksumKey()
+		}
+		date, ok := dates[dep.url]
+		if !ok {
+			date = c.releaseDate(ctx, dep.url, opts)
+			dates[dep.url] = date
+		}
+		repair.ReleaseDate = date
+		repairs = append(repairs, repair)
+	}
+	return repairs, nil
+}
+
+// RepairChecksums sets the url checksums in repairs to their new values. Bin checksums for the same downloads are
+// removed because the bins may have changed too.
+func (c *Config) RepairChecksums(repairs []ChecksumRepair) {
+	for _, repair := range repairs {
+		if c.URLChecksums == nil {
+			c.URLChecksums = map[string]string{}
+		}
+		c.URLChecksums[repair.Key] = repair.NewChecksum
+		if repair.BinChecksumKey != "" {
+			delete(c.BinChecksums, repair.BinChecksumKey)
+		}
+	}
+}
+
+// releaseDate returns when the GitHub or GitLab release dlURL downloads from was published. It returns the zero time
+// when dlURL isn't a release url or the date can't be looked up.
+func (c *Config) releaseDate(ctx context.Context, dlURL string, opts *ChecksumRepairOpts) time.Time {
+	repo, tag := releaseRepoAndTag(dlURL, c.GitHubEnterpriseHosts)
+	if repo == nil {
+		return time.Time{}
+	}
+	repo.setToken(opts.GitHubToken, opts.GitLabToken)
+	date, err := repo.releaseDate(ctx, c.downloader().httpClient(), tag)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// releaseRepoAndTag returns the repository and tag of a GitHub or GitLab release download url. The repository is nil
+// when dlURL isn't one. Urls on githubHosts are GitHub Enterprise Server release urls.
+func releaseRepoAndTag(dlURL string, githubHosts []string) (*upstreamRepo, string) {
+	u, err := url.Parse(dlURL)
+	if err != nil || u.Host == "" {
+		return nil, ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if apiURL, ok := githubAPIURLFor(u, githubHosts); ok {
+		// /<owner>/<repo>/releases/download/<tag>/<file>
+		if len(segments) < 6 || segments[2] != "releases" || segments[3] != "download" {
+			return nil, ""
+		}
+		project := segments[0] + "/" + segments[1]
+		return &upstreamRepo{kind: "github", apiURL: apiURL, project: project, enterprise: u.Host != "github.com"}, segments[4]
+	}
+	// /<group>/<project>/-/releases/<tag>/downloads/<file>
+	idx := strings.Index(u.Path, "/-/releases/")
+	if idx == -1 {
+		return nil, ""
+	}
+	return &upstreamRepo{
+		kind:    "gitlab",
+		apiURL:  u.Scheme + "://" + u.Host + "/api/v4",
+		project: strings.Trim(u.Path[:idx], "/"),
+	}, strings.Split(u.Path[idx+len("/-/releases/"):], "/")[0]
+}
+
+// releaseDate returns when the release for tag was published.
+func (r *upstreamRepo) releaseDate(ctx context.Context, client *http.Client, tag string) (time.Time, error) {
+	var endpoint string
+	switch r.kind {
+	case "github":
+		endpoint = fmt.Sprintf("%s/repos/%s/releases/tags/%s", r.apiURL, r.project, url.PathEscape(tag))
+	default:
+		endpoint = fmt.Sprintf("%s/projects/%s/releases/%s", r.apiURL, url.PathEscape(r.project), url.PathEscape(tag))
+	}
+	data, err := r.apiGet(ctx, client, endpoint)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var body struct {
+		PublishedAt time.Time `json:"published_at"`
+		ReleasedAt  time.Time `json:"released_at"`
+	}
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if r.kind == "github" {
+		return body.PublishedAt, nil
+	}
+	return body.ReleasedAt, nil
+}
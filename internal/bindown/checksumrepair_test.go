@@ -0,0 +1,93 @@
+package bindown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_PlanChecksumRepair(t *testing.T) {
+	ts := testutil.ServeFile(t, filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"), "/foo.tar.gz", "")
+	depURL := ts.URL + "/foo.tar.gz"
+	wantSum := "27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3"
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+systems: [linux/amd64, darwin/arm64]
+dependencies:
+  foo:
+    url: %q
+url_checksums:
+  %q: deadbeef
+bin_checksums:
+  "%s#foo": deadbeef
+  other: deadbeef
+`, depURL, depURL, depURL))
+	repairs, err := config.PlanChecksumRepair(context.Background(), "foo", nil)
+	require.NoError(t, err)
+	require.Equal(t, []ChecksumRepair{
+		{
+			Dependency:     "foo",
+			System:         "linux/amd64",
+			URL:            depURL,
+			Key:            depURL,
+			OldChecksum:    "deadbeef",
+			NewChecksum:    wantSum,
+			BinChecksumKey: depURL + "#foo",
+		},
+		{
+			Dependency:     "foo",
+			System:         "darwin/arm64",
+			URL:            depURL,
+			Key:            depURL,
+			OldChecksum:    "deadbeef",
+			NewChecksum:    wantSum,
+			BinChecksumKey: depURL + "#foo",
+		},
+	}, repairs)
+
+	config.RepairChecksums(repairs)
+	require.Equal(t, map[string]string{depURL: wantSum}, config.URLChecksums)
+	require.Equal(t, map[string]string{"other": "deadbeef"}, config.BinChecksums)
+
+	repairs, err = config.PlanChecksumRepair(context.Background(), "foo", nil)
+	require.NoError(t, err)
+	require.Empty(t, repairs)
+}
+
+func Test_upstreamRepo_releaseDate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases/tags/v1.2.3":
+			fmt.Fprint(w, `{"tag_name": "v1.2.3", "published_at": "2024-03-04T05:06:07Z"}`)
+		case "/api/v4/projects/group/project/releases/v1.0.0":
+			fmt.Fprint(w, `{"tag_name": "v1.0.0", "released_at": "2023-01-02T03:04:05Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	oldAPI := githubAPIURL
+	githubAPIURL = server.URL
+	t.Cleanup(func() { githubAPIURL = oldAPI })
+
+	repo, tag := releaseRepoAndTag("https://github.com/owner/repo/releases/download/v1.2.3/repo_linux.tar.gz", nil)
+	require.Equal(t, "v1.2.3", tag)
+	date, err := repo.releaseDate(context.Background(), http.DefaultClient, tag)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC), date.UTC())
+
+	repo, tag = releaseRepoAndTag(server.URL+"/group/project/-/releases/v1.0.0/downloads/project_linux.tar.gz", nil)
+	require.Equal(t, "v1.0.0", tag)
+	date, err = repo.releaseDate(context.Background(), http.DefaultClient, tag)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), date.UTC())
+
+	repo, _ = releaseRepoAndTag("https://example.com/foo.tar.gz", nil)
+	require.Nil(t, repo)
+}
@@ -0,0 +1,94 @@
+package bindown
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var sha256HexExp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// checksumServiceURL returns the url to look up dep's checksum from c.ChecksumService. A template gets the
+// dependency's vars along with "url" for its download url and "name" for its name. Any other value is a base url that
+// gets the download url in its "url" query parameter.
+func (c *Config) checksumServiceURL(dep *Dependency) (string, error) {
+	if !strings.Contains(c.ChecksumService, "{{") {
+		u, err := url.Parse(c.ChecksumService)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set("url", dep.url)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+	vars := maps.Clone(dep.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["url"] = dep.url
+	if _, ok := vars["name"]; !ok {
+		vars["name"] = dep.name
+	}
+	return executeTemplate(c.ChecksumService, dep.system.OS(), dep.system.Arch(), vars)
+}
+
+// lookupServiceChecksum sets dep's checksum from c.ChecksumService when it doesn't have one. A dependency the service
+// has no checksum for is left without one.
+func (c *Config) lookupServiceChecksum(dep *Dependency) (errOut error) {
+	dep.mustBeBuilt()
+	if c.ChecksumService == "" || dep.checksum != "" {
+		return nil
+	}
+	lookupURL, err := c.checksumServiceURL(dep)
+	if err != nil {
+		return &ConfigError{Err: fmt.Errorf("checksum_service: %w", err)}
+	}
+	u, err := url.Parse(lookupURL)
+	if err != nil {
+		return &ConfigError{Err: fmt.Errorf("checksum_service: %w", err)}
+	}
+	// the service's token and the checksums it returns must not go over plain http unless that is asked for
+	if u.Scheme != "https" && !(u.Scheme == "http" && firstEnv("BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP") != "") {
+		return &ConfigError{Err: fmt.Errorf(
+			"checksum_service %s must be an https url. set BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP=1 to allow http",
+			redactURL(lookupURL),
+		)}
+	}
+	ctx, cancel := dep.downloader.context()
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+	if token := firstEnv("BINDOWN_CHECKSUM_SERVICE_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := dep.downloader.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &downloadError{err: fmt.Errorf("failed looking up the checksum for %s %s: %s", dep.name, dep.url, resp.Status)}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	// the response can be a bare checksum or a line like sha256sum's "<checksum>  <file>"
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 || !sha256HexExp.MatchString(fields[0]) {
+		return fmt.Errorf("checksum_service returned an invalid checksum for %s %s", dep.name, dep.url)
+	}
+	dep.checksum = strings.ToLower(fields[0])
+	dep.serviceChecksum = true
+	return nil
+}
@@ -0,0 +1,103 @@
+package bindown
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_checksumService(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"))
+	require.NoError(t, err)
+	fooSum := "27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3"
+	setup := func(t *testing.T, service func(ts *httptest.Server) string, sums map[string]string) *Config {
+		t.Helper()
+		t.Setenv("BINDOWN_CHECKSUM_SERVICE_TOKEN", "sums-token")
+		t.Setenv("BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP", "1")
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/foo.tar.gz" {
+				_, e := w.Write(content)
+				assert.NoError(t, e)
+				return
+			}
+			assert.Equal(t, "Bearer sums-token", r.Header.Get("Authorization"))
+			key := r.URL.Path
+			if r.URL.Query().Has("url") {
+				// urls are keyed by their path because the server's address isn't known ahead of time
+				dlURL, e := url.Parse(r.URL.Query().Get("url"))
+				assert.NoError(t, e)
+				key = "url:" + dlURL.Path
+			}
+			sum, ok := sums[key]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, e := fmt.Fprintf(w, "%s  foo.tar.gz\n", sum)
+			assert.NoError(t, e)
+		}))
+		t.Cleanup(ts.Close)
+		dir := t.TempDir()
+		return mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+install_dir: %q
+checksum_service: %q
+dependencies:
+  foo:
+    url: %s/foo.tar.gz
+    vars:
+      version: 1.2.3
+`, filepath.Join(dir, "cache"), filepath.Join(dir, "bin"), service(ts), ts.URL))
+	}
+	byURL := func(ts *httptest.Server) string { return ts.URL + "/sums" }
+	byVersion := func(ts *httptest.Server) string { return ts.URL + "/sums/{{.name}}/{{.version}}/{{.os}}-{{.arch}}" }
+
+	t.Run("by url", func(t *testing.T) {
+		cfg := setup(t, byURL, map[string]string{"url:/foo.tar.gz": fooSum})
+		err = cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(cfg.InstallDir, "foo"))
+		require.Empty(t, cfg.URLChecksums)
+	})
+
+	t.Run("by tool and version", func(t *testing.T) {
+		cfg := setup(t, byVersion, map[string]string{"/sums/foo/1.2.3/linux-amd64": fooSum})
+		err := cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(cfg.InstallDir, "foo"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cfg := setup(t, byVersion, map[string]string{"/sums/foo/1.2.3/darwin-amd64": fooSum})
+		err := cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.ErrorContains(t, err, "no checksum")
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		cfg := setup(t, byVersion, map[string]string{"/sums/foo/1.2.3/linux-amd64": "0000000000000000000000000000000000000000000000000000000000000000"})
+		err := cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.ErrorContains(t, err, "checksum")
+		require.NoFileExists(t, filepath.Join(cfg.InstallDir, "foo"))
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		cfg := setup(t, byVersion, map[string]string{"/sums/foo/1.2.3/linux-amd64": "nope"})
+		err := cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.ErrorContains(t, err, "checksum_service returned an invalid checksum for foo")
+	})
+
+	t.Run("http", func(t *testing.T) {
+		cfg := setup(t, byVersion, map[string]string{"/sums/foo/1.2.3/linux-amd64": fooSum})
+		t.Setenv("BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP", "")
+		err := cfg.InstallDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.ErrorContains(t, err, "must be an https url. set BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP=1 to allow http")
+		require.NoFileExists(t, filepath.Join(cfg.InstallDir, "foo"))
+	})
+}
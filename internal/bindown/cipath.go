@@ -0,0 +1,94 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultDotenvFile is the file GitLab CI jobs write PATH to unless BINDOWN_DOTENV is set. The job needs to declare it
+// as a dotenv report artifact.
+const defaultDotenvFile = "bindown.env"
+
+// addToCIPath adds dirs to PATH for the later steps of the CI job bindown is running in. GitHub Actions, Azure
+// Pipelines and GitLab CI are supported. Azure Pipelines logging commands are written to stdout, which must be the
+// process's stdout.
+func addToCIPath(dirs []string, stdout io.Writer) (errOut error) {
+	absDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		absDirs = append(absDirs, absDir)
+	}
+	dirs = absDirs
+	switch {
+	case os.Getenv("GITHUB_PATH") != "":
+		f, err := os.OpenFile(os.Getenv("GITHUB_PATH"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer deferErr(&errOut, f.Close)
+		for _, dir := range dirs {
+			_, err = fmt.Fprintln(f, dir)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case strings.EqualFold(os.Getenv("TF_BUILD"), "true"):
+		if stdout == nil {
+			return errors.New("no stdout to write the Azure Pipelines command that adds to PATH to")
+		}
+		for _, dir := range dirs {
+			_, err := fmt.Fprintf(stdout, "##vso[task.prependpath]%s\n", dir)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case os.Getenv("GITLAB_CI") != "":
+		return writeDotenvPath(dirs)
+	default:
+		return errors.New("could not detect a supported CI system. GitHub Actions, Azure Pipelines and GitLab CI are supported")
+	}
+}
+
+// writeDotenvPath sets PATH in the dotenv file named by BINDOWN_DOTENV to dirs followed by the PATH already in the
+// file, or by the current PATH when the file has none. GitLab doesn't expand variables in dotenv values, so the PATH
+// later jobs get has to be written out in full. Other variables in the file are kept.
+func writeDotenvPath(dirs []string) error {
+	filename := os.Getenv("BINDOWN_DOTENV")
+	if filename == "" {
+		filename = defaultDotenvFile
+	}
+	var lines []string
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	basePath := os.Getenv("PATH")
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "PATH="); ok {
+			basePath = value
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	pathDirs := slices.Clone(dirs)
+	for _, dir := range filepath.SplitList(basePath) {
+		// an empty entry would add the working directory
+		if dir != "" && !slices.Contains(pathDirs, dir) {
+			pathDirs = append(pathDirs, dir)
+		}
+	}
+	lines = append(lines, "PATH="+strings.Join(pathDirs, string(filepath.ListSeparator)))
+	return os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
@@ -0,0 +1,61 @@
+package bindown
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// installTargets returns the paths installing dep writes to relative to the output directory, where installPath is
+// the dependency's path there. A dependency with entrypoints installs a link for each one next to installPath.
+func (d *Dependency) installTargets(installPath string) []string {
+	if len(d.Entrypoints) == 0 {
+		return []string{filepath.Clean(installPath)}
+	}
+	binDir := filepath.Dir(installPath)
+	targets := make([]string, len(d.Entrypoints))
+	for i, entrypoint := range d.Entrypoints {
+		targets[i] = filepath.Join(binDir, filepath.Base(filepath.FromSlash(entrypoint)))
+	}
+	return targets
+}
+
+// checkInstallCollisions returns a *ConfigError when two of deps would install to the same path for system. When
+// involving isn't empty, only collisions with one of those dependencies are reported. pathTemplate returns the install
+// path template for a built dependency. Dependencies that don't build for system are skipped so installing them
+// reports the error. Paths are compared case-insensitively on windows and darwin.
+func (c *Config) checkInstallCollisions(
+	deps, involving []string,
+	system System,
+	pathTemplate func(dep *Dependency) string,
+) error {
+	owners := map[string]string{}
+	for _, name := range deps {
+		dep, err := c.buildSupported(name, system, nil)
+		if err != nil {
+			continue
+		}
+		installPath, err := dep.installPath(pathTemplate(dep))
+		if err != nil {
+			continue
+		}
+		for _, target := range dep.installTargets(installPath) {
+			key := target
+			if system.OS() == "windows" || system.OS() == "darwin" {
+				key = strings.ToLower(key)
+			}
+			owner, ok := owners[key]
+			if ok && owner != name && (len(involving) == 0 ||
+				slices.Contains(involving, owner) || slices.Contains(involving, name)) {
+				return &ConfigError{Err: fmt.Errorf(
+					"%s and %s would both install to %s on %s", owner, name, filepath.ToSlash(target), system,
+				)}
+			}
+			if !ok {
+				owners[key] = name
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package bindown
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_InstallDependencies_collisions(t *testing.T) {
+	dir := t.TempDir()
+	cfg := mustConfigFromYAML(t, `
+systems: [linux/amd64, darwin/arm64]
+dependencies:
+  jq:
+    url: https://example.com/jq-{{.os}}
+  jq-legacy:
+    url: https://example.com/jq-legacy-{{.os}}
+    bin: jq
+  yq:
+    url: https://example.com/yq-{{.os}}
+    overrides:
+      - matcher:
+          os: [darwin]
+        dependency:
+          bin: JQ
+  tools:
+    url: https://example.com/tools.tar.gz
+    entrypoints: [bin/yq, bin/gojq]
+`)
+	cfg.InstallDir = filepath.Join(dir, "bin")
+	cfg.Cache = filepath.Join(dir, "cache")
+
+	err := cfg.InstallDependencies([]string{"jq", "jq-legacy"}, "linux/amd64", nil)
+	require.EqualError(t, err, "jq and jq-legacy would both install to jq on linux/amd64")
+	require.IsType(t, &ConfigError{}, err)
+	require.NoDirExists(t, cfg.InstallDir)
+
+	// darwin paths are case-insensitive
+	err = cfg.checkInstallCollisions([]string{"jq", "yq"}, nil, "linux/amd64", cfg.installPathTemplate)
+	require.NoError(t, err)
+	err = cfg.checkInstallCollisions([]string{"jq", "yq"}, nil, "darwin/arm64", cfg.installPathTemplate)
+	require.EqualError(t, err, "jq and yq would both install to JQ on darwin/arm64")
+
+	// entrypoints install links next to the install path
+	err = cfg.checkInstallCollisions([]string{"yq", "tools"}, nil, "linux/amd64", cfg.installPathTemplate)
+	require.EqualError(t, err, "yq and tools would both install to yq on linux/amd64")
+
+	// only collisions with involving are reported
+	err = cfg.checkInstallCollisions([]string{"jq", "jq-legacy", "yq"}, []string{"yq"}, "linux/amd64", cfg.installPathTemplate)
+	require.NoError(t, err)
+
+	err = cfg.Validate("jq-legacy", []System{"linux/amd64"}, nil)
+	require.EqualError(t, err, "jq and jq-legacy would both install to jq on linux/amd64")
+
+	err = cfg.InstallDependenciesForSystems([]string{"jq", "jq-legacy"}, nil, &ConfigInstallDependenciesOpts{
+		Output: filepath.Join(dir, "dist"),
+	})
+	require.EqualError(t, err, "jq and jq-legacy would both install to linux-amd64/jq on linux/amd64")
+}
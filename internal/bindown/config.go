@@ -1,13 +1,17 @@
 package bindown
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"io/fs"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,22 +19,110 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/willabides/bindown/v4/internal/cache"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	// The oldest version of bindown that can use this config. Older versions refuse to load it with an error asking
+	// the user to upgrade instead of failing on properties they don't know about.
+	MinBindownVersion string `json:"min_bindown_version,omitempty" yaml:"min_bindown_version,omitempty"`
+
 	// The directory where bindown will cache downloads and extracted files. This is relative to the directory where
 	// the configuration file resides. cache paths should always use / as a delimiter even on Windows or other
 	// operating systems where the native delimiter isn't /.
 	Cache string `json:"cache,omitempty" yaml:"cache,omitempty"`
 
+	// The directory where bindown keeps temporary files while downloading. This is relative to the directory where
+	// the configuration file resides. Files are moved from here into the cache, so it should be on the same
+	// filesystem as the cache. Defaults to a directory in the cache, or the system temp directory when there is no
+	// cache.
+	WorkDir string `json:"work_dir,omitempty" yaml:"work_dir,omitempty"`
+
 	// The directory that bindown installs files to. This is relative to the directory where the configuration file
 	// resides. install_directory paths should always use / as a delimiter even on Windows or other operating systems
 	// where the native delimiter isn't /.
 	InstallDir string `json:"install_dir,omitempty" yaml:"install_dir,omitempty"`
 
+	// A template for the path where dependencies are installed relative to install_dir. The template can use the
+	// dependency's vars along with "name" for the dependency name and "bin" for the bin name. The default is
+	// "{{.bin}}". For example, "{{.name}}-{{.version}}" would allow multiple versions to be installed side by side.
+	// The result must be a relative path that stays inside install_dir.
+	InstallPath string `json:"install_path,omitempty" yaml:"install_path,omitempty"`
+
+	// What to do when a url has no checksum in url_checksums. "require-checksum" fails unless missing checksums are
+	// explicitly allowed. "allow-missing-with-warning" downloads the file after writing a warning.
+	// "trust-on-first-use" downloads the file and adds its checksum to url_checksums. Default is "require-checksum".
+	ChecksumPolicy ChecksumPolicy `json:"checksum_policy,omitempty" yaml:"checksum_policy,omitempty" jsonschema:"enum=require-checksum,enum=allow-missing-with-warning,enum=trust-on-first-use"`
+
+	// How urls are keyed in url_checksums. "url" uses the exact url. "strip-query" drops the query and fragment so
+	// changing a query parameter like a signature keeps the checksum. "path" uses only the url's path so mirrors with
+	// the same layout share checksums. "template" uses the url template and the values of the vars it uses. Default is
+	// "url".
+	ChecksumKey ChecksumKey `json:"checksum_key,omitempty" yaml:"checksum_key,omitempty" jsonschema:"enum=url,enum=strip-query,enum=path,enum=template"`
+
+	// A command to run to download files instead of having bindown download them. Each element is a template for one
+	// argument. "{{.url}}" is the url to download and "{{.output}}" is the file to write. bindown still verifies
+	// checksums and extracts the downloaded file. For example, ["curl", "-fsSL", "-o", "{{.output}}", "{{.url}}"]. It
+	// can't be used with allowed_hosts.
+	DownloadCommand []string `json:"download_command,omitempty" yaml:"download_command,omitempty"`
+
+	// A command to run on each downloaded file before it is extracted, such as a virus scanner. bindown fails when the
+	// command exits non-zero. Each element is a template for one argument. "{{.file}}" is the downloaded file and
+	// "{{.url}}" is the url it came from. Files are scanned once when they are added to the cache. For example,
+	// ["clamscan", "--no-summary", "{{.file}}"].
+	ScanCommand []string `json:"scan_command,omitempty" yaml:"scan_command,omitempty"`
+
+	// The largest file bindown will download. Downloads are stopped as soon as they exceed this size. The value is a
+	// number of bytes with an optional unit like "500MB" or "1GiB". Dependencies can set their own max_download_size.
+	MaxDownloadSize string `json:"max_download_size,omitempty" yaml:"max_download_size,omitempty"`
+
+	// The longest bindown spends downloading, extracting and installing one dependency before giving up on it. The
+	// value is a duration like "90s" or "5m". When installing multiple dependencies, the rest are still installed
+	// after one times out. Dependencies can set their own timeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// How long responses from the GitHub and GitLab apis are cached when listing versions and updating dependencies.
+	// The value is a duration like "10m" or "1h". "0s" disables caching. Default is "10m".
+	APICacheTTL string `json:"api_cache_ttl,omitempty" yaml:"api_cache_ttl,omitempty"`
+
+	// Proxy settings for downloads. When this isn't set, bindown uses the proxy from the HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY environment variables.
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+
+	// The User-Agent header to send with requests, for proxies and artifact servers that allow or route requests by
+	// User-Agent. Default is "bindown/<version> (<os>/<arch>; +https://github.com/willabides/bindown)".
+	UserAgent string `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+
+	// Hosts that dependencies may download from. Any dependency whose url or zsync_url resolves to another host fails
+	// to build, so it can't be installed or validated. Presigned urls and GitHub Enterprise Server release asset
+	// requests are checked when they are made. It can't be used with download_command. "*.example.com" allows any
+	// subdomain of example.com. Redirects have to go to an allowed host too. Default is to allow any host.
+	AllowedHosts []string `json:"allowed_hosts,omitempty" yaml:"allowed_hosts,omitempty"`
+
+	// Hosts of GitHub Enterprise Server instances like "github.example.com". Release urls and template sources on these
+	// hosts work like the ones on github.com. Versions and release dates come from the instance's api at
+	// https://<host>/api/v3, and raw file urls like https://<host>/raw/<owner>/<repo>/<ref>/<path> can be pinned to a
+	// commit. When GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN is set, release assets are downloaded from the api with
+	// it so private repositories work.
+	GitHubEnterpriseHosts []string `json:"github_enterprise_hosts,omitempty" yaml:"github_enterprise_hosts,omitempty"`
+
+	// A service that has the checksums of urls that aren't in url_checksums, so many configs can share one audited
+	// store. A url is looked up with a GET request and the response is the sha256 checksum, optionally followed by
+	// whitespace and more text like sha256sum's output. A 404 means the service doesn't have the url. When the value
+	// is a go template, it gets the dependency's vars along with "url" and "name", like
+	// "https://checksums.example.com/{{.name}}/{{.version}}/{{.os}}-{{.arch}}". Otherwise, the url is added to it as the
+	// "url" query parameter. BINDOWN_CHECKSUM_SERVICE_TOKEN is sent as a bearer token when it is set. The service must
+	// use https unless BINDOWN_CHECKSUM_SERVICE_ALLOW_HTTP is set.
+	ChecksumService string `json:"checksum_service,omitempty" yaml:"checksum_service,omitempty"`
+
+	// Public keys bindown.lock can be signed with when installing with --require-signed-lock. Each is an ssh public
+	// key like "ssh-ed25519 AAAA..." or a minisign public key. These keys can be changed along with the lock file, so
+	// pipelines should pass the keys they trust with --lock-public-key instead.
+	LockPublicKeys []string `json:"lock_public_keys,omitempty" yaml:"lock_public_keys,omitempty"`
+
 	// List of systems supported by this config. Systems are in the form of os/architecture.
 	Systems []System `json:"systems,omitempty" yaml:"systems,omitempty"`
 
@@ -40,13 +132,61 @@ type Config struct {
 	// Templates that can be used by dependencies in this file.
 	Templates map[string]*Dependency `json:"templates,omitempty" yaml:",omitempty"`
 
-	// Upstream sources for templates.
+	// Reject override matchers and substitutions that refer to variables the dependency doesn't have. This catches
+	// typos that would otherwise silently never match.
+	Strict bool `json:"strict,omitempty" yaml:"strict,omitempty"`
+
+	// Upstream sources for templates. Each source is a path, an http(s) URL or an oci:// reference to an OCI artifact
+	// containing a config.
 	TemplateSources map[string]string `json:"template_sources,omitempty" yaml:"template_sources,omitempty"`
 
+	// Digests of template sources' content like "sha256:<hex>" keyed by source name. Templates aren't copied from a
+	// source whose content doesn't match its digest, so a changed upstream source can't silently change what
+	// "dependency add" produces. Set them with "bindown template-source pin".
+	TemplateSourceDigests map[string]string `json:"template_source_digests,omitempty" yaml:"template_source_digests,omitempty"`
+
+	// Local copies of template sources keyed by source name. Templates are copied from the local file instead of the
+	// source, so "dependency add" keeps working when the source is unreachable. Paths are relative to the config file.
+	// Create them with "bindown template-source export" and refresh them with "bindown template-source sync".
+	TemplateSourceMirrors map[string]string `json:"template_source_mirrors,omitempty" yaml:"template_source_mirrors,omitempty"`
+
 	// Checksums of downloaded files.
 	URLChecksums map[string]string `json:"url_checksums,omitempty" yaml:"url_checksums,omitempty"`
 
+	// Checksums of installed bins. Keys are the url_checksums key and the archive path separated by "#". When a
+	// dependency has a bin checksum, its extracted bin is verified before it is installed and "bindown verify" can
+	// check installed bins without downloading.
+	BinChecksums map[string]string `json:"bin_checksums,omitempty" yaml:"bin_checksums,omitempty"`
+
 	Filename string `json:"-" yaml:"-"`
+
+	// Environment is a label like "ci" or "airgapped" for where bindown is running. When it is set, dependencies get
+	// it in the "environment" var, so overrides can match on it to use a different url in each environment. It isn't
+	// part of the config file. The cli sets it from --environment.
+	Environment string `json:"-" yaml:"-"`
+
+	// AllowExtractCommand allows dependencies to extract downloads with their extract_command. It isn't part of the
+	// config file because a config from somewhere else shouldn't be able to run commands on its own. The cli sets it
+	// from --allow-extract-command.
+	AllowExtractCommand bool `json:"-" yaml:"-"`
+
+	// SchemaURL is the schema from the config file's yaml-language-server modeline. The modeline is kept when the
+	// config is written as yaml.
+	SchemaURL string `json:"-" yaml:"-"`
+
+	// batch is set while Batch is running.
+	batch *configBatch
+
+	// fragments are the files from ConfigFragmentDir that were merged into the config.
+	fragments []*configFragment
+
+	// lock is the config's LockFile. It is nil when there is none.
+	lock *LockFile
+	// lockRequired is set by RequireSignedLock.
+	lockRequired bool
+
+	// downloads coalesces downloads of the same url. Use downloadGroup to get it.
+	downloads *downloadGroup
 }
 
 func (c *Config) DependencyNames() []string {
@@ -134,7 +274,7 @@ func (c *Config) BinName(depName string, system System) (string, error) {
 func (c *Config) MissingDependencyVars(depName string) ([]string, error) {
 	dep := c.Dependencies[depName]
 	if dep == nil {
-		return nil, fmt.Errorf("no dependency configured with the name %q", depName)
+		return nil, c.unknownDependencyError(depName)
 	}
 	var result []string
 	dep = dep.clone()
@@ -154,16 +294,30 @@ func (c *Config) MissingDependencyVars(depName string) ([]string, error) {
 }
 
 // BuildDependency returns a dependency with templates and overrides applied and variables interpolated for the given system.
+// Errors are returned as *ConfigError.
 func (c *Config) BuildDependency(depName string, system System) (*Dependency, error) {
+	dep, err := c.buildDependency(depName, system)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	return dep, nil
+}
+
+func (c *Config) buildDependency(depName string, system System) (*Dependency, error) {
 	dep := c.Dependencies[depName]
 	if dep == nil {
-		return nil, fmt.Errorf("no dependency configured with the name %q", depName)
+		return nil, c.unknownDependencyError(depName)
 	}
 	dep = dep.clone()
 	err := dep.applyTemplate(c.Templates, 0)
 	if err != nil {
 		return nil, err
 	}
+	err = c.applyLockedVersion(depName, dep)
+	if err != nil {
+		return nil, err
+	}
+	c.setEnvironmentVar(dep)
 	err = dep.applyOverrides(system, 0)
 	if err != nil {
 		return nil, err
@@ -177,23 +331,84 @@ func (c *Config) BuildDependency(depName string, system System) (*Dependency, er
 	if _, ok := dep.Vars["arch"]; !ok {
 		dep.Vars["arch"] = system.Arch()
 	}
-	dep.Vars = varsWithSubstitutions(dep.Vars, dep.Substitutions)
-	err = dep.interpolateVars(system)
+	err = resolveVarExpressions(dep.Vars)
 	if err != nil {
 		return nil, err
 	}
+	dep.Vars = varsWithSubstitutions(dep.Vars, dep.Substitutions)
 	if dep.URL == nil {
 		return nil, fmt.Errorf("dependency %q has no URL", depName)
 	}
-	checksum := ""
-	if c.URLChecksums != nil && dep.URL != nil {
-		checksum = c.URLChecksums[*dep.URL]
+	urlTmpl := *dep.URL
+	err = dep.interpolateVars(system)
+	if err != nil {
+		return nil, err
+	}
+	dep.checksumKey, err = c.checksumKey(*dep.URL, urlTmpl, dep.Vars)
+	if err != nil {
+		return nil, err
 	}
 	dep.built = true
 	dep.name = depName
 	dep.system = system
-	dep.checksum = checksum
+	if c.lockRequired {
+		// url_checksums is in the unsigned config, so only the signed lock file can say what to download
+		locked := c.lockedDependency(depName)
+		if locked == nil || locked.Downloads[system].URL != *dep.URL {
+			return nil, &ConfigError{Err: fmt.Errorf(
+				`%s on %s isn't in the signed %s. run "bindown lock %s" and sign it again`,
+				depName, system, LockFileName, depName,
+			)}
+		}
+		dep.checksum = locked.Downloads[system].Checksum
+	} else {
+		dep.checksum = c.URLChecksums[dep.checksumKey]
+		if dep.checksum == "" {
+			dep.checksum = c.lockedChecksum(depName, system, *dep.URL)
+		}
+	}
+	dep.binChecksum = c.BinChecksums[dep.binChecksumKey()]
 	dep.url = *dep.URL
+	if len(c.AllowedHosts) > 0 && len(c.DownloadCommand) > 0 {
+		return nil, fmt.Errorf("download_command can't be used with allowed_hosts because the hosts it downloads from can't be checked")
+	}
+	err = c.checkAllowedHost(depName, "url", dep.url)
+	if err != nil {
+		return nil, err
+	}
+	if dep.ZsyncURL != nil {
+		dep.zsyncURL = *dep.ZsyncURL
+		err = c.checkAllowedHost(depName, "zsync_url", dep.zsyncURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dep.downloader = c.downloader()
+	dep.downloader.presignCommand = dep.PresignCommand
+	dep.downloader.workDir = c.workDir(dep)
+	dep.allowExtractCommand = c.AllowExtractCommand
+	maxSize := c.MaxDownloadSize
+	if dep.MaxDownloadSize != nil && *dep.MaxDownloadSize != "" {
+		maxSize = *dep.MaxDownloadSize
+	}
+	if maxSize != "" {
+		dep.downloader.maxSize, err = parseByteSize(maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_download_size for %q: %w", depName, err)
+		}
+	}
+	timeout := c.Timeout
+	if dep.Timeout != nil && *dep.Timeout != "" {
+		timeout = *dep.Timeout
+	}
+	if timeout != "" {
+		dep.downloader.timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for %q: %w", depName, err)
+		}
+	}
+	// the timeout covers everything done with the dependency from here on
+	dep.downloader.startTimeout()
 	return dep, nil
 }
 
@@ -206,7 +421,7 @@ func (c *Config) defaultSystems() []System {
 }
 
 // AddChecksums downloads, calculates checksums and adds them to the config's URLChecksums. AddChecksums skips urls that
-// already exist in URLChecksums.
+// already exist in URLChecksums. Checksums are added for the urls of every environment in Environments.
 func (c *Config) AddChecksums(dependencies []string, systems []System) error {
 	if len(dependencies) == 0 && c.Dependencies != nil {
 		dependencies = make([]string, 0, len(c.Dependencies))
@@ -225,10 +440,24 @@ func (c *Config) AddChecksums(dependencies []string, systems []System) error {
 		}
 		dp := c.Dependencies[depName]
 		if dp == nil {
-			return fmt.Errorf("no dependency configured with the name %q", depName)
+			return c.unknownDependencyError(depName)
 		}
-		for _, system := range depSystems {
-			err = c.addChecksum(depName, system)
+		err = c.addEnvironmentChecksums(depName, depSystems)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEnvironmentChecksums adds the checksums of depName for each of systems in every environment.
+func (c *Config) addEnvironmentChecksums(depName string, systems []System) error {
+	environment := c.Environment
+	defer func() { c.Environment = environment }()
+	for _, env := range c.Environments() {
+		c.Environment = env
+		for _, system := range systems {
+			err := c.addChecksum(depName, system)
 			if err != nil {
 				return err
 			}
@@ -237,29 +466,101 @@ func (c *Config) AddChecksums(dependencies []string, systems []System) error {
 	return nil
 }
 
-// PruneChecksums removes checksums for dependencies that are not used by any configured system.
-func (c *Config) PruneChecksums() error {
-	allURLS := make(map[string]bool, len(c.Dependencies)*8)
-	for depName := range c.Dependencies {
-		systems, err := c.DependencySystems(depName)
+// PruneChecksums removes checksums for dependencies that are not used by any configured system. It returns what was
+// removed and why.
+func (c *Config) PruneChecksums() ([]PrunedChecksum, error) {
+	pruned, err := c.PlanPruneChecksums()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pruned {
+		if p.Bin {
+			delete(c.BinChecksums, p.Key)
+		} else {
+			delete(c.URLChecksums, p.Key)
+		}
+	}
+	return pruned, nil
+}
+
+// RemoveDependency removes a dependency from the config. When prune is true, it also removes url_checksums that no
+// other dependency uses and templates from the dependency's template chain that no other dependency uses.
+func (c *Config) RemoveDependency(depName string, prune bool) error {
+	if c.Dependencies == nil || c.Dependencies[depName] == nil {
+		return fmt.Errorf("no dependency named %q", depName)
+	}
+	if !prune {
+		delete(c.Dependencies, depName)
+		return nil
+	}
+	depURLs, err := c.dependencyURLs(depName)
+	if err != nil {
+		return err
+	}
+	depTemplates := c.templateChain(c.Dependencies[depName])
+	delete(c.Dependencies, depName)
+
+	usedURLs := map[string]bool{}
+	usedTemplates := map[string]bool{}
+	for name, dep := range c.Dependencies {
+		var urls []string
+		urls, err = c.dependencyURLs(name)
 		if err != nil {
 			return err
 		}
+		for _, u := range urls {
+			usedURLs[u] = true
+		}
+		for _, tmpl := range c.templateChain(dep) {
+			usedTemplates[tmpl] = true
+		}
+	}
+	for _, u := range depURLs {
+		if !usedURLs[u] {
+			delete(c.URLChecksums, u)
+		}
+	}
+	for _, tmpl := range depTemplates {
+		if !usedTemplates[tmpl] {
+			delete(c.Templates, tmpl)
+		}
+	}
+	return nil
+}
+
+// dependencyURLs returns the url_checksums keys of a dependency for all of its systems in every environment
+func (c *Config) dependencyURLs(depName string) ([]string, error) {
+	systems, err := c.DependencySystems(depName)
+	if err != nil {
+		return nil, err
+	}
+	environment := c.Environment
+	defer func() { c.Environment = environment }()
+	var urls []string
+	for _, env := range c.Environments() {
+		c.Environment = env
 		for _, system := range systems {
 			var dep *Dependency
 			dep, err = c.BuildDependency(depName, system)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if !slices.Contains(urls, dep.checksumKey) {
+				urls = append(urls, dep.checksumKey)
 			}
-			allURLS[dep.url] = true
-		}
-	}
-	for u := range c.URLChecksums {
-		if !allURLS[u] {
-			delete(c.URLChecksums, u)
 		}
 	}
-	return nil
+	return urls, nil
+}
+
+// templateChain returns the names of the template dep uses and the templates that template uses
+func (c *Config) templateChain(dep *Dependency) []string {
+	var chain []string
+	for i := 0; dep != nil && dep.Template != nil && *dep.Template != "" && i < maxTemplateDepth; i++ {
+		chain = append(chain, *dep.Template)
+		dep = c.Templates[*dep.Template]
+	}
+	return chain
 }
 
 func (c *Config) addChecksum(dependencyName string, system System) error {
@@ -267,23 +568,45 @@ func (c *Config) addChecksum(dependencyName string, system System) error {
 	if err != nil {
 		return err
 	}
-	existingSum := c.URLChecksums[dep.url]
+	existingSum := c.URLChecksums[dep.checksumKey]
 	if existingSum != "" {
 		return nil
 	}
-	sum, err := getURLChecksum(dep.url, "")
+	sum, err := getURLChecksum(dep.url, "", dep.downloader)
 	if err != nil {
 		return err
 	}
 	if c.URLChecksums == nil {
 		c.URLChecksums = make(map[string]string, 1)
 	}
-	c.URLChecksums[dep.url] = sum
+	c.URLChecksums[dep.checksumKey] = sum
 	return nil
 }
 
 // Validate installs the downloader to a temporary directory and returns an error if it was unsuccessful.
-func (c *Config) Validate(depName string, systems []System) (errOut error) {
+// ValidateOpts provides options for Config.Validate
+type ValidateOpts struct {
+	// Jobs is how many systems are validated at once. Default is the number of CPUs.
+	Jobs int
+	// URLsOnly checks that each url can be downloaded with a HEAD request instead of installing. It can't be used
+	// with the config's DownloadCommand.
+	URLsOnly bool
+}
+
+// Validate installs depName for each of systems into a temporary directory with an empty cache, so every download is
+// fetched and checked against its checksum. Systems are validated concurrently. When systems is empty, all the
+// systems the dependency supports are validated.
+func (c *Config) Validate(depName string, systems []System, opts *ValidateOpts) (errOut error) {
+	if opts == nil {
+		opts = &ValidateOpts{}
+	}
+	if opts.URLsOnly {
+		if len(c.DownloadCommand) > 0 {
+			// a HEAD request doesn't check what download_command would download
+			return &ConfigError{Err: fmt.Errorf("--urls-only can't be used with download_command. validate without it to run the download command")}
+		}
+		return c.validateURLs(depName, systems, opts.Jobs)
+	}
 	tmpDir, err := os.MkdirTemp("", "bindown-validate")
 	if err != nil {
 		return err
@@ -291,11 +614,10 @@ func (c *Config) Validate(depName string, systems []System) (errOut error) {
 	defer deferErr(&errOut, func() error {
 		return os.RemoveAll(tmpDir)
 	})
-	installDir, cacheDir := c.InstallDir, c.Cache
-	c.InstallDir = filepath.Join(tmpDir, "bin")
+	cacheDir := c.Cache
 	c.Cache = filepath.Join(tmpDir, "cache")
 	defer func() {
-		c.InstallDir, c.Cache = installDir, cacheDir
+		c.Cache = cacheDir
 	}()
 	depSystems := systems
 	if len(depSystems) == 0 {
@@ -304,10 +626,109 @@ func (c *Config) Validate(depName string, systems []System) (errOut error) {
 			return err
 		}
 	}
-	for _, system := range depSystems {
-		err = c.InstallDependencies([]string{depName}, system, &ConfigInstallDependenciesOpts{
-			Force: true,
-		})
+	deps, err := c.withRequirements([]string{depName})
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(depSystems))
+	// each system gets its own copy of the config because installing can add trusted checksums to it. The download
+	// group is created first so the copies still share it.
+	c.downloadGroup()
+	systemConfigs := make([]*Config, len(depSystems))
+	for i := range systemConfigs {
+		systemConfigs[i] = c.clone()
+	}
+	forEachParallel(len(depSystems), opts.Jobs, func(i int) {
+		system := depSystems[i]
+		sc := systemConfigs[i]
+		// installing everything must not overwrite another dependency's bin with this one or its requirements
+		err := sc.checkInstallCollisions(sc.DependencyNames(), deps, system, sc.installPathTemplate)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		// each system gets its own install dir so concurrent installs don't write the same path
+		output := filepath.Join(tmpDir, "bin", strings.ReplaceAll(string(system), "/", "-"))
+		for _, name := range deps {
+			_, _, err := sc.installDependency(name, system, output, true, nil, &ConfigInstallDependenciesOpts{
+				Force: true,
+			})
+			if err != nil {
+				if len(depSystems) > 1 {
+					err = fmt.Errorf("%s: %w", system, err)
+				}
+				errs[i] = err
+				return
+			}
+		}
+	})
+	for _, sc := range systemConfigs {
+		for key, sum := range sc.URLChecksums {
+			if c.URLChecksums[key] != "" {
+				continue
+			}
+			if c.URLChecksums == nil {
+				c.URLChecksums = map[string]string{}
+			}
+			c.URLChecksums[key] = sum
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateURLs makes a HEAD request for the url and zsync_url of depName and its requirements on each of systems. It
+// catches urls that no longer exist without downloading anything.
+func (c *Config) validateURLs(depName string, systems []System, jobs int) error {
+	var err error
+	if len(systems) == 0 {
+		systems, err = c.DependencySystems(depName)
+		if err != nil {
+			return err
+		}
+	}
+	deps, err := c.withRequirements([]string{depName})
+	if err != nil {
+		return err
+	}
+	type urlCheck struct {
+		system System
+		url    string
+		dl     *downloader
+	}
+	var checks []urlCheck
+	seen := map[string]bool{}
+	for _, system := range systems {
+		for _, name := range deps {
+			dep, err := c.BuildDependency(name, system)
+			if err != nil {
+				return err
+			}
+			for _, u := range []string{dep.url, dep.zsyncURL} {
+				if u == "" || seen[u] {
+					continue
+				}
+				seen[u] = true
+				checks = append(checks, urlCheck{system: system, url: u, dl: dep.downloader})
+			}
+		}
+	}
+	errs := make([]error, len(checks))
+	forEachParallel(len(checks), jobs, func(i int) {
+		err := checks[i].dl.head(checks[i].url)
+		if err != nil && len(systems) > 1 {
+			err = fmt.Errorf("%s: %w", checks[i].system, err)
+		}
+		errs[i] = err
+	})
+	return errors.Join(errs...)
+}
+
+// ClearDownloads removes cached downloads but keeps extracted files. Installs keep using an extracted download without
+// downloading it again as long as its extract marker matches the dependency's checksum.
+func (c *Config) ClearDownloads() error {
+	dirs := append([]string{c.Cache}, c.dependencyCacheDirs()...)
+	for _, dir := range dirs {
+		err := cache.RemoveRoot(filepath.Join(dir, "downloads"))
 		if err != nil {
 			return err
 		}
@@ -316,7 +737,7 @@ func (c *Config) Validate(depName string, systems []System) (errOut error) {
 }
 
 func (c *Config) ClearCache() error {
-	err := cache.RemoveRoot(c.downloadsCache().Root)
+	err := cache.RemoveRoot(c.downloadsCache(nil).Root)
 	if err != nil {
 		return err
 	}
@@ -324,12 +745,136 @@ func (c *Config) ClearCache() error {
 	if err != nil {
 		return err
 	}
-	return os.RemoveAll(c.Cache)
+	err = os.RemoveAll(c.Cache)
+	if err != nil {
+		return err
+	}
+	// Dependencies with their own cache may share a directory with other things, so only what bindown puts there
+	// is removed.
+	for _, dir := range c.dependencyCacheDirs() {
+		for _, name := range []string{"downloads", "extracts", "bin"} {
+			err = cache.RemoveRoot(filepath.Join(dir, name))
+			if err != nil {
+				return err
+			}
+		}
+		err = os.RemoveAll(filepath.Join(dir, ".extract_sums"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencyCacheDirs returns the cache directories set by dependencies and templates.
+func (c *Config) dependencyCacheDirs() []string {
+	var dirs []string
+	for _, deps := range []map[string]*Dependency{c.Dependencies, c.Templates} {
+		for _, dep := range deps {
+			dir := c.dependencyCacheDir(dep, "")
+			if dir != "" && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// CacheKey returns a stable hash of the resolved dependencies for use as a CI cache key. It only changes when
+// something that affects the installed files changes, such as a dependency's url, checksum or bin name. When systems
+// is empty, each dependency's DependencySystems are used.
+func (c *Config) CacheKey(deps []string, systems []System) (string, error) {
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	deps = slices.Clone(deps)
+	slices.Sort(deps)
+	type keyEntry struct {
+		Name        string `json:"name"`
+		System      System `json:"system"`
+		URL         string `json:"url"`
+		Checksum    string `json:"checksum"`
+		ArchivePath string `json:"archive_path"`
+		BinName     string `json:"bin_name"`
+		Link        bool   `json:"link"`
+		InstallPath string `json:"install_path"`
+	}
+	var entries []keyEntry
+	for _, name := range deps {
+		depSystems := systems
+		if len(depSystems) == 0 {
+			var err error
+			depSystems, err = c.DependencySystems(name)
+			if err != nil {
+				return "", err
+			}
+		}
+		depSystems = slices.Clone(depSystems)
+		slices.Sort(depSystems)
+		for _, system := range depSystems {
+			dep, err := c.BuildDependency(name, system)
+			if err != nil {
+				return "", err
+			}
+			entry := keyEntry{
+				Name:        name,
+				System:      system,
+				URL:         dep.url,
+				Checksum:    dep.checksum,
+				InstallPath: c.installPathTemplate(dep),
+			}
+			if dep.ArchivePath != nil {
+				entry.ArchivePath = *dep.ArchivePath
+			}
+			if dep.BinName != nil {
+				entry.BinName = *dep.BinName
+			}
+			if dep.Link != nil {
+				entry.Link = *dep.Link
+			}
+			entries = append(entries, entry)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// dependencyCacheDir returns the cache directory for dep. It is defaultDir unless dep sets its own cache.
+func (c *Config) dependencyCacheDir(dep *Dependency, defaultDir string) string {
+	if dep == nil || dep.Cache == nil || *dep.Cache == "" {
+		return defaultDir
+	}
+	dir := filepath.FromSlash(*dep.Cache)
+	if !filepath.IsAbs(dir) && c.Filename != "" {
+		dir = filepath.Join(filepath.Dir(c.Filename), dir)
+	}
+	return dir
+}
+
+// workDir returns the directory for dep's temporary files. It is empty when the system temp directory should be used.
+func (c *Config) workDir(dep *Dependency) string {
+	if c.WorkDir == "" {
+		dir := c.dependencyCacheDir(dep, c.Cache)
+		if dir == "" {
+			return ""
+		}
+		return filepath.Join(dir, "tmp")
+	}
+	dir := filepath.FromSlash(c.WorkDir)
+	if !filepath.IsAbs(dir) && c.Filename != "" {
+		dir = filepath.Join(filepath.Dir(c.Filename), dir)
+	}
+	return dir
 }
 
-func (c *Config) downloadsCache() *cache.Cache {
+func (c *Config) downloadsCache(dep *Dependency) *cache.Cache {
 	return &cache.Cache{
-		Root: filepath.Join(c.Cache, "downloads"),
+		Root: filepath.Join(c.dependencyCacheDir(dep, c.Cache), "downloads"),
 	}
 }
 
@@ -350,6 +895,14 @@ type ConfigDownloadDependenciesOpts struct {
 	AllowMissingChecksum bool
 	AllDeps              bool
 	Stdout               io.Writer
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+	// Output is where downloads are copied instead of being left in the cache. When downloading a single dependency
+	// it is the file to write unless it is an existing directory. Otherwise, it is a directory and each download keeps
+	// its file name.
+	Output string
+	// Porcelain gets a record for each download.
+	Porcelain *Porcelain
 }
 
 func (c *Config) DownloadDependencies(deps []string, system System, opts *ConfigDownloadDependenciesOpts) error {
@@ -359,28 +912,63 @@ func (c *Config) DownloadDependencies(deps []string, system System, opts *Config
 	if opts.AllDeps {
 		deps = c.DependencyNames()
 	}
+	var errs []error
 	for _, name := range deps {
-		dep, err := c.BuildDependency(name, system)
-		if err != nil {
-			return err
-		}
-		dlFile, _, unlock, err := downloadDependency(dep, c.downloadsCache(), opts.AllowMissingChecksum, opts.Force)
+		dlFile, err := c.downloadTo(name, system, len(deps) > 1, opts)
 		if err != nil {
-			return err
+			// keep going when downloading multiple dependencies so one failure doesn't hide the others
+			if len(deps) == 1 {
+				return err
+			}
+			errs = append(errs, &DependencyError{Dependency: name, Err: err})
+			err = opts.Porcelain.write(PorcelainFailed, name, system, "", err)
+			if err != nil {
+				return err
+			}
+			continue
 		}
-		err = unlock()
+		err = opts.Porcelain.write(PorcelainDownloaded, name, system, dlFile, nil)
 		if err != nil {
 			return err
 		}
 		if opts.Stdout == nil {
 			continue
 		}
-		_, err = fmt.Fprintf(opts.Stdout, "downloaded %s to %s\n", dep.name, dlFile)
+		_, err = fmt.Fprintf(opts.Stdout, "downloaded %s to %s\n", name, dlFile)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// downloadTo downloads name and copies it to opts.Output if it is set. It returns the path to the download.
+func (c *Config) downloadTo(name string, system System, multiple bool, opts *ConfigDownloadDependenciesOpts) (string, error) {
+	dep, err := c.buildSupported(name, system, opts.Stderr)
+	if err != nil {
+		return "", err
+	}
+	allowMissing, err := c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+	if err != nil {
+		return "", err
+	}
+	dlFile, _, unlock, err := downloadDependency(dep, c.downloadsCache(dep), allowMissing, opts.Force)
+	if err != nil {
+		return "", err
+	}
+	c.trustChecksum(dep)
+	if opts.Output == "" {
+		return dlFile, unlock()
+	}
+	target := opts.Output
+	if multiple || dirExists(target) {
+		target = filepath.Join(target, filepath.Base(dlFile))
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0o755)
+	if err == nil {
+		err = copyFile(dlFile, target)
+	}
+	return target, errors.Join(err, unlock())
 }
 
 func urlFilename(dlURL string) (string, error) {
@@ -394,7 +982,16 @@ func urlFilename(dlURL string) (string, error) {
 type ConfigExtractDependenciesOpts struct {
 	AllowMissingChecksum bool
 	AllDeps              bool
-	Stdout               io.Writer
+	// Stream extracts tar-based archives while they download instead of caching the download first.
+	Stream bool
+	Stdout io.Writer
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+	// Output is a directory the extracted files are copied to instead of being left in the cache. When extracting
+	// multiple dependencies, each is copied to a subdirectory named for the dependency.
+	Output string
+	// Porcelain gets a record for each extracted dependency.
+	Porcelain *Porcelain
 }
 
 func (c *Config) ExtractDependencies(deps []string, system System, opts *ConfigExtractDependenciesOpts) error {
@@ -404,32 +1001,59 @@ func (c *Config) ExtractDependencies(deps []string, system System, opts *ConfigE
 	if opts.AllDeps {
 		deps = c.DependencyNames()
 	}
+	var errs []error
 	for _, name := range deps {
-		dep, err := c.BuildDependency(name, system)
-		if err != nil {
-			return err
-		}
-		dlFile, key, dlUnlock, err := downloadDependency(dep, c.downloadsCache(), opts.AllowMissingChecksum, false)
-		if err != nil {
-			return err
-		}
-		outDir, unlock, err := extractDependencyToCache(dlFile, c.Cache, key, c.extractsCache(), false)
+		outDir, err := c.extractTo(name, system, len(deps) > 1, opts)
 		if err != nil {
-			return errors.Join(dlUnlock(), err)
+			// keep going when extracting multiple dependencies so one failure doesn't hide the others
+			if len(deps) == 1 {
+				return err
+			}
+			errs = append(errs, &DependencyError{Dependency: name, Err: err})
+			err = opts.Porcelain.write(PorcelainFailed, name, system, "", err)
+			if err != nil {
+				return err
+			}
+			continue
 		}
-		err = errors.Join(dlUnlock(), unlock())
+		err = opts.Porcelain.write(PorcelainExtracted, name, system, outDir, nil)
 		if err != nil {
 			return err
 		}
 		if opts.Stdout == nil {
 			continue
 		}
-		_, err = fmt.Fprintf(opts.Stdout, "extracted %s to %s\n", dep.name, outDir)
+		_, err = fmt.Fprintf(opts.Stdout, "extracted %s to %s\n", name, outDir)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// extractTo extracts name and copies it to opts.Output if it is set. It returns the path to the extracted files.
+func (c *Config) extractTo(name string, system System, multiple bool, opts *ConfigExtractDependenciesOpts) (string, error) {
+	dep, err := c.buildSupported(name, system, opts.Stderr)
+	if err != nil {
+		return "", err
+	}
+	allowMissing, err := c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+	if err != nil {
+		return "", err
+	}
+	outDir, unlock, err := downloadAndExtract(dep, c.dependencyCacheDir(dep, c.Cache), false, allowMissing, opts.Stream)
+	if err != nil {
+		return "", err
+	}
+	c.trustChecksum(dep)
+	if opts.Output == "" {
+		return outDir, unlock()
+	}
+	target := opts.Output
+	if multiple {
+		target = filepath.Join(target, name)
+	}
+	return target, errors.Join(copyDir(outDir, target), unlock())
 }
 
 // ConfigInstallDependencyOpts provides options for Config.InstallDependency
@@ -446,13 +1070,48 @@ type ConfigInstallDependencyOpts struct {
 
 // ConfigInstallDependenciesOpts provides options for Config.InstallDependencies
 type ConfigInstallDependenciesOpts struct {
-	Output               string
+	Output string
+	// OutputIsDir makes Output a directory that dependencies are installed to with their default bin names even when
+	// a single dependency is installed.
+	OutputIsDir          bool
 	TargetDir            string
 	Stdout               io.Writer
 	Force                bool
 	AllowMissingChecksum bool
 	ToCache              bool
 	AllDeps              bool
+	// Stream extracts tar-based archives while they download instead of caching the download first.
+	Stream bool
+	// SystemPath is used by InstallDependenciesForSystems. It is a template for the path relative to the output
+	// directory where each system's bin is installed. In addition to the dependency's vars, the template can use
+	// "name" for the dependency name and "bin" for the bin name. Default is DefaultSystemPath.
+	SystemPath string
+	// Color colors the status at the start of each line written to Stdout.
+	Color bool
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+	// AddToCIPath adds the directories dependencies are installed in to PATH for later steps of the CI job bindown is
+	// running in. Not supported by InstallDependenciesForSystems.
+	AddToCIPath bool
+	// CIStdout is the process's stdout, where AddToCIPath writes Azure Pipelines logging commands. It is required for
+	// AddToCIPath on Azure Pipelines.
+	CIStdout io.Writer
+	// Metrics gets the download size, cache hits and timing of each dependency installed.
+	Metrics *InstallMetrics
+	// Journal keeps an InstallJournal at InstallJournalPath while installing. Dependencies the journal says were
+	// installed by an earlier run are skipped unless Force is set or the installed file changed. The journal is
+	// removed once everything is installed. Output is always a directory with a journal. Not supported by
+	// InstallDependenciesForSystems.
+	Journal bool
+	// Porcelain gets a record for each dependency installed, skipped or failed.
+	Porcelain *Porcelain
+	// Manifest writes an InstallManifest listing the installed dependencies to InstallManifestFile in the output
+	// directory. It is only written when installing to a directory. Not supported with ToCache or by
+	// InstallDependenciesForSystems.
+	Manifest bool
+	// Universal installs a macOS universal binary made from the darwin/amd64 and darwin/arm64 bins of dependencies
+	// that support both when installing for darwin. Not supported with ToCache or by InstallDependenciesForSystems.
+	Universal bool
 }
 
 func (c *Config) InstallDependencies(deps []string, system System, opts *ConfigInstallDependenciesOpts) error {
@@ -463,38 +1122,294 @@ func (c *Config) InstallDependencies(deps []string, system System, opts *ConfigI
 		deps = c.DependencyNames()
 	}
 	output := opts.Output
-	outputIsDir := opts.AllDeps || len(deps) > 1
+	outputIsDir := opts.AllDeps || len(deps) > 1 || opts.Journal || opts.OutputIsDir
 	if output == "" {
 		output = c.InstallDir
 		outputIsDir = true
 	}
-	for _, name := range deps {
-		dep, err := c.BuildDependency(name, system)
+	requested := deps
+	deps, err := c.withRequirements(deps)
+	if err != nil {
+		return err
+	}
+	if outputIsDir && !opts.ToCache {
+		err = c.checkInstallCollisions(deps, nil, system, c.installPathTemplate)
 		if err != nil {
 			return err
 		}
-		target := output
-		if outputIsDir {
-			target = filepath.Join(output, dep.binName())
+	}
+	var journal *InstallJournal
+	journalPath := c.InstallJournalPath()
+	if opts.Journal && !opts.ToCache {
+		journal, err = startInstallJournal(journalPath, system, output, deps)
+		if err != nil {
+			return err
+		}
+	}
+	var errs []error
+	var binDirs []string
+	installed := map[string]string{}
+	for _, name := range deps {
+		depOutput, depOutputIsDir := output, outputIsDir
+		// output is a file path for the requested dependency, so its requirements go to the install dir
+		if !outputIsDir && !slices.Contains(requested, name) {
+			depOutput, depOutputIsDir = c.InstallDir, true
 		}
-		out, err := install(dep, target, c.Cache, opts.Force, opts.ToCache, opts.AllowMissingChecksum)
+		out, skipped, err := c.installDependency(name, system, depOutput, depOutputIsDir, journal, opts)
+		if err != nil {
+			journal.markFailed(name, err)
+		}
+		journalErr := journal.write(journalPath, false)
+		if journalErr != nil {
+			return errors.Join(err, journalErr)
+		}
+		if err == nil && opts.AddToCIPath {
+			binDir := filepath.Dir(out)
+			if !slices.Contains(binDirs, binDir) {
+				binDirs = append(binDirs, binDir)
+			}
+		}
+		if err != nil {
+			// keep going when installing multiple dependencies so one failure doesn't hide the others
+			if len(deps) == 1 {
+				return err
+			}
+			errs = append(errs, &DependencyError{Dependency: name, Err: err})
+			err = opts.Porcelain.write(PorcelainFailed, name, system, "", err)
+			if err != nil {
+				return err
+			}
+			if opts.Stdout != nil {
+				err = writeStatus(opts.Stdout, opts.Color, statusFailed, name)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		installed[name] = out
+		err = opts.Porcelain.writeInstalled(skipped, name, system, out)
 		if err != nil {
 			return err
 		}
 		if opts.Stdout == nil {
 			continue
 		}
-		if !opts.ToCache {
-			out = fmt.Sprintf("installed %s to %s", dep.name, out)
+		if opts.ToCache {
+			_, err = fmt.Fprintln(opts.Stdout, out)
+		} else {
+			err = writeInstallStatus(opts.Stdout, opts.Color, skipped, name, out)
 		}
-		_, err = fmt.Fprintln(opts.Stdout, out)
 		if err != nil {
 			return err
 		}
 	}
+	if len(binDirs) > 0 {
+		errs = append(errs, addToCIPath(binDirs, opts.CIStdout))
+	}
+	if opts.Manifest && outputIsDir && !opts.ToCache {
+		errs = append(errs, c.writeInstallManifest(output, system, installed))
+	}
+	errs = append(errs, journal.write(journalPath, true))
+	return errors.Join(errs...)
+}
+
+func (c *Config) installDependency(
+	name string,
+	system System,
+	output string,
+	outputIsDir bool,
+	journal *InstallJournal,
+	opts *ConfigInstallDependenciesOpts,
+) (_ string, skipped bool, errOut error) {
+	dep, err := c.buildSupported(name, system, opts.Stderr)
+	if err != nil {
+		return "", false, err
+	}
+	dep.metrics = opts.Metrics.start(dep)
+	defer func() { dep.metrics.finish(errOut) }()
+	target := output
+	if outputIsDir {
+		var installPath string
+		installPath, err = dep.installPath(c.installPathTemplate(dep))
+		if err != nil {
+			return "", false, err
+		}
+		target = filepath.Join(output, installPath)
+	}
+	// --force must reinstall what an earlier run recorded as installed
+	if !opts.Force {
+		var journaled bool
+		journaled, err = journal.installed(dep, target)
+		if err != nil {
+			return "", false, err
+		}
+		if journaled {
+			return target, true, nil
+		}
+	}
+	if opts.Universal && !opts.ToCache {
+		var universal []*Dependency
+		universal, err = c.universalDependencies(dep)
+		if err != nil {
+			return "", false, err
+		}
+		if universal != nil {
+			out, skipped, err := c.installUniversal(universal, target, opts)
+			if err != nil {
+				return "", false, err
+			}
+			if !skipped && system == CurrentSystem {
+				err = runValidateCommand(dep, out)
+				if err != nil {
+					return "", false, errors.Join(err, os.RemoveAll(out))
+				}
+			}
+			journal.markInstalled(dep, out)
+			return out, skipped, nil
+		}
+	}
+	allowMissing, err := c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+	if err != nil {
+		return "", false, err
+	}
+	out, skipped, err := install(dep, target, c.dependencyCacheDir(dep, c.Cache), opts.Force, opts.ToCache, allowMissing, opts.Stream)
+	if err != nil {
+		return "", false, dep.downloader.wrapTimeout(err)
+	}
+	if !skipped && system == CurrentSystem {
+		err = runValidateCommand(dep, out)
+		if err != nil {
+			// the bin would be skipped as up to date next time, so it can't stay installed without passing
+			return "", false, errors.Join(err, os.RemoveAll(out))
+		}
+	}
+	c.trustChecksum(dep)
+	journal.markInstalled(dep, out)
+	return out, skipped, nil
+}
+
+// DependencyInstallPath returns the path where InstallDependencies installs depName for system when no output is set.
+func (c *Config) DependencyInstallPath(depName string, system System) (string, error) {
+	dep, err := c.BuildDependency(depName, system)
+	if err != nil {
+		return "", err
+	}
+	installPath, err := dep.installPath(c.installPathTemplate(dep))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.InstallDir, installPath), nil
+}
+
+// installPathTemplate returns the install path template for a built dependency.
+func (c *Config) installPathTemplate(dep *Dependency) string {
+	if dep.InstallPath != nil && *dep.InstallPath != "" {
+		return *dep.InstallPath
+	}
+	if c.InstallPath != "" {
+		return c.InstallPath
+	}
+	return "{{.bin}}"
+}
+
+// DefaultSystemPath is the default template for the path where InstallDependenciesForSystems installs each
+// system's bin. It is relative to the output directory.
+const DefaultSystemPath = "{{.os}}-{{.arch}}/{{.bin}}"
+
+// InstallDependenciesForSystems installs deps for each of systems into per-system paths under the output directory.
+// When systems is empty, each dependency is installed for all the systems it supports.
+func (c *Config) InstallDependenciesForSystems(deps []string, systems []System, opts *ConfigInstallDependenciesOpts) error {
+	if opts == nil {
+		opts = &ConfigInstallDependenciesOpts{}
+	}
+	if opts.ToCache {
+		return fmt.Errorf("cannot install to cache for multiple systems")
+	}
+	if opts.AllDeps {
+		deps = c.DependencyNames()
+	}
+	deps, err := c.withRequirements(deps)
+	if err != nil {
+		return err
+	}
+	output := opts.Output
+	if output == "" {
+		output = c.InstallDir
+	}
+	systemPath := opts.SystemPath
+	if systemPath == "" {
+		systemPath = DefaultSystemPath
+	}
+	depSystems := make(map[string][]System, len(deps))
+	var allSystems []System
+	for _, name := range deps {
+		depSystems[name] = systems
+		if len(systems) == 0 {
+			depSystems[name], err = c.DependencySystems(name)
+			if err != nil {
+				return err
+			}
+		}
+		for _, system := range depSystems[name] {
+			if !slices.Contains(allSystems, system) {
+				allSystems = append(allSystems, system)
+			}
+		}
+	}
+	for _, system := range allSystems {
+		err = c.checkInstallCollisions(deps, nil, system, func(*Dependency) string { return systemPath })
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range deps {
+		for _, system := range depSystems[name] {
+			err := c.installDependencyForSystem(name, system, output, systemPath, opts)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+func (c *Config) installDependencyForSystem(
+	name string,
+	system System,
+	output, systemPath string,
+	opts *ConfigInstallDependenciesOpts,
+) (errOut error) {
+	dep, err := c.buildSupported(name, system, opts.Stderr)
+	if err != nil {
+		return err
+	}
+	dep.metrics = opts.Metrics.start(dep)
+	defer func() { dep.metrics.finish(errOut) }()
+	target, err := dep.installPath(systemPath)
+	if err != nil {
+		return err
+	}
+	target = filepath.Join(output, target)
+	allowMissing, err := c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+	if err != nil {
+		return err
+	}
+	out, skipped, err := install(dep, target, c.dependencyCacheDir(dep, c.Cache), opts.Force, false, allowMissing, opts.Stream)
+	if err != nil {
+		return dep.downloader.wrapTimeout(err)
+	}
+	c.trustChecksum(dep)
+	err = opts.Porcelain.writeInstalled(skipped, dep.name, system, out)
+	if err != nil {
+		return err
+	}
+	if opts.Stdout == nil {
+		return nil
+	}
+	return writeInstallStatus(opts.Stdout, opts.Color, skipped, fmt.Sprintf("%s for %s", dep.name, system), out)
+}
+
 type ConfigWrapDependenciesOpts struct {
 	Output               string
 	BindownExec          string
@@ -504,6 +1419,8 @@ type ConfigWrapDependenciesOpts struct {
 	AllowMissingChecksum bool
 	AllDeps              bool
 	Stdout               io.Writer
+	// Porcelain gets a record for each wrapper.
+	Porcelain *Porcelain
 }
 
 func (c *Config) WrapDependencies(deps []string, opts *ConfigWrapDependenciesOpts) error {
@@ -544,6 +1461,10 @@ func (c *Config) WrapDependencies(deps []string, opts *ConfigWrapDependenciesOpt
 		if err != nil {
 			return err
 		}
+		err = opts.Porcelain.write(PorcelainWrapped, "bindown", "", out, nil)
+		if err != nil {
+			return err
+		}
 		if opts.Stdout != nil {
 			_, err = fmt.Fprintln(opts.Stdout, out)
 			if err != nil {
@@ -570,7 +1491,15 @@ func (c *Config) WrapDependencies(deps []string, opts *ConfigWrapDependenciesOpt
 		if name == "bindown" && wrapsSelf {
 			continue
 		}
-		out, err := createWrapper(name, target, bindownExec, c.Cache, c.Filename, opts.AllowMissingChecksum)
+		exportEnv, err := c.exportsEnv(name)
+		if err != nil {
+			return err
+		}
+		out, err := createWrapper(name, target, bindownExec, c.Cache, c.Filename, opts.AllowMissingChecksum, exportEnv)
+		if err != nil {
+			return err
+		}
+		err = opts.Porcelain.write(PorcelainWrapped, name, "", out, nil)
 		if err != nil {
 			return err
 		}
@@ -630,7 +1559,18 @@ func (c *Config) addOrGetTemplate(ctx context.Context, name, src string) (destNa
 		return destName, nil, nil
 	}
 	if src == "" {
-		return "", nil, fmt.Errorf("no template named %q", name)
+		builtin, err := builtinTemplates(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		if builtin.Templates[name] == nil {
+			return "", nil, fmt.Errorf("no template named %q", name)
+		}
+		src = BuiltinTemplateSource
+		destName = fmt.Sprintf("%s#%s", src, name)
+		if _, ok := c.Templates[destName]; ok {
+			return destName, nil, nil
+		}
 	}
 	tmplSrc := src
 	tmplSrcs := c.TemplateSources
@@ -650,10 +1590,10 @@ func (c *Config) addOrGetTemplate(ctx context.Context, name, src string) (destNa
 
 // CopyTemplateFromSource copies a template from source
 func (c *Config) CopyTemplateFromSource(ctx context.Context, src, srcTemplate, destName string) error {
-	if c.TemplateSources == nil {
-		return fmt.Errorf("no template source named %q", src)
-	}
 	tmplSrc := c.TemplateSources[src]
+	if tmplSrc == "" && src == BuiltinTemplateSource {
+		tmplSrc = BuiltinTemplateSource
+	}
 	if tmplSrc == "" {
 		return fmt.Errorf("no template source named %q", src)
 	}
@@ -663,7 +1603,7 @@ func (c *Config) CopyTemplateFromSource(ctx context.Context, src, srcTemplate, d
 
 // addTemplateFromSource copies a template from another config file
 func (c *Config) addTemplateFromSource(ctx context.Context, src, srcTemplate, destName string) (map[string][]string, error) {
-	srcCfg, err := NewConfig(ctx, src, true)
+	srcCfg, err := c.loadTemplateSource(ctx, src)
 	if err != nil {
 		return nil, err
 	}
@@ -709,10 +1649,13 @@ func (c *Config) ListTemplates(ctx context.Context, templateSource string) ([]st
 }
 
 func (c *Config) templateSourceConfig(ctx context.Context, name string) (*Config, error) {
+	if c.TemplateSources[name] == "" && name == BuiltinTemplateSource {
+		return builtinTemplates(ctx)
+	}
 	if c.TemplateSources == nil || c.TemplateSources[name] == "" {
 		return nil, fmt.Errorf("no template source named %q", name)
 	}
-	return NewConfig(ctx, c.TemplateSources[name], true)
+	return c.loadTemplateSource(ctx, c.TemplateSources[name])
 }
 
 // DependencySystems returns the supported systems of either the config or the dependency if one is not empty
@@ -748,33 +1691,164 @@ func (c *Config) DependencySystems(depName string) ([]System, error) {
 	return result, nil
 }
 
+// WriteFile writes the config to c.Filename. The file is replaced in one step so it is never left partially written.
+// Inside Batch, the write is put off until the batch is done. When the config was merged with files from
+// ConfigFragmentDir, what came from each file is written back to it.
 func (c *Config) WriteFile(outputJSON bool) (errOut error) {
 	if c.Filename == "" {
 		return fmt.Errorf("no filename specified")
 	}
+	if c.batch != nil {
+		c.batch.written = true
+		c.batch.outputJSON = c.batch.outputJSON || outputJSON
+		return nil
+	}
+	if len(c.fragments) > 0 {
+		return c.writeFragments(outputJSON)
+	}
+	return c.writeFile(outputJSON)
+}
+
+// writeFile writes the config to c.Filename. When c.Filename is a symlink, the file it points to is written.
+func (c *Config) writeFile(outputJSON bool) (errOut error) {
 	if filepath.Ext(c.Filename) == ".json" {
 		outputJSON = true
 	}
-	file, err := os.Create(c.Filename)
+	filename, err := filepath.EvalSymlinks(c.Filename)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		filename = c.Filename
+	}
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errOut != nil {
+			errOut = errors.Join(errOut, os.Remove(file.Name()))
+		}
+	}()
+	mode := os.FileMode(0o644)
+	info, err := os.Stat(filename)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+	err = file.Chmod(mode)
+	if err == nil {
+		err = c.encode(file, outputJSON)
+	}
+	err = errors.Join(err, file.Close())
 	if err != nil {
 		return err
 	}
-	defer deferErr(&errOut, file.Close)
+	return os.Rename(file.Name(), filename)
+}
+
+func (c *Config) encode(w io.Writer, outputJSON bool) error {
 	slices.Sort(c.Systems)
 	if outputJSON {
-		encoder := json.NewEncoder(file)
+		encoder := json.NewEncoder(w)
 		encoder.SetIndent("", "  ")
 		return encoder.Encode(c)
 	}
-	return EncodeYaml(file, &c)
+	if c.SchemaURL != "" {
+		_, err := fmt.Fprintln(w, SchemaHeader(c.SchemaURL))
+		if err != nil {
+			return err
+		}
+	}
+	return EncodeYaml(w, &c)
 }
 
-// NewConfig loads a config from a URL
+// configBatch tracks writes put off by Config.Batch.
+type configBatch struct {
+	written    bool
+	outputJSON bool
+}
+
+// clone returns a copy of c that can be changed without changing c. The lock file, config fragments and download
+// group are shared.
+func (c *Config) clone() *Config {
+	cc := *c
+	cc.DownloadCommand = slices.Clone(c.DownloadCommand)
+	cc.ScanCommand = slices.Clone(c.ScanCommand)
+	if c.Proxy != nil {
+		proxy := *c.Proxy
+		proxy.Hosts = maps.Clone(c.Proxy.Hosts)
+		proxy.NoProxy = slices.Clone(c.Proxy.NoProxy)
+		cc.Proxy = &proxy
+	}
+	cc.AllowedHosts = slices.Clone(c.AllowedHosts)
+	cc.GitHubEnterpriseHosts = slices.Clone(c.GitHubEnterpriseHosts)
+	cc.LockPublicKeys = slices.Clone(c.LockPublicKeys)
+	cc.Systems = slices.Clone(c.Systems)
+	cc.Dependencies = cloneDependencies(c.Dependencies)
+	cc.Templates = cloneDependencies(c.Templates)
+	cc.TemplateSources = maps.Clone(c.TemplateSources)
+	cc.TemplateSourceDigests = maps.Clone(c.TemplateSourceDigests)
+	cc.TemplateSourceMirrors = maps.Clone(c.TemplateSourceMirrors)
+	cc.URLChecksums = maps.Clone(c.URLChecksums)
+	cc.BinChecksums = maps.Clone(c.BinChecksums)
+	return &cc
+}
+
+func cloneDependencies(deps map[string]*Dependency) map[string]*Dependency {
+	if deps == nil {
+		return nil
+	}
+	cloned := make(map[string]*Dependency, len(deps))
+	for name, dep := range deps {
+		if dep != nil {
+			dep = dep.clone()
+		}
+		cloned[name] = dep
+	}
+	return cloned
+}
+
+// Batch runs fn and writes the config once when it is done. WriteFile calls made by fn are put off until then, so a
+// series of changes is written together. When fn returns an error, the config is restored to how it was before
+// fn and nothing is written. Batches can be nested. Only the outermost one writes.
+func (c *Config) Batch(outputJSON bool, fn func() error) error {
+	if c.batch != nil {
+		return fn()
+	}
+	snapshot := c.clone()
+	before, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	c.batch = &configBatch{outputJSON: outputJSON}
+	err = fn()
+	batch := c.batch
+	c.batch = nil
+	if err != nil {
+		*c = *snapshot
+		return err
+	}
+	if !batch.written {
+		after, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			return nil
+		}
+	}
+	return c.WriteFile(batch.outputJSON)
+}
+
+// NewConfig loads a config from a file, an http(s) URL or an oci:// reference to an artifact in an OCI registry.
 func NewConfig(ctx context.Context, cfgSrc string, noDefaultDirs bool) (*Config, error) {
 	cfgURL, err := url.Parse(cfgSrc)
 	if err == nil {
-		if cfgURL.Scheme == "http" || cfgURL.Scheme == "https" {
+		switch cfgURL.Scheme {
+		case "http", "https":
 			return configFromHTTP(ctx, cfgSrc)
+		case "oci":
+			return configFromOCI(ctx, cfgSrc)
 		}
 	}
 	data, err := os.ReadFile(cfgSrc)
@@ -786,6 +1860,14 @@ func NewConfig(ctx context.Context, cfgSrc string, noDefaultDirs bool) (*Config,
 		return nil, err
 	}
 	cfg.Filename = cfgSrc
+	err = cfg.mergeConfigFragments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.readLockFile()
+	if err != nil {
+		return nil, err
+	}
 	if noDefaultDirs {
 		return cfg, nil
 	}
@@ -837,26 +1919,38 @@ func findCacheDir(cfgDir string) (string, error) {
 }
 
 func configFromHTTP(ctx context.Context, src string) (*Config, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", src, http.NoBody)
+	data, err := httpConfigData(ctx, src)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	return ConfigFromYAML(ctx, data)
+}
+
+// httpConfigData returns the content of the config at an http(s) url.
+func httpConfigData(ctx context.Context, src string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("error downloading %q", src)
-	}
-	data, err := io.ReadAll(resp.Body)
+	resp, err := defaultHTTPClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
-	return ConfigFromYAML(ctx, data)
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return nil, &downloadError{err: fmt.Errorf("error downloading %q", src)}
+	}
+	return io.ReadAll(resp.Body)
 }
 
 func ConfigFromYAML(ctx context.Context, data []byte) (*Config, error) {
-	err := validateConfig(ctx, data)
+	// check the version first so older versions report that they are too old instead of complaining about new
+	// properties
+	err := checkMinBindownVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	err = validateConfig(ctx, data)
 	if err != nil {
 		return nil, err
 	}
@@ -867,5 +1961,6 @@ func ConfigFromYAML(ctx context.Context, data []byte) (*Config, error) {
 	}
 	cfg.Cache = filepath.FromSlash(cfg.Cache)
 	cfg.InstallDir = filepath.FromSlash(cfg.InstallDir)
+	cfg.SchemaURL = schemaHeaderURL(data)
 	return &cfg, nil
 }
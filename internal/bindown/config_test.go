@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/willabides/bindown/v4/internal/testutil"
 )
@@ -41,6 +44,99 @@ dependencies:
 	})
 }
 
+func TestConfig_Batch(t *testing.T) {
+	original := `dependencies:
+  foo:
+    vars:
+      version: 1.0.0
+`
+	setup := func(t *testing.T) *Config {
+		t.Helper()
+		filename := filepath.Join(t.TempDir(), "bindown.yaml")
+		require.NoError(t, os.WriteFile(filename, []byte(original), 0o600))
+		cfg, err := NewConfig(context.Background(), filename, true)
+		require.NoError(t, err)
+		return cfg
+	}
+	readConfig := func(t *testing.T, cfg *Config) string {
+		t.Helper()
+		content, err := os.ReadFile(cfg.Filename)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	t.Run("writes once", func(t *testing.T) {
+		cfg := setup(t)
+		err := cfg.Batch(false, func() error {
+			require.NoError(t, cfg.SetDependencyVars("foo", map[string]string{"version": "2.0.0"}))
+			require.NoError(t, cfg.WriteFile(false))
+			// the write is put off until the batch is done
+			require.Equal(t, original, readConfig(t, cfg))
+			return cfg.SetDependencyVars("foo", map[string]string{"bar": "baz"})
+		})
+		require.NoError(t, err)
+		require.Equal(t, `dependencies:
+  foo:
+    vars:
+      bar: baz
+      version: 2.0.0
+`, readConfig(t, cfg))
+		info, err := os.Stat(cfg.Filename)
+		require.NoError(t, err)
+		if runtime.GOOS != "windows" {
+			require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+		entries, err := os.ReadDir(filepath.Dir(cfg.Filename))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		cfg := setup(t)
+		cfg.Environment = "ci"
+		cfg.AllowExtractCommand = true
+		err := cfg.Batch(false, func() error {
+			require.NoError(t, cfg.SetDependencyVars("foo", map[string]string{"version": "2.0.0"}))
+			return fmt.Errorf("oops")
+		})
+		require.EqualError(t, err, "oops")
+		require.Equal(t, original, readConfig(t, cfg))
+		require.Equal(t, "1.0.0", cfg.Dependencies["foo"].Vars["version"])
+		// settings that aren't in the file are kept too
+		require.Equal(t, "ci", cfg.Environment)
+		require.True(t, cfg.AllowExtractCommand)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks")
+		}
+		cfg := setup(t)
+		link := filepath.Join(t.TempDir(), "bindown.yaml")
+		require.NoError(t, os.Symlink(cfg.Filename, link))
+		target := cfg.Filename
+		cfg.Filename = link
+		err := cfg.Batch(false, func() error {
+			return cfg.SetDependencyVars("foo", map[string]string{"version": "2.0.0"})
+		})
+		require.NoError(t, err)
+		got, err := os.Readlink(link)
+		require.NoError(t, err)
+		require.Equal(t, target, got)
+		content, err := os.ReadFile(target)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "version: 2.0.0")
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		cfg := setup(t)
+		require.NoError(t, os.WriteFile(cfg.Filename, []byte("# not formatted\n"+original), 0o600))
+		err := cfg.Batch(false, func() error { return nil })
+		require.NoError(t, err)
+		require.Equal(t, "# not formatted\n"+original, readConfig(t, cfg))
+	})
+}
+
 func TestConfig_UnsetTemplateVars(t *testing.T) {
 	t.Run("deletes", func(t *testing.T) {
 		cfg := mustConfigFromYAML(t, `
@@ -193,6 +289,90 @@ func TestConfig_addTemplateFromSource(t *testing.T) {
 	})
 }
 
+func TestConfig_CopyDependency(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join("testdata", "configs", "ex1.yaml")
+	srcCfg, err := NewConfig(ctx, src, true)
+	require.NoError(t, err)
+
+	t.Run("file", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+url_checksums:
+  https://example.com/other.tar.gz: deadbeef
+`)
+		err := cfg.CopyDependency(ctx, src, "goreleaser", &CopyDependencyOpts{DependencyName: "gr"})
+		require.NoError(t, err)
+		require.Equal(t, srcCfg.Dependencies["goreleaser"], cfg.Dependencies["gr"])
+		require.Equal(t, srcCfg.Templates["goreleaser"], cfg.Templates["goreleaser"])
+		require.Equal(t, map[string]string{
+			"https://example.com/other.tar.gz": "deadbeef",
+			"https://github.com/goreleaser/goreleaser/releases/download/v0.120.7/goreleaser_Darwin_x86_64.tar.gz": "2ec8bb354cca2936d0722e7da770c37e2ba6cc90de4a1cea186e20968c47b663",
+			"https://github.com/goreleaser/goreleaser/releases/download/v0.120.7/goreleaser_Linux_x86_64.tar.gz":  "771f2ad8219078b16a3e82097e9805309f6516640f0c6ab6b87f9b085a8ad743",
+			"https://github.com/goreleaser/goreleaser/releases/download/v0.120.7/goreleaser_Windows_x86_64.zip":   "0e06f50e1b2213a84b493d32a805dd6d8e8ad960ec9526edd8ecd96e2ab91743",
+		}, cfg.URLChecksums)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		ts := testutil.ServeFile(t, src, "/ex1.yaml", "")
+		cfg := mustConfigFromYAML(t, `{}`)
+		err := cfg.CopyDependency(ctx, ts.URL+"/ex1.yaml", "golangci-lint", nil)
+		require.NoError(t, err)
+		require.Equal(t, srcCfg.Dependencies["golangci-lint"], cfg.Dependencies["golangci-lint"])
+		require.Len(t, cfg.URLChecksums, 3)
+	})
+
+	t.Run("different template", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+templates:
+  goreleaser:
+    url: https://example.com/goreleaser.tar.gz
+`)
+		err := cfg.CopyDependency(ctx, src, "goreleaser", nil)
+		require.EqualError(t, err, fmt.Sprintf(`template "goreleaser" in %s is different from the one in this config`, src))
+		require.Empty(t, cfg.Dependencies)
+	})
+
+	t.Run("existing dependency", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  goreleaser:
+    url: https://example.com/goreleaser.tar.gz
+`)
+		err := cfg.CopyDependency(ctx, src, "goreleaser", nil)
+		require.EqualError(t, err, `dependency named "goreleaser" already exists`)
+	})
+
+	t.Run("unsupported systems", func(t *testing.T) {
+		srcFile := filepath.Join(t.TempDir(), "src.yaml")
+		require.NoError(t, os.WriteFile(srcFile, []byte(`
+dependencies:
+  foo:
+    url: https://example.com/foo-{{ .suffix }}{{ .ext }}
+    systems: [linux/amd64]
+    vars:
+      ext: ""
+    overrides:
+      - matcher:
+          os: [linux]
+        dependency:
+          vars:
+            suffix: linux
+url_checksums:
+  https://example.com/foo-linux: deadbeef
+`), 0o600))
+		cfg := mustConfigFromYAML(t, `{}`)
+		err := cfg.CopyDependency(ctx, srcFile, "foo", nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"https://example.com/foo-linux": "deadbeef"}, cfg.URLChecksums)
+	})
+
+	t.Run("missing dependency", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `{}`)
+		err := cfg.CopyDependency(ctx, src, "fake", nil)
+		require.EqualError(t, err, fmt.Sprintf(`%s has no dependency named "fake"`, src))
+	})
+}
+
 func TestConfig_InstallDependencies(t *testing.T) {
 	t.Run("raw file", func(t *testing.T) {
 		dir := t.TempDir()
@@ -223,6 +403,109 @@ dependencies:
 		testutil.AssertFile(t, wantBin, true, false)
 	})
 
+	t.Run("work dir", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		workDir := filepath.Join(dir, "work")
+		// the download must not touch the system temp directory
+		t.Setenv("TMPDIR", filepath.Join(dir, "missing"))
+		t.Setenv("TMP", filepath.Join(dir, "missing"))
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+work_dir: %q
+dependencies:
+  foo:
+    url: %q
+`, binDir, cacheDir, workDir, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		wantBin := filepath.Join(binDir, "foo")
+		require.NoError(t, os.MkdirAll(binDir, 0o755))
+		require.NoError(t, os.WriteFile(wantBin, []byte("old"), 0o755))
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			AllowMissingChecksum: true,
+		})
+		require.NoError(t, err)
+		testutil.AssertFile(t, wantBin, true, false)
+		want, err := os.ReadFile(servePath)
+		require.NoError(t, err)
+		got, err := os.ReadFile(wantBin)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		work, err := os.ReadDir(workDir)
+		require.NoError(t, err)
+		require.Empty(t, work)
+		bins, err := os.ReadDir(binDir)
+		require.NoError(t, err)
+		require.Len(t, bins, 1)
+	})
+
+	t.Run("requires", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		binDir := filepath.Join(dir, "bin")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+dependencies:
+  script:
+    url: %[3]q
+    archive_path: foo
+    requires: [jq, yq]
+  yq:
+    url: %[3]q
+    archive_path: foo
+    requires: [jq]
+  jq:
+    url: %[3]q
+    archive_path: foo
+`, binDir, filepath.Join(dir, ".bindown"), depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		var stdout bytes.Buffer
+		output := filepath.Join(dir, "out", "script")
+		err := config.InstallDependencies([]string{"script"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Output: output,
+			Stdout: &stdout,
+		})
+		require.NoError(t, err)
+		// requirements are installed first and go to the install dir when output is a file
+		require.Equal(t, fmt.Sprintf(`installed jq to %s
+installed yq to %s
+installed script to %s
+`, filepath.Join(binDir, "jq"), filepath.Join(binDir, "yq"), output), stdout.String())
+	})
+
+	t.Run("requires cycle", func(t *testing.T) {
+		config := mustConfigFromYAML(t, `
+dependencies:
+  a:
+    url: https://example.com/a
+    requires: [b]
+  b:
+    url: https://example.com/b
+    template: tmpl
+  c:
+    url: https://example.com/c
+    requires: [missing]
+templates:
+  tmpl:
+    requires: [a]
+`)
+		err := config.InstallDependencies([]string{"a"}, "darwin/amd64", nil)
+		require.EqualError(t, err, "dependency cycle: a -> b -> a")
+		require.Equal(t, FailureConfig, FailureKindOf(err))
+		err = config.InstallDependencies([]string{"c"}, "darwin/amd64", nil)
+		require.EqualError(t, err, `dependency "c" requires "missing", which is not configured`)
+	})
+
 	t.Run("bin in root", func(t *testing.T) {
 		dir := t.TempDir()
 		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
@@ -322,6 +605,493 @@ dependencies:
 		require.Error(t, err)
 		require.False(t, FileExists(wantBin))
 	})
+	t.Run("skips up to date and continues after failure", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+dependencies:
+  bar:
+    url: %q
+  foo:
+    url: %q
+`, binDir, cacheDir, depURL, ts.URL+"/missing", depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		wantBin := filepath.Join(binDir, "foo")
+		opts := ConfigInstallDependenciesOpts{AllDeps: true, AllowMissingChecksum: true, Color: true}
+		var stdout bytes.Buffer
+		opts.Stdout = &stdout
+		err := config.InstallDependencies(nil, "darwin/amd64", &opts)
+		require.ErrorContains(t, err, "bar: failed downloading")
+		require.Equal(t, fmt.Sprintf("\x1b[31mfailed\x1b[0m bar\n\x1b[32minstalled\x1b[0m foo to %s\n", wantBin), stdout.String())
+		testutil.AssertFile(t, wantBin, true, false)
+
+		stdout.Reset()
+		opts.AllDeps = false
+		opts.Color = false
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &opts)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("skipped foo: %s is up to date\n", wantBin), stdout.String())
+
+		stdout.Reset()
+		opts.Force = true
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &opts)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("installed foo to %s\n", wantBin), stdout.String())
+	})
+
+	t.Run("stream metrics", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+`, filepath.Join(dir, "bin"), filepath.Join(dir, ".bindown"), depURL, depURL))
+		metrics := &InstallMetrics{}
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream:  true,
+			Metrics: metrics,
+		})
+		require.NoError(t, err)
+		require.Len(t, metrics.Dependencies, 1)
+		got := metrics.Dependencies[0]
+		require.Equal(t, "foo", got.Name)
+		require.Equal(t, System("darwin/amd64"), got.System)
+		require.True(t, got.Streamed)
+		require.False(t, got.DownloadCached)
+		require.False(t, got.ExtractCached)
+		require.Equal(t, int64(179), got.DownloadBytes)
+	})
+
+	t.Run("download command", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses cp")
+		}
+		dir := t.TempDir()
+		srcFile, err := filepath.Abs(filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"))
+		require.NoError(t, err)
+		depURL := "https://example.invalid/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+download_command: [cp, %q, "{{.output}}"]
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+`, binDir, cacheDir, srcFile, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream: true,
+		})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+
+		// checksums are still verified
+		config.URLChecksums[depURL] = "0000000000000000000000000000000000000000000000000000000000000000"
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Force: true,
+		})
+		require.ErrorContains(t, err, "checksum mismatch")
+
+		config.DownloadCommand = []string{"false", "{{.url}}"}
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Force: true,
+		})
+		require.ErrorContains(t, err, "failed downloading "+depURL+" with false")
+	})
+
+	t.Run("scan command", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh")
+		}
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		scanned := filepath.Join(dir, "scanned")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+scan_command: ["false", "{{.file}}"]
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+`, binDir, filepath.Join(dir, ".bindown"), depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream: true,
+		})
+		require.ErrorContains(t, err, "false rejected the download from "+depURL)
+		require.NoFileExists(t, filepath.Join(binDir, "foo"))
+
+		// the rejected download isn't reused from the cache
+		config.ScanCommand = []string{"sh", "-c", `cp "$0" "$1"`, "{{.file}}", scanned}
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+		require.FileExists(t, scanned)
+	})
+
+	t.Run("attestation", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses a shell script as gh")
+		}
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		ghDir := filepath.Join(dir, "ghbin")
+		ghArgs := filepath.Join(dir, "gh-args")
+		require.NoError(t, os.MkdirAll(ghDir, 0o755))
+		ghScript := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\necho 'no matching attestations'\nexit 1\n", ghArgs)
+		require.NoError(t, os.WriteFile(filepath.Join(ghDir, "gh"), []byte(ghScript), 0o755))
+		t.Setenv("PATH", ghDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+    vars:
+      owner: willabides
+    attestation:
+      repository: "{{.owner}}/foo"
+      signer_workflow: "{{.owner}}/foo/.github/workflows/release.yml"
+`, binDir, filepath.Join(dir, ".bindown"), depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream: true,
+		})
+		require.ErrorContains(t, err, "attestation verification failed for "+depURL)
+		require.ErrorContains(t, err, "no matching attestations")
+		require.NoFileExists(t, filepath.Join(binDir, "foo"))
+		got, err := os.ReadFile(ghArgs)
+		require.NoError(t, err)
+		require.Regexp(t, `^attestation verify \S+/fooinroot\.tar\.gz --repo willabides/foo --signer-workflow willabides/foo/\.github/workflows/release\.yml\n$`, string(got))
+
+		require.NoError(t, os.WriteFile(filepath.Join(ghDir, "gh"), []byte("#!/bin/sh\nexit 0\n"), 0o755))
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+	})
+
+	t.Run("shared download", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+  bar:
+    url: %q
+    archive_path: foo
+`, binDir, cacheDir, depURL, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo", "bar"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+		testutil.AssertFile(t, filepath.Join(binDir, "bar"), true, false)
+		// the archive is only extracted once
+		sums, err := os.ReadDir(filepath.Join(cacheDir, ".extract_sums"))
+		require.NoError(t, err)
+		require.Len(t, sums, 1)
+	})
+
+	t.Run("dependency cache", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+  bar:
+    url: %q
+    archive_path: foo
+    cache: scratch/cache
+`, binDir, cacheDir, depURL, depURL, depURL))
+		config.Filename = filepath.Join(dir, "bindown.yaml")
+		scratchDir := filepath.Join(dir, "scratch", "cache")
+		require.NoError(t, os.MkdirAll(scratchDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(scratchDir, "other"), nil, 0o600))
+		err := config.InstallDependencies([]string{"foo", "bar"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+		testutil.AssertFile(t, filepath.Join(binDir, "bar"), true, false)
+		for _, d := range []string{cacheDir, scratchDir} {
+			require.DirExists(t, filepath.Join(d, "downloads"))
+			sums, err := os.ReadDir(filepath.Join(d, ".extract_sums"))
+			require.NoError(t, err)
+			require.Len(t, sums, 1)
+		}
+		// only what bindown put in the dependency's cache is cleared
+		require.NoError(t, config.ClearCache())
+		require.NoDirExists(t, cacheDir)
+		entries, err := os.ReadDir(scratchDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "other", entries[0].Name())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(stuck.Close)
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		stuckURL := stuck.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+timeout: 10s
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  bar:
+    url: %q
+    archive_path: foo
+    timeout: 100ms
+  foo:
+    url: %q
+`, binDir, filepath.Join(dir, ".bindown"), stuckURL, depURL, stuckURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"bar", "foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.ErrorContains(t, err, "bar: timed out after 100ms")
+		require.NoFileExists(t, filepath.Join(binDir, "bar"))
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+
+		config.Timeout = "soon"
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.ErrorContains(t, err, `invalid timeout for "foo"`)
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		// the proxy serves the file for any url it is asked for
+		proxy := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := "http://bindown.invalid/foo/foo"
+		binDir := filepath.Join(dir, "bin")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+proxy:
+  hosts:
+    "*.invalid": %q
+url_checksums:
+  "%s": f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+dependencies:
+  foo:
+    url: %q
+`, binDir, filepath.Join(dir, ".bindown"), proxy.URL, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo"), true, false)
+	})
+
+	t.Run("max download size", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+max_download_size: 100B
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+  bar:
+    url: %q
+    archive_path: foo
+    max_download_size: 1KB
+`, binDir, filepath.Join(dir, ".bindown"), depURL, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.ErrorContains(t, err, "exceeds max_download_size of 100 bytes")
+		require.NoFileExists(t, filepath.Join(binDir, "foo"))
+
+		err = config.InstallDependencies([]string{"bar"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "bar"), true, false)
+
+		config.MaxDownloadSize = "lots"
+		err = config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.ErrorContains(t, err, `invalid max_download_size for "foo"`)
+	})
+
+	t.Run("html instead of archive", func(t *testing.T) {
+		dir := t.TempDir()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, err := w.Write([]byte("<!DOCTYPE html><html><body>sign in</body></html>"))
+			assert.NoError(t, err)
+		}))
+		t.Cleanup(ts.Close)
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  foo:
+    url: %q
+`, filepath.Join(dir, ".bindown"), ts.URL+"/foo/foo.tar.gz"))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.AddChecksums([]string{"foo"}, []System{"darwin/amd64"})
+		require.ErrorContains(t, err, "got an HTML page instead of a gzip archive for foo.tar.gz")
+		require.Empty(t, config.URLChecksums)
+	})
+
+	t.Run("install path", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		ts := testutil.ServeFile(t, servePath, "/foo/foo", "")
+		depURL := ts.URL + "/foo/foo"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+install_path: "{{.name}}-{{.version}}"
+cache: %q
+url_checksums:
+  "%s": f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+dependencies:
+  foo:
+    url: %q
+    vars:
+      version: 1.2.3
+  bar:
+    url: %q
+    archive_path: foo
+    install_path: "{{.os}}/{{.arch}}/{{.bin}}"
+`, binDir, cacheDir, depURL, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo", "bar"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+		require.NoError(t, err)
+		testutil.AssertFile(t, filepath.Join(binDir, "foo-1.2.3"), true, false)
+		testutil.AssertFile(t, filepath.Join(binDir, "darwin", "amd64", "bar"), true, false)
+	})
+
+	t.Run("install path outside install dir", func(t *testing.T) {
+		for _, installPath := range []string{"../{{.bin}}", "/tmp/{{.bin}}", "{{.version}}/{{.bin}}"} {
+			config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_path: %q
+url_checksums:
+  "https://example.com/foo": f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+dependencies:
+  foo:
+    url: https://example.com/foo
+    vars:
+      version: ..
+`, installPath))
+			err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{})
+			require.ErrorContains(t, err, "must be a relative path inside the install directory", installPath)
+			require.IsType(t, &ConfigError{}, err, installPath)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+dependencies:
+  foo:
+    url: %q
+`, binDir, cacheDir, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		wantBin := filepath.Join(binDir, "foo")
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream: true,
+		})
+		require.NoError(t, err)
+		testutil.AssertFile(t, wantBin, true, false)
+		// nothing should be written to the downloads cache
+		require.NoDirExists(t, filepath.Join(cacheDir, "downloads"))
+	})
+
+	t.Run("stream wrong checksum", func(t *testing.T) {
+		dir := t.TempDir()
+		servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+		ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+		depURL := ts.URL + "/foo/fooinroot.tar.gz"
+		binDir := filepath.Join(dir, "bin")
+		cacheDir := filepath.Join(dir, ".bindown")
+		config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+url_checksums:
+  "%s": "0000000000000000000000000000000000000000000000000000000000000000"
+dependencies:
+  foo:
+    url: %q
+`, binDir, cacheDir, depURL, depURL))
+		t.Cleanup(func() { require.NoError(t, config.ClearCache()) })
+		err := config.InstallDependencies([]string{"foo"}, "darwin/amd64", &ConfigInstallDependenciesOpts{
+			Stream: true,
+		})
+		require.ErrorContains(t, err, "checksum mismatch")
+		require.False(t, FileExists(filepath.Join(binDir, "foo")))
+		// the extracted files should have been discarded
+		entries, err := os.ReadDir(filepath.Join(cacheDir, "extracts"))
+		require.NoError(t, err)
+		for _, entry := range entries {
+			require.False(t, entry.IsDir() && entry.Name() != ".locks", "unexpected extract dir %s", entry.Name())
+		}
+	})
 }
 
 func TestConfig_addChecksums(t *testing.T) {
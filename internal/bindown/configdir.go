@@ -0,0 +1,333 @@
+package bindown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFragmentDir is the directory next to a config file with more config files that are merged into it when it is
+// loaded. Large configs can be split into a file per dependency, so changes to different dependencies don't touch the
+// same file.
+const ConfigFragmentDir = "bindown.d"
+
+// fragmentProperties are the top level properties a file in ConfigFragmentDir can have. Settings that apply to the
+// whole config stay in the config file.
+var fragmentProperties = []string{
+	"dependencies",
+	"templates",
+	"template_sources",
+	"template_source_digests",
+	"url_checksums",
+	"bin_checksums",
+}
+
+// ConfigFragments returns the yaml and json files in the ConfigFragmentDir next to cfgFile in name order. Hidden files
+// are skipped.
+func ConfigFragments(cfgFile string) ([]string, error) {
+	dir := filepath.Join(filepath.Dir(cfgFile), ConfigFragmentDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch filepath.Ext(name) {
+		case ".yml", ".yaml", ".json":
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// mergeConfigFragments merges the files in the ConfigFragmentDir next to c.Filename into c. A dependency, template or
+// template source can only be in one file, and files can't disagree about a checksum.
+func (c *Config) mergeConfigFragments(ctx context.Context) error {
+	files, err := ConfigFragments(c.Filename)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	// origins has the file each merged value came from keyed by property and name
+	origins := map[string]map[string]string{}
+	for _, property := range fragmentProperties {
+		origins[property] = map[string]string{}
+	}
+	addOrigins := func(property, file string, names []string) {
+		for _, name := range names {
+			origins[property][name] = file
+		}
+	}
+	addOrigins("dependencies", c.Filename, MapKeys(c.Dependencies))
+	addOrigins("templates", c.Filename, MapKeys(c.Templates))
+	addOrigins("template_sources", c.Filename, MapKeys(c.TemplateSources))
+	addOrigins("template_source_digests", c.Filename, MapKeys(c.TemplateSourceDigests))
+	addOrigins("url_checksums", c.Filename, MapKeys(c.URLChecksums))
+	addOrigins("bin_checksums", c.Filename, MapKeys(c.BinChecksums))
+	for _, file := range files {
+		fragment, err := loadConfigFragment(ctx, file)
+		if err != nil {
+			return err
+		}
+		err = errors.Join(
+			mergeDefinitions(&c.Dependencies, fragment.Dependencies, "dependency", origins["dependencies"], file),
+			mergeDefinitions(&c.Templates, fragment.Templates, "template", origins["templates"], file),
+			mergeDefinitions(&c.TemplateSources, fragment.TemplateSources, "template source", origins["template_sources"], file),
+			mergeValues(&c.TemplateSourceDigests, fragment.TemplateSourceDigests, "template_source_digests", origins["template_source_digests"], file),
+			mergeValues(&c.URLChecksums, fragment.URLChecksums, "url_checksums", origins["url_checksums"], file),
+			mergeValues(&c.BinChecksums, fragment.BinChecksums, "bin_checksums", origins["bin_checksums"], file),
+		)
+		if err != nil {
+			return &ConfigError{Err: err}
+		}
+		fragment.Filename = file
+		encoded, err := fragment.encodeFragment()
+		if err != nil {
+			return err
+		}
+		c.fragments = append(c.fragments, &configFragment{config: fragment, encoded: encoded})
+	}
+	return nil
+}
+
+// configFragment is a file from ConfigFragmentDir that was merged into a config.
+type configFragment struct {
+	// config is what the file had when it was last loaded or written.
+	config *Config
+	// encoded is config the way writeFragments writes it. The file is only written when this changes, so a change to
+	// one dependency doesn't reformat the files of the others.
+	encoded []byte
+}
+
+// encodeFragment returns c encoded the way it is written to a file in ConfigFragmentDir.
+func (c *Config) encodeFragment() ([]byte, error) {
+	var buf bytes.Buffer
+	err := c.encode(&buf, filepath.Ext(c.Filename) == ".json")
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFragments writes what came from each of c.fragments back to its file and the rest of the config to c.Filename.
+// Each dependency, template and template source stays in the file it came from, and so do checksums. New checksums go
+// to the file with the dependency they are for. New dependencies, templates and template sources go in c.Filename. A
+// file that is left with nothing is removed.
+func (c *Config) writeFragments(outputJSON bool) error {
+	main := *c
+	main.fragments = nil
+	main.Dependencies = maps.Clone(c.Dependencies)
+	main.Templates = maps.Clone(c.Templates)
+	main.TemplateSources = maps.Clone(c.TemplateSources)
+	main.TemplateSourceDigests = maps.Clone(c.TemplateSourceDigests)
+	main.URLChecksums = maps.Clone(c.URLChecksums)
+	main.BinChecksums = maps.Clone(c.BinChecksums)
+	fragments := make([]*configFragment, 0, len(c.fragments))
+	for _, fragment := range c.fragments {
+		part := c.fragmentPart(fragment.config)
+		for _, name := range MapKeys(part.Dependencies) {
+			delete(main.Dependencies, name)
+		}
+		for _, name := range MapKeys(part.Templates) {
+			delete(main.Templates, name)
+		}
+		for _, name := range MapKeys(part.TemplateSources) {
+			delete(main.TemplateSources, name)
+		}
+		for _, key := range MapKeys(part.TemplateSourceDigests) {
+			delete(main.TemplateSourceDigests, key)
+		}
+		for _, key := range MapKeys(part.URLChecksums) {
+			delete(main.URLChecksums, key)
+		}
+		for _, key := range MapKeys(part.BinChecksums) {
+			delete(main.BinChecksums, key)
+		}
+		if part.fragmentEmpty() {
+			err := os.Remove(part.Filename)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		encoded, err := part.encodeFragment()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(encoded, fragment.encoded) {
+			err = part.writeFile(filepath.Ext(part.Filename) == ".json")
+			if err != nil {
+				return err
+			}
+		}
+		fragments = append(fragments, &configFragment{config: part, encoded: encoded})
+	}
+	err := main.writeFile(outputJSON)
+	if err != nil {
+		return err
+	}
+	c.fragments = fragments
+	return nil
+}
+
+// fragmentPart returns the part of c that belongs in the file fragment was loaded from.
+func (c *Config) fragmentPart(fragment *Config) *Config {
+	part := &Config{Filename: fragment.Filename, SchemaURL: fragment.SchemaURL}
+	for _, name := range MapKeys(fragment.Dependencies) {
+		if c.Dependencies[name] != nil {
+			setMapValue(&part.Dependencies, name, c.Dependencies[name])
+		}
+	}
+	for _, name := range MapKeys(fragment.Templates) {
+		if c.Templates[name] != nil {
+			setMapValue(&part.Templates, name, c.Templates[name])
+		}
+	}
+	for _, name := range MapKeys(fragment.TemplateSources) {
+		if c.TemplateSources[name] != "" {
+			setMapValue(&part.TemplateSources, name, c.TemplateSources[name])
+		}
+	}
+	for key, value := range c.TemplateSourceDigests {
+		if fragment.TemplateSourceDigests[key] != "" || part.TemplateSources[key] != "" {
+			setMapValue(&part.TemplateSourceDigests, key, value)
+		}
+	}
+	depKeys := map[string]bool{}
+	for _, name := range MapKeys(part.Dependencies) {
+		for _, key := range c.dependencyChecksumKeys(name) {
+			depKeys[key] = true
+		}
+	}
+	for key, value := range c.URLChecksums {
+		if fragment.URLChecksums[key] != "" || depKeys[key] {
+			setMapValue(&part.URLChecksums, key, value)
+		}
+	}
+	for key, value := range c.BinChecksums {
+		urlKey, _, _ := strings.Cut(key, "#")
+		if fragment.BinChecksums[key] != "" || depKeys[urlKey] {
+			setMapValue(&part.BinChecksums, key, value)
+		}
+	}
+	return part
+}
+
+// fragmentEmpty returns true when c has none of the fragmentProperties.
+func (c *Config) fragmentEmpty() bool {
+	return len(c.Dependencies) == 0 &&
+		len(c.Templates) == 0 &&
+		len(c.TemplateSources) == 0 &&
+		len(c.TemplateSourceDigests) == 0 &&
+		len(c.URLChecksums) == 0 &&
+		len(c.BinChecksums) == 0
+}
+
+// dependencyChecksumKeys returns the url_checksums keys of depName on each of its systems. Systems the dependency
+// can't be built for are skipped.
+func (c *Config) dependencyChecksumKeys(depName string) []string {
+	systems, err := c.DependencySystems(depName)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, system := range systems {
+		dep, err := c.BuildDependency(depName, system)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(keys, dep.checksumKey) {
+			keys = append(keys, dep.checksumKey)
+		}
+	}
+	return keys
+}
+
+func setMapValue[T any](m *map[string]T, key string, value T) {
+	if *m == nil {
+		*m = map[string]T{}
+	}
+	(*m)[key] = value
+}
+
+// loadConfigFragment loads a file from ConfigFragmentDir. It is an error for it to have properties that aren't in
+// fragmentProperties.
+func loadConfigFragment(ctx context.Context, file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var properties map[string]any
+	err = yaml.Unmarshal(data, &properties)
+	if err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("%s: %w", file, err)}
+	}
+	for _, property := range sortedKeys(properties) {
+		if !slices.Contains(fragmentProperties, property) {
+			return nil, &ConfigError{Err: fmt.Errorf(
+				"%s: %s can't be set in %s. it can only have %s",
+				file, property, ConfigFragmentDir, strings.Join(fragmentProperties, ", "),
+			)}
+		}
+	}
+	fragment, err := ConfigFromYAML(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return fragment, nil
+}
+
+// mergeDefinitions adds the values in src to dst. It is an error for a name to already be in dst. origins has the file
+// each name in dst came from and gets the names from src.
+func mergeDefinitions[T any](dst *map[string]T, src map[string]T, kind string, origins map[string]string, file string) error {
+	var errs []error
+	for _, name := range sortedKeys(src) {
+		if origin, ok := origins[name]; ok {
+			errs = append(errs, fmt.Errorf("%s %q is in both %s and %s", kind, name, origin, file))
+			continue
+		}
+		if *dst == nil {
+			*dst = map[string]T{}
+		}
+		(*dst)[name] = src[name]
+		origins[name] = file
+	}
+	return errors.Join(errs...)
+}
+
+// mergeValues adds the values in src to dst. Files can repeat a key as long as they have the same value for it.
+func mergeValues(dst *map[string]string, src map[string]string, property string, origins map[string]string, file string) error {
+	var errs []error
+	for _, key := range sortedKeys(src) {
+		if origin, ok := origins[key]; ok {
+			if (*dst)[key] != src[key] {
+				errs = append(errs, fmt.Errorf("%s and %s have different %s for %q", origin, file, property, key))
+			}
+			continue
+		}
+		if *dst == nil {
+			*dst = map[string]string{}
+		}
+		(*dst)[key] = src[key]
+		origins[key] = file
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,164 @@
+package bindown
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig_fragments(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, fragments map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		cfgFile := filepath.Join(dir, "bindown.yml")
+		require.NoError(t, os.WriteFile(cfgFile, []byte(`
+systems: [linux/amd64]
+templates:
+  base:
+    url: https://example.com/{{.name}}
+dependencies:
+  yq:
+    url: https://example.com/yq
+url_checksums:
+  https://example.com/yq: aaaa
+`), 0o644))
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ConfigFragmentDir), 0o755))
+		for name, content := range fragments {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFragmentDir, name), []byte(content), 0o644))
+		}
+		return cfgFile
+	}
+
+	t.Run("merges", func(t *testing.T) {
+		cfgFile := setup(t, map[string]string{
+			"jq.yml": `
+dependencies:
+  jq:
+    template: base
+    vars:
+      name: jq
+url_checksums:
+  https://example.com/jq: bbbb
+  https://example.com/yq: aaaa
+`,
+			"tools.json":  `{"dependencies": {"gh": {"url": "https://example.com/gh"}}}`,
+			".jq.yml.swp": "not yaml: [",
+			"README.md":   "not yaml: [",
+		})
+		cfg, err := NewConfig(ctx, cfgFile, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"gh", "jq", "yq"}, cfg.DependencyNames())
+		require.Equal(t, map[string]string{
+			"https://example.com/jq": "bbbb",
+			"https://example.com/yq": "aaaa",
+		}, cfg.URLChecksums)
+		dep, err := cfg.BuildDependency("jq", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, "bbbb", dep.checksum)
+	})
+
+	t.Run("writes back", func(t *testing.T) {
+		cfgFile := setup(t, map[string]string{
+			"jq.yml": `
+dependencies:
+  jq:
+    template: base
+    vars:
+      name: jq
+`,
+			"gh.json": `{"dependencies": {"gh": {"url": "https://example.com/gh"}}}`,
+			"untouched.yml": `# comments are kept when nothing in the file changes
+templates:
+  other:
+    url: https://example.com/other
+`,
+		})
+		dir := filepath.Join(filepath.Dir(cfgFile), ConfigFragmentDir)
+		cfg, err := NewConfig(ctx, cfgFile, true)
+		require.NoError(t, err)
+		cfg.Dependencies["jq"].Vars["version"] = "1.7.1"
+		cfg.URLChecksums["https://example.com/jq"] = "bbbb"
+		cfg.BinChecksums = map[string]string{"https://example.com/jq#jq": "cccc"}
+		delete(cfg.Dependencies, "gh")
+		cfg.Dependencies["new"] = &Dependency{Overrideable: Overrideable{URL: ptr("https://example.com/new")}}
+		require.NoError(t, cfg.WriteFile(false))
+
+		requireFileContent := func(t *testing.T, want, file string) {
+			t.Helper()
+			got, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Equal(t, strings.TrimPrefix(want, "\n"), string(got))
+		}
+		requireFileContent(t, `
+dependencies:
+  jq:
+    template: base
+    vars:
+      name: jq
+      version: 1.7.1
+url_checksums:
+  https://example.com/jq: bbbb
+bin_checksums:
+  https://example.com/jq#jq: cccc
+`, filepath.Join(dir, "jq.yml"))
+		requireFileContent(t, `# comments are kept when nothing in the file changes
+templates:
+  other:
+    url: https://example.com/other
+`, filepath.Join(dir, "untouched.yml"))
+		require.NoFileExists(t, filepath.Join(dir, "gh.json"))
+		requireFileContent(t, `
+systems:
+  - linux/amd64
+dependencies:
+  new:
+    url: https://example.com/new
+  yq:
+    url: https://example.com/yq
+templates:
+  base:
+    url: https://example.com/{{.name}}
+url_checksums:
+  https://example.com/yq: aaaa
+`, cfgFile)
+
+		reloaded, err := NewConfig(ctx, cfgFile, true)
+		require.NoError(t, err)
+		require.Equal(t, cfg.Dependencies, reloaded.Dependencies)
+		require.Equal(t, cfg.URLChecksums, reloaded.URLChecksums)
+	})
+
+	t.Run("duplicate dependency", func(t *testing.T) {
+		cfgFile := setup(t, map[string]string{
+			"yq.yml": `dependencies: {yq: {url: "https://example.com/other"}}`,
+		})
+		_, err := NewConfig(ctx, cfgFile, true)
+		fragment := filepath.Join(filepath.Dir(cfgFile), ConfigFragmentDir, "yq.yml")
+		require.EqualError(t, err, `dependency "yq" is in both `+cfgFile+` and `+fragment)
+		require.IsType(t, &ConfigError{}, err)
+	})
+
+	t.Run("different checksum", func(t *testing.T) {
+		cfgFile := setup(t, map[string]string{
+			"a.yml": `url_checksums: {"https://example.com/gh": cccc}`,
+			"b.yml": `url_checksums: {"https://example.com/gh": dddd}`,
+		})
+		_, err := NewConfig(ctx, cfgFile, true)
+		dir := filepath.Join(filepath.Dir(cfgFile), ConfigFragmentDir)
+		require.EqualError(t, err, filepath.Join(dir, "a.yml")+" and "+filepath.Join(dir, "b.yml")+
+			` have different url_checksums for "https://example.com/gh"`)
+	})
+
+	t.Run("config setting", func(t *testing.T) {
+		cfgFile := setup(t, map[string]string{
+			"jq.yml": `cache: .cache`,
+		})
+		_, err := NewConfig(ctx, cfgFile, true)
+		require.ErrorContains(t, err, "jq.yml: cache can't be set in bindown.d")
+	})
+}
@@ -0,0 +1,135 @@
+package bindown
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// CopyDependencyOpts options for Config.CopyDependency
+type CopyDependencyOpts struct {
+	// DependencyName is the dependency's name in this config. Default is its name in the source config.
+	DependencyName string
+}
+
+// CopyDependency copies the dependency named name from the config at src, which is a path, an http(s) url or an oci://
+// reference. The templates it uses and the url and bin checksums it has in the source config are copied with it.
+// Dependencies it requires are not copied.
+func (c *Config) CopyDependency(ctx context.Context, src, name string, opts *CopyDependencyOpts) (errOut error) {
+	if opts == nil {
+		opts = &CopyDependencyOpts{}
+	}
+	destName := opts.DependencyName
+	if destName == "" {
+		destName = name
+	}
+	if c.Dependencies[destName] != nil {
+		return fmt.Errorf("dependency named %q already exists", destName)
+	}
+	data, err := configSourceData(ctx, src)
+	if err != nil {
+		return err
+	}
+	srcCfg, err := ConfigFromYAML(ctx, data)
+	if err != nil {
+		return err
+	}
+	srcDep := srcCfg.Dependencies[name]
+	if srcDep == nil {
+		return fmt.Errorf("%s has no dependency named %q", src, name)
+	}
+	var addedTemplates []string
+	for _, tmplName := range srcCfg.templateChain(srcDep) {
+		srcTmpl := srcCfg.Templates[tmplName]
+		if srcTmpl == nil {
+			return &ConfigError{Err: fmt.Errorf("%s has no template named %q", src, tmplName)}
+		}
+		if existing := c.Templates[tmplName]; existing != nil {
+			if !reflect.DeepEqual(existing, srcTmpl) {
+				return fmt.Errorf("template %q in %s is different from the one in this config", tmplName, src)
+			}
+			continue
+		}
+		addedTemplates = append(addedTemplates, tmplName)
+	}
+
+	if c.Dependencies == nil {
+		c.Dependencies = map[string]*Dependency{}
+	}
+	if c.Templates == nil && len(addedTemplates) > 0 {
+		c.Templates = map[string]*Dependency{}
+	}
+	c.Dependencies[destName] = srcDep.clone()
+	for _, tmplName := range addedTemplates {
+		c.Templates[tmplName] = srcCfg.Templates[tmplName].clone()
+	}
+	var addedSums, addedBinSums []string
+	defer func() {
+		if errOut == nil {
+			return
+		}
+		delete(c.Dependencies, destName)
+		for _, tmplName := range addedTemplates {
+			delete(c.Templates, tmplName)
+		}
+		for _, key := range addedSums {
+			delete(c.URLChecksums, key)
+		}
+		for _, key := range addedBinSums {
+			delete(c.BinChecksums, key)
+		}
+	}()
+
+	supported, err := srcCfg.DependencySystems(name)
+	if err != nil {
+		return err
+	}
+	systems := slices.Clone(supported)
+	// the source config may have checksums for systems it doesn't list, so check every system Go knows about too
+	for _, system := range strings.Fields(GoDists) {
+		if !slices.Contains(systems, System(system)) {
+			systems = append(systems, System(system))
+		}
+	}
+	// the checksum keys can differ between configs, so checksums are matched by system
+	for _, system := range systems {
+		srcBuilt, built, err := srcCfg.buildCopyPair(c, name, destName, system)
+		if err != nil {
+			if !slices.Contains(supported, system) {
+				// a dependency doesn't have to build for systems it doesn't support
+				continue
+			}
+			return err
+		}
+		if srcBuilt.checksum != "" && c.URLChecksums[built.checksumKey] == "" {
+			if c.URLChecksums == nil {
+				c.URLChecksums = map[string]string{}
+			}
+			c.URLChecksums[built.checksumKey] = srcBuilt.checksum
+			addedSums = append(addedSums, built.checksumKey)
+		}
+		if srcBuilt.binChecksum != "" && c.BinChecksums[built.binChecksumKey()] == "" {
+			if c.BinChecksums == nil {
+				c.BinChecksums = map[string]string{}
+			}
+			c.BinChecksums[built.binChecksumKey()] = srcBuilt.binChecksum
+			addedBinSums = append(addedBinSums, built.binChecksumKey())
+		}
+	}
+	return nil
+}
+
+// buildCopyPair builds the dependency named name in c and its copy named destName in dest for system.
+func (c *Config) buildCopyPair(dest *Config, name, destName string, system System) (srcBuilt, built *Dependency, _ error) {
+	srcBuilt, err := c.BuildDependency(name, system)
+	if err != nil {
+		return nil, nil, err
+	}
+	built, err = dest.BuildDependency(destName, system)
+	if err != nil {
+		return nil, nil, err
+	}
+	return srcBuilt, built, nil
+}
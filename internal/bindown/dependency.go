@@ -12,9 +12,9 @@ import (
 )
 
 type DependencyOverride struct {
-	// Limits the override to configurations matching all of the matchers. Keys may be "os", "arch" or any variable name.
-	// Values are an array of values to match. Any matching value will match. If a value can be interpreted as a
-	// semantic version it will be treated as such.
+	// Limits the override to configurations matching all of the matchers. Keys may be "os", "arch", "environment" or any
+	// variable name. Values are an array of values to match. Any matching value will match. If a value can be
+	// interpreted as a semantic version it will be treated as such.
 	OverrideMatcher map[string][]string `json:"matcher" yaml:"matcher,omitempty"`
 
 	// Values to override the parent dependency
@@ -25,7 +25,8 @@ type Overrideable struct {
 	// The url to download a dependency from.
 	URL *string `json:"url,omitempty" yaml:",omitempty"`
 
-	// The path in the downloaded archive where the binary is located. Default is ./<bin>
+	// The path in the downloaded archive where the binary is located. Default is ./<bin>. When nothing is there, the
+	// only file named like the bin is used, or else the only executable file.
 	ArchivePath *string `json:"archive_path,omitempty" yaml:"archive_path,omitempty"`
 
 	// The name of the binary to be installed. Default is the name of the dependency.
@@ -34,6 +35,12 @@ type Overrideable struct {
 	// Whether to create a symlink to the bin instead of copying it.
 	Link *bool `json:"link,omitempty" yaml:",omitempty"`
 
+	// Paths in the downloaded archive of executables to link into the install directory. For tools that ship a
+	// directory of files that depend on each other. When set, the whole extracted archive is copied to
+	// .tools/<dependency name> in the install directory and only the entrypoints are linked next to it. archive_path
+	// and link are not used.
+	Entrypoints []string `json:"entrypoints,omitempty" yaml:"entrypoints,omitempty"`
+
 	// A list of variables that can be used in 'url', 'archive_path' and 'bin'.
 	//
 	// Two variables are always added based on the current environment: 'os' and 'arch'. Those are the operating
@@ -44,6 +51,9 @@ type Overrideable struct {
 	// `https://example.org/mydependency/v{{.version}}/mydependency-{{.os}}-{{.arch}}.tar.gz`.  If you define the var
 	// 'version: 1.2.3' and run bindown on a 64-bit Linux system, it will download
 	// `https://example.org/mydependency/v1.2.3/mydependency-linux-amd64.tar.gz`.
+	//
+	// A var can be computed from other vars with a template like `major: "{{ semverMajor .version }}"`. Templates can
+	// use the functions semverMajor, semverMinor, semverPatch, trimPrefix, trimSuffix, replace, lower and upper.
 	Vars map[string]string `json:"vars,omitempty" yaml:",omitempty"`
 
 	// Overrides allows you to override values depending on the os and architecture of the target system.
@@ -72,6 +82,7 @@ func (d *Overrideable) clone() *Overrideable {
 		ArchivePath:   clonePointer(d.ArchivePath),
 		BinName:       clonePointer(d.BinName),
 		Link:          clonePointer(d.Link),
+		Entrypoints:   slices.Clone(d.Entrypoints),
 		Vars:          maps.Clone(d.Vars),
 		Overrides:     overrides,
 		Substitutions: cloneSubstitutions(d.Substitutions),
@@ -85,6 +96,10 @@ type Dependency struct {
 	// A description of the dependency. Informational only.
 	Description *string `json:"description,omitempty" yaml:",omitempty"`
 
+	// The SPDX identifier of the dependency's license, like "MIT". Informational only. It is listed in the install
+	// manifest for supply-chain scanners.
+	License *string `json:"license,omitempty" yaml:",omitempty"`
+
 	// A template for this dependency. Value is the name of a template in the templates section of this config.
 	// Any unset fields in this dependency will be set by values from the template. Overrides in the dependency
 	// and its template are concatenated with the template's overrides coming first. Vars and substitutions
@@ -99,11 +114,108 @@ type Dependency struct {
 	// A list of variables that must be present for an install to succeed
 	RequiredVars []string `json:"required_vars,omitempty" yaml:"required_vars,omitempty"`
 
+	// A template for the path where this dependency is installed relative to install_dir. Overrides the config's
+	// install_path.
+	InstallPath *string `json:"install_path,omitempty" yaml:"install_path,omitempty"`
+
+	// The largest file bindown will download for this dependency. Overrides the config's max_download_size.
+	MaxDownloadSize *string `json:"max_download_size,omitempty" yaml:"max_download_size,omitempty"`
+
+	// The directory where this dependency's downloads and extracted files are cached. Overrides the config's cache
+	// for dependencies that should be cached somewhere else, like a large SDK on a scratch volume. Like the config's
+	// cache, it is relative to the directory where the configuration file resides and uses / as a delimiter.
+	Cache *string `json:"cache,omitempty" yaml:"cache,omitempty"`
+
+	// The longest bindown spends downloading, extracting and installing this dependency. Overrides the config's
+	// timeout.
+	Timeout *string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Requires downloads to have a GitHub artifact attestation from the given repository and workflow. Attestations
+	// are verified with the gh cli before the download is extracted.
+	Attestation *AttestationConfig `json:"attestation,omitempty" yaml:"attestation,omitempty"`
+
+	// The package to look up in the OSV vulnerability database for "bindown audit". Default for dependencies
+	// released on GitHub is the go module in the go.mod at the root of the repository. Tools that aren't go modules
+	// are skipped without it.
+	OSV *OSVPackage `json:"osv,omitempty" yaml:"osv,omitempty"`
+
+	// Environment variables to set when the dependency's bin is run by a wrapper or "bindown exec" or exported with
+	// "bindown env". Values can use the same variables as url. Env from the dependency's template is combined with the
+	// dependency's value taking precedence.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// The url of a zsync control file for the download. When it is set, a new version of the download is rebuilt
+	// from the blocks it shares with the previously downloaded version, and only the changed blocks are downloaded
+	// with range requests. bindown falls back to downloading the whole file when that fails. Can use the same
+	// variables as url.
+	ZsyncURL *string `json:"zsync_url,omitempty" yaml:"zsync_url,omitempty"`
+
+	// Other dependencies this one needs at runtime, like jq for a script that runs it. They are installed along with
+	// this dependency, before it. Requirements from the dependency's template are combined with the dependency's.
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+
+	// A command that prints a short-lived presigned url for downloading from url, like
+	// ["aws", "s3", "presign", "{{.url}}"]. Arguments are templates that can use "url". It is run at download time, so
+	// the config only has the url the presigned url is made for. Without a presign_command, s3:// and gs:// urls are
+	// presigned with credentials from the environment.
+	PresignCommand []string `json:"presign_command,omitempty" yaml:"presign_command,omitempty"`
+
+	// Extract the squashfs image inside an AppImage download instead of installing the AppImage itself. archive_path
+	// is then the bin's path inside the image, like "usr/bin/tool". Extracting requires unsquashfs from
+	// squashfs-tools.
+	ExtractAppImage *bool `json:"extract_appimage,omitempty" yaml:"extract_appimage,omitempty"`
+
+	// A command that extracts downloads in a format bindown doesn't support, like
+	// ["innoextract", "-d", "{{.dir}}", "{{.download}}"]. Arguments are templates that can use the dependency's vars,
+	// "download" for the path of the downloaded file and "dir" for the directory to extract to. It is only run when
+	// the config has AllowExtractCommand set.
+	ExtractCommand []string `json:"extract_command,omitempty" yaml:"extract_command,omitempty"`
+
+	// Globs for the files to extract from an archive download, like ["bin/*", "lib"]. A glob that matches a directory
+	// matches everything in it. Other files aren't extracted, which saves time and disk space for large archives.
+	// archive_path is always extracted.
+	ExtractInclude []string `json:"extract_include,omitempty" yaml:"extract_include,omitempty"`
+
+	// Globs for files not to extract from an archive download, like ["docs", "*/examples"]. A glob that matches a
+	// directory matches everything in it. archive_path is always extracted.
+	ExtractExclude []string `json:"extract_exclude,omitempty" yaml:"extract_exclude,omitempty"`
+
+	// A command that checks the installed bin works, like ["{{.path}}", "--version"]. Arguments are templates that can
+	// use the dependency's vars and "path" for the installed bin. It is run after each install on the system bindown
+	// is running on, and the install fails when it exits non-zero. This catches downloads that match their checksums
+	// but don't run.
+	ValidateCommand []string `json:"validate_command,omitempty" yaml:"validate_command,omitempty"`
+
+	// A regular expression the output of validate_command must match, like "version {{.version}}". It is a template
+	// that can use the dependency's vars.
+	ValidateOutput *string `json:"validate_output,omitempty" yaml:"validate_output,omitempty"`
+
+	// A command that prints a shell completion script for the installed bin, like
+	// ["{{.path}}", "completion", "{{.shell}}"]. Arguments are templates that can use the dependency's vars, "path"
+	// for the installed bin and "shell" for bash, zsh or fish. It is used by "bindown completion tools".
+	CompletionCommand []string `json:"completion_command,omitempty" yaml:"completion_command,omitempty"`
+
+	// Labels for selecting a group of dependencies, like "bindown build-release-dir --tag toolbox". Tags from the
+	// dependency's template are combined with the dependency's.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
 	built    bool
 	name     string
 	checksum string
-	url      string
-	system   System
+	// serviceChecksum is set when checksum came from the config's ChecksumService.
+	serviceChecksum bool
+	// binChecksum is the checksum of the extracted bin from bin_checksums
+	binChecksum string
+	// checksumKey is the dependency's key in url_checksums
+	checksumKey string
+	url         string
+	zsyncURL    string
+	system      System
+	downloader  *downloader
+	// metrics records how installing the dependency went. It is nil unless metrics are being collected.
+	metrics *DependencyMetrics
+	// allowExtractCommand is the config's AllowExtractCommand
+	allowExtractCommand bool
 }
 
 func cloneSubstitutions(subs map[string]map[string]string) map[string]map[string]string {
@@ -130,12 +242,31 @@ func varsWithSubstitutions(vars map[string]string, subs map[string]map[string]st
 
 func (d *Dependency) clone() *Dependency {
 	dd := &Dependency{
-		Overrideable: *(d.Overrideable.clone()),
-		Homepage:     clonePointer(d.Homepage),
-		Description:  clonePointer(d.Description),
-		Template:     clonePointer(d.Template),
-		Systems:      slices.Clone(d.Systems),
-		RequiredVars: slices.Clone(d.RequiredVars),
+		Overrideable:      *(d.Overrideable.clone()),
+		Homepage:          clonePointer(d.Homepage),
+		Description:       clonePointer(d.Description),
+		License:           clonePointer(d.License),
+		Template:          clonePointer(d.Template),
+		Systems:           slices.Clone(d.Systems),
+		RequiredVars:      slices.Clone(d.RequiredVars),
+		InstallPath:       clonePointer(d.InstallPath),
+		MaxDownloadSize:   clonePointer(d.MaxDownloadSize),
+		Cache:             clonePointer(d.Cache),
+		Timeout:           clonePointer(d.Timeout),
+		Attestation:       clonePointer(d.Attestation),
+		OSV:               clonePointer(d.OSV),
+		Env:               maps.Clone(d.Env),
+		ZsyncURL:          clonePointer(d.ZsyncURL),
+		Requires:          slices.Clone(d.Requires),
+		PresignCommand:    slices.Clone(d.PresignCommand),
+		ExtractAppImage:   clonePointer(d.ExtractAppImage),
+		ExtractCommand:    slices.Clone(d.ExtractCommand),
+		ExtractInclude:    slices.Clone(d.ExtractInclude),
+		ExtractExclude:    slices.Clone(d.ExtractExclude),
+		ValidateCommand:   slices.Clone(d.ValidateCommand),
+		ValidateOutput:    clonePointer(d.ValidateOutput),
+		Tags:              slices.Clone(d.Tags),
+		CompletionCommand: slices.Clone(d.CompletionCommand),
 	}
 	return dd
 }
@@ -148,9 +279,61 @@ func (d *Dependency) binName() string {
 	return d.name
 }
 
+// archivePath returns the slash-separated path of the bin inside the extracted download. An AppImage that isn't
+// extracted is the bin, so its default is the download's name.
+func (d *Dependency) archivePath() string {
+	d.mustBeBuilt()
+	if d.ArchivePath != nil {
+		return *d.ArchivePath
+	}
+	dlName, err := urlFilename(d.url)
+	if err == nil && isAppImage(dlName) && !d.extractsAppImage() {
+		return dlName
+	}
+	return d.binName()
+}
+
+// extractsAppImage returns true when an AppImage download is extracted rather than installed as is.
+func (d *Dependency) extractsAppImage() bool {
+	return d.ExtractAppImage != nil && *d.ExtractAppImage
+}
+
+// installPath executes the install path template tmpl for the dependency. The template can use the dependency's vars
+// along with "name" for the dependency name and "bin" for the bin name. "os" and "arch" are always the target system's
+// values regardless of any substitutions. The result must be a relative path that stays inside the directory it is
+// joined to.
+func (d *Dependency) installPath(tmpl string) (string, error) {
+	d.mustBeBuilt()
+	vars := maps.Clone(d.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["os"] = d.system.OS()
+	vars["arch"] = d.system.Arch()
+	vars["name"] = d.name
+	vars["bin"] = d.binName()
+	p, err := executeTemplate(tmpl, d.system.OS(), d.system.Arch(), vars)
+	if err != nil {
+		return "", err
+	}
+	p = filepath.FromSlash(p)
+	if !filepath.IsLocal(p) {
+		return "", &ConfigError{Err: fmt.Errorf("install path %q for %s must be a relative path inside the install directory", p, d.name)}
+	}
+	return p, nil
+}
+
 // interpolateVars executes go templates in values
 func (d *Dependency) interpolateVars(system System) error {
-	for _, p := range []*string{d.URL, d.ArchivePath, d.BinName} {
+	ptrs := []*string{d.URL, d.ArchivePath, d.BinName, d.ZsyncURL}
+	if d.Attestation != nil {
+		ptrs = append(ptrs, &d.Attestation.Repository, &d.Attestation.SignerWorkflow)
+	}
+	d.Entrypoints = slices.Clone(d.Entrypoints)
+	for i := range d.Entrypoints {
+		ptrs = append(ptrs, &d.Entrypoints[i])
+	}
+	for _, p := range ptrs {
 		if p == nil {
 			continue
 		}
@@ -160,6 +343,13 @@ func (d *Dependency) interpolateVars(system System) error {
 			return err
 		}
 	}
+	for key, val := range d.Env {
+		var err error
+		d.Env[key], err = executeTemplate(val, system.OS(), system.Arch(), d.Vars)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -189,17 +379,64 @@ func (d *Dependency) applyTemplate(templates map[string]*Dependency, depth int)
 		return err
 	}
 	newDL.Template = d.Template
+	newDL.Homepage = overrideValue(newDL.Homepage, d.Homepage)
+	newDL.Description = overrideValue(newDL.Description, d.Description)
+	newDL.License = overrideValue(newDL.License, d.License)
 	if newDL.Vars == nil && d.Vars != nil {
 		newDL.Vars = make(map[string]string, len(d.Vars))
 	}
 	maps.Copy(newDL.Vars, d.Vars)
+	if newDL.Env == nil && d.Env != nil {
+		newDL.Env = make(map[string]string, len(d.Env))
+	}
+	maps.Copy(newDL.Env, d.Env)
 	newDL.ArchivePath = overrideValue(newDL.ArchivePath, d.ArchivePath)
 	newDL.BinName = overrideValue(newDL.BinName, d.BinName)
 	newDL.URL = overrideValue(newDL.URL, d.URL)
 	newDL.Link = overrideValue(newDL.Link, d.Link)
+	newDL.InstallPath = overrideValue(newDL.InstallPath, d.InstallPath)
+	newDL.MaxDownloadSize = overrideValue(newDL.MaxDownloadSize, d.MaxDownloadSize)
+	newDL.Cache = overrideValue(newDL.Cache, d.Cache)
+	newDL.Timeout = overrideValue(newDL.Timeout, d.Timeout)
+	newDL.Attestation = overrideValue(newDL.Attestation, d.Attestation)
+	newDL.OSV = overrideValue(newDL.OSV, d.OSV)
+	newDL.ZsyncURL = overrideValue(newDL.ZsyncURL, d.ZsyncURL)
+	newDL.ValidateOutput = overrideValue(newDL.ValidateOutput, d.ValidateOutput)
+	newDL.ExtractAppImage = overrideValue(newDL.ExtractAppImage, d.ExtractAppImage)
 	if d.RequiredVars != nil {
 		newDL.RequiredVars = append(newDL.RequiredVars, d.RequiredVars...)
 	}
+	if d.PresignCommand != nil {
+		newDL.PresignCommand = d.PresignCommand
+	}
+	if d.Entrypoints != nil {
+		newDL.Entrypoints = d.Entrypoints
+	}
+	if d.ExtractCommand != nil {
+		newDL.ExtractCommand = d.ExtractCommand
+	}
+	if d.ExtractInclude != nil {
+		newDL.ExtractInclude = d.ExtractInclude
+	}
+	if d.ExtractExclude != nil {
+		newDL.ExtractExclude = d.ExtractExclude
+	}
+	if d.ValidateCommand != nil {
+		newDL.ValidateCommand = d.ValidateCommand
+	}
+	if d.CompletionCommand != nil {
+		newDL.CompletionCommand = d.CompletionCommand
+	}
+	for _, req := range d.Requires {
+		if !slices.Contains(newDL.Requires, req) {
+			newDL.Requires = append(newDL.Requires, req)
+		}
+	}
+	for _, tag := range d.Tags {
+		if !slices.Contains(newDL.Tags, tag) {
+			newDL.Tags = append(newDL.Tags, tag)
+		}
+	}
 	newDL.Systems = slices.Clone(newDL.Systems)
 
 	if len(d.Overrides) > 0 {
@@ -225,44 +462,48 @@ func (d *Dependency) cacheKey() string {
 
 const maxOverrideDepth = 10
 
+// matches returns true when the override's matcher matches system and vars
+func (o *DependencyOverride) matches(system System, vars map[string]string) bool {
+	systemVars := maps.Clone(vars)
+	if systemVars == nil {
+		systemVars = make(map[string]string)
+	}
+	if _, ok := systemVars["os"]; !ok {
+		systemVars["os"] = system.OS()
+	}
+	if _, ok := systemVars["arch"]; !ok {
+		systemVars["arch"] = system.Arch()
+	}
+	return !slices.ContainsFunc(MapKeys(o.OverrideMatcher), func(varName string) bool {
+		overridePatterns := o.OverrideMatcher[varName]
+		val := systemVars[varName]
+		// A match is found if the value is an exact match for a pattern or if the
+		// pattern is a valid semver constraint and the value is a valid semver that
+		// satisfies the constraint.
+		matcher := func(pattern string) bool {
+			if pattern == val {
+				return true
+			}
+			constraint, err := semver.NewConstraint(pattern)
+			if err != nil {
+				return false
+			}
+			version, err := semver.NewVersion(val)
+			if err != nil {
+				return false
+			}
+			return constraint.Check(version)
+		}
+		return !slices.ContainsFunc(overridePatterns, matcher)
+	})
+}
+
 func (d *Overrideable) applyOverrides(system System, depth int) error {
 	if depth >= maxOverrideDepth && len(d.Overrides) > 0 {
 		return fmt.Errorf("max override depth of %d exceeded", maxOverrideDepth)
 	}
 	for i := range d.Overrides {
-		systemVars := maps.Clone(d.Vars)
-		if systemVars == nil {
-			systemVars = make(map[string]string)
-		}
-		if _, ok := systemVars["os"]; !ok {
-			systemVars["os"] = system.OS()
-		}
-		if _, ok := systemVars["arch"]; !ok {
-			systemVars["arch"] = system.Arch()
-		}
-		match := !slices.ContainsFunc(MapKeys(d.Overrides[i].OverrideMatcher), func(varName string) bool {
-			overridePatterns := d.Overrides[i].OverrideMatcher[varName]
-			val := systemVars[varName]
-			// A match is found if the value is an exact match for a pattern or if the
-			// pattern is a valid semver constraint and the value is a valid semver that
-			// satisfies the constraint.
-			matcher := func(pattern string) bool {
-				if pattern == val {
-					return true
-				}
-				constraint, err := semver.NewConstraint(pattern)
-				if err != nil {
-					return false
-				}
-				version, err := semver.NewVersion(val)
-				if err != nil {
-					return false
-				}
-				return constraint.Check(version)
-			}
-			return !slices.ContainsFunc(overridePatterns, matcher)
-		})
-		if !match {
+		if !d.Overrides[i].matches(system, d.Vars) {
 			continue
 		}
 		dependency := &d.Overrides[i].Dependency
@@ -282,6 +523,9 @@ func (d *Overrideable) applyOverrides(system System, depth int) error {
 			}
 		}
 		d.Link = overrideValue(d.Link, dependency.Link)
+		if dependency.Entrypoints != nil {
+			d.Entrypoints = dependency.Entrypoints
+		}
 		d.ArchivePath = overrideValue(d.ArchivePath, dependency.ArchivePath)
 		d.BinName = overrideValue(d.BinName, dependency.BinName)
 		d.URL = overrideValue(d.URL, dependency.URL)
@@ -55,6 +55,8 @@ templates:
     url: parentTemplateURL
   template1:
     template: parentTemplate
+    homepage: templateHomepage
+    description: templateDescription
     link: true
     archive_path: templateArchivePath
     vars:
@@ -69,6 +71,7 @@ templates:
 dependencies:
   myDependency:
     template: template1
+    description: dependencyDescription
     link: false
     archive_path: dependencyArchivePath
     vars:
@@ -82,6 +85,8 @@ dependencies:
           url: dependencyOverrideURL
   want:
     template: template1
+    homepage: templateHomepage
+    description: dependencyDescription
     link: false
     archive_path: dependencyArchivePath
     url: parentTemplateURL
@@ -0,0 +1,88 @@
+package bindown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeDependencyName folds the differences people commonly make when typing a dependency name, so
+// "GolangCI_Lint" and "golangci-lint" compare equal.
+func NormalizeDependencyName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '.', ' ':
+			return '-'
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// ResolveDependencyName returns the configured name of the dependency name refers to. An exact match wins. Otherwise
+// name matches a dependency when they are equal ignoring case and "_", "." and "-" differences. name is returned
+// unchanged when nothing or more than one dependency matches.
+func (c *Config) ResolveDependencyName(name string) string {
+	if _, ok := c.Dependencies[name]; ok {
+		return name
+	}
+	normalized := NormalizeDependencyName(name)
+	resolved := ""
+	for _, depName := range c.DependencyNames() {
+		if NormalizeDependencyName(depName) != normalized {
+			continue
+		}
+		if resolved != "" {
+			return name
+		}
+		resolved = depName
+	}
+	if resolved == "" {
+		return name
+	}
+	return resolved
+}
+
+// suggestDependencyName returns the configured dependency name closest to name or "" when none is close enough to be
+// a likely typo.
+func (c *Config) suggestDependencyName(name string) string {
+	normalized := NormalizeDependencyName(name)
+	// allow about one typo per four characters
+	best, bestDistance := "", len(normalized)/4+1
+	for _, depName := range c.DependencyNames() {
+		distance := levenshtein(normalized, NormalizeDependencyName(depName))
+		if distance <= bestDistance && (best == "" || distance < bestDistance) {
+			best, bestDistance = depName, distance
+		}
+	}
+	return best
+}
+
+// unknownDependencyError is the error for a dependency name that isn't configured. It suggests a near-miss.
+func (c *Config) unknownDependencyError(name string) error {
+	suggestion := c.suggestDependencyName(name)
+	if suggestion == "" {
+		return fmt.Errorf("no dependency configured with the name %q", name)
+	}
+	return fmt.Errorf("no dependency configured with the name %q. did you mean %q?", name, suggestion)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range ar {
+		cur[0] = i + 1
+		for j := range br {
+			cost := 1
+			if ar[i] == br[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,49 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ResolveDependencyName(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+dependencies:
+  golangci-lint:
+    url: https://example.com/golangci-lint
+  foo:
+    url: https://example.com/foo
+  Foo:
+    url: https://example.com/Foo
+`)
+	for _, td := range []struct {
+		name string
+		want string
+	}{
+		{name: "golangci-lint", want: "golangci-lint"},
+		{name: "GolangCI_Lint", want: "golangci-lint"},
+		{name: "golangci.lint", want: "golangci-lint"},
+		{name: "Foo", want: "Foo"},
+		// ambiguous
+		{name: "FOO", want: "FOO"},
+		{name: "missing", want: "missing"},
+	} {
+		require.Equal(t, td.want, cfg.ResolveDependencyName(td.name), td.name)
+	}
+}
+
+func TestConfig_unknownDependencyError(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+dependencies:
+  golangci-lint:
+    url: https://example.com/golangci-lint
+  goreleaser:
+    url: https://example.com/goreleaser
+`)
+	err := cfg.unknownDependencyError("golangcilint")
+	require.EqualError(t, err, `no dependency configured with the name "golangcilint". did you mean "golangci-lint"?`)
+	err = cfg.unknownDependencyError("go-releaser")
+	require.EqualError(t, err, `no dependency configured with the name "go-releaser". did you mean "goreleaser"?`)
+	err = cfg.unknownDependencyError("terraform")
+	require.EqualError(t, err, `no dependency configured with the name "terraform"`)
+}
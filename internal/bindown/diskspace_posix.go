@@ -0,0 +1,15 @@
+//go:build !windows
+
+package bindown
+
+import "golang.org/x/sys/unix"
+
+// freeDiskSpace returns the number of bytes available to the current user on the filesystem containing dir
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat unix.Statfs_t
+	err := unix.Statfs(dir, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
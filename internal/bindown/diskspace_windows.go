@@ -0,0 +1,19 @@
+//go:build windows
+
+package bindown
+
+import "golang.org/x/sys/windows"
+
+// freeDiskSpace returns the number of bytes available to the current user on the filesystem containing dir
+func freeDiskSpace(dir string) (uint64, error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	err = windows.GetDiskFreeSpaceEx(dirPtr, &free, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,232 @@
+package bindown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DoctorStatus is the outcome of a DoctorCheck
+type DoctorStatus string
+
+const (
+	DoctorOK   DoctorStatus = "ok"
+	DoctorWarn DoctorStatus = "warn"
+	DoctorFail DoctorStatus = "fail"
+)
+
+// DoctorCheck is the result of a single diagnostic check
+type DoctorCheck struct {
+	Name    string
+	Status  DoctorStatus
+	Message string
+	// Fix is a suggestion for resolving the problem. It is empty when Status is DoctorOK.
+	Fix string
+}
+
+// DoctorOpts provides options for Config.Doctor
+type DoctorOpts struct {
+	// SkipNetwork skips checking that the hosts in dependency urls are reachable.
+	SkipNetwork bool
+	// Timeout for each host check. Default is 10 seconds.
+	Timeout time.Duration
+	// PathEnv is the PATH to look for the install directory in. Default is the PATH environment variable.
+	PathEnv string
+	// MinFreeSpace is the number of free bytes below which the disk space check warns. Default is 1 GiB.
+	MinFreeSpace uint64
+}
+
+// Doctor runs diagnostics on the config and the environment bindown runs in.
+func (c *Config) Doctor(ctx context.Context, opts *DoctorOpts) []DoctorCheck {
+	if opts == nil {
+		opts = &DoctorOpts{}
+	}
+	depsCheck, hosts := c.doctorDependencies()
+	checks := []DoctorCheck{depsCheck}
+	cacheCheck := c.doctorCache()
+	checks = append(checks, cacheCheck)
+	if cacheCheck.Status == DoctorOK {
+		checks = append(checks, c.doctorSymlink(), c.doctorDiskSpace(opts.MinFreeSpace))
+	}
+	checks = append(checks, c.doctorPath(opts.PathEnv))
+	if !opts.SkipNetwork {
+		checks = append(checks, doctorHosts(ctx, c.downloader().httpClient(), hosts, opts.Timeout)...)
+	}
+	return checks
+}
+
+// doctorDependencies checks that every dependency builds for its systems and has checksums. It also returns the
+// hosts of all dependency urls.
+func (c *Config) doctorDependencies() (DoctorCheck, []string) {
+	check := DoctorCheck{Name: "dependencies"}
+	var hosts, missingSums []string
+	for _, name := range c.DependencyNames() {
+		systems, err := c.DependencySystems(name)
+		if err != nil {
+			check.Status = DoctorFail
+			check.Message = err.Error()
+			check.Fix = fmt.Sprintf("fix the configuration of %q in %s", name, c.Filename)
+			return check, hosts
+		}
+		for _, system := range systems {
+			var dep *Dependency
+			dep, err = c.BuildDependency(name, system)
+			if err != nil {
+				check.Status = DoctorFail
+				check.Message = fmt.Sprintf("%s on %s: %v", name, system, err)
+				check.Fix = fmt.Sprintf("fix the configuration of %q in %s", name, c.Filename)
+				return check, hosts
+			}
+			if dep.checksum == "" {
+				missingSums = append(missingSums, fmt.Sprintf("%s on %s", name, system))
+			}
+			u, err := url.Parse(dep.url)
+			if err == nil && u.Host != "" {
+				host := u.Scheme + "://" + u.Host
+				if !slices.Contains(hosts, host) {
+					hosts = append(hosts, host)
+				}
+			}
+		}
+	}
+	if len(missingSums) > 0 {
+		check.Status = DoctorWarn
+		check.Message = "missing checksum for " + missingSums[0]
+		if len(missingSums) > 1 {
+			check.Message += fmt.Sprintf(" and %d more", len(missingSums)-1)
+		}
+		check.Fix = "run `bindown checksums add --all`"
+		return check, hosts
+	}
+	check.Status = DoctorOK
+	check.Message = "all dependencies are valid for their systems"
+	return check, hosts
+}
+
+func (c *Config) doctorCache() DoctorCheck {
+	check := DoctorCheck{Name: "cache"}
+	fix := "set cache in your config or BINDOWN_CACHE to a writable directory"
+	err := os.MkdirAll(c.Cache, 0o755)
+	if err == nil {
+		var f *os.File
+		f, err = os.CreateTemp(c.Cache, ".doctor-")
+		if err == nil {
+			_ = f.Close()
+			err = os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		check.Status = DoctorFail
+		check.Message = fmt.Sprintf("%s is not writable: %v", c.Cache, err)
+		check.Fix = fix
+		return check
+	}
+	check.Status = DoctorOK
+	check.Message = fmt.Sprintf("%s is writable", c.Cache)
+	return check
+}
+
+func (c *Config) doctorSymlink() DoctorCheck {
+	check := DoctorCheck{Name: "symlinks"}
+	dir, err := os.MkdirTemp(c.Cache, ".doctor-")
+	if err == nil {
+		err = os.Symlink("target", filepath.Join(dir, "link"))
+		err = errors.Join(err, os.RemoveAll(dir))
+	}
+	if err != nil {
+		check.Status = DoctorWarn
+		check.Message = fmt.Sprintf("unable to create symlinks: %v", err)
+		check.Fix = "dependencies with link: true need symlinks. On Windows, enable Developer Mode"
+		return check
+	}
+	check.Status = DoctorOK
+	check.Message = "symlinks are supported"
+	return check
+}
+
+func (c *Config) doctorDiskSpace(minFree uint64) DoctorCheck {
+	check := DoctorCheck{Name: "disk space"}
+	if minFree == 0 {
+		minFree = 1 << 30
+	}
+	free, err := freeDiskSpace(c.Cache)
+	if err != nil {
+		check.Status = DoctorWarn
+		check.Message = fmt.Sprintf("unable to check free space: %v", err)
+		return check
+	}
+	check.Message = fmt.Sprintf("%d MiB free in %s", free>>20, c.Cache)
+	if free < minFree {
+		check.Status = DoctorWarn
+		check.Fix = "free up disk space or run `bindown cache clear`"
+		return check
+	}
+	check.Status = DoctorOK
+	return check
+}
+
+func (c *Config) doctorPath(pathEnv string) DoctorCheck {
+	check := DoctorCheck{Name: "PATH"}
+	if pathEnv == "" {
+		pathEnv = os.Getenv("PATH")
+	}
+	installDir, err := filepath.Abs(c.InstallDir)
+	if err != nil {
+		check.Status = DoctorWarn
+		check.Message = err.Error()
+		return check
+	}
+	for _, dir := range filepath.SplitList(pathEnv) {
+		dir, err = filepath.Abs(dir)
+		if err == nil && dir == installDir {
+			check.Status = DoctorOK
+			check.Message = fmt.Sprintf("%s is in PATH", c.InstallDir)
+			return check
+		}
+	}
+	check.Status = DoctorWarn
+	check.Message = fmt.Sprintf("%s is not in PATH", c.InstallDir)
+	check.Fix = fmt.Sprintf("add %s to your PATH or run installed bins by their path", installDir)
+	return check
+}
+
+func doctorHosts(ctx context.Context, client *http.Client, hosts []string, timeout time.Duration) []DoctorCheck {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	client = &http.Client{Transport: client.Transport, Timeout: timeout}
+	checks := make([]DoctorCheck, 0, len(hosts))
+	for _, host := range hosts {
+		check := DoctorCheck{Name: "network"}
+		err := headHost(ctx, client, host)
+		if err != nil {
+			check.Status = DoctorFail
+			check.Message = fmt.Sprintf("%s is unreachable: %v", host, err)
+			check.Fix = "check your network connection and proxy settings"
+		} else {
+			check.Status = DoctorOK
+			check.Message = fmt.Sprintf("%s is reachable", host)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// headHost returns nil if host responds to a HEAD request with any status
+func headHost(ctx context.Context, client *http.Client, host string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, host, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
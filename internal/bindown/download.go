@@ -1,13 +1,22 @@
 package bindown
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/willabides/bindown/v4/internal/cache"
 )
@@ -18,20 +27,36 @@ func downloadDependency(
 	allowMissingChecksum, force bool,
 ) (cachedFile, key string, unlock func() error, errOut error) {
 	dep.mustBeBuilt()
+	start := time.Now()
 	dlFile, err := urlFilename(dep.url)
 	if err != nil {
 		return "", "", nil, err
 	}
 
+	// wait for another dependency that is downloading the same url, so this one finds it in the cache
+	groupSum, groupDone := dep.downloader.downloadGroup().start(dep.url)
+	defer func() {
+		if errOut != nil {
+			groupDone("")
+			return
+		}
+		groupDone(dep.checksum)
+	}()
+	if dep.checksum == "" && allowMissingChecksum {
+		dep.checksum = groupSum
+	}
+
+	// downloaded is set when the file was downloaded instead of coming from the cache
+	downloaded := false
 	var downloader func(dir string) error
 	checksum := dep.checksum
 	if checksum == "" {
 		if !allowMissingChecksum {
-			err = fmt.Errorf("no checksum configured for %s %s", dep.name, dep.url)
+			err = &ConfigError{Err: fmt.Errorf("no checksum configured for %s %s", dep.name, dep.url)}
 			return "", "", nil, err
 		}
 		var tempDir string
-		tempDir, err = os.MkdirTemp("", "bindown")
+		tempDir, err = dep.downloader.tempDir()
 		if err != nil {
 			return "", "", nil, err
 		}
@@ -39,27 +64,56 @@ func downloadDependency(
 			return os.RemoveAll(tempDir)
 		})
 		tempFile := filepath.Join(tempDir, dlFile)
-		checksum, err = getURLChecksum(dep.url, tempFile)
+		checksum, err = getURLChecksum(dep.url, tempFile, dep.downloader)
+		if err != nil {
+			return "", "", nil, err
+		}
+		downloaded = true
+		err = dep.downloader.scan(dep.url, tempFile)
 		if err != nil {
 			return "", "", nil, err
 		}
+		err = verifyAttestation(dep, tempFile)
+		if err != nil {
+			return "", "", nil, err
+		}
+		// let the caller see the checksum that was computed
+		dep.checksum = checksum
 		downloader = func(dir string) (dlErrOut error) {
-			return copyFile(tempFile, filepath.Join(dir, dlFile))
+			return moveFile(tempFile, filepath.Join(dir, dlFile))
 		}
 	} else {
+		seed := ""
+		if dep.zsyncURL != "" && len(dep.downloader.commandArgs()) == 0 {
+			seed = deltaSeed(dlCache, dep)
+		}
 		downloader = func(dir string) error {
 			ok, dlErr := fileExistsWithChecksum(filepath.Join(dir, dlFile), checksum)
 			if dlErr != nil || ok {
 				return dlErr
 			}
-			gotSum, dlErr := downloadFile(filepath.Join(dir, dlFile), dep.url)
+			downloaded = true
+			var gotSum string
+			if seed != "" {
+				gotSum, dlErr = zsyncDownload(filepath.Join(dir, dlFile), seed, dep)
+			}
+			// fall back to a full download when the delta download fails or doesn't match
+			if seed == "" || dlErr != nil || gotSum != checksum {
+				gotSum, dlErr = downloadFile(filepath.Join(dir, dlFile), dep.url, dep.downloader)
+			}
 			if dlErr != nil {
 				return dlErr
 			}
 			if checksum != gotSum {
-				return fmt.Errorf(`checksum mismatch in downloaded file %q 
-wanted: %s
-got: %s`, dlFile, checksum, gotSum)
+				return &ChecksumMismatchError{File: dlFile, Want: checksum, Got: gotSum}
+			}
+			dlErr = dep.downloader.scan(dep.url, filepath.Join(dir, dlFile))
+			if dlErr == nil {
+				dlErr = verifyAttestation(dep, filepath.Join(dir, dlFile))
+			}
+			if dlErr != nil {
+				// don't leave a file that would pass validation in the cache
+				return errors.Join(dlErr, os.Remove(filepath.Join(dir, dlFile)))
 			}
 			return nil
 		}
@@ -87,25 +141,65 @@ got: %s`, dlFile, checksum, gotSum)
 	if err != nil {
 		return "", "", nil, err
 	}
+	if dep.zsyncURL != "" {
+		err = recordDeltaSeed(dlCache, dep, filepath.Join(dir, dlFile))
+		if err != nil {
+			return "", "", nil, errors.Join(err, unlock())
+		}
+	}
+	if dep.metrics != nil {
+		info, statErr := os.Stat(filepath.Join(dir, dlFile))
+		if statErr != nil {
+			return "", "", nil, errors.Join(statErr, unlock())
+		}
+		dep.metrics.download(!downloaded, start, info.Size())
+	}
 	return filepath.Join(dir, dlFile), key, unlock, nil
 }
 
 // downloadFile downloads the file at url to targetPath. It returns the checksum of the file.
-func downloadFile(targetPath, url string) (_ string, errOut error) {
+func downloadFile(targetPath, url string, dl *downloader) (_ string, errOut error) {
 	hasher := sha256.New()
-	err := os.MkdirAll(filepath.Dir(targetPath), 0o750)
+	dlName, err := urlFilename(url)
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Get(url)
+	err = os.MkdirAll(filepath.Dir(targetPath), 0o750)
+	if err != nil {
+		return "", err
+	}
+	if len(dl.commandArgs()) > 0 {
+		var signedURL string
+		signedURL, err = dl.presign(url)
+		if err != nil {
+			return "", err
+		}
+		ctx, cancel := dl.context()
+		err = runDownloadCommand(ctx, dl.commandArgs(), signedURL, targetPath)
+		cancel()
+		if err != nil {
+			return "", err
+		}
+		err = dl.checkFileSize(url, targetPath)
+		if err != nil {
+			return "", err
+		}
+		err = checkFileMagic(dlName, targetPath)
+		if err != nil {
+			return "", err
+		}
+		return fileChecksum(targetPath)
+	}
+	resp, err := dl.get(url)
 	if err != nil {
 		return "", err
 	}
 	defer deferErr(&errOut, resp.Body.Close)
-	bodyReader := io.TeeReader(resp.Body, hasher)
-	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("failed downloading %s", url)
+	err = checkContentType(dlName, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
 	}
+	bodyReader := io.TeeReader(resp.Body, hasher)
 	out, err := os.Create(targetPath)
 	if err != nil {
 		return "", err
@@ -115,17 +209,341 @@ func downloadFile(targetPath, url string) (_ string, errOut error) {
 	if err != nil {
 		return "", err
 	}
+	err = checkFileMagic(dlName, targetPath)
+	if err != nil {
+		return "", err
+	}
 	sum := hex.EncodeToString(hasher.Sum(nil))
 	return sum, nil
 }
 
+// downloader holds the config settings that affect how files are downloaded. A nil downloader uses the defaults.
+type downloader struct {
+	command []string
+	// scanCommand is run on each downloaded file before it is cached
+	scanCommand []string
+	proxy       *ProxyConfig
+	// maxSize is the largest file that can be downloaded. Zero means no limit.
+	maxSize int64
+	// timeout and deadline limit how long downloads and commands for a dependency can run. Zero means no limit.
+	timeout  time.Duration
+	deadline time.Time
+	// presignCommand prints a presigned url for the url it is given
+	presignCommand []string
+	// presigned holds the urls returned by presign
+	presigned map[string]string
+	// userAgent is the User-Agent header for requests. Empty means DefaultUserAgent.
+	userAgent string
+	// group coalesces downloads of the same url.
+	group *downloadGroup
+	// githubHosts are the config's GitHubEnterpriseHosts.
+	githubHosts []string
+	// workDir is where temporary downloads go. Empty means the system temp directory.
+	workDir string
+	// allowedHosts are the config's AllowedHosts.
+	allowedHosts []string
+}
+
+func (c *Config) downloader() *downloader {
+	return &downloader{
+		command:      c.DownloadCommand,
+		scanCommand:  c.ScanCommand,
+		proxy:        c.Proxy,
+		userAgent:    c.UserAgent,
+		group:        c.downloadGroup(),
+		githubHosts:  c.GitHubEnterpriseHosts,
+		allowedHosts: c.AllowedHosts,
+	}
+}
+
+// downloadGroup returns the group that coalesces downloads. It is nil for a nil downloader.
+func (dl *downloader) downloadGroup() *downloadGroup {
+	if dl == nil {
+		return nil
+	}
+	return dl.group
+}
+
+// tempDir creates a temporary directory in the work dir. The caller removes it.
+func (dl *downloader) tempDir() (string, error) {
+	if dl == nil || dl.workDir == "" {
+		return os.MkdirTemp("", "bindown")
+	}
+	err := os.MkdirAll(dl.workDir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dl.workDir, "bindown")
+}
+
+// commandArgs returns the download command templates. It is empty when files are downloaded by bindown.
+func (dl *downloader) commandArgs() []string {
+	if dl == nil {
+		return nil
+	}
+	return dl.command
+}
+
+// scan runs the scan command on filename, which was downloaded from url. It does nothing when there is no scan
+// command.
+func (dl *downloader) scan(url, filename string) error {
+	if dl == nil || len(dl.scanCommand) == 0 {
+		return nil
+	}
+	ctx, cancel := dl.context()
+	defer cancel()
+	return runScanCommand(ctx, dl.scanCommand, url, filename)
+}
+
+// httpClient returns a client that uses the configured proxy and User-Agent and stops at the deadline
+func (dl *downloader) httpClient() *http.Client {
+	var transport http.RoundTripper = http.DefaultTransport
+	client := &http.Client{}
+	userAgent := ""
+	if dl != nil {
+		userAgent = dl.userAgent
+		if dl.proxy != nil {
+			proxyTransport := http.DefaultTransport.(*http.Transport).Clone()
+			proxyTransport.Proxy = dl.proxy.proxyFunc()
+			transport = proxyTransport
+		}
+		if !dl.deadline.IsZero() {
+			// a non-positive Timeout means no timeout, so use the smallest positive one once the deadline has passed
+			client.Timeout = max(time.Until(dl.deadline), 1)
+		}
+		if len(dl.allowedHosts) > 0 {
+			client.CheckRedirect = dl.checkRedirect
+		}
+	}
+	client.Transport = &userAgentTransport{base: withHTTPFixtures(withHTTPDebug(transport)), userAgent: userAgent}
+	return client
+}
+
+// startTimeout starts the timeout for working on a dependency.
+func (dl *downloader) startTimeout() {
+	if dl != nil && dl.timeout > 0 {
+		dl.deadline = time.Now().Add(dl.timeout)
+	}
+}
+
+// context returns a context for commands that is canceled at the deadline.
+func (dl *downloader) context() (context.Context, context.CancelFunc) {
+	if dl == nil || dl.deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), dl.deadline)
+}
+
+// wrapTimeout adds the timeout to err when the deadline has passed.
+func (dl *downloader) wrapTimeout(err error) error {
+	if err == nil || dl == nil || dl.deadline.IsZero() || time.Now().Before(dl.deadline) {
+		return err
+	}
+	return fmt.Errorf("timed out after %s: %w", dl.timeout, err)
+}
+
+// get performs a GET request for url and returns an error for non-successful responses. The request is made to the
+// presigned url for url, or the api for a release asset on a GitHub Enterprise Server host.
+func (dl *downloader) get(url string) (*http.Response, error) {
+	req, err := dl.newRequest(context.Background(), http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := dl.do(req, url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, errors.Join(&downloadError{err: fmt.Errorf("failed downloading %s", url)}, resp.Body.Close())
+	}
+	if dl == nil || dl.maxSize == 0 {
+		return resp, nil
+	}
+	if resp.ContentLength > dl.maxSize {
+		return nil, errors.Join(dl.tooLargeErr(url), resp.Body.Close())
+	}
+	resp.Body = &maxSizeReader{
+		ReadCloser: resp.Body,
+		remaining:  dl.maxSize,
+		err:        dl.tooLargeErr(url),
+	}
+	return resp, nil
+}
+
+// head performs a HEAD request for url and returns an error when the response isn't successful. The request is made to
+// the same url as get's.
+func (dl *downloader) head(url string) error {
+	req, err := dl.newRequest(context.Background(), http.MethodHead, url)
+	if err != nil {
+		return err
+	}
+	resp, err := dl.do(req, url)
+	if err != nil {
+		return err
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &downloadError{err: fmt.Errorf("%s returned %s", url, resp.Status)}
+	}
+	return nil
+}
+
+// do sends req, which newRequest made for url. Errors name url instead of the presigned url that was requested, so the
+// signature and credentials in it aren't shown.
+func (dl *downloader) do(req *http.Request, url string) (*http.Response, error) {
+	resp, err := dl.httpClient().Do(req)
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(url)
+	}
+	return resp, err
+}
+
+func (dl *downloader) tooLargeErr(url string) error {
+	return fmt.Errorf("download of %s exceeds max_download_size of %d bytes", url, dl.maxSize)
+}
+
+// checkFileSize returns an error if a downloaded file is larger than maxSize
+func (dl *downloader) checkFileSize(url, filename string) error {
+	if dl == nil || dl.maxSize == 0 {
+		return nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if info.Size() > dl.maxSize {
+		return dl.tooLargeErr(url)
+	}
+	return nil
+}
+
+// maxSizeReader returns err once more than remaining bytes have been read
+type maxSizeReader struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, r.err
+	}
+	// read one byte past the limit to tell a file of exactly remaining bytes from a larger one
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, r.err
+	}
+	return n, err
+}
+
+// parseByteSize parses a number of bytes with an optional unit like "500MB" or "1GiB"
+func parseByteSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"KIB", 1 << 10},
+		{"MIB", 1 << 20},
+		{"GIB", 1 << 30},
+		{"TIB", 1 << 40},
+		{"KB", 1e3},
+		{"MB", 1e6},
+		{"GB", 1e9},
+		{"TB", 1e12},
+		{"K", 1e3},
+		{"M", 1e6},
+		{"G", 1e9},
+		{"T", 1e12},
+		{"B", 1},
+	}
+	numStr := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(numStr, unit.suffix) {
+			numStr = strings.TrimSpace(strings.TrimSuffix(numStr, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	num, err := strconv.ParseFloat(numStr, 64)
+	if err != nil || num < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(num * float64(multiplier)), nil
+}
+
+// runDownloadCommand runs the download command templates to download url to targetPath
+func runDownloadCommand(ctx context.Context, downloadCommand []string, url, targetPath string) error {
+	args, err := executeCommandTemplates(downloadCommand, map[string]string{
+		"url":    url,
+		"output": targetPath,
+	})
+	if err != nil {
+		return err
+	}
+	//nolint:gosec // the command comes from the config file
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &downloadError{err: fmt.Errorf("failed downloading %s with %s: %w\n%s", url, args[0], err, output)}
+	}
+	if !FileExists(targetPath) {
+		return fmt.Errorf("download command %s did not create %s", args[0], targetPath)
+	}
+	return nil
+}
+
+// runScanCommand runs the scan command templates on the file downloaded from url. It returns an error when the
+// scanner exits non-zero.
+func runScanCommand(ctx context.Context, scanCommand []string, url, filename string) error {
+	args, err := executeCommandTemplates(scanCommand, map[string]string{
+		"url":  url,
+		"file": filename,
+	})
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s rejected the download from %s: %w\n%s", args[0], url, err, output)
+	}
+	return nil
+}
+
+// executeCommandTemplates executes each argument template in command with vars.
+func executeCommandTemplates(command []string, vars map[string]string) ([]string, error) {
+	args := make([]string, len(command))
+	for i, argTmpl := range command {
+		tmpl, err := template.New("arg").Option("missingkey=error").Funcs(templateFuncs).Parse(argTmpl)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = buf.String()
+	}
+	return args, nil
+}
+
 // getURLChecksum returns the checksum of the file at dlURL. If tempFile is specified
 // it will be used as the temporary file to download the file to and it will be the caller's
 // responsibility to clean it up. Otherwise, a temporary file will be created and cleaned up
 // automatically.
-func getURLChecksum(dlURL, tempFile string) (_ string, errOut error) {
+func getURLChecksum(dlURL, tempFile string, dl *downloader) (_ string, errOut error) {
 	if tempFile == "" {
-		downloadDir, err := os.MkdirTemp("", "bindown")
+		downloadDir, err := dl.tempDir()
 		if err != nil {
 			return "", err
 		}
@@ -134,5 +552,5 @@ func getURLChecksum(dlURL, tempFile string) (_ string, errOut error) {
 			return os.RemoveAll(downloadDir)
 		})
 	}
-	return downloadFile(tempFile, dlURL)
+	return downloadFile(tempFile, dlURL, dl)
 }
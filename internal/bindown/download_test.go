@@ -0,0 +1,56 @@
+package bindown
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseByteSize(t *testing.T) {
+	for _, td := range []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "100", want: 100},
+		{input: "100B", want: 100},
+		{input: "1.5KB", want: 1500},
+		{input: "500mb", want: 500_000_000},
+		{input: "2 GiB", want: 2 << 30},
+		{input: "1M", want: 1_000_000},
+		{input: "", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "ten MB", wantErr: true},
+	} {
+		t.Run(td.input, func(t *testing.T) {
+			got, err := parseByteSize(td.input)
+			if td.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, got)
+		})
+	}
+}
+
+func Test_maxSizeReader(t *testing.T) {
+	tooLarge := errors.New("too large")
+	newReader := func(content string, maxSize int64) io.Reader {
+		return &maxSizeReader{
+			ReadCloser: io.NopCloser(strings.NewReader(content)),
+			remaining:  maxSize,
+			err:        tooLarge,
+		}
+	}
+
+	got, err := io.ReadAll(newReader("12345", 5))
+	require.NoError(t, err)
+	require.Equal(t, "12345", string(got))
+
+	_, err = io.ReadAll(newReader("123456", 5))
+	require.ErrorIs(t, err, tooLarge)
+}
@@ -0,0 +1,56 @@
+package bindown
+
+import "sync"
+
+// downloadGroup coalesces downloads of the same url by the dependencies a config installs, downloads or extracts.
+// Only one download of a url runs at a time, so dependencies that resolve to the same url wait for the first one and
+// then find the file in the cache instead of downloading it again. It also remembers the checksum of each url that was
+// downloaded, so a url without a configured checksum is only downloaded once to compute it.
+type downloadGroup struct {
+	mu   sync.Mutex
+	urls map[string]*downloadGroupURL
+}
+
+type downloadGroupURL struct {
+	mu       sync.Mutex
+	checksum string
+}
+
+// downloadGroupsMu guards creating Config.downloads. Config is copied by value, so it can't hold a mutex itself.
+var downloadGroupsMu sync.Mutex
+
+// downloadGroup returns the group for c's downloads.
+func (c *Config) downloadGroup() *downloadGroup {
+	downloadGroupsMu.Lock()
+	defer downloadGroupsMu.Unlock()
+	if c.downloads == nil {
+		c.downloads = &downloadGroup{}
+	}
+	return c.downloads
+}
+
+// start waits until no other download of url is running. It returns the checksum of url when it has already been
+// downloaded and a function to call with the checksum, or "" on failure, once the download is done. A nil group
+// doesn't wait.
+func (g *downloadGroup) start(url string) (checksum string, done func(checksum string)) {
+	if g == nil {
+		return "", func(string) {}
+	}
+	g.mu.Lock()
+	if g.urls == nil {
+		g.urls = map[string]*downloadGroupURL{}
+	}
+	u := g.urls[url]
+	if u == nil {
+		u = &downloadGroupURL{}
+		g.urls[url] = u
+	}
+	g.mu.Unlock()
+	u.mu.Lock()
+	return u.checksum, func(checksum string) {
+		if checksum != "" {
+			u.checksum = checksum
+		}
+		u.mu.Unlock()
+	}
+}
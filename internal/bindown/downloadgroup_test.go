@@ -0,0 +1,85 @@
+package bindown
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_downloadGroup(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"))
+	require.NoError(t, err)
+	setup := func(t *testing.T) (*Config, *atomic.Int32) {
+		t.Helper()
+		var requests atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			_, e := w.Write(content)
+			assert.NoError(t, e)
+		}))
+		t.Cleanup(ts.Close)
+		dir := t.TempDir()
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+install_dir: %q
+dependencies:
+  foo:
+    url: %s/foo.tar.gz
+  foo2:
+    url: %s/foo.tar.gz
+    archive_path: foo
+    bin: foo2
+`, filepath.Join(dir, "cache"), filepath.Join(dir, "bin"), ts.URL, ts.URL))
+		return cfg, &requests
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		cfg, requests := setup(t)
+		err := cfg.InstallDependencies([]string{"foo", "foo2"}, "linux/amd64", &ConfigInstallDependenciesOpts{
+			AllowMissingChecksum: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(1), requests.Load())
+		require.FileExists(t, filepath.Join(cfg.InstallDir, "foo"))
+		require.FileExists(t, filepath.Join(cfg.InstallDir, "foo2"))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		cfg, requests := setup(t)
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				name := []string{"foo", "foo2"}[i%2]
+				errs[i] = cfg.DownloadDependencies([]string{name}, "linux/amd64", &ConfigDownloadDependenciesOpts{
+					AllowMissingChecksum: true,
+				})
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("configured checksum is still required", func(t *testing.T) {
+		cfg, _ := setup(t)
+		err := cfg.DownloadDependencies([]string{"foo"}, "linux/amd64", &ConfigDownloadDependenciesOpts{
+			AllowMissingChecksum: true,
+		})
+		require.NoError(t, err)
+		err = cfg.DownloadDependencies([]string{"foo2"}, "linux/amd64", nil)
+		require.ErrorContains(t, err, "no checksum configured for foo2")
+	})
+}
@@ -0,0 +1,78 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// toolsDirName is the directory in the install directory where dependencies with entrypoints are copied.
+const toolsDirName = ".tools"
+
+// toolSourceFile is written in a tool's directory. It holds the name of the extracted cache entry the tool was copied
+// from so an unchanged tool isn't copied again.
+const toolSourceFile = ".bindown-source"
+
+// installEntrypoints copies extractDir to .tools/<name> in targetPath's directory and links each of dep's entrypoints
+// next to targetPath. It returns the link for the entrypoint with the dependency's bin name, or the first entrypoint's
+// link when none has that name.
+func installEntrypoints(dep *Dependency, extractDir, targetPath string, force bool) (_ string, skipped bool, _ error) {
+	binDir := filepath.Dir(targetPath)
+	toolDir := filepath.Join(binDir, toolsDirName, dep.name)
+	source := filepath.Base(extractDir)
+	links := make([]string, len(dep.Entrypoints))
+	srcs := make([]string, len(dep.Entrypoints))
+	installed := targetPath
+	for i, entrypoint := range dep.Entrypoints {
+		rel := filepath.FromSlash(strings.TrimPrefix(entrypoint, "/"))
+		if !FileExists(filepath.Join(extractDir, rel)) {
+			return "", false, fmt.Errorf("entrypoint %q is not in the download for %s", entrypoint, dep.name)
+		}
+		srcs[i] = filepath.Join(toolDir, rel)
+		links[i] = filepath.Join(binDir, filepath.Base(rel))
+		if filepath.Base(rel) == filepath.Base(targetPath) || i == 0 {
+			installed = links[i]
+		}
+	}
+
+	got, err := os.ReadFile(filepath.Join(toolDir, toolSourceFile))
+	if !force && err == nil && string(got) == source && linksPointTo(links, srcs) {
+		return installed, true, nil
+	}
+	err = os.RemoveAll(toolDir)
+	if err != nil {
+		return "", false, err
+	}
+	err = copyDir(extractDir, toolDir)
+	if err != nil {
+		return "", false, err
+	}
+	for i := range links {
+		err = linkBin(links[i], srcs[i])
+		if err != nil {
+			return "", false, err
+		}
+	}
+	// written last so a partial install isn't mistaken for a complete one
+	err = os.WriteFile(filepath.Join(toolDir, toolSourceFile), []byte(source), 0o644)
+	if err != nil {
+		return "", false, err
+	}
+	return installed, false, nil
+}
+
+// linksPointTo reports whether each of links is a symlink that resolves to the same file as the matching src.
+func linksPointTo(links, srcs []string) bool {
+	for i, link := range links {
+		got, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			return false
+		}
+		want, err := filepath.EvalSymlinks(srcs[i])
+		if err != nil || got != want {
+			return false
+		}
+	}
+	return true
+}
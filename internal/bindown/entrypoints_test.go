@@ -0,0 +1,100 @@
+package bindown
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_InstallDependencies_entrypoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool-1.0.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"tool-1.0/bin/tool":      "#!/bin/sh\n. \"$(dirname \"$0\")/../lib/tool.sh\"\n",
+		"tool-1.0/bin/toolctl":   "#!/bin/sh\necho toolctl\n",
+		"tool-1.0/lib/tool.sh":   "echo tool\n",
+		"tool-1.0/share/README":  "docs\n",
+		"tool-1.0/bin/unrelated": "#!/bin/sh\n",
+	})
+	sum, err := fileChecksum(archive)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, archive, "/tool-1.0.tar.gz", "")
+	depURL := ts.URL + "/tool-1.0.tar.gz"
+	binDir := filepath.Join(dir, "bin")
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+dependencies:
+  tool:
+    url: %q
+    vars:
+      version: "1.0"
+    entrypoints:
+      - tool-{{ .version }}/bin/toolctl
+      - tool-{{ .version }}/bin/tool
+url_checksums:
+  %q: %s
+`, binDir, filepath.Join(dir, "cache"), depURL, depURL, sum))
+
+	var stdout bytes.Buffer
+	err = config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Stdout: &stdout})
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("installed tool to %s\n", filepath.Join(binDir, "tool")), stdout.String())
+
+	toolDir := filepath.Join(binDir, ".tools", "tool")
+	require.FileExists(t, filepath.Join(toolDir, "tool-1.0", "share", "README"))
+	for _, name := range []string{"tool", "toolctl"} {
+		target, err := os.Readlink(filepath.Join(binDir, name))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(".tools", "tool", "tool-1.0", "bin", name), target)
+	}
+	require.NoFileExists(t, filepath.Join(binDir, "unrelated"))
+
+	stdout.Reset()
+	err = config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Stdout: &stdout})
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("skipped tool: %s is up to date\n", filepath.Join(binDir, "tool")), stdout.String())
+
+	// a broken link is repaired
+	require.NoError(t, os.Remove(filepath.Join(binDir, "toolctl")))
+	stdout.Reset()
+	err = config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Stdout: &stdout})
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("installed tool to %s\n", filepath.Join(binDir, "tool")), stdout.String())
+	require.FileExists(t, filepath.Join(binDir, "toolctl"))
+
+	config.Dependencies["tool"].Entrypoints = []string{"tool-1.0/bin/missing"}
+	err = config.InstallDependencies([]string{"tool"}, CurrentSystem, nil)
+	require.EqualError(t, err, `entrypoint "tool-1.0/bin/missing" is not in the download for tool`)
+}
+
+func writeTarGz(t *testing.T, filename string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedKeys(files) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0o755,
+			Size: int64(len(files[name])),
+		}))
+		_, err = tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+}
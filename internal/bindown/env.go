@@ -0,0 +1,50 @@
+package bindown
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DependencyEnv returns the env vars deps export on system. Default is all dependencies. It errors when two
+// dependencies export different values for the same variable.
+func (c *Config) DependencyEnv(deps []string, system System) (map[string]string, error) {
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	env := map[string]string{}
+	exportedBy := map[string]string{}
+	for _, name := range deps {
+		dep, err := c.BuildDependency(name, system)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedKeys(dep.Env) {
+			if !envNameRegexp.MatchString(key) {
+				return nil, &ConfigError{Err: fmt.Errorf("%s: invalid env var name %q", name, key)}
+			}
+			val := dep.Env[key]
+			other, ok := exportedBy[key]
+			if ok && env[key] != val {
+				return nil, &ConfigError{Err: fmt.Errorf("%s and %s export different values for %s", other, name, key)}
+			}
+			env[key] = val
+			exportedBy[key] = name
+		}
+	}
+	return env, nil
+}
+
+// exportsEnv returns true when depName exports env vars on any system.
+func (c *Config) exportsEnv(depName string) (bool, error) {
+	if c.Dependencies == nil || c.Dependencies[depName] == nil {
+		return false, c.unknownDependencyError(depName)
+	}
+	dep := c.Dependencies[depName].clone()
+	err := dep.applyTemplate(c.Templates, 0)
+	if err != nil {
+		return false, err
+	}
+	return len(dep.Env) > 0, nil
+}
@@ -0,0 +1,52 @@
+package bindown
+
+import (
+	"slices"
+)
+
+// setEnvironmentVar sets the "environment" var from c.Environment unless dep already sets it.
+func (c *Config) setEnvironmentVar(dep *Dependency) {
+	if c.Environment == "" {
+		return
+	}
+	if _, ok := dep.Vars["environment"]; ok {
+		return
+	}
+	if dep.Vars == nil {
+		dep.Vars = map[string]string{}
+	}
+	dep.Vars["environment"] = c.Environment
+}
+
+// Environments returns the environments that override matchers in the config refer to along with c.Environment. The
+// empty environment for running without --environment is always first.
+func (c *Config) Environments() []string {
+	envs := []string{""}
+	add := func(env string) {
+		if !slices.Contains(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+	var walk func(o *Overrideable)
+	walk = func(o *Overrideable) {
+		for i := range o.Overrides {
+			for _, env := range o.Overrides[i].OverrideMatcher["environment"] {
+				add(env)
+			}
+			walk(&o.Overrides[i].Dependency)
+		}
+	}
+	for _, name := range sortedKeys(c.Dependencies) {
+		if c.Dependencies[name] != nil {
+			walk(&c.Dependencies[name].Overrideable)
+		}
+	}
+	for _, name := range sortedKeys(c.Templates) {
+		if c.Templates[name] != nil {
+			walk(&c.Templates[name].Overrideable)
+		}
+	}
+	add(c.Environment)
+	slices.Sort(envs[1:])
+	return envs
+}
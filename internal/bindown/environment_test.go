@@ -0,0 +1,105 @@
+package bindown
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_Environment(t *testing.T) {
+	cfgYAML := `
+systems: [linux/amd64]
+dependencies:
+  foo:
+    template: tmpl
+templates:
+  tmpl:
+    url: https://example.com/foo-{{ .os }}
+    overrides:
+      - matcher:
+          environment: [ci]
+        dependency:
+          url: https://mirror.internal/foo-{{ .os }}
+      - matcher:
+          environment: [airgapped]
+        dependency:
+          url: file:///mnt/mirror/foo-{{ .os }}
+url_checksums:
+  https://example.com/foo-linux: deadbeef
+  https://mirror.internal/foo-linux: deadbeef
+  https://old.example.com/foo-linux: deadbeef
+`
+
+	t.Run("build", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, cfgYAML)
+		dep, err := cfg.BuildDependency("foo", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/foo-linux", dep.url)
+		require.NotContains(t, dep.Vars, "environment")
+
+		cfg.Environment = "ci"
+		dep, err = cfg.BuildDependency("foo", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, "https://mirror.internal/foo-linux", dep.url)
+		require.Equal(t, "deadbeef", dep.checksum)
+		require.Equal(t, "ci", dep.Vars["environment"])
+
+		cfg.Environment = "laptop"
+		dep, err = cfg.BuildDependency("foo", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/foo-linux", dep.url)
+	})
+
+	t.Run("environments", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, cfgYAML)
+		require.Equal(t, []string{"", "airgapped", "ci"}, cfg.Environments())
+		cfg.Environment = "laptop"
+		require.Equal(t, []string{"", "airgapped", "ci", "laptop"}, cfg.Environments())
+	})
+
+	t.Run("prune keeps checksums for other environments", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, cfgYAML)
+		pruned, err := cfg.PruneChecksums()
+		require.NoError(t, err)
+		require.Equal(t, []PrunedChecksum{{
+			Key:    "https://old.example.com/foo-linux",
+			Reason: "no dependency uses it",
+		}}, pruned)
+		require.Equal(t, map[string]string{
+			"https://example.com/foo-linux":     "deadbeef",
+			"https://mirror.internal/foo-linux": "deadbeef",
+		}, cfg.URLChecksums)
+		require.Empty(t, cfg.Environment)
+	})
+
+	t.Run("add checksums for every environment", func(t *testing.T) {
+		foo := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+		ts := testutil.ServeFiles(t, map[string]string{"/foo-linux": foo, "/mirror/foo-linux": foo})
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+systems: [linux/amd64]
+dependencies:
+  foo:
+    url: %s/foo-{{ .os }}
+    overrides:
+      - matcher:
+          environment: [ci]
+        dependency:
+          url: %s/mirror/foo-{{ .os }}
+`, ts.URL, ts.URL))
+		err := cfg.AddChecksums(nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			ts.URL + "/foo-linux":        "f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41",
+			ts.URL + "/mirror/foo-linux": "f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41",
+		}, cfg.URLChecksums)
+		require.Empty(t, cfg.Environment)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, cfgYAML)
+		require.NoError(t, cfg.CheckStrict())
+	})
+}
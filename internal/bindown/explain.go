@@ -0,0 +1,136 @@
+package bindown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ExplainDependency builds a dependency the same way as BuildDependency and also returns a description of each
+// template, override, substitution and interpolation that was applied, in the order they were applied.
+func (c *Config) ExplainDependency(depName string, system System) ([]string, *Dependency, error) {
+	dep := c.Dependencies[depName]
+	if dep == nil {
+		return nil, nil, c.unknownDependencyError(depName)
+	}
+	var steps []string
+
+	chain := c.templateChain(dep)
+	for i := len(chain) - 1; i >= 0; i-- {
+		tmpl := c.Templates[chain[i]]
+		if tmpl == nil {
+			break
+		}
+		steps = append(steps, explainStep(fmt.Sprintf("template %q", chain[i]), &tmpl.Overrideable))
+	}
+	steps = append(steps, explainStep(fmt.Sprintf("dependency %q", depName), &dep.Overrideable))
+
+	merged := dep.clone()
+	err := merged.applyTemplate(c.Templates, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.setEnvironmentVar(merged)
+	overrides := merged.Overrides
+	for i := range overrides {
+		override := &overrides[i]
+		desc := fmt.Sprintf("override %d matching %s", i, explainMatcher(override.OverrideMatcher))
+		if !override.matches(system, merged.Vars) {
+			steps = append(steps, desc+": no match")
+			continue
+		}
+		// apply one override at a time so later matchers see the vars set by earlier overrides
+		merged.Overrides = overrides[i : i+1]
+		err = merged.applyOverrides(system, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		steps = append(steps, explainStep(desc, &override.Dependency))
+	}
+
+	vars := merged.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	subNames := MapKeys(merged.Substitutions)
+	slices.Sort(subNames)
+	for _, k := range subNames {
+		val, ok := vars[k]
+		if k == "os" && !ok {
+			val, ok = system.OS(), true
+		}
+		if k == "arch" && !ok {
+			val, ok = system.Arch(), true
+		}
+		sub := merged.Substitutions[k][val]
+		if ok && sub != "" {
+			steps = append(steps, fmt.Sprintf("substitution %s: %q -> %q", k, val, sub))
+		}
+	}
+
+	built, err := c.BuildDependency(depName, system)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, field := range []struct {
+		name        string
+		tmpl, value *string
+	}{
+		{"url", merged.URL, built.URL},
+		{"archive_path", merged.ArchivePath, built.ArchivePath},
+		{"bin", merged.BinName, built.BinName},
+	} {
+		if field.tmpl != nil && field.value != nil && *field.tmpl != *field.value {
+			steps = append(steps, fmt.Sprintf("interpolated %s: %q -> %q", field.name, *field.tmpl, *field.value))
+		}
+	}
+	return steps, built, nil
+}
+
+// explainStep describes the values that o sets
+func explainStep(desc string, o *Overrideable) string {
+	lines := []string{desc}
+	if o.URL != nil {
+		lines = append(lines, fmt.Sprintf("  url: %q", *o.URL))
+	}
+	if o.ArchivePath != nil {
+		lines = append(lines, fmt.Sprintf("  archive_path: %q", *o.ArchivePath))
+	}
+	if o.BinName != nil {
+		lines = append(lines, fmt.Sprintf("  bin: %q", *o.BinName))
+	}
+	if o.Link != nil {
+		lines = append(lines, fmt.Sprintf("  link: %t", *o.Link))
+	}
+	if o.Entrypoints != nil {
+		lines = append(lines, fmt.Sprintf("  entrypoints: %q", o.Entrypoints))
+	}
+	varNames := MapKeys(o.Vars)
+	slices.Sort(varNames)
+	for _, k := range varNames {
+		lines = append(lines, fmt.Sprintf("  vars.%s: %q", k, o.Vars[k]))
+	}
+	subNames := MapKeys(o.Substitutions)
+	slices.Sort(subNames)
+	for _, k := range subNames {
+		vals := MapKeys(o.Substitutions[k])
+		slices.Sort(vals)
+		for _, v := range vals {
+			lines = append(lines, fmt.Sprintf("  substitutions.%s: %q -> %q", k, v, o.Substitutions[k][v]))
+		}
+	}
+	if len(o.Overrides) > 0 {
+		lines = append(lines, fmt.Sprintf("  overrides: %d", len(o.Overrides)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func explainMatcher(matcher map[string][]string) string {
+	keys := MapKeys(matcher)
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, strings.Join(matcher[k], ","))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
@@ -1,36 +1,201 @@
 package bindown
 
 import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mholt/archiver/v3"
 	"github.com/willabides/bindown/v4/internal/cache"
 )
 
+// downloadAndExtract downloads dep and extracts it to the extracts cache. When the extracts cache already has the
+// dependency's download extracted the same way, it is used without downloading. When stream is true and dep is a
+// tar-based archive with a known checksum, the archive is extracted while it downloads instead of being cached first.
+// Streaming is not used with a download command, scan command or attestation.
+func downloadAndExtract(
+	dep *Dependency,
+	cacheDir string,
+	force, allowMissingChecksum, stream bool,
+) (extractDir string, unlock func() error, _ error) {
+	extractsCache := &cache.Cache{Root: filepath.Join(cacheDir, "extracts")}
+	if len(dep.ExtractCommand) > 0 {
+		if !dep.allowExtractCommand {
+			return "", nil, &ConfigError{Err: fmt.Errorf(
+				"%s has an extract_command, which only runs when extract commands are allowed with --allow-extract-command", dep.name,
+			)}
+		}
+		stream = false
+	}
+	err := dep.extractFilter().validate()
+	if err != nil {
+		return "", nil, err
+	}
+	if dep.checksum != "" && !force {
+		extractDir, unlock, err := cachedExtract(dep, cacheDir, extractsCache)
+		if err != nil {
+			return "", nil, err
+		}
+		if extractDir != "" {
+			return extractDir, unlock, nil
+		}
+	}
+	if stream && dep.checksum != "" && len(dep.downloader.commandArgs()) == 0 && len(dep.downloader.scanCommand) == 0 && dep.Attestation == nil {
+		dlName, err := urlFilename(dep.url)
+		if err != nil {
+			return "", nil, err
+		}
+		if isStreamableArchive(dlName) {
+			return streamDependencyToCache(dep, cacheDir, extractsCache, force)
+		}
+	}
+	dlCache := &cache.Cache{Root: filepath.Join(cacheDir, "downloads")}
+	dlFile, _, dlUnlock, err := downloadDependency(dep, dlCache, allowMissingChecksum, force)
+	if err != nil {
+		return "", nil, err
+	}
+	extractDir, exUnlock, err := extractDependencyToCache(dep, dlFile, cacheDir, extractsCache, force)
+	if err != nil {
+		return "", nil, errors.Join(dlUnlock(), err)
+	}
+	return extractDir, func() error {
+		return errors.Join(exUnlock(), dlUnlock())
+	}, nil
+}
+
+// cachedExtract returns the dependency's extracted download from the extracts cache when its marker shows it was
+// extracted from the download with the dependency's checksum in the way the dependency extracts it. It returns an
+// empty extractDir when there is no such entry.
+func cachedExtract(dep *Dependency, cacheDir string, exCache *cache.Cache) (extractDir string, unlock func() error, _ error) {
+	start := time.Now()
+	dlName, err := urlFilename(dep.url)
+	if err != nil {
+		return "", nil, err
+	}
+	want := dep.extractMarker(dlName)
+	markerFile, err := extractMarkerPath(cacheDir, dep.extractKey(dlName))
+	if err != nil {
+		return "", nil, err
+	}
+	extractDir, unlock, err = exCache.Dir(dep.extractKey(dlName), want.validator(markerFile), nil)
+	if err != nil {
+		// not in the cache or not extracted from this download
+		return "", nil, nil
+	}
+	// the download isn't needed, but its size is still useful in metrics when it is cached
+	var size int64
+	if info, statErr := os.Stat(filepath.Join(cacheDir, "downloads", cacheKey(dep.checksum), dlName)); statErr == nil {
+		size = info.Size()
+	}
+	dep.metrics.download(true, start, size)
+	dep.metrics.extract(true, start)
+	return extractDir, unlock, nil
+}
+
+// extractDependencyToCache extracts the dependency's download at archivePath to the extracts cache and records an
+// extract marker for it.
 func extractDependencyToCache(
-	archivePath, cacheDir, key string,
+	dep *Dependency,
+	archivePath, cacheDir string,
 	exCache *cache.Cache,
 	force bool,
 ) (extractDir string, unlock func() error, _ error) {
-	extractSumsDir := filepath.Join(cacheDir, ".extract_sums")
-	err := os.MkdirAll(extractSumsDir, 0o755)
+	start := time.Now()
+	dlName := filepath.Base(archivePath)
+	key := dep.extractKey(dlName)
+	marker := dep.extractMarker(dlName)
+	markerFile, err := extractMarkerPath(cacheDir, key)
 	if err != nil {
 		return "", nil, err
 	}
-	extractSumFile := filepath.Join(extractSumsDir, key+".sum")
+	extractFn := extract
+	switch {
+	case len(dep.ExtractCommand) > 0:
+		extractFn = dep.runExtractCommand
+	case dep.extractsAppImage() && isAppImage(dlName):
+		extractFn = extractAppImage
+	case dep.extractFilter() != nil:
+		extractFn = func(archivePath, extractDir string) error {
+			return extractFiltered(archivePath, extractDir, dep.extractFilter())
+		}
+	}
 
+	extracted := false
 	extractor := func(dir string) error {
-		exErr := extract(archivePath, dir)
+		extracted = true
+		exErr := extractFn(archivePath, dir)
 		if exErr != nil {
 			return exErr
 		}
-		gotSum, exErr := directoryChecksum(dir)
+		return marker.write(markerFile, dir)
+	}
+
+	if force {
+		err = exCache.Evict(key)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	extractDir, unlock, err = exCache.Dir(key, marker.validator(markerFile), extractor)
+	if err != nil {
+		return "", nil, err
+	}
+	dep.metrics.extract(!extracted, start)
+	return extractDir, unlock, nil
+}
+
+// streamDependencyToCache downloads a tar-based archive and extracts it to the cache in a single pass without writing
+// the archive to disk. The checksum is verified once the whole download has been read, and the extracted files are
+// discarded on a mismatch.
+func streamDependencyToCache(
+	dep *Dependency,
+	cacheDir string,
+	exCache *cache.Cache,
+	force bool,
+) (extractDir string, unlock func() error, _ error) {
+	dep.mustBeBuilt()
+	start := time.Now()
+	if dep.checksum == "" {
+		return "", nil, &ConfigError{Err: fmt.Errorf("no checksum configured for %s %s", dep.name, dep.url)}
+	}
+	dlName, err := urlFilename(dep.url)
+	if err != nil {
+		return "", nil, err
+	}
+	key := dep.extractKey(dlName)
+	marker := dep.extractMarker(dlName)
+	markerFile, err := extractMarkerPath(cacheDir, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var size int64
+	extracted := false
+	extractor := func(dir string) (exErrOut error) {
+		extracted = true
+		defer func() {
+			if exErrOut != nil {
+				exErrOut = errors.Join(exErrOut, os.RemoveAll(dir))
+			}
+		}()
+		gotSum, n, exErr := streamExtract(dep.downloader, dep.url, dlName, dir, dep.extractFilter())
+		size = n
 		if exErr != nil {
 			return exErr
 		}
-		return os.WriteFile(extractSumFile, []byte(gotSum), 0o644)
+		if gotSum != dep.checksum {
+			return &ChecksumMismatchError{File: dlName, Want: dep.checksum, Got: gotSum}
+		}
+		return marker.write(markerFile, dir)
 	}
 
 	if force {
@@ -39,7 +204,256 @@ func extractDependencyToCache(
 			return "", nil, err
 		}
 	}
-	return exCache.Dir(key, nil, extractor)
+	extractDir, unlock, err = exCache.Dir(key, marker.validator(markerFile), extractor)
+	if err != nil {
+		return "", nil, err
+	}
+	dep.metrics.stream(!extracted, start, size)
+	return extractDir, unlock, nil
+}
+
+// extractKey returns the extracts cache key for the download named dlName with the downloads cache key dlKey.
+// Downloads with the same checksum share an extraction as long as they are extracted the same way, so dependencies
+// that install different bins from one archive only extract it once.
+func extractKey(dlKey, dlName string) string {
+	return cacheKey(dlKey + "\n" + extractOptions(dlName))
+}
+
+// extractOptions describes how the dependency extracts the download named dlName.
+func (d *Dependency) extractOptions(dlName string) string {
+	switch {
+	case len(d.ExtractCommand) > 0:
+		// a different command can extract different files
+		return "command " + strings.Join(d.ExtractCommand, "\n")
+	case d.extractsAppImage() && isAppImage(dlName):
+		return "appimage"
+	case d.extractFilter() != nil && strings.HasPrefix(extractOptions(dlName), "unarchive "):
+		// a different filter extracts different files
+		return extractOptions(dlName) + "\n" + d.extractFilter().options()
+	default:
+		return extractOptions(dlName)
+	}
+}
+
+// extractKey returns the key in the extracts cache for the dependency's download named dlName.
+func (d *Dependency) extractKey(dlName string) string {
+	dlKey := cacheKey(d.checksum)
+	options := d.extractOptions(dlName)
+	if options == extractOptions(dlName) {
+		return extractKey(dlKey, dlName)
+	}
+	return cacheKey(dlKey + "\n" + options)
+}
+
+// extractOptions describes how extract handles the download named dlName. Archives extract the same regardless of
+// their name, but decompressed and copied files are named after the download.
+func extractOptions(dlName string) string {
+	if isMSI(dlName) {
+		return "msi"
+	}
+	byExt, err := archiver.ByExtension(dlName)
+	if err != nil {
+		return "copy " + dlName
+	}
+	switch byExt.(type) {
+	case archiver.Unarchiver:
+		return fmt.Sprintf("unarchive %T", byExt)
+	case archiver.Decompressor:
+		return "decompress " + dlName
+	default:
+		return "copy " + dlName
+	}
+}
+
+// isStreamableArchive returns true if filename is a tar-based archive that can be extracted while it is downloading.
+func isStreamableArchive(filename string) bool {
+	byExt, err := archiver.ByExtension(filename)
+	if err != nil {
+		return false
+	}
+	switch byExt.(type) {
+	case *archiver.Tar, *archiver.TarGz, *archiver.TarBz2, *archiver.TarXz,
+		*archiver.TarZstd, *archiver.TarLz4, *archiver.TarSz, *archiver.TarBrotli:
+		return true
+	default:
+		return false
+	}
+}
+
+// streamExtract downloads dlURL and extracts it to extractDir as it is read. Only the files filter matches are
+// extracted when it isn't nil. It returns the checksum and size of the download.
+func streamExtract(dl *downloader, dlURL, dlName, extractDir string, filter *extractFilter) (_ string, size int64, errOut error) {
+	resp, err := dl.get(dlURL)
+	if err != nil {
+		return "", 0, err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	err = checkContentType(dlName, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", 0, err
+	}
+	hasher := &sizeHasher{Hash: sha256.New()}
+	bodyReader := bufio.NewReaderSize(io.TeeReader(resp.Body, hasher), magicHeaderSize)
+	header, err := bodyReader.Peek(magicHeaderSize)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	err = checkMagic(dlName, header)
+	if err != nil {
+		return "", 0, err
+	}
+	err = untarStream(dlName, bodyReader, extractDir, filter)
+	if err != nil {
+		return "", 0, err
+	}
+	// read whatever is left after the end of the archive so the checksum covers the whole file
+	_, err = io.Copy(io.Discard, bodyReader)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), hasher.size, nil
+}
+
+// sizeHasher is a hash.Hash that counts how many bytes were written to it.
+type sizeHasher struct {
+	hash.Hash
+	size int64
+}
+
+func (h *sizeHasher) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	return h.Hash.Write(p)
+}
+
+// untarStream extracts the tar-based archive dlName from r into extractDir. Only the files filter matches are
+// extracted when it isn't nil.
+func untarStream(dlName string, r io.Reader, extractDir string, filter *extractFilter) (errOut error) {
+	byExt, err := archiver.ByExtension(dlName)
+	if err != nil {
+		return err
+	}
+	rdr, ok := byExt.(archiver.Reader)
+	if !ok || !isStreamableArchive(dlName) {
+		return fmt.Errorf("%s is not a tar archive", dlName)
+	}
+	err = os.MkdirAll(extractDir, 0o750)
+	if err != nil {
+		return err
+	}
+	err = rdr.Open(r, 0)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, rdr.Close)
+	for {
+		f, readErr := rdr.Read()
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if filter == nil || filter.match(f.Header.(*tar.Header).Name) {
+			err = writeTarEntry(extractDir, f)
+		}
+		err = errors.Join(err, f.Close())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeTarEntry(extractDir string, f archiver.File) error {
+	hdr, ok := f.Header.(*tar.Header)
+	if !ok {
+		return fmt.Errorf("expected header to be *tar.Header but was %T", f.Header)
+	}
+	to, err := archiveEntryPath(extractDir, hdr.Name)
+	if err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(to, f.Mode().Perm()|0o700)
+	case tar.TypeReg, tar.TypeChar, tar.TypeBlock, tar.TypeFifo, tar.TypeGNUSparse:
+		err = os.MkdirAll(filepath.Dir(to), 0o755)
+		if err != nil {
+			return err
+		}
+		return writeFileFromReader(to, f, f.Mode().Perm())
+	case tar.TypeSymlink:
+		return writeSymlink(extractDir, hdr.Name, hdr.Linkname, to)
+	case tar.TypeLink:
+		target, err := archiveEntryPath(extractDir, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(filepath.Dir(to), 0o755)
+		if err != nil {
+			return err
+		}
+		return os.Link(target, to)
+	case tar.TypeXGlobalHeader:
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown type flag: %c", hdr.Name, hdr.Typeflag)
+	}
+}
+
+// archiveEntryPath returns where the archive entry name is written in extractDir. It errors when name is outside
+// extractDir or when anything on the way is a symlink, so a link from an earlier entry can't redirect a later one.
+func archiveEntryPath(extractDir, name string) (string, error) {
+	to := filepath.Join(extractDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(extractDir, to)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal file path in archive: %s", name)
+	}
+	p := extractDir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("illegal file path in archive: %s is through a symlink", name)
+		}
+	}
+	return to, nil
+}
+
+// writeSymlink creates the symlink to for the archive entry name pointing at target. It errors when target is
+// absolute or resolves outside extractDir.
+func writeSymlink(extractDir, name, target, to string) error {
+	nativeTarget := filepath.FromSlash(target)
+	if filepath.IsAbs(nativeTarget) || filepath.VolumeName(nativeTarget) != "" || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("illegal link target in archive: %s -> %s", name, target)
+	}
+	rel, err := filepath.Rel(extractDir, filepath.Join(filepath.Dir(to), nativeTarget))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("illegal link target in archive: %s -> %s", name, target)
+	}
+	err = os.MkdirAll(filepath.Dir(to), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, to)
+}
+
+func writeFileFromReader(filename string, r io.Reader, mode os.FileMode) (errOut error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, file.Close)
+	_, err = io.Copy(file, r)
+	return err
 }
 
 // extract extracts an archive
@@ -56,6 +470,9 @@ func extract(archivePath, extractDir string) error {
 		return err
 	}
 	tarPath := filepath.Join(downloadDir, dlName)
+	if isMSI(dlName) {
+		return extractMSI(tarPath, extractDir)
+	}
 	byExt, err := archiver.ByExtension(dlName)
 	if err != nil {
 		return copyFile(tarPath, filepath.Join(extractDir, dlName))
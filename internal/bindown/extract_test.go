@@ -0,0 +1,130 @@
+package bindown
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_extractKey(t *testing.T) {
+	dlKey := cacheKey("f7fa712caea646575c920af17de3462fe9d08d7fe062b9a17010117d5fa4ed88")
+	// archives extract the same way whatever they are named
+	require.Equal(t, extractKey(dlKey, "foo.tar.gz"), extractKey(dlKey, "foo_linux_amd64.tar.gz"))
+	require.Equal(t, extractKey(dlKey, "foo.tar.gz"), extractKey(dlKey, "foo.tgz"))
+	require.NotEqual(t, extractKey(dlKey, "foo.tar.gz"), extractKey(dlKey, "foo.zip"))
+	// decompressed and copied files are named after the download
+	require.NotEqual(t, extractKey(dlKey, "foo.gz"), extractKey(dlKey, "bar.gz"))
+	require.NotEqual(t, extractKey(dlKey, "foo"), extractKey(dlKey, "bar"))
+	require.NotEqual(t, extractKey(dlKey, "foo.tar.gz"), extractKey(cacheKey("other"), "foo.tar.gz"))
+}
+
+func Test_extractMarker(t *testing.T) {
+	dir := t.TempDir()
+	servePath := filepath.Join("testdata", "downloadables", "fooinroot.tar.gz")
+	ts := testutil.ServeFile(t, servePath, "/foo/fooinroot.tar.gz", "")
+	depURL := ts.URL + "/foo/fooinroot.tar.gz"
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  foo:
+    url: %q
+url_checksums:
+  %q: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, filepath.Join(dir, "cache"), depURL, depURL))
+	install := func() {
+		t.Helper()
+		err := config.InstallDependencies([]string{"foo"}, CurrentSystem, &ConfigInstallDependenciesOpts{
+			Output: filepath.Join(dir, "bin", "foo"),
+		})
+		require.NoError(t, err)
+	}
+	install()
+	markers, err := filepath.Glob(filepath.Join(dir, "cache", ".extract_sums", "*.json"))
+	require.NoError(t, err)
+	require.Len(t, markers, 1)
+	marker, err := readExtractMarker(markers[0])
+	require.NoError(t, err)
+	require.Equal(t, "27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3", marker.Download)
+	require.Equal(t, "unarchive *archiver.TarGz", marker.Options)
+	require.NotEmpty(t, marker.Checksum)
+
+	// an extraction without a marker, like one that was interrupted, is extracted again
+	extractDir := filepath.Join(dir, "cache", "extracts", strings.TrimSuffix(filepath.Base(markers[0]), ".json"))
+	require.NoError(t, os.Chmod(extractDir, 0o755))
+	require.NoError(t, os.Remove(filepath.Join(extractDir, "foo")))
+	require.NoError(t, os.Remove(markers[0]))
+	require.NoError(t, os.Remove(filepath.Join(dir, "bin", "foo")))
+	install()
+	require.FileExists(t, filepath.Join(extractDir, "foo"))
+	require.FileExists(t, markers[0])
+}
+
+func Test_untarStream_links(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	untar := func(t *testing.T, headers ...*tar.Header) (string, error) {
+		t.Helper()
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range headers {
+			if hdr.Typeflag == tar.TypeReg {
+				hdr.Size = int64(len(hdr.Name))
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			if hdr.Typeflag == tar.TypeReg {
+				_, err := tw.Write([]byte(hdr.Name))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, tw.Close())
+		dir := t.TempDir()
+		extractDir := filepath.Join(dir, "extract")
+		return dir, untarStream("foo.tar", &buf, extractDir, nil)
+	}
+
+	t.Run("link inside", func(t *testing.T) {
+		dir, err := untar(t,
+			&tar.Header{Name: "bin/foo", Typeflag: tar.TypeReg, Mode: 0o755},
+			&tar.Header{Name: "foo", Typeflag: tar.TypeSymlink, Linkname: "bin/foo"},
+			&tar.Header{Name: "bar", Typeflag: tar.TypeLink, Linkname: "bin/foo"},
+		)
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(dir, "extract", "bar"))
+		require.NoError(t, err)
+		require.Equal(t, "bin/foo", string(got))
+	})
+
+	t.Run("absolute symlink", func(t *testing.T) {
+		_, err := untar(t, &tar.Header{Name: "foo", Typeflag: tar.TypeSymlink, Linkname: "/etc"})
+		require.EqualError(t, err, "illegal link target in archive: foo -> /etc")
+	})
+
+	t.Run("escaping symlink", func(t *testing.T) {
+		_, err := untar(t, &tar.Header{Name: "bin/foo", Typeflag: tar.TypeSymlink, Linkname: "../../outside"})
+		require.EqualError(t, err, "illegal link target in archive: bin/foo -> ../../outside")
+	})
+
+	t.Run("through symlink", func(t *testing.T) {
+		dir, err := untar(t,
+			&tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0o755},
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+			&tar.Header{Name: "link/x", Typeflag: tar.TypeReg, Mode: 0o644},
+		)
+		require.EqualError(t, err, "illegal file path in archive: link/x is through a symlink")
+		require.NoFileExists(t, filepath.Join(dir, "extract", "sub", "x"))
+	})
+
+	t.Run("escaping hardlink", func(t *testing.T) {
+		_, err := untar(t, &tar.Header{Name: "foo", Typeflag: tar.TypeLink, Linkname: "../outside"})
+		require.EqualError(t, err, "illegal file path in archive: ../outside")
+	})
+}
@@ -0,0 +1,49 @@
+package bindown
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runExtractCommand extracts the download at downloadPath to extractDir with the dependency's extract_command.
+func (d *Dependency) runExtractCommand(downloadPath, extractDir string) error {
+	downloadPath, err := filepath.Abs(downloadPath)
+	if err != nil {
+		return err
+	}
+	extractDir, err = filepath.Abs(extractDir)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(extractDir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(extractDir, 0o750)
+	if err != nil {
+		return err
+	}
+	vars := maps.Clone(d.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["download"] = downloadPath
+	vars["dir"] = extractDir
+	args, err := executeCommandTemplates(d.ExtractCommand, vars)
+	if err != nil {
+		return fmt.Errorf("extract_command for %s: %w", d.name, err)
+	}
+	ctx, cancel := d.downloader.context()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = extractDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("extract command %s failed for %s: %w\n%s", args[0], filepath.Base(downloadPath), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
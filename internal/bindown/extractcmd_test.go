@@ -0,0 +1,54 @@
+package bindown
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_InstallDependencies_extractCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh")
+	}
+	dir := t.TempDir()
+	download := filepath.Join(dir, "foo.pkg")
+	require.NoError(t, os.WriteFile(download, []byte("#!/bin/sh\necho foo\n"), 0o644))
+	sum, err := fileChecksum(download)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, download, "/foo.pkg", "")
+	depURL := ts.URL + "/foo.pkg"
+	binDir := filepath.Join(dir, "bin")
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+dependencies:
+  foo:
+    url: %q
+    archive_path: out/foo
+    extract_command: ["sh", "-c", "mkdir out && cp \"$0\" out/{{ .name }} && chmod +x out/{{ .name }}", "{{ .download }}"]
+    vars:
+      name: foo
+url_checksums:
+  %q: %s
+`, binDir, filepath.Join(dir, "cache"), depURL, depURL, sum))
+
+	err = config.InstallDependencies([]string{"foo"}, CurrentSystem, nil)
+	require.EqualError(t, err, "foo has an extract_command, which only runs when extract commands are allowed with --allow-extract-command")
+	require.NoFileExists(t, filepath.Join(binDir, "foo"))
+
+	config.AllowExtractCommand = true
+	err = config.InstallDependencies([]string{"foo"}, CurrentSystem, nil)
+	require.NoError(t, err)
+	got, err := os.ReadFile(filepath.Join(binDir, "foo"))
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho foo\n", string(got))
+
+	config.Dependencies["foo"].ExtractCommand = []string{"sh", "-c", "echo broken package >&2; exit 1"}
+	err = config.InstallDependencies([]string{"foo"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+	require.EqualError(t, err, "extract command sh failed for foo.pkg: exit status 1\nbroken package")
+}
@@ -0,0 +1,177 @@
+package bindown
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zip"
+	"github.com/mholt/archiver/v3"
+)
+
+// extractFilter selects the files extracted from an archive with a dependency's extract_include and extract_exclude.
+type extractFilter struct {
+	include []string
+	exclude []string
+	// archivePath is always extracted so the bin is there to install
+	archivePath string
+}
+
+// extractFilter returns the dependency's extract filter or nil when it has none.
+func (d *Dependency) extractFilter() *extractFilter {
+	d.mustBeBuilt()
+	if len(d.ExtractInclude) == 0 && len(d.ExtractExclude) == 0 {
+		return nil
+	}
+	return &extractFilter{
+		include:     d.ExtractInclude,
+		exclude:     d.ExtractExclude,
+		archivePath: strings.Trim(d.archivePath(), "/"),
+	}
+}
+
+// validate checks that the filter's patterns are valid globs.
+func (f *extractFilter) validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, pattern := range append(f.include, f.exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return &ConfigError{Err: fmt.Errorf("invalid extract filter pattern %q: %w", pattern, err)}
+		}
+	}
+	return nil
+}
+
+// options describes the filter for the extract marker.
+func (f *extractFilter) options() string {
+	return fmt.Sprintf("include %q exclude %q", f.include, f.exclude)
+}
+
+// match returns whether the file at name in the archive is extracted.
+func (f *extractFilter) match(name string) bool {
+	name = strings.Trim(path.Clean(filepath.ToSlash(name)), "/")
+	name = strings.TrimPrefix(name, "./")
+	if strings.EqualFold(name, f.archivePath) {
+		return true
+	}
+	if len(f.include) > 0 && !globsMatch(f.include, name) {
+		return false
+	}
+	return !globsMatch(f.exclude, name)
+}
+
+// globsMatch returns whether any of patterns matches name or one of its parent directories.
+func globsMatch(patterns []string, name string) bool {
+	for dir := name; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(strings.Trim(pattern, "/"), dir); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// extractFiltered extracts the files filter matches from the archive at archivePath to extractDir. Downloads that
+// aren't archives are extracted as usual.
+func extractFiltered(archivePath, extractDir string, filter *extractFilter) error {
+	byExt, err := archiver.ByExtension(filepath.Base(archivePath))
+	walker, isWalker := byExt.(archiver.Walker)
+	if _, isUnarchiver := byExt.(archiver.Unarchiver); err != nil || !isUnarchiver || !isWalker || isMSI(archivePath) {
+		return extract(archivePath, extractDir)
+	}
+	err = os.RemoveAll(extractDir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(extractDir, 0o750)
+	if err != nil {
+		return err
+	}
+	pruneAfter := false
+	err = walker.Walk(archivePath, func(f archiver.File) error {
+		switch hdr := f.Header.(type) {
+		case *tar.Header:
+			if !filter.match(hdr.Name) {
+				return nil
+			}
+			return writeTarEntry(extractDir, f)
+		case zip.FileHeader:
+			if !filter.match(hdr.Name) {
+				return nil
+			}
+			return writeZipEntry(extractDir, hdr.Name, f)
+		default:
+			// no writer for this kind of entry, so extract everything and remove what the filter doesn't match
+			pruneAfter = true
+			return archiver.ErrStopWalk
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if !pruneAfter {
+		return nil
+	}
+	err = extract(archivePath, extractDir)
+	if err != nil {
+		return err
+	}
+	return filter.prune(extractDir)
+}
+
+// writeZipEntry writes the zip entry f named name to extractDir.
+func writeZipEntry(extractDir, name string, f archiver.File) error {
+	to, err := archiveEntryPath(extractDir, name)
+	if err != nil {
+		return err
+	}
+	if f.IsDir() {
+		return os.MkdirAll(to, f.Mode().Perm()|0o700)
+	}
+	if f.Mode()&os.ModeSymlink != 0 {
+		link, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		return writeSymlink(extractDir, name, string(link), to)
+	}
+	err = os.MkdirAll(filepath.Dir(to), 0o755)
+	if err != nil {
+		return err
+	}
+	return writeFileFromReader(to, f, f.Mode().Perm())
+}
+
+// prune removes the files in extractDir that the filter doesn't match.
+func (f *extractFilter) prune(extractDir string) error {
+	var remove []string
+	err := filepath.WalkDir(extractDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(extractDir, p)
+		if err != nil {
+			return err
+		}
+		if !f.match(rel) {
+			remove = append(remove, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range remove {
+		errs = append(errs, os.Remove(p))
+	}
+	return errors.Join(errs...)
+}
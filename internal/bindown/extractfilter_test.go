@@ -0,0 +1,176 @@
+package bindown
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+var extractFilterFiles = []string{"sdk/bin/tool", "sdk/docs/index.html", "sdk/examples/hello/main.go", "sdk/lib/libsdk.so"}
+
+func writeExtractFilterArchives(t *testing.T, dir string) {
+	t.Helper()
+	tgz, err := os.Create(filepath.Join(dir, "sdk.tar.gz"))
+	require.NoError(t, err)
+	gz := gzip.NewWriter(tgz)
+	tw := tar.NewWriter(gz)
+	zf, err := os.Create(filepath.Join(dir, "sdk.zip"))
+	require.NoError(t, err)
+	zw := zip.NewWriter(zf)
+	for _, name := range extractFilterFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(name))}))
+		_, err = tw.Write([]byte(name))
+		require.NoError(t, err)
+		zfw, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = zfw.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, tgz.Close())
+	require.NoError(t, zw.Close())
+	require.NoError(t, zf.Close())
+}
+
+func TestExtractFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeExtractFilterArchives(t, dir)
+	ts := testutil.ServeFiles(t, map[string]string{
+		"/sdk.tar.gz": filepath.Join(dir, "sdk.tar.gz"),
+		"/sdk.zip":    filepath.Join(dir, "sdk.zip"),
+	})
+
+	extracted := func(t *testing.T, archive, filters string, stream bool) []string {
+		t.Helper()
+		dir := t.TempDir()
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  sdk:
+    url: %s/%s
+    archive_path: sdk/bin/tool
+%s
+`, filepath.Join(dir, "cache"), ts.URL, archive, filters))
+		if stream {
+			// streaming needs a checksum
+			dep, err := cfg.BuildDependency("sdk", CurrentSystem)
+			require.NoError(t, err)
+			sum, err := getURLChecksum(dep.url, "", dep.downloader)
+			require.NoError(t, err)
+			cfg.URLChecksums = map[string]string{dep.url: sum}
+		}
+		out := filepath.Join(dir, "out")
+		err := cfg.ExtractDependencies([]string{"sdk"}, CurrentSystem, &ConfigExtractDependenciesOpts{
+			AllowMissingChecksum: true,
+			Stream:               stream,
+			Output:               out,
+		})
+		require.NoError(t, err)
+		var files []string
+		err = filepath.WalkDir(out, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(out, path)
+			files = append(files, filepath.ToSlash(rel))
+			return err
+		})
+		require.NoError(t, err)
+		sort.Strings(files)
+		return files
+	}
+
+	for _, archive := range []string{"sdk.tar.gz", "sdk.zip"} {
+		t.Run(archive, func(t *testing.T) {
+			t.Run("no filter", func(t *testing.T) {
+				got := extracted(t, archive, "", false)
+				require.Equal(t, extractFilterFiles, got)
+			})
+
+			t.Run("include", func(t *testing.T) {
+				got := extracted(t, archive, `    extract_include: ["*/lib"]`, false)
+				require.Equal(t, []string{"sdk/bin/tool", "sdk/lib/libsdk.so"}, got)
+			})
+
+			t.Run("exclude", func(t *testing.T) {
+				got := extracted(t, archive, `    extract_exclude: ["sdk/docs", "*/examples/*"]`, false)
+				require.Equal(t, []string{"sdk/bin/tool", "sdk/lib/libsdk.so"}, got)
+			})
+
+			t.Run("include and exclude", func(t *testing.T) {
+				got := extracted(t, archive, `    extract_include: ["sdk"]
+    extract_exclude: ["sdk/*s"]`, false)
+				require.Equal(t, []string{"sdk/bin/tool", "sdk/lib/libsdk.so"}, got)
+			})
+		})
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		got := extracted(t, "sdk.tar.gz", `    extract_include: ["sdk/docs"]`, true)
+		require.Equal(t, []string{"sdk/bin/tool", "sdk/docs/index.html"}, got)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  sdk:
+    url: %s/sdk.tar.gz
+    extract_include: ["[a"]
+`, filepath.Join(t.TempDir(), "cache"), ts.URL))
+		err := cfg.ExtractDependencies([]string{"sdk"}, CurrentSystem, &ConfigExtractDependenciesOpts{AllowMissingChecksum: true})
+		require.ErrorContains(t, err, `invalid extract filter pattern "[a"`)
+	})
+}
+
+func TestExtractFilter_zipLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	extractZip := func(t *testing.T, link string) (string, error) {
+		t.Helper()
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "links.zip")
+		f, err := os.Create(archive)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		hdr := &zip.FileHeader{Name: "a"}
+		hdr.SetMode(os.ModeSymlink | 0o777)
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(link))
+		require.NoError(t, err)
+		w, err = zw.Create("a/x")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("x"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+		return dir, extractFiltered(archive, filepath.Join(dir, "extract"), &extractFilter{})
+	}
+
+	t.Run("absolute link", func(t *testing.T) {
+		target := t.TempDir()
+		_, err := extractZip(t, target)
+		require.ErrorContains(t, err, fmt.Sprintf("illegal link target in archive: a -> %s", target))
+		require.NoFileExists(t, filepath.Join(target, "x"))
+	})
+
+	t.Run("escaping link", func(t *testing.T) {
+		dir, err := extractZip(t, "../target")
+		require.ErrorContains(t, err, "illegal link target in archive: a -> ../target")
+		require.NoFileExists(t, filepath.Join(dir, "target", "x"))
+		require.NoFileExists(t, filepath.Join(dir, "extract", "a", "x"))
+	})
+}
@@ -0,0 +1,88 @@
+package bindown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// extractMarker records what an entry in the extracts cache was extracted from. An entry is only used while its
+// marker matches the download and extraction options it is wanted for, so a partial extraction or one from a
+// different download is extracted again instead of being installed.
+type extractMarker struct {
+	// Download is the checksum of the download that was extracted.
+	Download string `json:"download"`
+	// Options describes how it was extracted.
+	Options string `json:"options"`
+	// Checksum is the directoryChecksum of the extracted files.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// extractMarker returns the marker an extraction of the dependency's download named dlName must have.
+func (d *Dependency) extractMarker(dlName string) *extractMarker {
+	return &extractMarker{
+		Download: d.checksum,
+		Options:  d.extractOptions(dlName),
+	}
+}
+
+// extractMarkerPath returns the path of the marker for the extracts cache entry with key.
+func extractMarkerPath(cacheDir, key string) (string, error) {
+	markersDir := filepath.Join(cacheDir, ".extract_sums")
+	err := os.MkdirAll(markersDir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(markersDir, key+".json"), nil
+}
+
+// readExtractMarker reads the marker at filename. It returns nil when there is no marker.
+func readExtractMarker(filename string) (*extractMarker, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var marker extractMarker
+	err = json.Unmarshal(data, &marker)
+	if err != nil {
+		return nil, err
+	}
+	return &marker, nil
+}
+
+// write records the checksum of extractDir and writes the marker to filename.
+func (m *extractMarker) write(filename, extractDir string) error {
+	sum, err := directoryChecksum(extractDir)
+	if err != nil {
+		return err
+	}
+	marker := *m
+	marker.Checksum = sum
+	data, err := json.Marshal(&marker)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o644)
+}
+
+// validator returns a cache validator that accepts entries whose marker at filename matches m.
+func (m *extractMarker) validator(filename string) func(string) error {
+	return func(string) error {
+		got, err := readExtractMarker(filename)
+		if err != nil {
+			return err
+		}
+		if got == nil {
+			return fmt.Errorf("no extract marker")
+		}
+		if got.Download != m.Download || got.Options != m.Options {
+			return fmt.Errorf("extract marker doesn't match")
+		}
+		return nil
+	}
+}
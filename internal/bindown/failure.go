@@ -0,0 +1,148 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+)
+
+// FailureKind is the broad cause of an error, so callers can react to a failure without parsing its message.
+type FailureKind string
+
+const (
+	// FailureUnknown is an error with no more specific kind.
+	FailureUnknown FailureKind = "error"
+	// FailureConfig is an error in the config, like an invalid value or a missing checksum.
+	FailureConfig FailureKind = "config error"
+	// FailureNetwork is a failed request or download.
+	FailureNetwork FailureKind = "network error"
+	// FailureChecksum is a download that doesn't match its checksum.
+	FailureChecksum FailureKind = "checksum mismatch"
+	// FailureUnsupportedSystem is a dependency used on a system it doesn't support.
+	FailureUnsupportedSystem FailureKind = "unsupported system"
+)
+
+// FailureKindOf returns the kind of err. It returns FailureUnknown for nil and errors that aren't recognized.
+func FailureKindOf(err error) FailureKind {
+	var checksumErr *ChecksumMismatchError
+	var systemErr *UnsupportedSystemError
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dlErr *downloadError
+	var configErr *ConfigError
+	var validationErr *ConfigValidationError
+	switch {
+	case err == nil:
+		return FailureUnknown
+	case errors.As(err, &checksumErr):
+		return FailureChecksum
+	case errors.As(err, &systemErr):
+		return FailureUnsupportedSystem
+	case errors.As(err, &urlErr), errors.As(err, &opErr), errors.As(err, &dlErr):
+		return FailureNetwork
+	case errors.As(err, &configErr), errors.As(err, &validationErr):
+		return FailureConfig
+	default:
+		return FailureUnknown
+	}
+}
+
+// ConfigError is an error caused by the config.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumMismatchError is returned when a download or the bin extracted from it doesn't match its checksum.
+type ChecksumMismatchError struct {
+	File string
+	Want string
+	Got  string
+	// Dependency is set when File is the bin extracted from the dependency's download instead of a download.
+	Dependency string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	if e.Dependency != "" {
+		return fmt.Sprintf(`bin checksum mismatch in %q extracted for %s
+wanted: %s
+got: %s`, e.File, e.Dependency, e.Want, e.Got)
+	}
+	return fmt.Sprintf(`checksum mismatch in downloaded file %q
+wanted: %s
+got: %s`, e.File, e.Want, e.Got)
+}
+
+// UnsupportedSystemError is returned when a dependency is used on a system that isn't in its systems.
+type UnsupportedSystemError struct {
+	Dependency string
+	System     System
+}
+
+func (e *UnsupportedSystemError) Error() string {
+	return fmt.Sprintf("%s does not support %s", e.Dependency, e.System)
+}
+
+// DependencyError is the error for one dependency in an operation on several dependencies.
+type DependencyError struct {
+	Dependency string
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return e.Dependency + ": " + e.Err.Error()
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// DependencyErrors returns the *DependencyError values in err, including the ones in errors.Join results.
+func DependencyErrors(err error) []*DependencyError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *DependencyError:
+		return []*DependencyError{e}
+	case interface{ Unwrap() []error }:
+		var result []*DependencyError
+		for _, wrapped := range e.Unwrap() {
+			result = append(result, DependencyErrors(wrapped)...)
+		}
+		return result
+	default:
+		return DependencyErrors(errors.Unwrap(err))
+	}
+}
+
+// downloadError is a download that failed without a transport error, like a non-2xx response.
+type downloadError struct {
+	err error
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *downloadError) Unwrap() error {
+	return e.err
+}
+
+// checkSystem returns an *UnsupportedSystemError when dep lists the systems it supports and its system isn't one of
+// them.
+func checkSystem(dep *Dependency) error {
+	dep.mustBeBuilt()
+	if len(dep.Systems) == 0 || slices.Contains(dep.Systems, dep.system) {
+		return nil
+	}
+	return &UnsupportedSystemError{Dependency: dep.name, System: dep.system}
+}
@@ -0,0 +1,448 @@
+package bindown
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/mholt/archiver/v3"
+	bootstrapper "github.com/willabides/bindown/v4/internal/build-bootstrapper"
+)
+
+//go:embed makefile.gotmpl
+var makefileTmplText string
+
+//go:embed justfile.gotmpl
+var justfileTmplText string
+
+//go:embed taskfile.gotmpl
+var taskfileTmplText string
+
+//go:embed magefile.gotmpl
+var magefileTmplText string
+
+//go:embed dockerfile.gotmpl
+var dockerfileTmplText string
+
+//go:embed installer.gotmpl
+var installerTmplText string
+
+//go:embed envrc.gotmpl
+var envrcTmplText string
+
+//go:embed gopackage.gotmpl
+var goPackageTmplText string
+
+var (
+	makefileTmpl = template.Must(template.New("makefile").Parse(makefileTmplText))
+	justfileTmpl = template.Must(template.New("justfile").Parse(justfileTmplText))
+	taskfileTmpl = template.Must(template.New("taskfile").Parse(taskfileTmplText))
+	magefileTmpl = template.Must(template.New("magefile").Funcs(template.FuncMap{
+		"goIdent": goIdent,
+	}).Parse(magefileTmplText))
+	dockerfileTmpl = template.Must(template.New("dockerfile").Funcs(template.FuncMap{
+		"base": path.Base,
+	}).Parse(dockerfileTmplText))
+	installerTmpl = template.Must(template.New("installer").Funcs(template.FuncMap{
+		"shquote": shquote,
+	}).Parse(installerTmplText))
+	envrcTmpl     = template.Must(template.New("envrc").Parse(envrcTmplText))
+	goPackageTmpl = template.Must(template.New("gopackage").Funcs(template.FuncMap{
+		"goIdent":       goIdent,
+		"goGenerateArg": goGenerateArg,
+	}).Parse(goPackageTmplText))
+)
+
+// GenerateOpts provides options for the Config.Generate* methods
+type GenerateOpts struct {
+	// Dependencies to generate for. Default is all dependencies.
+	Dependencies []string
+	// System used to resolve install paths. Default is CurrentSystem.
+	System System
+	// Dir is the directory that paths in the generated file are relative to. Default is the current directory.
+	Dir string
+	// BindownExec is the bindown command the generated file runs. Default is "bindown".
+	BindownExec string
+	// BindownTag is the bindown release the generated Dockerfile installs. Required by GenerateDockerfile.
+	BindownTag string
+	// BaseImage is the image the generated Dockerfile installs dependencies in. Default is "alpine:3".
+	BaseImage string
+	// BinDir is where the generated installer puts the bin unless the script is run with -b. Default is "./bin".
+	BinDir string
+	// InstallMissing makes the generated .envrc install dependencies that are missing.
+	InstallMissing bool
+	// Package is the package name of the file GenerateGoPackage writes. Default is the name of Dir.
+	Package string
+}
+
+type generateTmplVars struct {
+	BindownExec    string
+	BindownTag     string
+	BaseImage      string
+	System         System
+	ConfigFile     string
+	InstallMissing bool
+	Package        string
+	Dependencies   []generateTmplDependency
+
+	// ConfigSupport are the lock file, its signatures and the ConfigFragmentDir when they exist. They are relative to
+	// GenerateOpts.Dir like ConfigFile.
+	ConfigSupport []string
+}
+
+// BinDirs returns the directories the dependencies are installed in without duplicates.
+func (v *generateTmplVars) BinDirs() []string {
+	var dirs []string
+	for _, dep := range v.Dependencies {
+		dir := path.Dir(dep.Path)
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+type generateTmplDependency struct {
+	Name string
+	// Path is the slash-separated install path relative to GenerateOpts.Dir
+	Path string
+}
+
+// GenerateMakefile writes Makefile targets that install each dependency when its bin is missing or older than the
+// config file.
+func (c *Config) GenerateMakefile(w io.Writer, opts *GenerateOpts) error {
+	return c.generate(w, makefileTmpl, opts)
+}
+
+// GenerateJustfile writes justfile recipes that install each dependency when its bin is missing.
+func (c *Config) GenerateJustfile(w io.Writer, opts *GenerateOpts) error {
+	return c.generate(w, justfileTmpl, opts)
+}
+
+// GenerateTaskfile writes a Taskfile.yml with tasks that install each dependency when its bin is missing or the config
+// file has changed.
+func (c *Config) GenerateTaskfile(w io.Writer, opts *GenerateOpts) error {
+	return c.generate(w, taskfileTmpl, opts)
+}
+
+// GenerateMagefile writes a magefile with a Bindown namespace containing targets that install each dependency when
+// its bin is missing or older than the config file.
+func (c *Config) GenerateMagefile(w io.Writer, opts *GenerateOpts) error {
+	return c.generateGo(w, magefileTmpl, opts, "All")
+}
+
+// GenerateDockerfile writes a multi-stage Dockerfile fragment. The "bindown" stage installs bindown and the
+// dependencies for opts.System, and the "bindown-bin" stage contains only the installed bins in /usr/local/bin. Paths
+// are relative to opts.Dir, which should be the docker build context.
+func (c *Config) GenerateDockerfile(w io.Writer, opts *GenerateOpts) error {
+	if opts == nil || opts.BindownTag == "" {
+		return fmt.Errorf("bindown tag is required")
+	}
+	return c.generate(w, dockerfileTmpl, opts)
+}
+
+// GenerateEnvrc writes a direnv .envrc that adds the directories the dependencies are installed in to PATH. When
+// opts.InstallMissing is set, it also installs dependencies that are missing whenever direnv loads it.
+func (c *Config) GenerateEnvrc(w io.Writer, opts *GenerateOpts) error {
+	return c.generate(w, envrcTmpl, opts)
+}
+
+// GenerateGoPackage writes a go file with a "//go:generate bindown install" directive for each dependency and a
+// constant with each dependency's install path relative to opts.Dir, so go code can find the tools bindown manages.
+func (c *Config) GenerateGoPackage(w io.Writer, opts *GenerateOpts) error {
+	return c.generateGo(w, goPackageTmpl, opts)
+}
+
+// GenerateInstaller writes a standalone shell script that downloads, checksum-verifies and installs the single
+// dependency in opts.Dependencies for the system it runs on. Every system the dependency supports must have a
+// checksum.
+func (c *Config) GenerateInstaller(w io.Writer, opts *GenerateOpts) error {
+	if opts == nil || len(opts.Dependencies) != 1 {
+		return fmt.Errorf("installer requires exactly one dependency")
+	}
+	name := opts.Dependencies[0]
+	systems, err := c.DependencySystems(name)
+	if err != nil {
+		return err
+	}
+	if len(systems) == 0 {
+		return fmt.Errorf("dependency %q has no systems", name)
+	}
+	shellLib, err := bootstrapper.ShellLib()
+	if err != nil {
+		return err
+	}
+	vars := installerTmplVars{
+		Name:     name,
+		BinDir:   opts.BinDir,
+		ShellLib: shellLib,
+	}
+	if vars.BinDir == "" {
+		vars.BinDir = "./bin"
+	}
+	for _, system := range systems {
+		var dep *Dependency
+		dep, err = c.BuildDependency(name, system)
+		if err != nil {
+			return err
+		}
+		if dep.checksum == "" {
+			return fmt.Errorf("no checksum configured for %s on %s. run `bindown checksums add %s` first", name, system, name)
+		}
+		var dlName string
+		dlName, err = urlFilename(dep.url)
+		if err != nil {
+			return err
+		}
+		var format string
+		format, err = installerFormat(dlName)
+		if err != nil {
+			return fmt.Errorf("can't generate an installer for %s on %s: %w", name, system, err)
+		}
+		bin := dep.binName()
+		if vars.Bin != "" && vars.Bin != bin {
+			return fmt.Errorf("can't generate an installer for %s: bin name differs between systems", name)
+		}
+		vars.Bin = bin
+		vars.Version = dep.Vars["version"]
+		vars.Systems = append(vars.Systems, installerTmplSystem{
+			System:       system,
+			URL:          dep.url,
+			Checksum:     dep.checksum,
+			DownloadName: dlName,
+			Format:       format,
+			ArchivePath:  dep.archivePath(),
+		})
+	}
+	return installerTmpl.Execute(w, vars)
+}
+
+type installerTmplVars struct {
+	Name     string
+	Version  string
+	Bin      string
+	BinDir   string
+	ShellLib string
+	Systems  []installerTmplSystem
+}
+
+type installerTmplSystem struct {
+	System       System
+	URL          string
+	Checksum     string
+	DownloadName string
+	// Format tells the script's extract function how to unpack the download.
+	Format      string
+	ArchivePath string
+}
+
+// installerFormat returns the format the installer's extract function uses for a download named dlName. Downloads
+// that aren't a recognized archive are installed as-is.
+func installerFormat(dlName string) (string, error) {
+	byExt, err := archiver.ByExtension(dlName)
+	if err != nil {
+		return "raw", nil
+	}
+	switch byExt.(type) {
+	case *archiver.TarGz:
+		return "tar.gz", nil
+	case *archiver.TarXz:
+		return "tar.xz", nil
+	case *archiver.TarBz2:
+		return "tar.bz2", nil
+	case *archiver.Tar:
+		return "tar", nil
+	case *archiver.Zip:
+		return "zip", nil
+	case *archiver.Gz:
+		return "gz", nil
+	case *archiver.Xz:
+		return "xz", nil
+	case *archiver.Bz2:
+		return "bz2", nil
+	default:
+		return "", fmt.Errorf("%s archives are not supported by the installer", path.Ext(dlName))
+	}
+}
+
+// shquote quotes s for use as a single word in a posix shell script.
+func shquote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func (c *Config) generate(w io.Writer, tmpl *template.Template, opts *GenerateOpts) error {
+	vars, err := c.generateTmplVars(opts)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, vars)
+}
+
+// generateGo executes a template that declares a go identifier for each dependency and formats the result. reserved
+// are identifiers the template already declares.
+func (c *Config) generateGo(w io.Writer, tmpl *template.Template, opts *GenerateOpts, reserved ...string) error {
+	vars, err := c.generateTmplVars(opts)
+	if err != nil {
+		return err
+	}
+	err = checkGoIdents(vars.Dependencies, reserved)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, vars)
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// checkGoIdents returns an error when two dependencies, or a dependency and one of reserved, have the same goIdent.
+func checkGoIdents(deps []generateTmplDependency, reserved []string) error {
+	seen := map[string]string{}
+	for _, ident := range reserved {
+		seen[ident] = ""
+	}
+	for _, dep := range deps {
+		ident := goIdent(dep.Name)
+		other, ok := seen[ident]
+		switch {
+		case ok && other == "":
+			return fmt.Errorf("dependency %q becomes the go identifier %s, which the generated code already uses", dep.Name, ident)
+		case ok:
+			return fmt.Errorf("dependencies %q and %q both become the go identifier %s", other, dep.Name, ident)
+		}
+		seen[ident] = dep.Name
+	}
+	return nil
+}
+
+func (c *Config) generateTmplVars(opts *GenerateOpts) (*generateTmplVars, error) {
+	if opts == nil {
+		opts = &GenerateOpts{}
+	}
+	deps := opts.Dependencies
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	system := opts.System
+	if system == "" {
+		system = CurrentSystem
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	vars := generateTmplVars{
+		BindownExec:    opts.BindownExec,
+		BindownTag:     opts.BindownTag,
+		BaseImage:      opts.BaseImage,
+		System:         system,
+		InstallMissing: opts.InstallMissing,
+		Package:        opts.Package,
+	}
+	if vars.Package == "" {
+		vars.Package = goPackageName(dir)
+	}
+	if vars.BindownExec == "" {
+		vars.BindownExec = "bindown"
+	}
+	if vars.BaseImage == "" {
+		vars.BaseImage = "alpine:3"
+	}
+	var err error
+	vars.ConfigFile, err = relPath(dir, c.Filename)
+	if err != nil {
+		return nil, err
+	}
+	var support []string
+	if FileExists(c.LockFilePath()) {
+		support = append(support, c.LockFilePath())
+		support = append(support, c.LockSignatures()...)
+	}
+	if len(c.fragments) > 0 {
+		support = append(support, filepath.Join(filepath.Dir(c.Filename), ConfigFragmentDir))
+	}
+	for _, p := range support {
+		p, err = relPath(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		vars.ConfigSupport = append(vars.ConfigSupport, p)
+	}
+	for _, name := range deps {
+		var installPath string
+		installPath, err = c.DependencyInstallPath(name, system)
+		if err != nil {
+			return nil, err
+		}
+		installPath, err = relPath(dir, installPath)
+		if err != nil {
+			return nil, err
+		}
+		vars.Dependencies = append(vars.Dependencies, generateTmplDependency{
+			Name: name,
+			Path: installPath,
+		})
+	}
+	return &vars, nil
+}
+
+// goPackageName returns a go package name made from the lowercase letters and digits of dir's name. It is "tools"
+// when that doesn't start with a letter.
+func goPackageName(dir string) string {
+	absDir, err := filepath.Abs(dir)
+	if err == nil {
+		dir = absDir
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(dir))
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return "tools"
+	}
+	return name
+}
+
+// goGenerateArg quotes s for a //go:generate directive when it has spaces or quotes.
+func goGenerateArg(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// goIdent converts a dependency name to an exported go identifier. For example "golangci-lint" becomes "GolangciLint".
+func goIdent(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	ident := sb.String()
+	if ident == "" || !unicode.IsLetter([]rune(ident)[0]) {
+		ident = "Dep" + ident
+	}
+	return ident
+}
@@ -0,0 +1,34 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_goIdent(t *testing.T) {
+	for name, want := range map[string]string{
+		"jq":            "Jq",
+		"golangci-lint": "GolangciLint",
+		"foo_bar.baz":   "FooBarBaz",
+		"7zip":          "Dep7zip",
+		"-":             "Dep",
+	} {
+		require.Equal(t, want, goIdent(name), name)
+	}
+}
+
+func Test_checkGoIdents(t *testing.T) {
+	deps := func(names ...string) []generateTmplDependency {
+		var result []generateTmplDependency
+		for _, name := range names {
+			result = append(result, generateTmplDependency{Name: name})
+		}
+		return result
+	}
+	require.NoError(t, checkGoIdents(deps("foo-bar", "foobar"), []string{"All"}))
+	err := checkGoIdents(deps("foo-bar", "jq", "foo_bar"), nil)
+	require.EqualError(t, err, `dependencies "foo-bar" and "foo_bar" both become the go identifier FooBar`)
+	err = checkGoIdents(deps("all"), []string{"All"})
+	require.EqualError(t, err, `dependency "all" becomes the go identifier All, which the generated code already uses`)
+}
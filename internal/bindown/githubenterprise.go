@@ -0,0 +1,142 @@
+package bindown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// githubEnterpriseHost returns true when host is one of hosts. Hosts are compared case-insensitively with or without
+// the port.
+func githubEnterpriseHost(u *url.URL, hosts []string) bool {
+	return slices.ContainsFunc(hosts, func(h string) bool {
+		return strings.EqualFold(h, u.Host) || strings.EqualFold(h, u.Hostname())
+	})
+}
+
+// githubAPIURLFor returns the api url for the GitHub instance u is on. It is false when u isn't on github.com or one of
+// the GitHub Enterprise Server hosts.
+func githubAPIURLFor(u *url.URL, enterpriseHosts []string) (string, bool) {
+	if u.Host == "github.com" {
+		return githubAPIURL, true
+	}
+	if githubEnterpriseHost(u, enterpriseHosts) {
+		return u.Scheme + "://" + u.Host + "/api/v3", true
+	}
+	return "", false
+}
+
+// githubEnterpriseToken returns the token for release downloads from GitHub Enterprise Server. It is the same
+// GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN the gh cli uses.
+func githubEnterpriseToken() string {
+	return firstEnv("GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN")
+}
+
+// githubAssetRequest returns a request for the api endpoint of the release asset dlURL downloads when dlURL is a
+// release download url on a GitHub Enterprise Server host and there is a token. Release download urls of private
+// repositories don't accept tokens, so the asset is downloaded from the api instead. It returns nil otherwise.
+func (dl *downloader) githubAssetRequest(ctx context.Context, method, dlURL string) (_ *http.Request, errOut error) {
+	if dl == nil || len(dl.githubHosts) == 0 {
+		return nil, nil
+	}
+	token := githubEnterpriseToken()
+	if token == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(dlURL)
+	if err != nil || !githubEnterpriseHost(u, dl.githubHosts) {
+		return nil, nil
+	}
+	apiURL, _ := githubAPIURLFor(u, dl.githubHosts)
+	// /<owner>/<repo>/releases/download/<tag>/<file>
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) != 6 || segments[2] != "releases" || segments[3] != "download" {
+		return nil, nil
+	}
+	project := segments[0] + "/" + segments[1]
+	endpoint := fmt.Sprintf("%s/repos/%s/releases/tags/%s", apiURL, project, url.PathEscape(segments[4]))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := dl.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &downloadError{err: fmt.Errorf("failed looking up release %s of %s: %s", segments[4], project, resp.Status)}
+	}
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"assets"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&release)
+	if err != nil {
+		return nil, err
+	}
+	name, err := url.PathUnescape(path.Base(u.Path))
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range release.Assets {
+		if asset.Name != name {
+			continue
+		}
+		req, err = http.NewRequestWithContext(ctx, method, asset.URL, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/octet-stream")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	}
+	return nil, &downloadError{err: fmt.Errorf("release %s of %s has no asset named %s", segments[4], project, name)}
+}
+
+// newRequest returns a request for the presigned url for dlURL. Release assets on GitHub Enterprise Server hosts are
+// requested from the api with a token. The request must go to a host in allowed_hosts.
+func (dl *downloader) newRequest(ctx context.Context, method, dlURL string) (*http.Request, error) {
+	req, err := dl.githubAssetRequest(ctx, method, dlURL)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		var signedURL string
+		signedURL, err = dl.presign(dlURL)
+		if err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequestWithContext(ctx, method, signedURL, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = dl.checkRequestHost(dlURL, req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// attestationHostname returns the GitHub Enterprise Server host dep downloads from for "gh attestation verify
+// --hostname". It is empty for other dependencies.
+func (dl *downloader) attestationHostname(dlURL string) string {
+	if dl == nil {
+		return ""
+	}
+	u, err := url.Parse(dlURL)
+	if err != nil || !githubEnterpriseHost(u, dl.githubHosts) {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,88 @@
+package bindown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubEnterprise(t *testing.T) {
+	ctx := context.Background()
+	content, err := os.ReadFile(filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"))
+	require.NoError(t, err)
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	var serverURL string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized := r.Header.Get("Authorization") == "Bearer ghe-token"
+		switch r.URL.EscapedPath() {
+		case "/api/v3/repos/org/foo/tags":
+			fmt.Fprint(w, `[{"name": "v1.1.0"}, {"name": "v1.0.0"}]`)
+		case "/api/v3/repos/org/foo/releases/tags/v1.0.0":
+			if !authorized {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"assets": [{"name": "foo.tar.gz", "url": "%s/api/v3/repos/org/foo/releases/assets/1"}]}`, serverURL)
+		case "/api/v3/repos/org/foo/releases/assets/1":
+			if !authorized || r.Header.Get("Accept") != "application/octet-stream" {
+				http.NotFound(w, r)
+				return
+			}
+			_, e := w.Write(content)
+			assert.NoError(t, e)
+		case "/api/v3/repos/org/templates/commits/main":
+			fmt.Fprint(w, sha)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	serverURL = ts.URL
+	host, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	t.Setenv("GH_ENTERPRISE_TOKEN", "ghe-token")
+	dir := t.TempDir()
+	cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+github_enterprise_hosts: [%s]
+dependencies:
+  foo:
+    url: %s/org/foo/releases/download/v{{.version}}/foo.tar.gz
+    vars:
+      version: 1.0.0
+url_checksums:
+  %s/org/foo/releases/download/v1.0.0/foo.tar.gz: 27dcce60d1ed72920a84dd4bc01e0bbd013e5a841660e9ee2e964e53fb83c0b3
+`, filepath.Join(dir, "cache"), host.Host, ts.URL, ts.URL))
+
+	t.Run("versions", func(t *testing.T) {
+		versions, err := cfg.DependencyVersions(ctx, "foo", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.1.0", "1.0.0"}, versions)
+	})
+
+	t.Run("download with token", func(t *testing.T) {
+		err := cfg.DownloadDependencies([]string{"foo"}, "linux/amd64", nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("pin template source", func(t *testing.T) {
+		got, err := cfg.pinGitHubCommit(ctx, ts.URL+"/raw/org/templates/main/bindown.yaml", "github.com-token")
+		require.NoError(t, err)
+		require.Equal(t, ts.URL+"/raw/org/templates/"+sha+"/bindown.yaml", got)
+	})
+
+	t.Run("other hosts", func(t *testing.T) {
+		other := &Config{}
+		require.Nil(t, parseUpstreamRepo(ts.URL+"/org/foo/releases/download/v{{.version}}/foo.tar.gz", nil))
+		_, err := other.pinGitHubCommit(ctx, ts.URL+"/raw/org/templates/main/bindown.yaml", "")
+		require.ErrorContains(t, err, "can only pin a commit for raw.githubusercontent.com urls")
+	})
+}
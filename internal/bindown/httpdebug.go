@@ -0,0 +1,175 @@
+package bindown
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// httpDebug is where requests are logged. It holds nil unless SetHTTPDebug was called.
+var httpDebug atomic.Pointer[httpDebugLog]
+
+type httpDebugLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	counter atomic.Int64
+}
+
+// SetHTTPDebug logs the method, url, headers, status and timing of every http request bindown's clients make to w.
+// Credentials in headers and query strings are redacted so the log can be shared in bug reports. A nil w stops
+// logging.
+func SetHTTPDebug(w io.Writer) {
+	if w == nil {
+		httpDebug.Store(nil)
+		return
+	}
+	httpDebug.Store(&httpDebugLog{w: w})
+}
+
+// withHTTPDebug wraps rt to log requests when SetHTTPDebug is on. Otherwise it returns rt.
+func withHTTPDebug(rt http.RoundTripper) http.RoundTripper {
+	log := httpDebug.Load()
+	if log == nil {
+		return rt
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &httpDebugTransport{base: rt, log: log}
+}
+
+type httpDebugTransport struct {
+	base http.RoundTripper
+	log  *httpDebugLog
+}
+
+func (t *httpDebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := t.log.counter.Add(1)
+	lines := []string{fmt.Sprintf("> [%d] %s %s", id, req.Method, redactURL(req.URL.String()))}
+	lines = append(lines, headerLines(fmt.Sprintf("> [%d] ", id), req.Header)...)
+	t.log.write(lines)
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Milliseconds()
+	if err != nil {
+		t.log.write([]string{fmt.Sprintf("< [%d] error after %dms: %v", id, elapsed, err)})
+		return nil, err
+	}
+	lines = []string{fmt.Sprintf("< [%d] %s in %dms", id, resp.Status, elapsed)}
+	lines = append(lines, headerLines(fmt.Sprintf("< [%d] ", id), resp.Header)...)
+	t.log.write(lines)
+	resp.Body = &httpDebugBody{ReadCloser: resp.Body, log: t.log, id: id, start: start}
+	return resp, nil
+}
+
+func (l *httpDebugLog) write(lines []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, strings.Join(lines, "\n")+"\n")
+}
+
+// httpDebugBody logs how much of the body was read and how long the whole request took when it is closed.
+type httpDebugBody struct {
+	io.ReadCloser
+	log    *httpDebugLog
+	id     int64
+	start  time.Time
+	read   int64
+	closed bool
+}
+
+func (b *httpDebugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *httpDebugBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.log.write([]string{fmt.Sprintf(
+			"< [%d] read %d bytes in %dms", b.id, b.read, time.Since(b.start).Milliseconds(),
+		)})
+	}
+	return b.ReadCloser.Close()
+}
+
+// sensitiveHeaders are redacted in addition to the headers isSensitiveName matches
+var sensitiveHeaders = []string{"Cookie", "Set-Cookie"}
+
+func headerLines(prefix string, header http.Header) []string {
+	header = redactHeader(header)
+	keys := MapKeys(header)
+	slices.Sort(keys)
+	var lines []string
+	for _, key := range keys {
+		for _, val := range header[key] {
+			lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, key, val))
+		}
+	}
+	return lines
+}
+
+// headerURLExp matches the urls in header values like Location and Link.
+var headerURLExp = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// redactHeader returns a copy of header with credential headers replaced with REDACTED and the urls in other headers
+// redacted by redactURL.
+func redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for key, vals := range header {
+		for _, val := range vals {
+			if slices.Contains(sensitiveHeaders, key) || isSensitiveName(key) {
+				val = "REDACTED"
+			} else {
+				val = headerURLExp.ReplaceAllStringFunc(val, redactURL)
+			}
+			redacted[key] = append(redacted[key], val)
+		}
+	}
+	return redacted
+}
+
+// isSensitiveName reports whether a header or query parameter name looks like it holds a credential.
+func isSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"token", "secret", "password", "credential", "key", "auth", "sig"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL replaces the password and credential-like query parameters of rawURL with REDACTED.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if isSensitiveName(key) {
+				for i := range query[key] {
+					query[key][i] = "REDACTED"
+				}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,177 @@
+package bindown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// httpFixtures is where responses are recorded or replayed from. It holds nil unless SetHTTPFixtures was called.
+var httpFixtures atomic.Pointer[httpFixtureStore]
+
+type httpFixtureStore struct {
+	dir    string
+	replay bool
+}
+
+// SetHTTPFixtures records the response to every http request bindown's clients make in dir, or with replay, answers
+// every request with the response recorded in dir instead of making it. A request without a recorded response fails when
+// replaying. This lets a config be tested in CI without reaching upstream hosts. An empty dir stops both.
+func SetHTTPFixtures(dir string, replay bool) {
+	if dir == "" {
+		httpFixtures.Store(nil)
+		return
+	}
+	httpFixtures.Store(&httpFixtureStore{dir: dir, replay: replay})
+}
+
+// withHTTPFixtures wraps rt to record or replay responses when SetHTTPFixtures is on. Otherwise it returns rt.
+func withHTTPFixtures(rt http.RoundTripper) http.RoundTripper {
+	store := httpFixtures.Load()
+	if store == nil {
+		return rt
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &httpFixtureTransport{base: rt, store: store}
+}
+
+// httpFixture is the metadata of a recorded response. The body is in a file next to it.
+type httpFixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+type httpFixtureTransport struct {
+	base  http.RoundTripper
+	store *httpFixtureStore
+}
+
+func (t *httpFixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		err = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	// credentials are redacted from the key so a fixture recorded with one token replays with another
+	redacted := redactURL(req.URL.String())
+	sum := sha256.Sum256([]byte(req.Method + " " + redacted + "\n" + string(reqBody)))
+	base := filepath.Join(t.store.dir, hex.EncodeToString(sum[:8]))
+	if t.store.replay {
+		return replayHTTPFixture(req, base, redacted)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return recordHTTPFixture(resp, base, redacted)
+}
+
+// recordHTTPFixture returns resp with a body that is written to base.body as it is read. When the body is closed, the
+// rest of it is written too, and then the metadata is written to base.json.
+func recordHTTPFixture(resp *http.Response, base, redactedURL string) (*http.Response, error) {
+	meta, err := json.MarshalIndent(httpFixture{
+		Method: resp.Request.Method,
+		URL:    redactedURL,
+		Status: resp.StatusCode,
+		// recordings are meant to be committed, so they get the same redaction as the debug log
+		Header: redactHeader(resp.Header),
+	}, "", "  ")
+	if err != nil {
+		return nil, errors.Join(err, resp.Body.Close())
+	}
+	err = os.MkdirAll(filepath.Dir(base), 0o755)
+	if err != nil {
+		return nil, errors.Join(err, resp.Body.Close())
+	}
+	// the body is written to a temp file so an interrupted recording doesn't leave a partial fixture
+	file, err := os.CreateTemp(filepath.Dir(base), ".tmp-"+filepath.Base(base)+"-*")
+	if err != nil {
+		return nil, errors.Join(err, resp.Body.Close())
+	}
+	resp.Body = &fixtureRecorder{
+		Reader: io.TeeReader(resp.Body, file),
+		body:   resp.Body,
+		file:   file,
+		base:   base,
+		meta:   append(meta, '\n'),
+	}
+	return resp, nil
+}
+
+// fixtureRecorder is a response body that is copied to a fixture file as it is read.
+type fixtureRecorder struct {
+	io.Reader
+	body io.ReadCloser
+	file *os.File
+	base string
+	meta []byte
+}
+
+func (r *fixtureRecorder) Close() error {
+	// record what the caller didn't read, so the fixture has the whole body
+	_, err := io.Copy(r.file, r.body)
+	err = errors.Join(err, r.body.Close(), r.file.Close())
+	if err == nil {
+		err = os.Rename(r.file.Name(), r.base+".body")
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(r.file.Name()))
+	}
+	return os.WriteFile(r.base+".json", r.meta, 0o644)
+}
+
+// replayHTTPFixture returns the response recorded at base for req.
+func replayHTTPFixture(req *http.Request, base, redactedURL string) (*http.Response, error) {
+	meta, err := os.ReadFile(base + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, redactedURL, filepath.Dir(base))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fixture httpFixture
+	err = json.Unmarshal(meta, &fixture)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response %s: %w", base+".json", err)
+	}
+	body, err := os.Open(base + ".body")
+	if err != nil {
+		return nil, err
+	}
+	info, err := body.Stat()
+	if err != nil {
+		return nil, errors.Join(err, body.Close())
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.Header,
+		Body:          body,
+		ContentLength: info.Size(),
+		Request:       req,
+	}, nil
+}
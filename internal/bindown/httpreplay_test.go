@@ -0,0 +1,88 @@
+package bindown
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetHTTPFixtures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Link", `<https://example.com/next?X-Amz-Signature=secret>; rel="next"`)
+		_, _ = w.Write([]byte("hello " + r.URL.Path))
+	}))
+	t.Cleanup(func() { SetHTTPFixtures("", false) })
+	dir := t.TempDir()
+	get := func(t *testing.T, u string) (int, string) {
+		t.Helper()
+		dl := &downloader{}
+		resp, err := dl.httpClient().Get(u)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode, string(body)
+	}
+
+	SetHTTPFixtures(dir, false)
+	status, body := get(t, ts.URL+"/foo?token=recorded")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello /foo", body)
+	status, _ = get(t, ts.URL+"/missing")
+	require.Equal(t, http.StatusNotFound, status)
+	recorded, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	for _, file := range recorded {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), "secret")
+	}
+
+	ts.Close()
+	SetHTTPFixtures(dir, true)
+	status, body = get(t, ts.URL+"/foo?token=other")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello /foo", body)
+	status, _ = get(t, ts.URL+"/missing")
+	require.Equal(t, http.StatusNotFound, status)
+
+	_, err = (&downloader{}).httpClient().Get(ts.URL + "/bar")
+	require.ErrorContains(t, err, "no recorded response for GET "+ts.URL+"/bar in "+dir)
+}
+
+func Test_recordHTTPFixture(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "fixtures", "abc")
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/big", http.NoBody)
+	resp, err := recordHTTPFixture(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("hello world")),
+		Request:    req,
+	}, base, "https://example.com/big")
+	require.NoError(t, err)
+	require.NoFileExists(t, base+".json")
+
+	// a body that isn't read to the end is still recorded whole
+	got := make([]byte, 5)
+	_, err = io.ReadFull(resp.Body, got)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	require.NoError(t, resp.Body.Close())
+	body, err := os.ReadFile(base + ".body")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(body))
+	require.FileExists(t, base+".json")
+	leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(base), ".tmp-*"))
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"text/template"
 
@@ -15,11 +16,13 @@ import (
 //go:embed wrapper.gotmpl
 var wrapperTmplText string
 
+// install installs dep to targetPath and returns the installed path. skipped is true when targetPath was already
+// up to date and force is false.
 func install(
 	dep *Dependency,
 	targetPath, cacheDir string,
-	force, toCache, missingSums bool,
-) (_ string, errOut error) {
+	force, toCache, missingSums, stream bool,
+) (_ string, skipped bool, errOut error) {
 	dep.mustBeBuilt()
 	if toCache {
 		instCache := &cache.Cache{Root: filepath.Join(cacheDir, "bin")}
@@ -33,72 +36,88 @@ func install(
 		}
 		popFn := func(dir string) error {
 			filename := filepath.Join(dir, dep.binName())
-			_, err := install(dep, filename, cacheDir, force, false, missingSums)
+			_, _, err := install(dep, filename, cacheDir, force, false, missingSums, stream)
 			return err
 		}
 		dir, unlock, err := instCache.Dir(key, validateFn, popFn)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 		err = unlock()
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
-		return filepath.Join(dir, dep.binName()), nil
-	}
-
-	dlCache := cache.Cache{Root: filepath.Join(cacheDir, "downloads")}
-	dlFile, key, dlUnlock, err := downloadDependency(dep, &dlCache, missingSums, force)
-	if err != nil {
-		return "", err
+		return filepath.Join(dir, dep.binName()), false, nil
 	}
-	defer deferErr(&errOut, dlUnlock)
 
-	extractsCache := cache.Cache{Root: filepath.Join(cacheDir, "extracts")}
-	extractDir, exUnlock, err := extractDependencyToCache(dlFile, cacheDir, key, &extractsCache, force)
+	extractDir, exUnlock, err := downloadAndExtract(dep, cacheDir, force, missingSums, stream)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	defer deferErr(&errOut, exUnlock)
 
-	var binName string
-	if dep.BinName != nil {
-		binName = *dep.BinName
-	}
-	if binName == "" {
-		binName = dep.name
+	if len(dep.Entrypoints) > 0 {
+		return installEntrypoints(dep, extractDir, targetPath, force)
 	}
-	archivePath := filepath.FromSlash(binName)
-	if dep.ArchivePath != nil {
-		archivePath = filepath.FromSlash(*dep.ArchivePath)
+	extractBin := dep.extractedBin(extractDir)
+	err = verifyBinChecksum(dep, extractBin)
+	if err != nil {
+		return "", false, err
 	}
-	extractBin := filepath.Join(extractDir, archivePath)
 	if dep.Link != nil && *dep.Link {
-		return targetPath, linkBin(targetPath, extractBin)
+		return targetPath, false, linkBin(targetPath, extractBin)
+	}
+	if !force {
+		skipped, err = isInstalled(targetPath, extractBin)
+		if err != nil {
+			return "", false, err
+		}
+		if skipped {
+			return targetPath, true, nil
+		}
 	}
-	if FileExists(targetPath) {
+	// a regular file is replaced atomically, so only remove what can't be renamed over
+	if info, statErr := os.Lstat(targetPath); statErr == nil && !info.Mode().IsRegular() {
 		err = os.RemoveAll(targetPath)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 	}
 	err = os.MkdirAll(filepath.Dir(targetPath), 0o755)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	err = copyFile(extractBin, targetPath)
+	binStat, err := os.Stat(extractBin)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	targetStat, err := os.Stat(targetPath)
+	err = replaceFile(extractBin, targetPath, addExec(binStat.Mode()))
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	err = os.Chmod(targetPath, addExec(targetStat.Mode()))
+	return targetPath, false, nil
+}
+
+// isInstalled returns true when targetPath is a regular executable file with the same content as extractBin
+func isInstalled(targetPath, extractBin string) (bool, error) {
+	want, err := fileChecksum(extractBin)
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	return hasInstalledChecksum(targetPath, want)
+}
+
+// hasInstalledChecksum returns true when targetPath is a regular executable file with the checksum want
+func hasInstalledChecksum(targetPath, want string) (bool, error) {
+	info, err := os.Lstat(targetPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+	// windows doesn't have exec bits
+	if runtime.GOOS != "windows" && info.Mode() != addExec(info.Mode()) {
+		return false, nil
 	}
-	return targetPath, nil
+	return fileExistsWithChecksum(targetPath, want)
 }
 
 type wrapperTmplVars struct {
@@ -106,11 +125,12 @@ type wrapperTmplVars struct {
 	BindownExec    string
 	ConfigFile     string
 	FlagArgs       string
+	ExportEnv      bool
 }
 
 var wrapperTmpl = template.Must(template.New("wrapper").Parse(wrapperTmplText))
 
-func createWrapper(name, target, bindownExec, cacheDir, configFile string, missingSums bool) (string, error) {
+func createWrapper(name, target, bindownExec, cacheDir, configFile string, missingSums, exportEnv bool) (string, error) {
 	wrapperDir := filepath.Dir(target)
 	err := os.MkdirAll(wrapperDir, 0o750)
 	if err != nil {
@@ -162,6 +182,7 @@ func createWrapper(name, target, bindownExec, cacheDir, configFile string, missi
 		BindownExec:    bindownExec,
 		ConfigFile:     configFile,
 		FlagArgs:       flagArgs,
+		ExportEnv:      exportEnv,
 	})
 	if err != nil {
 		return "", err
@@ -0,0 +1,87 @@
+package bindown
+
+// DependencyDetail describes a configured dependency for "bindown dependency list --detail".
+type DependencyDetail struct {
+	Name string `json:"name" yaml:"name"`
+	// Version is the dependency's version var.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Template is the template the dependency's values come from.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Systems are the systems the dependency supports.
+	Systems []System `json:"systems" yaml:"systems"`
+	// MissingChecksums are the systems with no checksum in url_checksums.
+	MissingChecksums []System `json:"missing_checksums,omitempty" yaml:"missing_checksums,omitempty"`
+	// MissingVars are required vars that aren't set. Checksums can't be looked up until they are.
+	MissingVars []string `json:"missing_vars,omitempty" yaml:"missing_vars,omitempty"`
+}
+
+// DependencyDetails describes each dependency in name order.
+func (c *Config) DependencyDetails() ([]DependencyDetail, error) {
+	names := c.DependencyNames()
+	details := make([]DependencyDetail, 0, len(names))
+	for _, name := range names {
+		dep := c.Dependencies[name].clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		detail := DependencyDetail{
+			Name:     name,
+			Version:  dep.Vars["version"],
+			Template: stringValue(c.Dependencies[name].Template),
+		}
+		detail.Systems, err = c.DependencySystems(name)
+		if err != nil {
+			return nil, err
+		}
+		detail.MissingVars, err = c.MissingDependencyVars(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, system := range detail.Systems {
+			if len(detail.MissingVars) > 0 {
+				detail.MissingChecksums = append(detail.MissingChecksums, system)
+				continue
+			}
+			built, err := c.BuildDependency(name, system)
+			if err != nil {
+				return nil, err
+			}
+			if built.checksum == "" {
+				detail.MissingChecksums = append(detail.MissingChecksums, system)
+			}
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// DependencyDoc describes what a dependency is for "bindown dependency docs".
+type DependencyDoc struct {
+	Name string `json:"name" yaml:"name"`
+	// Version is the dependency's version var.
+	Version     string `json:"version,omitempty" yaml:"version,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Homepage    string `json:"homepage,omitempty" yaml:"homepage,omitempty"`
+}
+
+// DependencyDocs returns the description and homepage of each dependency in name order. A dependency that doesn't set
+// them gets them from its template.
+func (c *Config) DependencyDocs() ([]DependencyDoc, error) {
+	names := c.DependencyNames()
+	docs := make([]DependencyDoc, 0, len(names))
+	for _, name := range names {
+		dep := c.Dependencies[name].clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		docs = append(docs, DependencyDoc{
+			Name:        name,
+			Version:     dep.Vars["version"],
+			Description: stringValue(dep.Description),
+			Homepage:    stringValue(dep.Homepage),
+		})
+	}
+	return docs, nil
+}
@@ -0,0 +1,141 @@
+package bindown
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// installJournalFile is the name of the install journal in the cache dir.
+const installJournalFile = "install-journal.json"
+
+// InstallJournal records the progress of installing several dependencies so a run after a failure can skip the
+// dependencies that were installed and retry the rest.
+type InstallJournal struct {
+	System System `json:"system"`
+	// Output is the directory the dependencies are installed to.
+	Output string `json:"output"`
+	// Dependencies are the dependencies the run was asked to install.
+	Dependencies []string `json:"dependencies"`
+	// Installed has the dependencies that were installed. They are skipped while the installed file is unchanged and
+	// the dependency's url and checksum are unchanged.
+	Installed map[string]JournalEntry `json:"installed,omitempty"`
+	// Failed has the error for each dependency that failed to install.
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// JournalEntry describes an installed dependency in an InstallJournal.
+type JournalEntry struct {
+	URL      string `json:"url"`
+	Checksum string `json:"checksum,omitempty"`
+	Path     string `json:"path"`
+	// FileChecksum is the checksum of the installed file. It is empty when the install isn't a single file.
+	FileChecksum string `json:"file_checksum,omitempty"`
+}
+
+// InstallJournalPath returns where InstallDependencies keeps its journal when ConfigInstallDependenciesOpts.Journal is
+// set.
+func (c *Config) InstallJournalPath() string {
+	return filepath.Join(c.Cache, installJournalFile)
+}
+
+// ReadInstallJournal reads the journal at filename. It returns nil when there is no journal.
+func ReadInstallJournal(filename string) (*InstallJournal, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var journal InstallJournal
+	err = json.Unmarshal(data, &journal)
+	if err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
+
+// startInstallJournal returns the journal for installing deps to output for system. Installed entries from the
+// journal at filename are kept when it is for the same system and output.
+func startInstallJournal(filename string, system System, output string, deps []string) (*InstallJournal, error) {
+	journal := &InstallJournal{
+		System:       system,
+		Output:       output,
+		Dependencies: deps,
+		Installed:    map[string]JournalEntry{},
+		Failed:       map[string]string{},
+	}
+	previous, err := ReadInstallJournal(filename)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil || previous.System != system || previous.Output != output {
+		return journal, nil
+	}
+	for name, entry := range previous.Installed {
+		if slices.Contains(deps, name) {
+			journal.Installed[name] = entry
+		}
+	}
+	return journal, nil
+}
+
+// installed returns true when the journal says dep was installed to target and nothing has changed since, including
+// the installed file.
+func (j *InstallJournal) installed(dep *Dependency, target string) (bool, error) {
+	if j == nil {
+		return false, nil
+	}
+	entry, ok := j.Installed[dep.name]
+	if !ok || entry.URL != dep.url || entry.Checksum != dep.checksum || entry.Path != target || entry.FileChecksum == "" {
+		return false, nil
+	}
+	return hasInstalledChecksum(target, entry.FileChecksum)
+}
+
+func (j *InstallJournal) markInstalled(dep *Dependency, target string) {
+	if j == nil {
+		return
+	}
+	delete(j.Failed, dep.name)
+	entry := JournalEntry{URL: dep.url, Checksum: dep.checksum, Path: target}
+	// without a checksum the entry is never skipped, which is the safe way to fail
+	if info, err := os.Lstat(target); err == nil && info.Mode().IsRegular() {
+		entry.FileChecksum, _ = fileChecksum(target)
+	}
+	j.Installed[dep.name] = entry
+}
+
+func (j *InstallJournal) markFailed(name string, err error) {
+	if j == nil {
+		return
+	}
+	delete(j.Installed, name)
+	j.Failed[name] = err.Error()
+}
+
+// write writes the journal to filename. When done is set and nothing failed, the journal is removed instead.
+func (j *InstallJournal) write(filename string, done bool) error {
+	if j == nil {
+		return nil
+	}
+	if done && len(j.Failed) == 0 {
+		err := os.Remove(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,41 @@
+package bindown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// AddDependencyFromJSON adds the dependency described by data, which is a json object with the same properties as a
+// dependency in the config file. It is checked against the config schema, and any template it uses must already be
+// in the config.
+func (c *Config) AddDependencyFromJSON(ctx context.Context, name string, data []byte) error {
+	if c.Dependencies[name] != nil {
+		return fmt.Errorf("dependency named %q already exists", name)
+	}
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return fmt.Errorf("dependency is not a valid json object: %w", err)
+	}
+	// wrap it in a config so the schema reports problems with the same paths as the config file
+	cfgData, err := json.Marshal(map[string]any{
+		"dependencies": map[string]any{name: raw},
+	})
+	if err != nil {
+		return err
+	}
+	depCfg, err := ConfigFromYAML(ctx, cfgData)
+	if err != nil {
+		return err
+	}
+	dep := depCfg.Dependencies[name]
+	if dep.Template != nil && c.Templates[*dep.Template] == nil {
+		return &ConfigError{Err: fmt.Errorf("dependency %q uses template %q, which isn't in the config", name, *dep.Template)}
+	}
+	if c.Dependencies == nil {
+		c.Dependencies = map[string]*Dependency{}
+	}
+	c.Dependencies[name] = dep
+	return nil
+}
@@ -1,9 +1,16 @@
 package bindown
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/santhosh-tekuri/jsonschema/v5"
 	"gopkg.in/yaml.v3"
@@ -12,12 +19,61 @@ import (
 //go:embed bindown.schema.json
 var jsonSchemaText string
 
+// DefaultSchemaURL is where the json schema for config files is published.
+const DefaultSchemaURL = "https://willabides.github.io/bindown/bindown.schema.json"
+
+var (
+	schemaIDRegexp     = regexp.MustCompile(`"\$id":\s*"[^"]*"`)
+	schemaHeaderRegexp = regexp.MustCompile(`^#\s*yaml-language-server:\s*\$schema=(\S+)`)
+)
+
+// JSONSchema returns the json schema for config files. When id isn't empty it replaces the schema's $id.
+func JSONSchema(id string) []byte {
+	if id == "" {
+		return []byte(jsonSchemaText)
+	}
+	idJSON, err := json.Marshal(id)
+	if err != nil {
+		panic(err)
+	}
+	replaced := false
+	return schemaIDRegexp.ReplaceAllFunc([]byte(jsonSchemaText), func(b []byte) []byte {
+		if replaced {
+			return b
+		}
+		replaced = true
+		return append([]byte(`"$id": `), idJSON...)
+	})
+}
+
+// SchemaHeader returns the yaml-language-server modeline that associates a yaml file with the schema at schemaURL.
+func SchemaHeader(schemaURL string) string {
+	return "# yaml-language-server: $schema=" + schemaURL
+}
+
+// schemaHeaderURL returns the schema url from a modeline on the first line of a yaml config.
+func schemaHeaderURL(data []byte) string {
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	match := schemaHeaderRegexp.FindSubmatch(bytes.TrimSpace(firstLine))
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
 // validateConfig checks whether cfg meets the json schema.
 func validateConfig(ctx context.Context, cfg []byte) error {
-	var val any
-	err := yaml.Unmarshal(cfg, &val)
+	var root yaml.Node
+	err := yaml.Unmarshal(cfg, &root)
 	if err != nil {
-		return fmt.Errorf("config is not valid yaml (or json)")
+		return fmt.Errorf("config is not valid yaml (or json): %s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	var val any
+	if len(root.Content) > 0 {
+		err = root.Decode(&val)
+		if err != nil {
+			return fmt.Errorf("config is not valid yaml (or json): %s", strings.TrimPrefix(err.Error(), "yaml: "))
+		}
 	}
 	schema, err := jsonschema.CompileString("", jsonSchemaText)
 	if err != nil {
@@ -25,7 +81,144 @@ func validateConfig(ctx context.Context, cfg []byte) error {
 	}
 	err = schema.Validate(val)
 	if err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return &ConfigValidationError{Problems: configProblems(&root, validationErr)}
+		}
 		return fmt.Errorf("invalid config: %w", err)
 	}
 	return nil
 }
+
+// ConfigProblem is a single schema violation in a config file.
+type ConfigProblem struct {
+	// Line and Column locate the offending value in the config file. They are zero when it can't be located.
+	Line   int
+	Column int
+	// Path is the JSON pointer to the offending value.
+	Path    string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", path, p.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", p.Line, p.Column, path, p.Message)
+}
+
+// ConfigValidationError is returned when a config doesn't meet the json schema.
+type ConfigValidationError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, "invalid config:")
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configProblems flattens a validation error into its leaf errors and locates each one in root.
+func configProblems(root *yaml.Node, validationErr *jsonschema.ValidationError) []ConfigProblem {
+	var problems []ConfigProblem
+	seen := map[ConfigProblem]bool{}
+	var walk func(ve *jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) > 0 {
+			for _, cause := range ve.Causes {
+				walk(cause)
+			}
+			return
+		}
+		problem := ConfigProblem{
+			Path:    ve.InstanceLocation,
+			Message: ve.Message,
+		}
+		node := yamlNodeAt(root, ve.InstanceLocation)
+		if node != nil && strings.HasPrefix(ve.Message, "additionalProperties ") {
+			// point at the first unexpected key instead of the object containing it
+			node = unexpectedKeyNode(node, ve.Message)
+		}
+		if node != nil {
+			problem.Line = node.Line
+			problem.Column = node.Column
+		}
+		if seen[problem] {
+			return
+		}
+		seen[problem] = true
+		problems = append(problems, problem)
+	}
+	walk(validationErr)
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// unexpectedKeyNode returns the first key in mapping that is named in an additionalProperties message. It returns
+// mapping when no key matches.
+func unexpectedKeyNode(mapping *yaml.Node, msg string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return mapping
+	}
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if strings.Contains(msg, "'"+mapping.Content[i].Value+"'") {
+			return mapping.Content[i]
+		}
+	}
+	return mapping
+}
+
+// yamlNodeAt returns the node at the json pointer ptr or nil if it doesn't exist.
+func yamlNodeAt(root *yaml.Node, ptr string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if ptr == "" {
+		return node
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					next = node.Content[i+1]
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			node = next
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[idx]
+		default:
+			return nil
+		}
+	}
+	return node
+}
@@ -48,7 +48,9 @@ url_checksums:
   bar: []
 `)
 		err := validateConfig(ctx, cfg)
-		require.Error(t, err)
+		require.EqualError(t, err, `invalid config:
+  line 3, column 18: /dependencies/golangci-lint: expected object, but got string
+  line 10, column 8: /url_checksums/bar: expected string, but got array`)
 	})
 
 	t.Run("invalid json", func(t *testing.T) {
@@ -71,7 +73,33 @@ url_checksums:
   }
 }`)
 		err := validateConfig(ctx, cfg)
-		require.Error(t, err)
+		require.EqualError(t, err, `invalid config:
+  line 4, column 22: /dependencies/golangci-lint: expected object, but got string
+  line 14, column 12: /url_checksums/bar: expected string, but got array`)
+	})
+
+	t.Run("unknown property", func(t *testing.T) {
+		cfg := []byte(`
+dependencies:
+  foo:
+    url: https://example.com/foo
+    bogus: true
+`)
+		err := validateConfig(ctx, cfg)
+		var validationErr *ConfigValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []ConfigProblem{{
+			Line:    5,
+			Column:  5,
+			Path:    "/dependencies/foo",
+			Message: "additionalProperties 'bogus' not allowed",
+		}}, validationErr.Problems)
+	})
+
+	t.Run("yaml syntax error", func(t *testing.T) {
+		cfg := []byte("systems: [")
+		err := validateConfig(ctx, cfg)
+		require.EqualError(t, err, "config is not valid yaml (or json): line 1: did not find expected node content")
 	})
 }
 
@@ -0,0 +1,280 @@
+package bindown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LatestVersion is the "version" var of a dependency that tracks the newest upstream release. Config.Lock resolves it
+// to a concrete version in the lock file, and the dependency is built with that version.
+const LatestVersion = "latest"
+
+// LockFileName is the name of the lock file next to the config file.
+const LockFileName = "bindown.lock"
+
+// lockFileHeader is written at the top of the lock file.
+const lockFileHeader = "# Generated by \"bindown lock\". Do not edit.\n"
+
+// LockFile pins the dependencies that track LatestVersion to the version, urls and checksums resolved by Config.Lock.
+type LockFile struct {
+	Dependencies map[string]*LockedDependency `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+
+	// data is the content of the lock file it was read from, so signatures are checked against what was used.
+	data []byte
+}
+
+// LockedDependency is a dependency's entry in a LockFile.
+type LockedDependency struct {
+	Version string `json:"version" yaml:"version"`
+	// Downloads are the url and checksum of the dependency's download for each system.
+	Downloads map[System]LockedDownload `json:"downloads,omitempty" yaml:"downloads,omitempty"`
+}
+
+// LockedDownload is a download in a LockedDependency.
+type LockedDownload struct {
+	URL      string `json:"url" yaml:"url"`
+	Checksum string `json:"checksum" yaml:"checksum"`
+}
+
+// LockFilePath returns the path of the lock file for the config.
+func (c *Config) LockFilePath() string {
+	return filepath.Join(filepath.Dir(c.Filename), LockFileName)
+}
+
+// readLockFile reads the lock file for the config. It is nil when there is none.
+func (c *Config) readLockFile() error {
+	data, err := os.ReadFile(c.LockFilePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lock := LockFile{data: data}
+	err = yaml.Unmarshal(data, &lock)
+	if err != nil {
+		return &ConfigError{Err: fmt.Errorf("%s: %w", c.LockFilePath(), err)}
+	}
+	c.lock = &lock
+	return nil
+}
+
+// writeLockFile writes c.lock to the lock file.
+func (c *Config) writeLockFile() error {
+	var buf bytes.Buffer
+	buf.WriteString(lockFileHeader)
+	err := EncodeYaml(&buf, c.lock)
+	if err != nil {
+		return err
+	}
+	c.lock.data = buf.Bytes()
+	return os.WriteFile(c.LockFilePath(), buf.Bytes(), 0o644)
+}
+
+// lockedDependency returns depName's entry in the lock file or nil when it has none.
+func (c *Config) lockedDependency(depName string) *LockedDependency {
+	if c.lock == nil {
+		return nil
+	}
+	return c.lock.Dependencies[depName]
+}
+
+// applyLockedVersion sets the version var of a dependency that tracks LatestVersion to its version in the lock file.
+func (c *Config) applyLockedVersion(depName string, dep *Dependency) error {
+	if dep.Vars["version"] != LatestVersion {
+		return nil
+	}
+	locked := c.lockedDependency(depName)
+	if locked == nil {
+		return fmt.Errorf(`dependency %q tracks the latest version but isn't in %s. run "bindown lock"`, depName, LockFileName)
+	}
+	dep.Vars = maps.Clone(dep.Vars)
+	dep.Vars["version"] = locked.Version
+	return nil
+}
+
+// lockedChecksum returns the checksum the lock file has for depName's download from dlURL on system.
+func (c *Config) lockedChecksum(depName string, system System, dlURL string) string {
+	locked := c.lockedDependency(depName)
+	if locked == nil {
+		return ""
+	}
+	download := locked.Downloads[system]
+	if download.URL != dlURL {
+		return ""
+	}
+	return download.Checksum
+}
+
+// LockOpts options for Config.Lock
+type LockOpts struct {
+	DependencyVersionsOpts
+}
+
+// Lock resolves each of deps that tracks LatestVersion to the newest upstream version and writes its version, urls and
+// checksums to the lock file. Other dependencies in deps are locked at their configured version so a signed lock file
+// can cover them. When deps is empty, every dependency that tracks LatestVersion or is already locked is locked and
+// lock file entries for other dependencies are removed. Dependencies whose lock file entry didn't change are left out
+// of the result.
+func (c *Config) Lock(ctx context.Context, deps []string, opts *LockOpts) ([]DependencyUpdate, error) {
+	if opts == nil {
+		opts = &LockOpts{}
+	}
+	floating, err := c.floatingDependencies()
+	if err != nil {
+		return nil, err
+	}
+	all := len(deps) == 0
+	if all {
+		deps = floating
+		for _, name := range c.DependencyNames() {
+			if !slices.Contains(floating, name) && c.lockedDependency(name) != nil {
+				deps = append(deps, name)
+			}
+		}
+	}
+	lock := &LockFile{Dependencies: map[string]*LockedDependency{}}
+	if c.lock != nil {
+		maps.Copy(lock.Dependencies, c.lock.Dependencies)
+	}
+	if all {
+		maps.DeleteFunc(lock.Dependencies, func(name string, _ *LockedDependency) bool {
+			return !slices.Contains(deps, name)
+		})
+	}
+	var updates []DependencyUpdate
+	for _, name := range deps {
+		if c.Dependencies[name] == nil {
+			return nil, c.unknownDependencyError(name)
+		}
+		if !slices.Contains(floating, name) {
+			update, err := c.lockPinnedDependency(name, lock)
+			if err != nil {
+				return nil, err
+			}
+			if update != nil {
+				updates = append(updates, *update)
+			}
+			continue
+		}
+		repo, releases, err := c.dependencyReleases(ctx, name, &opts.DependencyVersionsOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(releases) == 0 {
+			return nil, fmt.Errorf("no versions found for %q", name)
+		}
+		latest := releases[0]
+		previous := lock.Dependencies[name]
+		locked, err := c.lockDependency(name, latest.version, previous)
+		if err != nil {
+			return nil, err
+		}
+		lock.Dependencies[name] = locked
+		if previous != nil && previous.Version == latest.version {
+			continue
+		}
+		update := DependencyUpdate{
+			Name:       name,
+			NewVersion: latest.version,
+			ReleaseURL: repo.releaseURL(latest.tag),
+		}
+		if previous != nil {
+			update.OldVersion = previous.Version
+			for _, r := range releases {
+				if r.version == previous.Version {
+					update.CompareURL = repo.compareURL(r.tag, latest.tag)
+					break
+				}
+			}
+		}
+		updates = append(updates, update)
+	}
+	c.lock = lock
+	return updates, c.writeLockFile()
+}
+
+// lockPinnedDependency adds depName to lock at its configured version. The update is nil when its entry didn't change.
+func (c *Config) lockPinnedDependency(depName string, lock *LockFile) (*DependencyUpdate, error) {
+	dep := c.Dependencies[depName].clone()
+	err := dep.applyTemplate(c.Templates, 0)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	version := dep.Vars["version"]
+	previous := lock.Dependencies[depName]
+	locked, err := c.lockDependency(depName, version, previous)
+	if err != nil {
+		return nil, err
+	}
+	lock.Dependencies[depName] = locked
+	if previous != nil && previous.Version == version && maps.Equal(previous.Downloads, locked.Downloads) {
+		return nil, nil
+	}
+	update := &DependencyUpdate{Name: depName, NewVersion: version}
+	if previous != nil {
+		update.OldVersion = previous.Version
+	}
+	return update, nil
+}
+
+// lockDependency returns the lock file entry for depName at version. Checksums are copied from previous when it has
+// the same url for a system. The others are downloaded.
+func (c *Config) lockDependency(depName, version string, previous *LockedDependency) (*LockedDependency, error) {
+	locked := &LockedDependency{Version: version, Downloads: map[System]LockedDownload{}}
+	// build with the new version and without the old checksums
+	lockBefore := c.lock
+	c.lock = &LockFile{Dependencies: map[string]*LockedDependency{depName: {Version: version}}}
+	defer func() { c.lock = lockBefore }()
+	systems, err := c.DependencySystems(depName)
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]string{}
+	for _, system := range systems {
+		dep, err := c.BuildDependency(depName, system)
+		if err != nil {
+			return nil, err
+		}
+		sum := dep.checksum
+		if sum == "" && previous != nil && previous.Downloads[system].URL == dep.url {
+			sum = previous.Downloads[system].Checksum
+		}
+		if sum == "" {
+			sum = sums[dep.url]
+		}
+		if sum == "" {
+			sum, err = getURLChecksum(dep.url, "", dep.downloader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sums[dep.url] = sum
+		locked.Downloads[system] = LockedDownload{URL: dep.url, Checksum: sum}
+	}
+	return locked, nil
+}
+
+// floatingDependencies returns the names of the dependencies whose version var is LatestVersion.
+func (c *Config) floatingDependencies() ([]string, error) {
+	var names []string
+	for _, name := range c.DependencyNames() {
+		dep := c.Dependencies[name].clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		if dep.Vars["version"] == LatestVersion {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
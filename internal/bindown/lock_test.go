@@ -0,0 +1,128 @@
+package bindown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Lock(t *testing.T) {
+	ctx := context.Background()
+	tags := `[{"name": "v1.0.0"}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Ftool/repository/tags":
+			body = tags
+		case "/group/tool/-/releases/v1.0.0/downloads/tool":
+			body = "tool 1.0.0"
+		case "/group/tool/-/releases/v1.1.0/downloads/tool":
+			body = "tool 1.1.0"
+		case "/other":
+			body = "other"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(body))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "bindown.yml")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+systems: [linux/amd64, darwin/arm64]
+dependencies:
+  tool:
+    url: %[1]s/group/tool/-/releases/v{{.version}}/downloads/tool
+    vars:
+      version: latest
+  other:
+    url: %[1]s/other
+    vars:
+      version: 1.0.0
+`, ts.URL)), 0o644))
+	cfg, err := NewConfig(ctx, cfgFile, true)
+	require.NoError(t, err)
+
+	_, err = cfg.BuildDependency("tool", "linux/amd64")
+	require.EqualError(t, err, `dependency "tool" tracks the latest version but isn't in bindown.lock. run "bindown lock"`)
+
+	// a pinned dependency is locked at its configured version
+	got, err := cfg.Lock(ctx, []string{"other"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []DependencyUpdate{{Name: "other", NewVersion: "1.0.0"}}, got)
+	got, err = cfg.Lock(ctx, []string{"other"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = cfg.UpdateDependencies(ctx, []string{"tool"}, nil)
+	require.EqualError(t, err, `dependency "tool" tracks the latest version. use "bindown lock" to update it`)
+
+	got, err = cfg.Lock(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []DependencyUpdate{{
+		Name:       "tool",
+		NewVersion: "1.0.0",
+		ReleaseURL: ts.URL + "/group/tool/-/releases/v1.0.0",
+	}}, got)
+	sum100 := "803f8788fa63e437af8e2af6d6b426f2b25ecbfffdda7fd7ba3f172ab60b1670"
+	lockData, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf(`# Generated by "bindown lock". Do not edit.
+dependencies:
+  other:
+    version: 1.0.0
+    downloads:
+      darwin/arm64:
+        url: %[1]s/other
+        checksum: d9298a10d1b0735837dc4bd85dac641b0f3cef27a47e5d53a54f2f3f5b2fcffa
+      linux/amd64:
+        url: %[1]s/other
+        checksum: d9298a10d1b0735837dc4bd85dac641b0f3cef27a47e5d53a54f2f3f5b2fcffa
+  tool:
+    version: 1.0.0
+    downloads:
+      darwin/arm64:
+        url: %[1]s/group/tool/-/releases/v1.0.0/downloads/tool
+        checksum: %[2]s
+      linux/amd64:
+        url: %[1]s/group/tool/-/releases/v1.0.0/downloads/tool
+        checksum: %[2]s
+`, ts.URL, sum100), string(lockData))
+	require.Empty(t, cfg.URLChecksums)
+
+	// a new config picks up the lock file
+	cfg, err = NewConfig(ctx, cfgFile, true)
+	require.NoError(t, err)
+	dep, err := cfg.BuildDependency("tool", "linux/amd64")
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", dep.Vars["version"])
+	require.Equal(t, sum100, dep.checksum)
+
+	got, err = cfg.Lock(ctx, []string{"tool"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	tags = `[{"name": "v1.1.0"}, {"name": "v1.0.0"}]`
+	got, err = cfg.Lock(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []DependencyUpdate{{
+		Name:       "tool",
+		OldVersion: "1.0.0",
+		NewVersion: "1.1.0",
+		ReleaseURL: ts.URL + "/group/tool/-/releases/v1.1.0",
+		CompareURL: ts.URL + "/group/tool/-/compare/v1.0.0...v1.1.0",
+	}}, got)
+	dep, err = cfg.BuildDependency("tool", "darwin/arm64")
+	require.NoError(t, err)
+	require.Equal(t, ts.URL+"/group/tool/-/releases/v1.1.0/downloads/tool", dep.url)
+	require.Equal(t, "5c2f2da60d19618cebf87972bd4fe076c91f9e220362048f9e56fdf2eed82e59", dep.checksum)
+}
@@ -0,0 +1,252 @@
+package bindown
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signatures of the lock file are in files next to it with these extensions.
+const (
+	// LockSSHSignatureExt is the extension of an ssh signature made with "ssh-keygen -Y sign -n file".
+	LockSSHSignatureExt = ".sig"
+	// LockMinisignSignatureExt is the extension of a signature made with "minisign -S".
+	LockMinisignSignatureExt = ".minisig"
+)
+
+// sshSignatureNamespace is the namespace lock file ssh signatures are made for.
+const sshSignatureNamespace = "file"
+
+// VerifyLockSignatureOpts provides options for Config.VerifyLockSignature
+type VerifyLockSignatureOpts struct {
+	// PublicKeys are the keys the lock file can be signed with. They are used instead of the config's
+	// LockPublicKeys when set. Keys from the config only catch mistakes because anyone who can change the lock file
+	// can usually change the config too, so a pipeline should pass the keys it trusts here.
+	PublicKeys []string
+}
+
+// VerifyLockSignature checks that the lock file has a valid ssh or minisign signature by one of the public keys.
+// It fails when there is no lock file, no signature or no public key to check it with.
+func (c *Config) VerifyLockSignature(opts *VerifyLockSignatureOpts) error {
+	if opts == nil {
+		opts = &VerifyLockSignatureOpts{}
+	}
+	publicKeys := opts.PublicKeys
+	if len(publicKeys) == 0 {
+		publicKeys = c.LockPublicKeys
+	}
+	if len(publicKeys) == 0 {
+		return &ConfigError{Err: fmt.Errorf("no public keys to verify %s with. add them to lock_public_keys", LockFileName)}
+	}
+	if c.lock == nil {
+		return &ConfigError{Err: fmt.Errorf("%s is required to be signed but doesn't exist", LockFileName)}
+	}
+	lockPath := c.LockFilePath()
+	var errs []error
+	found := false
+	for _, ext := range []string{LockSSHSignatureExt, LockMinisignSignatureExt} {
+		sig, err := os.ReadFile(lockPath + ext)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		verify := verifySSHSignature
+		if ext == LockMinisignSignatureExt {
+			verify = verifyMinisignSignature
+		}
+		err = verify(c.lock.data, sig, publicKeys)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s%s: %w", LockFileName, ext, err))
+	}
+	if !found {
+		return &ConfigError{Err: fmt.Errorf(
+			"%s isn't signed. sign it with ssh-keygen to create %s%s or minisign to create %s%s",
+			LockFileName, LockFileName, LockSSHSignatureExt, LockFileName, LockMinisignSignatureExt,
+		)}
+	}
+	return &ConfigError{Err: errors.Join(errs...)}
+}
+
+// RequireSignedLock verifies the lock file's signature like VerifyLockSignature and then makes the lock file the only
+// source of urls and checksums. Dependencies are built with the checksums the lock file has for them, and building one
+// whose url isn't in the lock file fails. This keeps changes to the unsigned config, like a new url with a matching
+// url_checksums entry, from being installed.
+func (c *Config) RequireSignedLock(opts *VerifyLockSignatureOpts) error {
+	err := c.VerifyLockSignature(opts)
+	if err != nil {
+		return err
+	}
+	c.lockRequired = true
+	return nil
+}
+
+// LockSignatures returns the paths of the lock file's signatures.
+func (c *Config) LockSignatures() []string {
+	var paths []string
+	for _, ext := range []string{LockSSHSignatureExt, LockMinisignSignatureExt} {
+		if FileExists(c.LockFilePath() + ext) {
+			paths = append(paths, c.LockFilePath()+ext)
+		}
+	}
+	return paths
+}
+
+// verifySSHSignature checks that armored is an ssh signature of data by one of publicKeys. The format is described in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func verifySSHSignature(data, armored []byte, publicKeys []string) error {
+	const begin, end = "-----BEGIN SSH SIGNATURE-----", "-----END SSH SIGNATURE-----"
+	text := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(text, begin) || !strings.HasSuffix(text, end) {
+		return fmt.Errorf("not an ssh signature")
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text[len(begin):len(text)-len(end)]), ""))
+	if err != nil {
+		return fmt.Errorf("not an ssh signature: %w", err)
+	}
+	const magic = "SSHSIG"
+	if !bytes.HasPrefix(blob, []byte(magic)) {
+		return fmt.Errorf("not an ssh signature")
+	}
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	err = ssh.Unmarshal(blob[len(magic):], &sig)
+	if err != nil {
+		return fmt.Errorf("not an ssh signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return fmt.Errorf("unsupported ssh signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshSignatureNamespace {
+		return fmt.Errorf("ssh signature is for namespace %q instead of %q", sig.Namespace, sshSignatureNamespace)
+	}
+	var hasher hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		hasher = sha256.New()
+	case "sha512":
+		hasher = sha512.New()
+	default:
+		return fmt.Errorf("unsupported ssh signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	hasher.Write(data)
+	signed := append([]byte(magic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, hasher.Sum(nil)})...)
+	var signature ssh.Signature
+	err = ssh.Unmarshal(sig.Signature, &signature)
+	if err != nil {
+		return fmt.Errorf("not an ssh signature: %w", err)
+	}
+	signer, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return err
+	}
+	trusted := false
+	for _, key := range publicKeys {
+		pub, _, _, _, parseErr := ssh.ParseAuthorizedKey([]byte(key))
+		if parseErr == nil && bytes.Equal(pub.Marshal(), signer.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("signed by %s, which isn't one of the public keys", ssh.FingerprintSHA256(signer))
+	}
+	err = signer.Verify(signed, &signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// verifyMinisignSignature checks that sigFile is a minisign signature of data by one of publicKeys. The format is
+// described in https://jedisct1.github.io/minisign/
+func verifyMinisignSignature(data, sigFile []byte, publicKeys []string) error {
+	lines := strings.Split(strings.TrimSpace(string(sigFile)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("not a minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("not a minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("not a minisign signature")
+	}
+	algorithm, keyID, signature := string(sig[:2]), sig[2:10], sig[10:]
+	message := data
+	switch algorithm {
+	case "Ed":
+	case "ED":
+		// hashed signatures are the default since minisign 0.10
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", algorithm)
+	}
+	var publicKey ed25519.PublicKey
+	for _, key := range publicKeys {
+		pub, ok := parseMinisignPublicKey(key)
+		if ok && bytes.Equal(pub[2:10], keyID) {
+			publicKey = pub[10:]
+			break
+		}
+	}
+	if publicKey == nil {
+		return fmt.Errorf("signed by key id %X, which isn't one of the public keys", reverseBytes(keyID))
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		return fmt.Errorf("invalid signature")
+	}
+	trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ed25519.Verify(publicKey, append(slices.Clone(signature), trustedComment...), globalSig) {
+		return fmt.Errorf("invalid signature of the trusted comment")
+	}
+	return nil
+}
+
+// parseMinisignPublicKey parses a minisign public key or the contents of a minisign.pub file. The result is the
+// algorithm, key id and key.
+func parseMinisignPublicKey(key string) ([]byte, bool) {
+	lines := strings.Split(strings.TrimSpace(key), "\n")
+	pub, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(pub) != 2+8+ed25519.PublicKeySize || string(pub[:2]) != "Ed" {
+		return nil, false
+	}
+	return pub, true
+}
+
+// reverseBytes returns b in reverse order. minisign shows key ids as little-endian numbers.
+func reverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
@@ -0,0 +1,174 @@
+package bindown
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign returns a minisign signature of data by priv along with the public key.
+func minisign(t *testing.T, priv ed25519.PrivateKey, keyID, data []byte) (sigFile, publicKey string) {
+	t.Helper()
+	sum := blake2b.Sum512(data)
+	sig := append(append([]byte("ED"), keyID...), ed25519.Sign(priv, sum[:])...)
+	trustedComment := "timestamp:1700000000\tfile:bindown.lock\thashed"
+	globalSig := ed25519.Sign(priv, append(sig[10:], trustedComment...))
+	sigFile = fmt.Sprintf(
+		"untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(sig), trustedComment, base64.StdEncoding.EncodeToString(globalSig),
+	)
+	pub := append(append([]byte("Ed"), keyID...), priv.Public().(ed25519.PublicKey)...)
+	publicKey = "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(pub)
+	return sigFile, publicKey
+}
+
+func TestConfig_VerifyLockSignature(t *testing.T) {
+	ctx := context.Background()
+	fixtures := filepath.FromSlash("testdata/locksign")
+	lockData, err := os.ReadFile(filepath.Join(fixtures, "bindown.lock"))
+	require.NoError(t, err)
+	sshSig, err := os.ReadFile(filepath.Join(fixtures, "bindown.lock.sig"))
+	require.NoError(t, err)
+	sshKey, err := os.ReadFile(filepath.Join(fixtures, "id_ed25519.pub"))
+	require.NoError(t, err)
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherKey := minisign(t, otherPriv, []byte("otherkey"), lockData)
+
+	setup := func(t *testing.T, lock string, sigs map[string]string) *Config {
+		t.Helper()
+		dir := t.TempDir()
+		cfgFile := filepath.Join(dir, "bindown.yml")
+		require.NoError(t, os.WriteFile(cfgFile, []byte(fmt.Sprintf("lock_public_keys: [%q]\n", sshKey)), 0o644))
+		if lock != "" {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName), []byte(lock), 0o644))
+		}
+		for ext, sig := range sigs {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName+ext), []byte(sig), 0o644))
+		}
+		cfg, err := NewConfig(ctx, cfgFile, true)
+		require.NoError(t, err)
+		return cfg
+	}
+
+	t.Run("ssh", func(t *testing.T) {
+		cfg := setup(t, string(lockData), map[string]string{LockSSHSignatureExt: string(sshSig)})
+		require.NoError(t, cfg.VerifyLockSignature(nil))
+
+		err := cfg.VerifyLockSignature(&VerifyLockSignatureOpts{PublicKeys: []string{otherKey}})
+		require.ErrorContains(t, err, "bindown.lock.sig: signed by SHA256:")
+		require.ErrorContains(t, err, "which isn't one of the public keys")
+	})
+
+	t.Run("minisign", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		sig, key := minisign(t, priv, []byte("lockkey1"), lockData)
+		cfg := setup(t, string(lockData), map[string]string{LockMinisignSignatureExt: sig})
+		require.NoError(t, cfg.VerifyLockSignature(&VerifyLockSignatureOpts{PublicKeys: []string{key}}))
+
+		err = cfg.VerifyLockSignature(nil)
+		require.ErrorContains(t, err, "bindown.lock.minisig: signed by key id 3179656B6B636F6C, which isn't one of the public keys")
+	})
+
+	t.Run("modified lock", func(t *testing.T) {
+		modified := string(lockData) + "  other:\n    version: 2.0.0\n"
+		cfg := setup(t, modified, map[string]string{LockSSHSignatureExt: string(sshSig)})
+		err := cfg.VerifyLockSignature(nil)
+		require.ErrorContains(t, err, "bindown.lock.sig: invalid signature")
+
+		_, priv, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		sig, key := minisign(t, priv, []byte("lockkey1"), lockData)
+		cfg = setup(t, modified, map[string]string{LockMinisignSignatureExt: sig})
+		err = cfg.VerifyLockSignature(&VerifyLockSignatureOpts{PublicKeys: []string{key}})
+		require.EqualError(t, err, "bindown.lock.minisig: invalid signature")
+	})
+
+	t.Run("not signed", func(t *testing.T) {
+		cfg := setup(t, string(lockData), nil)
+		err := cfg.VerifyLockSignature(nil)
+		require.EqualError(t, err, "bindown.lock isn't signed. sign it with ssh-keygen to create bindown.lock.sig or minisign to create bindown.lock.minisig")
+	})
+
+	t.Run("no lock", func(t *testing.T) {
+		cfg := setup(t, "", map[string]string{LockSSHSignatureExt: string(sshSig)})
+		err := cfg.VerifyLockSignature(nil)
+		require.EqualError(t, err, "bindown.lock is required to be signed but doesn't exist")
+	})
+
+	t.Run("no public keys", func(t *testing.T) {
+		cfg := setup(t, string(lockData), map[string]string{LockSSHSignatureExt: string(sshSig)})
+		cfg.LockPublicKeys = nil
+		err := cfg.VerifyLockSignature(nil)
+		require.EqualError(t, err, "no public keys to verify bindown.lock with. add them to lock_public_keys")
+	})
+}
+
+func TestConfig_RequireSignedLock(t *testing.T) {
+	ctx := context.Background()
+	lockedSum := "803f8788fa63e437af8e2af6d6b426f2b25ecbfffdda7fd7ba3f172ab60b1670"
+	lock := fmt.Sprintf(`dependencies:
+  tool:
+    version: 1.0.0
+    downloads:
+      linux/amd64:
+        url: https://example.com/tool-1.0.0
+        checksum: %s
+`, lockedSum)
+	_, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sig, key := minisign(t, priv, []byte("lockkey1"), []byte(lock))
+
+	setup := func(t *testing.T, version string) *Config {
+		t.Helper()
+		dir := t.TempDir()
+		cfgFile := filepath.Join(dir, "bindown.yml")
+		require.NoError(t, os.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+dependencies:
+  tool:
+    url: https://example.com/tool-{{ .version }}
+    vars:
+      version: %s
+  other:
+    url: https://example.com/other
+url_checksums:
+  https://example.com/tool-%s: 5c2f2da60d19618cebf87972bd4fe076c91f9e220362048f9e56fdf2eed82e59
+  https://example.com/other: 5c2f2da60d19618cebf87972bd4fe076c91f9e220362048f9e56fdf2eed82e59
+`, version, version)), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName), []byte(lock), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, LockFileName+LockMinisignSignatureExt), []byte(sig), 0o644))
+		cfg, err := NewConfig(ctx, cfgFile, true)
+		require.NoError(t, err)
+		require.NoError(t, cfg.RequireSignedLock(&VerifyLockSignatureOpts{PublicKeys: []string{key}}))
+		return cfg
+	}
+
+	t.Run("locked checksum wins", func(t *testing.T) {
+		cfg := setup(t, "1.0.0")
+		dep, err := cfg.BuildDependency("tool", "linux/amd64")
+		require.NoError(t, err)
+		require.Equal(t, lockedSum, dep.checksum)
+	})
+
+	t.Run("url changed in config", func(t *testing.T) {
+		cfg := setup(t, "1.0.1")
+		_, err := cfg.BuildDependency("tool", "linux/amd64")
+		require.EqualError(t, err, `tool on linux/amd64 isn't in the signed bindown.lock. run "bindown lock tool" and sign it again`)
+	})
+
+	t.Run("not locked", func(t *testing.T) {
+		cfg := setup(t, "1.0.0")
+		_, err := cfg.BuildDependency("other", "linux/amd64")
+		require.EqualError(t, err, `other on linux/amd64 isn't in the signed bindown.lock. run "bindown lock other" and sign it again`)
+		_, err = cfg.BuildDependency("tool", "darwin/arm64")
+		require.EqualError(t, err, `tool on darwin/arm64 isn't in the signed bindown.lock. run "bindown lock tool" and sign it again`)
+	})
+}
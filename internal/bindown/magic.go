@@ -0,0 +1,130 @@
+package bindown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+
+	"github.com/mholt/archiver/v3"
+)
+
+// magicHeaderSize is how many leading bytes are needed to identify any of the supported formats.
+const magicHeaderSize = 512
+
+// archiveMagic describes the leading bytes of an archive or compression format.
+type archiveMagic struct {
+	format string
+	offset int
+	magic  [][]byte
+}
+
+// expectedMagic returns the magic bytes for the format implied by filename's extension. It returns nil when the
+// format is unknown or has no reliable signature.
+func expectedMagic(filename string) *archiveMagic {
+	if isMSI(filename) {
+		return &archiveMagic{format: "msi", magic: [][]byte{{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}}}
+	}
+	byExt, err := archiver.ByExtension(filename)
+	if err != nil {
+		return nil
+	}
+	gzMagic := []byte{0x1f, 0x8b}
+	bz2Magic := []byte("BZh")
+	xzMagic := []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic := []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic := []byte{0x04, 0x22, 0x4d, 0x18}
+	szMagic := []byte("\xff\x06\x00\x00sNaPpY")
+	switch byExt.(type) {
+	case *archiver.Tar:
+		return &archiveMagic{format: "tar", offset: 257, magic: [][]byte{[]byte("ustar")}}
+	case *archiver.TarGz, *archiver.Gz:
+		return &archiveMagic{format: "gzip", magic: [][]byte{gzMagic}}
+	case *archiver.TarBz2, *archiver.Bz2:
+		return &archiveMagic{format: "bzip2", magic: [][]byte{bz2Magic}}
+	case *archiver.TarXz, *archiver.Xz:
+		return &archiveMagic{format: "xz", magic: [][]byte{xzMagic}}
+	case *archiver.TarZstd, *archiver.Zstd:
+		return &archiveMagic{format: "zstd", magic: [][]byte{zstdMagic}}
+	case *archiver.TarLz4, *archiver.Lz4:
+		return &archiveMagic{format: "lz4", magic: [][]byte{lz4Magic}}
+	case *archiver.TarSz, *archiver.Snappy:
+		return &archiveMagic{format: "snappy", magic: [][]byte{szMagic}}
+	case *archiver.Zip:
+		return &archiveMagic{format: "zip", magic: [][]byte{
+			[]byte("PK\x03\x04"),
+			[]byte("PK\x05\x06"),
+			[]byte("PK\x07\x08"),
+		}}
+	case *archiver.Rar:
+		return &archiveMagic{format: "rar", magic: [][]byte{[]byte("Rar!\x1a\x07")}}
+	default:
+		return nil
+	}
+}
+
+// checkMagic returns an error when header, the first bytes of filename, doesn't match the format implied by
+// filename's extension.
+func checkMagic(filename string, header []byte) error {
+	want := expectedMagic(filename)
+	if want == nil {
+		return nil
+	}
+	if len(header) > want.offset {
+		got := header[want.offset:]
+		for _, m := range want.magic {
+			if bytes.HasPrefix(got, m) {
+				return nil
+			}
+		}
+	}
+	if looksLikeHTML(header) {
+		return fmt.Errorf("got an HTML page instead of a %s archive for %s", want.format, filename)
+	}
+	return fmt.Errorf("%s is not a valid %s archive", filename, want.format)
+}
+
+// checkFileMagic is checkMagic for a downloaded file. name is the file name the format is taken from.
+func checkFileMagic(name, path string) (errOut error) {
+	if expectedMagic(name) == nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, f.Close)
+	header := make([]byte, magicHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	return checkMagic(name, header[:n])
+}
+
+// checkContentType returns an error when an archive is served as an HTML page.
+func checkContentType(name, contentType string) error {
+	want := expectedMagic(name)
+	if want == nil || contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return fmt.Errorf("got an HTML page instead of a %s archive for %s", want.format, name)
+	}
+	return nil
+}
+
+func looksLikeHTML(header []byte) bool {
+	header = bytes.TrimLeft(header, "\xef\xbb\xbf \t\r\n")
+	for _, prefix := range []string{"<!doctype html", "<html", "<head", "<body", "<?xml"} {
+		if len(header) >= len(prefix) && bytes.EqualFold(header[:len(prefix)], []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package bindown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkMagic(t *testing.T) {
+	for _, td := range []struct {
+		name    string
+		header  string
+		wantErr string
+	}{
+		{name: "foo.tar.gz", header: "\x1f\x8b\x08\x00"},
+		{name: "foo.zip", header: "PK\x03\x04"},
+		{name: "foo.tar.xz", header: "\xfd7zXZ\x00"},
+		{name: "foo.exe", header: "MZ"},
+		{name: "foo", header: "<html>"},
+		{name: "foo.tar.gz", header: "\n<!DOCTYPE html>", wantErr: "got an HTML page instead of a gzip archive for foo.tar.gz"},
+		{name: "foo.zip", header: "not found", wantErr: "foo.zip is not a valid zip archive"},
+		{name: "foo.tar.bz2", header: "", wantErr: "foo.tar.bz2 is not a valid bzip2 archive"},
+	} {
+		t.Run(td.name+" "+td.header, func(t *testing.T) {
+			err := checkMagic(td.name, []byte(td.header))
+			if td.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, td.wantErr)
+		})
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		header := make([]byte, magicHeaderSize)
+		copy(header[257:], "ustar")
+		require.NoError(t, checkMagic("foo.tar", header))
+		require.Error(t, checkMagic("foo.tar", header[:200]))
+	})
+}
+
+func Test_checkFileMagic(t *testing.T) {
+	require.NoError(t, checkFileMagic(
+		"fooinroot.tar.gz",
+		filepath.Join("testdata", "downloadables", "fooinroot.tar.gz"),
+	))
+	file := filepath.Join(t.TempDir(), "download")
+	require.NoError(t, os.WriteFile(file, []byte("<html><body>rate limited</body></html>"), 0o600))
+	require.EqualError(t, checkFileMagic("foo.tgz", file), "got an HTML page instead of a gzip archive for foo.tgz")
+}
+
+func Test_checkContentType(t *testing.T) {
+	require.NoError(t, checkContentType("foo.tar.gz", "application/octet-stream"))
+	require.NoError(t, checkContentType("foo.tar.gz", ""))
+	require.NoError(t, checkContentType("foo", "text/html"))
+	require.EqualError(t,
+		checkContentType("foo.zip", "text/html; charset=utf-8"),
+		"got an HTML page instead of a zip archive for foo.zip",
+	)
+}
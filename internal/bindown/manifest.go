@@ -0,0 +1,84 @@
+package bindown
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// InstallManifestFile is the name of the manifest InstallDependencies writes to the install directory when
+// ConfigInstallDependenciesOpts.Manifest is set.
+const InstallManifestFile = "bindown-manifest.json"
+
+// InstallManifestVersion is the version of the InstallManifest format. It only changes when a change would break
+// existing readers.
+const InstallManifestVersion = 1
+
+// InstallManifest lists the tools in an install directory with where they came from. It is meant for supply-chain
+// scanners, so it doesn't use bindown's config format.
+type InstallManifest struct {
+	Version int    `json:"version"`
+	System  System `json:"system"`
+	// Tools are sorted by name.
+	Tools []ManifestTool `json:"tools"`
+}
+
+// ManifestTool is a tool in an InstallManifest.
+type ManifestTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// SourceURL is the url the tool was downloaded from.
+	SourceURL string `json:"source_url"`
+	// Checksum is the checksum of the download like "sha256:<hex>". It is empty when the download had no checksum.
+	Checksum string `json:"checksum,omitempty"`
+	// License is an SPDX license identifier.
+	License  string `json:"license,omitempty"`
+	Homepage string `json:"homepage,omitempty"`
+	// Path is the installed tool relative to the manifest.
+	Path string `json:"path"`
+}
+
+// writeInstallManifest writes the manifest for the dependencies in installed to dir. installed maps dependency names
+// to the paths they were installed to.
+func (c *Config) writeInstallManifest(dir string, system System, installed map[string]string) error {
+	manifest := InstallManifest{
+		Version: InstallManifestVersion,
+		System:  system,
+		Tools:   []ManifestTool{},
+	}
+	for _, name := range sortedKeys(installed) {
+		dep, err := c.buildSupported(name, system, nil)
+		if err != nil {
+			return err
+		}
+		err = c.lookupServiceChecksum(dep)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, installed[name])
+		if err != nil {
+			return err
+		}
+		tool := ManifestTool{
+			Name:      name,
+			Version:   dep.Vars["version"],
+			SourceURL: dep.url,
+			License:   stringValue(dep.License),
+			Homepage:  stringValue(dep.Homepage),
+			Path:      filepath.ToSlash(rel),
+		}
+		if dep.checksum != "" {
+			tool.Checksum = "sha256:" + dep.checksum
+		}
+		manifest.Tools = append(manifest.Tools, tool)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, InstallManifestFile), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,89 @@
+package bindown
+
+import (
+	"sync"
+	"time"
+)
+
+// InstallMetrics collects a DependencyMetrics for each dependency installed with it in ConfigInstallDependenciesOpts.
+type InstallMetrics struct {
+	mu           sync.Mutex
+	Dependencies []*DependencyMetrics `json:"dependencies"`
+}
+
+func (m *InstallMetrics) start(dep *Dependency) *DependencyMetrics {
+	if m == nil {
+		return nil
+	}
+	dm := &DependencyMetrics{
+		Name:   dep.name,
+		System: dep.system,
+		URL:    redactURL(dep.url),
+		start:  time.Now(),
+	}
+	m.mu.Lock()
+	m.Dependencies = append(m.Dependencies, dm)
+	m.mu.Unlock()
+	return dm
+}
+
+// DependencyMetrics is how long each step of installing a dependency took and whether it came from the cache.
+type DependencyMetrics struct {
+	Name   string `json:"name"`
+	System System `json:"system"`
+	URL    string `json:"url"`
+	// DownloadBytes is the size of the download. It is zero for streamed downloads that were already extracted.
+	DownloadBytes int64 `json:"download_bytes"`
+	// DownloadCached is true when the download was already in the cache.
+	DownloadCached bool `json:"download_cached"`
+	// DownloadMS is how long it took to download the file or check the cached one.
+	DownloadMS int64 `json:"download_ms"`
+	// ExtractCached is true when the extracted download was already in the cache.
+	ExtractCached bool `json:"extract_cached"`
+	// ExtractMS is how long it took to extract the download or check the cached extraction. Streamed downloads are
+	// extracted while they download, so this is included in DownloadMS.
+	ExtractMS int64 `json:"extract_ms"`
+	// Streamed is true when the download was extracted while it downloaded.
+	Streamed bool `json:"streamed,omitempty"`
+	// TotalMS is how long the whole install took.
+	TotalMS int64  `json:"total_ms"`
+	Error   string `json:"error,omitempty"`
+
+	start time.Time
+}
+
+func (m *DependencyMetrics) download(cached bool, start time.Time, size int64) {
+	if m == nil {
+		return
+	}
+	m.DownloadCached = cached
+	m.DownloadMS = time.Since(start).Milliseconds()
+	m.DownloadBytes = size
+}
+
+func (m *DependencyMetrics) extract(cached bool, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.ExtractCached = cached
+	m.ExtractMS = time.Since(start).Milliseconds()
+}
+
+func (m *DependencyMetrics) stream(cached bool, start time.Time, size int64) {
+	if m == nil {
+		return
+	}
+	m.Streamed = true
+	m.download(cached, start, size)
+	m.ExtractCached = cached
+}
+
+func (m *DependencyMetrics) finish(err error) {
+	if m == nil {
+		return
+	}
+	m.TotalMS = time.Since(m.start).Milliseconds()
+	if err != nil {
+		m.Error = err.Error()
+	}
+}
@@ -0,0 +1,39 @@
+package bindown
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// RunningVersion is the version of the bindown executable. Configs with a min_bindown_version newer than
+// RunningVersion fail to load. The check is skipped when RunningVersion is empty, as it is for development builds.
+var RunningVersion string
+
+// checkMinBindownVersion returns an error when the config in data has a min_bindown_version newer than
+// RunningVersion. Configs that can't be parsed are left for validateConfig to report.
+func checkMinBindownVersion(data []byte) error {
+	if RunningVersion == "" {
+		return nil
+	}
+	var cfg struct {
+		MinBindownVersion string `yaml:"min_bindown_version"`
+	}
+	err := yaml.Unmarshal(data, &cfg)
+	if err != nil || cfg.MinBindownVersion == "" {
+		return nil
+	}
+	minVersion, err := semver.NewVersion(cfg.MinBindownVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min_bindown_version %q", cfg.MinBindownVersion)
+	}
+	running, err := semver.NewVersion(RunningVersion)
+	if err != nil {
+		return nil
+	}
+	if running.LessThan(minVersion) {
+		return fmt.Errorf("this config requires a newer bindown. please upgrade bindown to >= %s (this is %s)", cfg.MinBindownVersion, RunningVersion)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package bindown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkMinBindownVersion(t *testing.T) {
+	ctx := context.Background()
+	orig := RunningVersion
+	t.Cleanup(func() { RunningVersion = orig })
+
+	RunningVersion = "4.9.0"
+	_, err := ConfigFromYAML(ctx, []byte(`
+min_bindown_version: 4.10.0
+some_new_feature: true
+`))
+	require.EqualError(t, err, "this config requires a newer bindown. please upgrade bindown to >= 4.10.0 (this is 4.9.0)")
+
+	cfg, err := ConfigFromYAML(ctx, []byte(`min_bindown_version: 4.9.0`))
+	require.NoError(t, err)
+	require.Equal(t, "4.9.0", cfg.MinBindownVersion)
+
+	_, err = ConfigFromYAML(ctx, []byte(`min_bindown_version: latest`))
+	require.EqualError(t, err, `invalid min_bindown_version "latest"`)
+
+	// development builds don't know their version
+	RunningVersion = ""
+	_, err = ConfigFromYAML(ctx, []byte(`min_bindown_version: 99.0.0`))
+	require.NoError(t, err)
+}
@@ -0,0 +1,315 @@
+package bindown
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ociHTTPClient is the client used to talk to OCI registries when it isn't nil. It is a var so tests can trust a test
+// server.
+var ociHTTPClient *http.Client
+
+func ociDo(req *http.Request) (*http.Response, error) {
+	if ociHTTPClient != nil {
+		return ociHTTPClient.Do(req)
+	}
+	return defaultHTTPClient().Do(req)
+}
+
+// maxOCIConfigSize limits the size of a config pulled from an OCI registry.
+const maxOCIConfigSize = 10 << 20
+
+var ociManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ociReference is a parsed oci://<registry>/<repository>[:<tag>|@<digest>] reference.
+type ociReference struct {
+	registry   string
+	repository string
+	// reference is a tag or digest
+	reference string
+}
+
+func parseOCIReference(src string) (*ociReference, error) {
+	rest, ok := strings.CutPrefix(src, "oci://")
+	if !ok {
+		return nil, fmt.Errorf("%q is not an oci:// reference", src)
+	}
+	registry, repository, ok := strings.Cut(rest, "/")
+	if !ok || registry == "" || repository == "" {
+		return nil, fmt.Errorf("invalid oci reference %q", src)
+	}
+	ref := &ociReference{registry: registry, repository: repository, reference: "latest"}
+	if repo, digest, found := strings.Cut(repository, "@"); found {
+		ref.repository, ref.reference = repo, digest
+	} else if i := strings.LastIndex(repository, ":"); i > 0 {
+		ref.repository, ref.reference = repository[:i], repository[i+1:]
+	}
+	if ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid oci reference %q", src)
+	}
+	return ref, nil
+}
+
+// ociManifest is the part of an OCI image manifest needed to find the config file.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// configFromOCI pulls a config from an OCI artifact. The artifact must have a single layer or a layer with a yaml or
+// json file name like the ones created by "oras push".
+func configFromOCI(ctx context.Context, src string) (*Config, error) {
+	data, err := ociConfigData(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigFromYAML(ctx, data)
+}
+
+// ociConfigData returns the content of the config layer of an OCI artifact.
+func ociConfigData(ctx context.Context, src string) ([]byte, error) {
+	ref, err := parseOCIReference(src)
+	if err != nil {
+		return nil, err
+	}
+	client := &ociClient{ref: ref, creds: dockerCredentials(ref.registry)}
+	data, err := client.get(ctx, "manifests/"+ref.reference, ociManifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	// a digest pins the manifest, and the manifest pins the layer, but only when the registry's answer is checked
+	if strings.Contains(ref.reference, ":") {
+		err = checkOCIDigest(ref.reference, data, src)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var manifest ociManifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %w", src, err)
+	}
+	layer, err := manifest.configLayer()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	data, err = client.get(ctx, "blobs/"+layer.Digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOCIDigest(layer.Digest, data, src)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkOCIDigest returns an error unless data has the digest from src.
+func checkOCIDigest(digest string, data []byte, src string) error {
+	algo, want, _ := strings.Cut(digest, ":")
+	if algo != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %q in %s", algo, src)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != want {
+		return fmt.Errorf("digest mismatch for %s in %s", digest, src)
+	}
+	return nil
+}
+
+// configLayer returns the layer containing the config.
+func (m *ociManifest) configLayer() (*ociDescriptor, error) {
+	if len(m.Layers) == 1 {
+		return &m.Layers[0], nil
+	}
+	for i := range m.Layers {
+		switch path.Ext(m.Layers[i].Annotations["org.opencontainers.image.title"]) {
+		case ".yaml", ".yml", ".json":
+			return &m.Layers[i], nil
+		}
+	}
+	return nil, errors.New("no layer with a yaml or json config")
+}
+
+// ociClient makes requests to a repository in an OCI registry.
+type ociClient struct {
+	ref *ociReference
+	// creds is "user:password" from the docker config or empty for anonymous access
+	creds string
+	token string
+}
+
+// get returns the body of the registry api endpoint under /v2/<repository>/. It authenticates when the registry asks
+// for it.
+func (c *ociClient) get(ctx context.Context, endpoint string, accept []string) ([]byte, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", c.ref.registry, c.ref.repository, endpoint)
+	resp, err := c.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		err = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		err = c.authenticate(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.do(ctx, u, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &downloadError{err: fmt.Errorf("failed getting %s: %s", u, resp.Status)}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxOCIConfigSize))
+}
+
+func (c *ociClient) do(ctx context.Context, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+	return ociDo(req)
+}
+
+var challengeParamExp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate sets the Authorization header value for a WWW-Authenticate challenge. Bearer challenges exchange the
+// credentials, if any, for a token.
+func (c *ociClient) authenticate(ctx context.Context, challenge string) (errOut error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(c.creds))
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.creds == "" {
+			return fmt.Errorf("%s requires credentials. use docker login", c.ref.registry)
+		}
+		c.token = basic
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported authentication challenge from %s: %q", c.ref.registry, challenge)
+	}
+	values := map[string]string{}
+	for _, m := range challengeParamExp.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	if values["realm"] == "" {
+		return fmt.Errorf("no realm in authentication challenge from %s", c.ref.registry)
+	}
+	tokenURL, err := url.Parse(values["realm"])
+	if err != nil {
+		return err
+	}
+	query := tokenURL.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", c.ref.repository)
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+	if c.creds != "" {
+		req.Header.Set("Authorization", basic)
+	}
+	resp, err := ociDo(req)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed authenticating to %s: %s", c.ref.registry, resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return err
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return fmt.Errorf("no token in authentication response from %s", c.ref.registry)
+	}
+	c.token = "Bearer " + token
+	return nil
+}
+
+// dockerCredentials returns "user:password" for registry from the docker config file written by "docker login". It
+// returns an empty string when there are no stored credentials. Credential helpers aren't supported.
+func dockerCredentials(registry string) string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &cfg) != nil {
+		return ""
+	}
+	for _, key := range []string{registry, "https://" + registry, "http://" + registry} {
+		auth, ok := cfg.Auths[key]
+		if !ok || auth.Auth == "" {
+			continue
+		}
+		creds, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err == nil {
+			return string(creds)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package bindown
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveOCIConfig serves cfg as the only layer of bindown/templates:v1 and returns the manifest's digest. The manifest
+// is served for any digest, like a registry that can't be trusted. When creds isn't empty the registry requires a
+// bearer token that is only handed out for those credentials.
+func serveOCIConfig(t *testing.T, cfg, creds string) (*httptest.Server, string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(cfg))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]any{{
+			"mediaType":   "application/vnd.oci.image.layer.v1.tar",
+			"digest":      digest,
+			"size":        len(cfg),
+			"annotations": map[string]string{"org.opencontainers.image.title": "bindown.yaml"},
+		}},
+	})
+	require.NoError(t, err)
+	manifestSum := sha256.Sum256(manifest)
+	manifestDigest := "sha256:" + hex.EncodeToString(manifestSum[:])
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.Header.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(creds)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "repository:bindown/templates:pull", r.URL.Query().Get("scope"))
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{"token": "tkn"}))
+			return
+		}
+		if creds != "" && r.Header.Get("Authorization") != "Bearer tkn" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test",scope="repository:bindown/templates:pull"`, ts.URL,
+			))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/bindown/templates/manifests/v1",
+			strings.HasPrefix(r.URL.Path, "/v2/bindown/templates/manifests/sha256:"):
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.manifest.v1+json")
+			_, err := w.Write(manifest)
+			assert.NoError(t, err)
+		case r.URL.Path == "/v2/bindown/templates/blobs/"+digest:
+			_, err := w.Write([]byte(cfg))
+			assert.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	orig := ociHTTPClient
+	ociHTTPClient = ts.Client()
+	t.Cleanup(func() { ociHTTPClient = orig })
+	return ts, manifestDigest
+}
+
+func TestConfigFromOCI(t *testing.T) {
+	ctx := context.Background()
+	cfg := `
+templates:
+  foo:
+    url: foo-{{ .os }}-{{ .arch }}-{{ .version }}
+`
+
+	t.Run("anonymous", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+		ts, _ := serveOCIConfig(t, cfg, "")
+		src := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/bindown/templates:v1"
+		got, err := NewConfig(ctx, src, true)
+		require.NoError(t, err)
+		require.Equal(t, "foo-{{ .os }}-{{ .arch }}-{{ .version }}", *got.Templates["foo"].URL)
+	})
+
+	t.Run("docker credentials", func(t *testing.T) {
+		ts, _ := serveOCIConfig(t, cfg, "user:pass")
+		registry := strings.TrimPrefix(ts.URL, "https://")
+		dockerDir := t.TempDir()
+		t.Setenv("DOCKER_CONFIG", dockerDir)
+		src := "oci://" + registry + "/bindown/templates:v1"
+
+		_, err := NewConfig(ctx, src, true)
+		require.ErrorContains(t, err, "failed authenticating to "+registry)
+
+		dockerCfg := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`,
+			registry, base64.StdEncoding.EncodeToString([]byte("user:pass")),
+		)
+		require.NoError(t, os.WriteFile(filepath.Join(dockerDir, "config.json"), []byte(dockerCfg), 0o600))
+		got, err := NewConfig(ctx, src, true)
+		require.NoError(t, err)
+		require.Contains(t, got.Templates, "foo")
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+		ts, manifestDigest := serveOCIConfig(t, cfg, "")
+		repo := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/bindown/templates"
+		got, err := NewConfig(ctx, repo+"@"+manifestDigest, true)
+		require.NoError(t, err)
+		require.Contains(t, got.Templates, "foo")
+
+		other := "sha256:" + strings.Repeat("0", 64)
+		_, err = NewConfig(ctx, repo+"@"+other, true)
+		require.EqualError(t, err, fmt.Sprintf("digest mismatch for %s in %s@%s", other, repo, other))
+	})
+
+	t.Run("missing tag", func(t *testing.T) {
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+		ts, _ := serveOCIConfig(t, cfg, "")
+		src := "oci://" + strings.TrimPrefix(ts.URL, "https://") + "/bindown/templates"
+		_, err := NewConfig(ctx, src, true)
+		require.ErrorContains(t, err, "/v2/bindown/templates/manifests/latest: 404 Not Found")
+	})
+}
+
+func TestParseOCIReference(t *testing.T) {
+	for _, td := range []struct {
+		src     string
+		want    ociReference
+		wantErr bool
+	}{
+		{src: "oci://ghcr.io/org/templates", want: ociReference{"ghcr.io", "org/templates", "latest"}},
+		{src: "oci://ghcr.io/org/templates:v1", want: ociReference{"ghcr.io", "org/templates", "v1"}},
+		{src: "oci://localhost:5000/templates:v1", want: ociReference{"localhost:5000", "templates", "v1"}},
+		{src: "oci://localhost:5000/templates", want: ociReference{"localhost:5000", "templates", "latest"}},
+		{src: "oci://ghcr.io/org/templates@sha256:abc", want: ociReference{"ghcr.io", "org/templates", "sha256:abc"}},
+		{src: "oci://ghcr.io", wantErr: true},
+		{src: "oci://ghcr.io/org/templates:", wantErr: true},
+	} {
+		t.Run(td.src, func(t *testing.T) {
+			got, err := parseOCIReference(td.src)
+			if td.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.want, *got)
+		})
+	}
+}
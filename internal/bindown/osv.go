@@ -0,0 +1,389 @@
+package bindown
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// osvAPIURL is the OSV api used to look up vulnerabilities. It is a var so tests can point it at a test server.
+var osvAPIURL = "https://api.osv.dev"
+
+// OSVPackage identifies a dependency in the OSV vulnerability database.
+type OSVPackage struct {
+	// The OSV ecosystem the package is in like "Go" or "npm".
+	Ecosystem string `json:"ecosystem" yaml:"ecosystem"`
+
+	// The name of the package in its ecosystem like "github.com/cli/cli/v2".
+	Name string `json:"name" yaml:"name"`
+}
+
+func (p OSVPackage) String() string {
+	return p.Ecosystem + " " + p.Name
+}
+
+// Vulnerability is a known vulnerability from the OSV database.
+type Vulnerability struct {
+	ID      string   `json:"id"`
+	Summary string   `json:"summary"`
+	Aliases []string `json:"aliases"`
+}
+
+// DependencyVulnerabilities are the known vulnerabilities in a dependency's pinned version.
+type DependencyVulnerabilities struct {
+	Dependency string
+	// Package is the OSV package the dependency was looked up as. It is nil when the dependency wasn't checked.
+	Package *OSVPackage
+	Version string
+	// Skipped explains why the dependency wasn't checked.
+	Skipped         string
+	Vulnerabilities []Vulnerability
+}
+
+// AuditVulnerabilitiesOpts provides options for Config.AuditVulnerabilities
+type AuditVulnerabilitiesOpts struct {
+	// Dependencies to audit. Default is all dependencies.
+	Dependencies []string
+	// OfflineDB is a directory or zip file of OSV json records, like the all.zip files published for each ecosystem,
+	// to use instead of the OSV api.
+	OfflineDB string
+}
+
+// AuditVulnerabilities looks up known vulnerabilities for the version var of each dependency. Dependencies are looked
+// up as the package in their osv property. When that isn't set, a dependency released on GitHub is looked up as the go
+// module in the go.mod at the root of its repository, or with OfflineDB, as the go module for its repository that the
+// records are for. Dependencies that have no package or no version var are skipped.
+func (c *Config) AuditVulnerabilities(ctx context.Context, opts *AuditVulnerabilitiesOpts) ([]DependencyVulnerabilities, error) {
+	if opts == nil {
+		opts = &AuditVulnerabilitiesOpts{}
+	}
+	deps := opts.Dependencies
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	var db []osvRecord
+	if opts.OfflineDB != "" {
+		var err error
+		db, err = loadOSVDB(opts.OfflineDB)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client := c.downloader().httpClient()
+	result := make([]DependencyVulnerabilities, 0, len(deps))
+	for _, name := range deps {
+		report, project, err := c.dependencyOSVPackage(name)
+		if err != nil {
+			return nil, err
+		}
+		if report.Package == nil && project != "" && report.Version != "" {
+			var module string
+			if opts.OfflineDB != "" {
+				module = offlineGoModule(db, project)
+			} else {
+				module, err = detectGoModule(ctx, client, project)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			if module != "" {
+				report.Package = &OSVPackage{Ecosystem: "Go", Name: module}
+			}
+		}
+		switch {
+		case report.Package == nil && project != "" && report.Version != "":
+			report.Skipped = fmt.Sprintf("no osv package and github.com/%s isn't a known go module", project)
+		case report.Package == nil:
+			report.Skipped = "no osv package"
+		case report.Version == "":
+			report.Skipped = "no version var"
+		}
+		if report.Skipped == "" {
+			if opts.OfflineDB != "" {
+				report.Vulnerabilities = offlineVulnerabilities(db, report.Package, report.Version)
+			} else {
+				report.Vulnerabilities, err = queryOSV(ctx, client, report.Package, report.Version)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+		result = append(result, *report)
+	}
+	return result, nil
+}
+
+// dependencyOSVPackage returns a report for depName with the package from its osv property and the version to look up
+// filled in. When it has no osv property, project is the GitHub repository it is released from, if any.
+func (c *Config) dependencyOSVPackage(depName string) (_ *DependencyVulnerabilities, project string, _ error) {
+	if c.Dependencies == nil || c.Dependencies[depName] == nil {
+		return nil, "", c.unknownDependencyError(depName)
+	}
+	dep := c.Dependencies[depName].clone()
+	err := dep.applyTemplate(c.Templates, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	report := &DependencyVulnerabilities{
+		Dependency: depName,
+		Version:    dep.Vars["version"],
+		Package:    dep.OSV,
+	}
+	if report.Package == nil {
+		repo, repoErr := c.dependencyUpstreamRepo(depName)
+		if repoErr != nil {
+			return nil, "", repoErr
+		}
+		if repo != nil && repo.kind == "github" && !repo.enterprise {
+			project = repo.project
+		}
+	}
+	return report, project, nil
+}
+
+// githubRawURL serves files from GitHub repositories. It is a var so tests can point it at a test server.
+var githubRawURL = "https://raw.githubusercontent.com"
+
+// detectGoModule returns the module path in the go.mod at the root of the GitHub repository project. It is empty when
+// the repository has no go.mod.
+func detectGoModule(ctx context.Context, client *http.Client, project string) (_ string, errOut error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubRawURL+"/"+project+"/HEAD/go.mod", http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &downloadError{err: fmt.Errorf("failed getting go.mod for github.com/%s: %s", project, resp.Status)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		module, ok := strings.CutPrefix(strings.TrimSpace(line), "module ")
+		if ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", nil
+}
+
+// offlineGoModule returns the go module for the GitHub repository project, like github.com/<project> or
+// github.com/<project>/v2, that records in db are for. It is empty when there are none.
+func offlineGoModule(db []osvRecord, project string) string {
+	base := "github.com/" + project
+	for i := range db {
+		for _, affected := range db[i].Affected {
+			name := affected.Package.Name
+			if affected.Package.Ecosystem != "Go" || len(name) < len(base) || !strings.EqualFold(name[:len(base)], base) {
+				continue
+			}
+			rest := name[len(base):]
+			if rest == "" {
+				return name
+			}
+			major, ok := strings.CutPrefix(rest, "/v")
+			if ok && major != "" && strings.Trim(major, "0123456789") == "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// queryOSV returns the vulnerabilities the OSV api knows for version of pkg.
+func queryOSV(ctx context.Context, client *http.Client, pkg *OSVPackage, version string) ([]Vulnerability, error) {
+	query := map[string]any{
+		"package": map[string]string{"ecosystem": pkg.Ecosystem, "name": pkg.Name},
+		"version": version,
+	}
+	var vulns []Vulnerability
+	// large results are split into pages. each page but the last has a token for the next one.
+	for {
+		page, next, err := queryOSVPage(ctx, client, pkg, query)
+		if err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, page...)
+		if next == "" {
+			break
+		}
+		query["page_token"] = next
+	}
+	slices.SortFunc(vulns, func(a, b Vulnerability) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return vulns, nil
+}
+
+// queryOSVPage returns one page of the OSV api's response to query and the token for the next page.
+func queryOSVPage(ctx context.Context, client *http.Client, pkg *OSVPackage, query map[string]any) (_ []Vulnerability, next string, errOut error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, osvAPIURL+"/v1/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &downloadError{err: fmt.Errorf("failed querying osv for %s: %s", pkg, resp.Status)}
+	}
+	var result struct {
+		Vulns         []Vulnerability `json:"vulns"`
+		NextPageToken string          `json:"next_page_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, "", err
+	}
+	return result.Vulns, result.NextPageToken, nil
+}
+
+// osvRecord is the part of an OSV record needed to tell whether a version is affected.
+type osvRecord struct {
+	Vulnerability
+	Affected []struct {
+		Package  OSVPackage `json:"package"`
+		Versions []string   `json:"versions"`
+		Ranges   []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced   string `json:"introduced"`
+				Fixed        string `json:"fixed"`
+				LastAffected string `json:"last_affected"`
+			} `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+}
+
+// loadOSVDB reads the OSV records in a directory or zip file.
+func loadOSVDB(dbPath string) (_ []osvRecord, errOut error) {
+	var fsys fs.FS
+	if strings.EqualFold(filepath.Ext(dbPath), ".zip") {
+		zr, err := zip.OpenReader(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer deferErr(&errOut, zr.Close)
+		fsys = zr
+	} else {
+		fsys = os.DirFS(dbPath)
+	}
+	var records []osvRecord
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".json" {
+			return err
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		err = errors.Join(err, f.Close())
+		if err != nil {
+			return err
+		}
+		var record osvRecord
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			return fmt.Errorf("invalid osv record %s: %w", p, err)
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// offlineVulnerabilities returns the records in db that affect version of pkg.
+func offlineVulnerabilities(db []osvRecord, pkg *OSVPackage, version string) []Vulnerability {
+	var result []Vulnerability
+	for i := range db {
+		if db[i].affects(pkg, version) {
+			result = append(result, db[i].Vulnerability)
+		}
+	}
+	slices.SortFunc(result, func(a, b Vulnerability) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return result
+}
+
+func (r *osvRecord) affects(pkg *OSVPackage, version string) bool {
+	ver, verErr := semver.NewVersion(version)
+	for _, affected := range r.Affected {
+		if affected.Package != *pkg {
+			continue
+		}
+		if slices.Contains(affected.Versions, version) {
+			return true
+		}
+		if verErr != nil {
+			continue
+		}
+		for _, rng := range affected.Ranges {
+			if rng.Type != "SEMVER" && rng.Type != "ECOSYSTEM" {
+				continue
+			}
+			// events are applied in order. introduced starts an affected range and fixed or last_affected ends it.
+			isAffected := false
+			for _, event := range rng.Events {
+				switch {
+				case event.Introduced == "0":
+					isAffected = true
+				case event.Introduced != "":
+					cmp, ok := compareSemver(ver, event.Introduced)
+					isAffected = isAffected || ok && cmp >= 0
+				case event.Fixed != "":
+					cmp, ok := compareSemver(ver, event.Fixed)
+					isAffected = isAffected && !(ok && cmp >= 0)
+				case event.LastAffected != "":
+					cmp, ok := compareSemver(ver, event.LastAffected)
+					isAffected = isAffected && !(ok && cmp > 0)
+				}
+			}
+			if isAffected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareSemver compares ver to the semver other. ok is false when other isn't a valid semver.
+func compareSemver(ver *semver.Version, other string) (_ int, ok bool) {
+	otherVer, err := semver.NewVersion(other)
+	if err != nil {
+		return 0, false
+	}
+	return ver.Compare(otherVer), true
+}
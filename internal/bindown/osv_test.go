@@ -0,0 +1,165 @@
+package bindown
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const osvTestRecord = `{
+  "id": "GO-2024-0001",
+  "summary": "bad things",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "github.com/example/tool"},
+    "ranges": [{
+      "type": "SEMVER",
+      "events": [{"introduced": "0"}, {"fixed": "1.2.0"}, {"introduced": "1.4.0"}, {"last_affected": "1.4.2"}]
+    }]
+  }, {
+    "package": {"ecosystem": "Go", "name": "github.com/example/other"},
+    "versions": ["2.0.0"]
+  }]
+}`
+
+func TestOSVRecord_affects(t *testing.T) {
+	var record osvRecord
+	require.NoError(t, json.Unmarshal([]byte(osvTestRecord), &record))
+	tool := &OSVPackage{Ecosystem: "Go", Name: "github.com/example/tool"}
+	other := &OSVPackage{Ecosystem: "Go", Name: "github.com/example/other"}
+	for _, td := range []struct {
+		pkg     *OSVPackage
+		version string
+		want    bool
+	}{
+		{pkg: tool, version: "1.0.0", want: true},
+		{pkg: tool, version: "1.2.0", want: false},
+		{pkg: tool, version: "1.3.9", want: false},
+		{pkg: tool, version: "1.4.0", want: true},
+		{pkg: tool, version: "1.4.2", want: true},
+		{pkg: tool, version: "1.4.3", want: false},
+		{pkg: tool, version: "not-semver", want: false},
+		{pkg: other, version: "2.0.0", want: true},
+		{pkg: other, version: "1.0.0", want: false},
+		{pkg: &OSVPackage{Ecosystem: "npm", Name: "github.com/example/tool"}, version: "1.0.0", want: false},
+	} {
+		t.Run(td.pkg.Name+"@"+td.version, func(t *testing.T) {
+			require.Equal(t, td.want, record.affects(td.pkg, td.version))
+		})
+	}
+}
+
+func TestConfig_AuditVulnerabilities(t *testing.T) {
+	ctx := context.Background()
+	cfg := mustConfigFromYAML(t, `
+dependencies:
+  tool:
+    url: https://github.com/example/tool/releases/download/v{{ .version }}/tool
+    vars:
+      version: 1.1.0
+  other:
+    url: https://example.com/other-{{ .version }}
+    osv:
+      ecosystem: Go
+      name: github.com/example/other
+    vars:
+      version: 2.0.0
+  unversioned:
+    url: https://example.com/unversioned
+    osv:
+      ecosystem: npm
+      name: unversioned
+  unknown:
+    url: https://example.com/unknown-{{ .version }}
+    vars:
+      version: 1.0.0
+  notgo:
+    url: https://github.com/example/notgo/releases/download/v{{ .version }}/notgo
+    vars:
+      version: 3.0.0
+`)
+	want := []DependencyVulnerabilities{
+		{Dependency: "notgo", Version: "3.0.0", Skipped: "no osv package and github.com/example/notgo isn't a known go module"},
+		{
+			Dependency:      "other",
+			Package:         &OSVPackage{Ecosystem: "Go", Name: "github.com/example/other"},
+			Version:         "2.0.0",
+			Vulnerabilities: []Vulnerability{{ID: "GO-2024-0001", Summary: "bad things"}},
+		},
+		{
+			Dependency:      "tool",
+			Package:         &OSVPackage{Ecosystem: "Go", Name: "github.com/example/tool"},
+			Version:         "1.1.0",
+			Vulnerabilities: []Vulnerability{{ID: "GO-2024-0001", Summary: "bad things"}},
+		},
+		{Dependency: "unknown", Version: "1.0.0", Skipped: "no osv package"},
+		{
+			Dependency: "unversioned",
+			Package:    &OSVPackage{Ecosystem: "npm", Name: "unversioned"},
+			Skipped:    "no version var",
+		},
+	}
+
+	t.Run("api", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/example/tool/HEAD/go.mod" {
+				_, err := w.Write([]byte("module github.com/example/tool\n\ngo 1.21\n"))
+				assert.NoError(t, err)
+				return
+			}
+			if r.URL.Path != "/v1/query" {
+				http.NotFound(w, r)
+				return
+			}
+			var query struct {
+				Package   OSVPackage `json:"package"`
+				Version   string     `json:"version"`
+				PageToken string     `json:"page_token"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+			// the first page is empty so the vulnerabilities are only found by following next_page_token
+			if query.PageToken == "" {
+				assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"next_page_token": "page2"}))
+				return
+			}
+			assert.Equal(t, "page2", query.PageToken)
+			var record osvRecord
+			assert.NoError(t, json.Unmarshal([]byte(osvTestRecord), &record))
+			body := map[string]any{}
+			if record.affects(&query.Package, query.Version) {
+				body["vulns"] = []any{json.RawMessage(osvTestRecord)}
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(body))
+		}))
+		t.Cleanup(ts.Close)
+		origAPI, origRaw := osvAPIURL, githubRawURL
+		osvAPIURL, githubRawURL = ts.URL, ts.URL
+		t.Cleanup(func() { osvAPIURL, githubRawURL = origAPI, origRaw })
+		got, err := cfg.AuditVulnerabilities(ctx, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("offline zip", func(t *testing.T) {
+		dbFile := filepath.Join(t.TempDir(), "all.zip")
+		f, err := os.Create(dbFile)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("GO-2024-0001.json")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(osvTestRecord))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+		got, err := cfg.AuditVulnerabilities(ctx, &AuditVulnerabilitiesOpts{OfflineDB: dbFile})
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+}
@@ -0,0 +1,28 @@
+package bindown
+
+import (
+	"runtime"
+	"sync"
+)
+
+// forEachParallel calls fn for each index below n with at most jobs calls running at once. jobs below 1 means the
+// number of CPUs.
+func forEachParallel(n, jobs int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,58 @@
+package bindown
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func Test_forEachParallel(t *testing.T) {
+	var running, maxRunning atomic.Int64
+	done := make([]bool, 20)
+	forEachParallel(len(done), 3, func(i int) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		done[i] = true
+	})
+	require.LessOrEqual(t, maxRunning.Load(), int64(3))
+	for _, d := range done {
+		require.True(t, d)
+	}
+}
+
+func TestConfig_Validate_parallelTrustOnFirstUse(t *testing.T) {
+	servePath := filepath.Join("testdata", "downloadables", "rawfile", "foo")
+	systems := []System{"darwin/amd64", "darwin/arm64", "linux/amd64", "linux/arm64"}
+	files := map[string]string{}
+	for _, system := range systems {
+		files["/foo-"+system.OS()+"-"+system.Arch()] = servePath
+	}
+	ts := testutil.ServeFiles(t, files)
+	cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+checksum_policy: trust-on-first-use
+systems: [darwin/amd64, darwin/arm64, linux/amd64, linux/arm64]
+dependencies:
+  foo:
+    url: %s/foo-{{ .os }}-{{ .arch }}
+    archive_path: foo-{{ .os }}-{{ .arch }}
+`, ts.URL))
+	err := cfg.Validate("foo", nil, &ValidateOpts{Jobs: len(systems)})
+	require.NoError(t, err)
+	require.Len(t, cfg.URLChecksums, len(systems))
+	for _, system := range systems {
+		require.Equal(t,
+			"f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41",
+			cfg.URLChecksums[fmt.Sprintf("%s/foo-%s-%s", ts.URL, system.OS(), system.Arch())],
+		)
+	}
+}
@@ -0,0 +1,72 @@
+package bindown
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// PorcelainVersion is the "v" of every PorcelainRecord. It only changes when a record changes in a way that breaks
+// scripts reading them. Adding fields or actions doesn't change it.
+const PorcelainVersion = 1
+
+// Porcelain actions
+const (
+	PorcelainInstalled  = "installed"
+	PorcelainSkipped    = "skipped"
+	PorcelainFailed     = "failed"
+	PorcelainDownloaded = "downloaded"
+	PorcelainExtracted  = "extracted"
+	PorcelainWrapped    = "wrapped"
+)
+
+// PorcelainRecord is a line of porcelain output.
+type PorcelainRecord struct {
+	V          int    `json:"v"`
+	Action     string `json:"action"`
+	Dependency string `json:"dependency"`
+	System     System `json:"system,omitempty"`
+	// Path is the installed, downloaded or extracted file or directory. It is empty for failures.
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Porcelain writes a PorcelainRecord as a json line for each dependency that is installed, downloaded, extracted or
+// wrapped. Unlike the messages written to Stdout, records don't change when the wording of messages does. A nil
+// Porcelain writes nothing.
+type Porcelain struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewPorcelain returns a Porcelain that writes to w.
+func NewPorcelain(w io.Writer) *Porcelain {
+	return &Porcelain{enc: json.NewEncoder(w)}
+}
+
+func (p *Porcelain) write(action, name string, system System, path string, err error) error {
+	if p == nil {
+		return nil
+	}
+	rec := PorcelainRecord{
+		V:          PorcelainVersion,
+		Action:     action,
+		Dependency: name,
+		System:     system,
+		Path:       path,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enc.Encode(&rec)
+}
+
+// writeInstalled writes an installed or skipped record.
+func (p *Porcelain) writeInstalled(skipped bool, name string, system System, path string) error {
+	if skipped {
+		return p.write(PorcelainSkipped, name, system, path, nil)
+	}
+	return p.write(PorcelainInstalled, name, system, path, nil)
+}
@@ -0,0 +1,241 @@
+package bindown
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// presignExpires is how long builtin presigned urls are valid.
+const presignExpires = 15 * time.Minute
+
+// presign returns the url to download dlURL from. It runs the dependency's presign_command when there is one. Otherwise
+// s3:// and gs:// urls are signed with credentials from the environment, and other urls are returned unchanged.
+// Results are kept for the life of the downloader so a command isn't run for every request.
+func (dl *downloader) presign(dlURL string) (string, error) {
+	if dl == nil {
+		return signBucketURL(dlURL, time.Now())
+	}
+	if signed, ok := dl.presigned[dlURL]; ok {
+		return signed, nil
+	}
+	var signed string
+	var err error
+	if len(dl.presignCommand) > 0 {
+		ctx, cancel := dl.context()
+		defer cancel()
+		var args []string
+		args, err = executeCommandTemplates(dl.presignCommand, map[string]string{"url": dlURL})
+		if err != nil {
+			return "", err
+		}
+		var out []byte
+		out, err = exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				err = fmt.Errorf("%w\n%s", err, exitErr.Stderr)
+			}
+			return "", fmt.Errorf("presign command %s failed for %s: %w", args[0], dlURL, err)
+		}
+		signed, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+		if signed == "" {
+			return "", fmt.Errorf("presign command %s printed no url for %s", args[0], dlURL)
+		}
+	} else {
+		signed, err = signBucketURL(dlURL, time.Now())
+		if err != nil {
+			return "", err
+		}
+	}
+	if dl.presigned == nil {
+		dl.presigned = map[string]string{}
+	}
+	dl.presigned[dlURL] = signed
+	return signed, nil
+}
+
+// signBucketURL returns a presigned https url for s3://bucket/key and gs://bucket/object urls. Other urls are returned
+// unchanged.
+//
+// s3 urls are signed with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN for the region in AWS_REGION
+// or AWS_DEFAULT_REGION. AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL set an S3-compatible endpoint.
+//
+// gs urls are signed with the GCS HMAC key in GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET. STORAGE_EMULATOR_HOST sets the
+// endpoint.
+func signBucketURL(dlURL string, now time.Time) (string, error) {
+	u, err := url.Parse(dlURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "s3":
+		region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return presignSigV4(&sigV4Params{
+			bucketURL: u,
+			endpoint:  firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+			accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			secret:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			token:     os.Getenv("AWS_SESSION_TOKEN"),
+			region:    region,
+			service:   "s3",
+			vendor:    "AWS",
+			now:       now,
+			expires:   presignExpires,
+		})
+	case "gs":
+		endpoint := os.Getenv("STORAGE_EMULATOR_HOST")
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		return presignSigV4(&sigV4Params{
+			bucketURL: u,
+			endpoint:  endpoint,
+			accessKey: os.Getenv("GCS_HMAC_ACCESS_ID"),
+			secret:    os.Getenv("GCS_HMAC_SECRET"),
+			region:    "auto",
+			service:   "storage",
+			vendor:    "GOOG",
+			now:       now,
+			expires:   presignExpires,
+		})
+	default:
+		return dlURL, nil
+	}
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if val := os.Getenv(name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+type sigV4Params struct {
+	// bucketURL is the s3:// or gs:// url with the bucket as its host
+	bucketURL *url.URL
+	// endpoint is the base url for path-style requests. When it is empty, requests are made to the bucket's virtual
+	// host on AWS.
+	endpoint  string
+	accessKey string
+	secret    string
+	token     string
+	region    string
+	service   string
+	// vendor is "AWS" or "GOOG". GCS uses the same signing process with its own names.
+	vendor  string
+	now     time.Time
+	expires time.Duration
+}
+
+// presignSigV4 presigns a GET request with AWS signature version 4 as a query string.
+func presignSigV4(p *sigV4Params) (string, error) {
+	if p.accessKey == "" || p.secret == "" {
+		return "", fmt.Errorf("no credentials for presigning %s", p.bucketURL)
+	}
+	bucket := p.bucketURL.Host
+	key := strings.TrimPrefix(p.bucketURL.Path, "/")
+	var host, scheme, canonicalPath string
+	if p.endpoint == "" {
+		scheme = "https"
+		host = bucket + ".s3.amazonaws.com"
+		if p.region != "us-east-1" {
+			host = fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, p.region)
+		}
+		canonicalPath = "/" + sigV4Escape(key, false)
+	} else {
+		endpoint, err := url.Parse(p.endpoint)
+		if err != nil {
+			return "", err
+		}
+		scheme, host = endpoint.Scheme, endpoint.Host
+		if host == "" {
+			// STORAGE_EMULATOR_HOST is often set without a scheme
+			scheme, host = "http", p.endpoint
+		}
+		canonicalPath = strings.TrimSuffix(endpoint.Path, "/") + "/" + sigV4Escape(bucket, false) + "/" + sigV4Escape(key, false)
+	}
+
+	prefix := "X-Amz-"
+	algorithm := "AWS4-HMAC-SHA256"
+	terminator := "aws4_request"
+	if p.vendor == "GOOG" {
+		prefix = "X-Goog-"
+		algorithm = "GOOG4-HMAC-SHA256"
+		terminator = "goog4_request"
+	}
+	now := p.now.UTC()
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, p.region, p.service, terminator}, "/")
+	query := map[string]string{
+		prefix + "Algorithm":     algorithm,
+		prefix + "Credential":    p.accessKey + "/" + scope,
+		prefix + "Date":          now.Format("20060102T150405Z"),
+		prefix + "Expires":       fmt.Sprint(int(p.expires.Seconds())),
+		prefix + "SignedHeaders": "host",
+	}
+	if p.token != "" {
+		query[prefix+"Security-Token"] = p.token
+	}
+	keys := MapKeys(query)
+	slices.Sort(keys)
+	queryParts := make([]string, len(keys))
+	for i, k := range keys {
+		queryParts[i] = sigV4Escape(k, true) + "=" + sigV4Escape(query[k], true)
+	}
+	canonicalQuery := strings.Join(queryParts, "&")
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalPath,
+		canonicalQuery,
+		"host:" + host,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{algorithm, query[prefix+"Date"], scope, hex.EncodeToString(requestHash[:])}, "\n")
+	signingKey := []byte(p.vendor + "4" + p.secret)
+	for _, part := range []string{date, p.region, p.service, terminator} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	return fmt.Sprintf("%s://%s%s?%s&%sSignature=%s", scheme, host, canonicalPath, canonicalQuery, prefix, signature), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sigV4Escape percent-encodes everything except unreserved characters. Slashes are only encoded when encodeSlash is
+// set.
+func sigV4Escape(s string, encodeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,108 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PrewarmCacheOpts provides options for Config.PrewarmCache
+type PrewarmCacheOpts struct {
+	// Extract extracts each download in the cache too, so installs don't need to.
+	Extract              bool
+	AllowMissingChecksum bool
+	// Jobs is how many dependencies are downloaded at once. Default is the number of CPUs.
+	Jobs int
+	// Stdout gets a line for each dependency cached.
+	Stdout io.Writer
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+}
+
+// prewarmJob is a dependency to cache for one system.
+type prewarmJob struct {
+	dep          *Dependency
+	allowMissing bool
+	err          error
+}
+
+// PrewarmCache downloads deps for each of systems to the cache without installing them, so a CI image or shared cache
+// can be seeded ahead of time. When deps is empty, every dependency is cached. When systems is empty, each dependency
+// is cached for every system it supports. Systems a dependency doesn't support are skipped.
+func (c *Config) PrewarmCache(deps []string, systems []System, opts *PrewarmCacheOpts) error {
+	if opts == nil {
+		opts = &PrewarmCacheOpts{}
+	}
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	deps, err := c.withRequirements(deps)
+	if err != nil {
+		return err
+	}
+	var jobs []*prewarmJob
+	for _, name := range deps {
+		depSystems := systems
+		if len(depSystems) == 0 {
+			depSystems, err = c.DependencySystems(name)
+			if err != nil {
+				return err
+			}
+		}
+		for _, system := range depSystems {
+			// prewarm what install would download, so windows/arm64 falls back to windows/amd64 the same way
+			dep, err := c.buildSupported(name, system, nil)
+			if errors.As(err, new(*UnsupportedSystemError)) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			// checked before downloading in parallel so warnings aren't interleaved
+			job := &prewarmJob{dep: dep}
+			job.allowMissing, job.err = c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+			jobs = append(jobs, job)
+		}
+	}
+	forEachParallel(len(jobs), opts.Jobs, func(i int) {
+		job := jobs[i]
+		if job.err == nil {
+			job.err = c.prewarmDependency(job.dep, job.allowMissing, opts.Extract)
+		}
+	})
+	var errs []error
+	for _, job := range jobs {
+		if job.err != nil {
+			errs = append(errs, &DependencyError{
+				Dependency: job.dep.name,
+				Err:        fmt.Errorf("%s: %w", job.dep.system, job.err),
+			})
+			continue
+		}
+		// trusted after the downloads are done so url_checksums isn't written concurrently
+		c.trustChecksum(job.dep)
+		if opts.Stdout == nil {
+			continue
+		}
+		_, err = fmt.Fprintf(opts.Stdout, "cached %s for %s\n", job.dep.name, job.dep.system)
+		if err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// prewarmDependency downloads dep to the cache and extracts it when extract is true.
+func (c *Config) prewarmDependency(dep *Dependency, allowMissing, extract bool) error {
+	var unlock func() error
+	var err error
+	if extract {
+		_, unlock, err = downloadAndExtract(dep, c.dependencyCacheDir(dep, c.Cache), false, allowMissing, false)
+	} else {
+		_, _, unlock, err = downloadDependency(dep, c.downloadsCache(dep), allowMissing, false)
+	}
+	if err != nil {
+		return dep.downloader.wrapTimeout(err)
+	}
+	return unlock()
+}
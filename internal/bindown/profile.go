@@ -0,0 +1,82 @@
+package bindown
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed profiles.yaml
+var profilesYAML []byte
+
+// Profile is a curated set of dependencies for a stack.
+type Profile struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description"`
+	// Dependencies maps builtin template names to the version to pin.
+	Dependencies map[string]string `yaml:"dependencies"`
+}
+
+// Profiles returns the profiles that ship with bindown in name order.
+func Profiles() ([]Profile, error) {
+	var m map[string]Profile
+	err := yaml.Unmarshal(profilesYAML, &m)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]Profile, 0, len(m))
+	for _, name := range sortedKeys(m) {
+		p := m[name]
+		p.Name = name
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// ProfileNames returns the names of the profiles that ship with bindown.
+func ProfileNames() []string {
+	profiles, err := Profiles()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// AddProfile adds each of a profile's dependencies from its builtin template. It returns the names of the added
+// dependencies. Dependencies that are already in the config are left alone.
+func (c *Config) AddProfile(ctx context.Context, name string) ([]string, error) {
+	profiles, err := Profiles()
+	if err != nil {
+		return nil, err
+	}
+	var profile *Profile
+	for i := range profiles {
+		if profiles[i].Name == name {
+			profile = &profiles[i]
+		}
+	}
+	if profile == nil {
+		return nil, fmt.Errorf("no profile named %q. available profiles are %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	var added []string
+	for _, depName := range sortedKeys(profile.Dependencies) {
+		if c.Dependencies[depName] != nil {
+			continue
+		}
+		_, _, err = c.AddDependencyFromTemplate(ctx, depName, &AddDependencyFromTemplateOpts{
+			Vars: map[string]string{"version": profile.Dependencies[depName]},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		added = append(added, depName)
+	}
+	return added, nil
+}
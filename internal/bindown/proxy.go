@@ -0,0 +1,79 @@
+package bindown
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig configures the proxies used for downloads. Proxy urls may use the http, https or socks5 schemes.
+type ProxyConfig struct {
+	// The proxy to use for hosts that don't match a rule in hosts or no_proxy. When this isn't set, the proxy from
+	// the environment is used.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// Proxies for specific hosts. Keys are a host name like "example.com" or a pattern like "*.example.com" that
+	// matches subdomains. Values are a proxy url or "direct" to connect without a proxy. When multiple keys match, the
+	// longest one is used.
+	Hosts map[string]string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+
+	// Hosts to connect to without a proxy. Entries have the same format as the keys of hosts.
+	NoProxy []string `json:"no_proxy,omitempty" yaml:"no_proxy,omitempty"`
+}
+
+// proxyFunc returns a function for http.Transport.Proxy
+func (p *ProxyConfig) proxyFunc() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxy, ok := p.proxyForHost(req.URL.Hostname())
+		if !ok {
+			return http.ProxyFromEnvironment(req)
+		}
+		if proxy == "" {
+			return nil, nil
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", proxy, err)
+		}
+		return proxyURL, nil
+	}
+}
+
+// proxyForHost returns the configured proxy url for host. proxy is empty when host should be connected to directly.
+// ok is false when nothing is configured for host.
+func (p *ProxyConfig) proxyForHost(host string) (proxy string, ok bool) {
+	match := ""
+	for pattern := range p.Hosts {
+		if len(pattern) > len(match) && hostMatches(pattern, host) {
+			match = pattern
+		}
+	}
+	if match != "" {
+		proxy = p.Hosts[match]
+		if proxy == "direct" {
+			proxy = ""
+		}
+		return proxy, true
+	}
+	for _, pattern := range p.NoProxy {
+		if hostMatches(pattern, host) {
+			return "", true
+		}
+	}
+	if p.URL != "" {
+		return p.URL, true
+	}
+	return "", false
+}
+
+// hostMatches returns true if host is pattern or pattern is like "*.example.com" and host is a subdomain of
+// example.com.
+func hostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix)
+	}
+	return pattern == host
+}
@@ -0,0 +1,42 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyConfig_proxyForHost(t *testing.T) {
+	proxy := &ProxyConfig{
+		URL: "http://proxy.local:8080",
+		Hosts: map[string]string{
+			"*.example.com":     "socks5://socks.local:1080",
+			"*.api.example.com": "direct",
+			"github.com":        "http://gh-proxy.local",
+		},
+		NoProxy: []string{"localhost", "*.internal"},
+	}
+	for _, td := range []struct {
+		host      string
+		wantProxy string
+		wantOK    bool
+	}{
+		{host: "dl.example.com", wantProxy: "socks5://socks.local:1080", wantOK: true},
+		{host: "v1.api.example.com", wantOK: true},
+		{host: "GitHub.com", wantProxy: "http://gh-proxy.local", wantOK: true},
+		{host: "api.github.com", wantProxy: "http://proxy.local:8080", wantOK: true},
+		{host: "localhost", wantOK: true},
+		{host: "foo.internal", wantOK: true},
+	} {
+		t.Run(td.host, func(t *testing.T) {
+			gotProxy, gotOK := proxy.proxyForHost(td.host)
+			require.Equal(t, td.wantProxy, gotProxy)
+			require.Equal(t, td.wantOK, gotOK)
+		})
+	}
+
+	t.Run("falls back to environment", func(t *testing.T) {
+		_, ok := (&ProxyConfig{NoProxy: []string{"localhost"}}).proxyForHost("example.com")
+		require.False(t, ok)
+	})
+}
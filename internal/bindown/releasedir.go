@@ -0,0 +1,212 @@
+package bindown
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// releaseProvenanceFile is the name of the file in a release dir that describes where each dependency came from.
+const releaseProvenanceFile = "provenance.json"
+
+// BuildReleaseDirOpts options for Config.BuildReleaseDir
+type BuildReleaseDirOpts struct {
+	// Tags selects the dependencies that have any of these tags in addition to the dependencies passed to
+	// BuildReleaseDir. When neither is set, all dependencies are included.
+	Tags []string
+	// Stdout gets a line for each dependency installed.
+	Stdout io.Writer
+	// Color colors the status at the start of each line written to Stdout.
+	Color bool
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+	// AllowMissingChecksum installs dependencies that have no checksum for the system.
+	AllowMissingChecksum bool
+}
+
+// ReleaseProvenance is the content of provenance.json in a release dir.
+type ReleaseProvenance struct {
+	System       System                          `json:"system"`
+	Dependencies map[string]DependencyProvenance `json:"dependencies"`
+}
+
+// DependencyProvenance describes where a dependency in a release dir came from.
+type DependencyProvenance struct {
+	Version  string `json:"version,omitempty"`
+	Homepage string `json:"homepage,omitempty"`
+	URL      string `json:"url"`
+	Checksum string `json:"checksum,omitempty"`
+	// Path is the installed bin relative to the release dir.
+	Path string `json:"path"`
+	// Licenses are the license files copied from the download relative to the release dir.
+	Licenses []string `json:"licenses,omitempty"`
+}
+
+// BuildReleaseDir installs deps and the dependencies they require for system into a directory tree at dest for
+// redistributing to people who don't use bindown. Bins go in dest/bin, license files found in each download go in
+// dest/licenses/<dependency> and dest/provenance.json records the url, checksum and version of each dependency.
+func (c *Config) BuildReleaseDir(deps []string, system System, dest string, opts *BuildReleaseDirOpts) error {
+	if opts == nil {
+		opts = &BuildReleaseDirOpts{}
+	}
+	deps = slices.Clone(deps)
+	if len(opts.Tags) > 0 {
+		tagged, err := c.taggedDependencies(opts.Tags)
+		if err != nil {
+			return err
+		}
+		if len(tagged) == 0 && len(deps) == 0 {
+			return fmt.Errorf("no dependencies are tagged with %s", strings.Join(opts.Tags, " or "))
+		}
+		for _, name := range tagged {
+			if !slices.Contains(deps, name) {
+				deps = append(deps, name)
+			}
+		}
+	}
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	deps, err := c.withRequirements(deps)
+	if err != nil {
+		return err
+	}
+	provenance := ReleaseProvenance{
+		System:       system,
+		Dependencies: map[string]DependencyProvenance{},
+	}
+	installOpts := &ConfigInstallDependenciesOpts{
+		AllowMissingChecksum: opts.AllowMissingChecksum,
+		Stderr:               opts.Stderr,
+	}
+	for _, name := range deps {
+		var out string
+		var skipped bool
+		out, skipped, err = c.installDependency(name, system, filepath.Join(dest, "bin"), true, nil, installOpts)
+		if err != nil {
+			return err
+		}
+		var depProvenance *DependencyProvenance
+		depProvenance, err = c.releaseDependency(name, system, dest, out, opts.AllowMissingChecksum)
+		if err != nil {
+			return err
+		}
+		provenance.Dependencies[name] = *depProvenance
+		if opts.Stdout != nil {
+			err = writeInstallStatus(opts.Stdout, opts.Color, skipped, name, out)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, releaseProvenanceFile), append(data, '\n'), 0o644)
+}
+
+// releaseDependency copies the license files from the dependency's extracted download to dest/licenses/<name> and
+// returns its provenance. binPath is where the dependency was installed.
+func (c *Config) releaseDependency(name string, system System, dest, binPath string, allowMissingChecksum bool) (_ *DependencyProvenance, errOut error) {
+	dep, err := c.buildSupported(name, system, nil)
+	if err != nil {
+		return nil, err
+	}
+	relBin, err := filepath.Rel(dest, binPath)
+	if err != nil {
+		return nil, err
+	}
+	provenance := &DependencyProvenance{
+		Version:  dep.Vars["version"],
+		URL:      dep.url,
+		Checksum: dep.checksum,
+		Path:     filepath.ToSlash(relBin),
+	}
+	if dep.Homepage != nil {
+		provenance.Homepage = *dep.Homepage
+	}
+	allowMissing, err := c.missingChecksumAllowed(dep, allowMissingChecksum, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the download was just extracted by the install, so this only looks it up in the cache
+	extractDir, unlock, err := downloadAndExtract(dep, c.dependencyCacheDir(dep, c.Cache), false, allowMissing, false)
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, unlock)
+	licenseDir := filepath.Join(dest, "licenses", name)
+	err = os.RemoveAll(licenseDir)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(extractDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !isLicenseFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(extractDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(licenseDir, rel)
+		err = os.MkdirAll(filepath.Dir(target), 0o755)
+		if err != nil {
+			return err
+		}
+		err = copyFile(path, target)
+		if err != nil {
+			return err
+		}
+		relTarget, err := filepath.Rel(dest, target)
+		if err != nil {
+			return err
+		}
+		provenance.Licenses = append(provenance.Licenses, filepath.ToSlash(relTarget))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return provenance, nil
+}
+
+// licenseFilePrefixes are the lowercase names license files start with.
+var licenseFilePrefixes = []string{"license", "licence", "copying", "notice", "copyright"}
+
+func isLicenseFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range licenseFilePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// taggedDependencies returns the names of the dependencies that have any of tags.
+func (c *Config) taggedDependencies(tags []string) ([]string, error) {
+	var names []string
+	for _, name := range c.DependencyNames() {
+		dep := c.Dependencies[name].clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		for _, tag := range tags {
+			if slices.Contains(dep.Tags, tag) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,96 @@
+package bindown
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_BuildReleaseDir(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool-1.0.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"tool-1.0/tool":        "#!/bin/sh\necho tool\n",
+		"tool-1.0/LICENSE":     "MIT\n",
+		"tool-1.0/doc/NOTICE":  "notice\n",
+		"tool-1.0/doc/README":  "docs\n",
+		"tool-1.0/helper":      "#!/bin/sh\necho helper\n",
+		"tool-1.0/COPYING.txt": "gpl\n",
+	})
+	sum, err := fileChecksum(archive)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, archive, "/tool-1.0.tar.gz", "")
+	depURL := ts.URL + "/tool-1.0.tar.gz"
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  tool:
+    homepage: https://example.com/tool
+    url: %q
+    archive_path: tool-{{ .version }}/tool
+    vars:
+      version: "1.0"
+    tags: [toolbox]
+    requires: [helper]
+  helper:
+    url: %q
+    archive_path: tool-1.0/helper
+  other:
+    url: %q
+    archive_path: tool-1.0/helper
+url_checksums:
+  %q: %s
+`, filepath.Join(dir, "cache"), depURL, depURL, depURL, depURL, sum))
+	system := System("linux/amd64")
+	dest := filepath.Join(dir, "dist", "linux-amd64")
+
+	err = config.BuildReleaseDir(nil, system, dest, &BuildReleaseDirOpts{Tags: []string{"toolbox"}})
+	require.NoError(t, err)
+	testutil.AssertFile(t, filepath.Join(dest, "bin", "tool"), true, false)
+	testutil.AssertFile(t, filepath.Join(dest, "bin", "helper"), true, false)
+	require.NoFileExists(t, filepath.Join(dest, "bin", "other"))
+	license, err := os.ReadFile(filepath.Join(dest, "licenses", "tool", "tool-1.0", "LICENSE"))
+	require.NoError(t, err)
+	require.Equal(t, "MIT\n", string(license))
+
+	data, err := os.ReadFile(filepath.Join(dest, "provenance.json"))
+	require.NoError(t, err)
+	var provenance ReleaseProvenance
+	require.NoError(t, json.Unmarshal(data, &provenance))
+	licenses := []string{
+		"licenses/tool/tool-1.0/COPYING.txt",
+		"licenses/tool/tool-1.0/LICENSE",
+		"licenses/tool/tool-1.0/doc/NOTICE",
+	}
+	require.Equal(t, ReleaseProvenance{
+		System: system,
+		Dependencies: map[string]DependencyProvenance{
+			"tool": {
+				Version:  "1.0",
+				Homepage: "https://example.com/tool",
+				URL:      depURL,
+				Checksum: sum,
+				Path:     "bin/tool",
+				Licenses: licenses,
+			},
+			"helper": {
+				URL:      depURL,
+				Checksum: sum,
+				Path:     "bin/helper",
+				Licenses: []string{
+					"licenses/helper/tool-1.0/COPYING.txt",
+					"licenses/helper/tool-1.0/LICENSE",
+					"licenses/helper/tool-1.0/doc/NOTICE",
+				},
+			},
+		},
+	}, provenance)
+
+	err = config.BuildReleaseDir(nil, system, dest, &BuildReleaseDirOpts{Tags: []string{"nope"}})
+	require.EqualError(t, err, "no dependencies are tagged with nope")
+}
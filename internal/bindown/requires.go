@@ -0,0 +1,54 @@
+package bindown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// withRequirements returns deps along with the dependencies they require directly or through other dependencies. Each
+// dependency comes after the ones it requires, and deps keep their order otherwise. Cycles and requirements that
+// aren't configured are returned as *ConfigError.
+func (c *Config) withRequirements(deps []string) ([]string, error) {
+	result := make([]string, 0, len(deps))
+	done := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if done[name] {
+			return nil
+		}
+		if i := slices.Index(path, name); i != -1 {
+			cycle := append(slices.Clone(path[i:]), name)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+		dep := c.Dependencies[name]
+		if dep == nil {
+			if len(path) == 0 {
+				return c.unknownDependencyError(name)
+			}
+			return fmt.Errorf("dependency %q requires %q, which is not configured", path[len(path)-1], name)
+		}
+		dep = dep.clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return err
+		}
+		path = append(path, name)
+		for _, req := range dep.Requires {
+			err = visit(req, path)
+			if err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		result = append(result, name)
+		return nil
+	}
+	for _, name := range deps {
+		err := visit(name, nil)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package bindown
+
+import (
+	"fmt"
+	"io"
+)
+
+type status string
+
+const (
+	statusInstalled status = "installed"
+	statusSkipped   status = "skipped"
+	statusFailed    status = "failed"
+)
+
+var statusColors = map[status]string{
+	statusInstalled: "\x1b[32m",
+	statusSkipped:   "\x1b[33m",
+	statusFailed:    "\x1b[31m",
+}
+
+// writeStatus writes a line starting with st followed by msg. st is colored when color is true.
+func writeStatus(w io.Writer, color bool, st status, msg string) error {
+	prefix := string(st)
+	if color {
+		prefix = statusColors[st] + prefix + "\x1b[0m"
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", prefix, msg)
+	return err
+}
+
+// writeInstallStatus writes the status line for an installed or skipped dependency
+func writeInstallStatus(w io.Writer, color, skipped bool, name, target string) error {
+	if skipped {
+		return writeStatus(w, color, statusSkipped, fmt.Sprintf("%s: %s is up to date", name, target))
+	}
+	return writeStatus(w, color, statusInstalled, fmt.Sprintf("%s to %s", name, target))
+}
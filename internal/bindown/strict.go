@@ -0,0 +1,118 @@
+package bindown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CheckStrict looks for the typos the json schema can't catch. It returns a *ConfigValidationError when an override
+// matcher or a substitution uses a key that isn't "os", "arch", "environment" or a variable set or required by the
+// dependency, its templates or their overrides.
+func (c *Config) CheckStrict() error {
+	var problems []ConfigProblem
+	seen := map[string]bool{}
+	checked := map[string]bool{}
+	check := func(kind, name string, dep *Dependency) {
+		chain := c.templateChain(dep)
+		known := map[string]bool{"os": true, "arch": true, "environment": true}
+		addKnownVars(known, dep)
+		for _, tmplName := range chain {
+			addKnownVars(known, c.Templates[tmplName])
+		}
+		for _, p := range unknownVarKeys(fmt.Sprintf("/%s/%s", kind, escapePointer(name)), &dep.Overrideable, known) {
+			if !seen[p.Path] {
+				seen[p.Path] = true
+				problems = append(problems, p)
+			}
+		}
+		for _, tmplName := range chain {
+			checked[tmplName] = true
+			tmpl := c.Templates[tmplName]
+			if tmpl == nil {
+				continue
+			}
+			for _, p := range unknownVarKeys("/templates/"+escapePointer(tmplName), &tmpl.Overrideable, known) {
+				if !seen[p.Path] {
+					seen[p.Path] = true
+					problems = append(problems, p)
+				}
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.Dependencies) {
+		if c.Dependencies[name] != nil {
+			check("dependencies", name, c.Dependencies[name])
+		}
+	}
+	// templates that no dependency uses are checked against their own variables
+	for _, name := range sortedKeys(c.Templates) {
+		if !checked[name] && c.Templates[name] != nil {
+			check("templates", name, c.Templates[name])
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(problems, func(a, b ConfigProblem) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return &ConfigValidationError{Problems: problems}
+}
+
+// addKnownVars adds the variables dep sets or requires to known.
+func addKnownVars(known map[string]bool, dep *Dependency) {
+	if dep == nil {
+		return
+	}
+	for _, v := range dep.RequiredVars {
+		known[v] = true
+	}
+	var addOverrideable func(o *Overrideable)
+	addOverrideable = func(o *Overrideable) {
+		for k := range o.Vars {
+			known[k] = true
+		}
+		for i := range o.Overrides {
+			addOverrideable(&o.Overrides[i].Dependency)
+		}
+	}
+	addOverrideable(&dep.Overrideable)
+}
+
+// unknownVarKeys returns a problem for each matcher or substitution key in o that isn't in known.
+func unknownVarKeys(path string, o *Overrideable, known map[string]bool) []ConfigProblem {
+	var problems []ConfigProblem
+	for _, key := range sortedKeys(o.Substitutions) {
+		if !known[key] {
+			problems = append(problems, ConfigProblem{
+				Path:    path + "/substitutions/" + escapePointer(key),
+				Message: fmt.Sprintf("substitution for unknown var %q", key),
+			})
+		}
+	}
+	for i := range o.Overrides {
+		overridePath := fmt.Sprintf("%s/overrides/%d", path, i)
+		for _, key := range sortedKeys(o.Overrides[i].OverrideMatcher) {
+			if !known[key] {
+				problems = append(problems, ConfigProblem{
+					Path:    overridePath + "/matcher/" + escapePointer(key),
+					Message: fmt.Sprintf("matcher key %q is not os, arch or a known var", key),
+				})
+			}
+		}
+		problems = append(problems, unknownVarKeys(overridePath+"/dependency", &o.Overrides[i].Dependency, known)...)
+	}
+	return problems
+}
+
+// escapePointer escapes s for use as a json pointer token.
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := MapKeys(m)
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_CheckStrict(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  foo:
+    template: tmpl
+    vars:
+      version: 1.2.3
+templates:
+  tmpl:
+    url: https://example.com/foo-{{.os}}-{{.arch}}-{{.version}}
+    required_vars: [version]
+    overrides:
+      - matcher:
+          os: [windows]
+          version: [">=1.0.0"]
+        dependency:
+          vars:
+            suffix: .exe
+      - matcher:
+          suffix: [.exe]
+        dependency:
+          archive_path: foo.exe
+    substitutions:
+      arch:
+        amd64: x86_64
+`)
+		require.NoError(t, cfg.CheckStrict())
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  foo:
+    template: tmpl
+    url: https://example.com/foo-{{.os}}
+    overrides:
+      - matcher:
+          ossss: [windows]
+        dependency:
+          substitutions:
+            verison:
+              1.0.0: "1"
+templates:
+  tmpl:
+    substitutions:
+      archh:
+        amd64: x86_64
+  unused:
+    overrides:
+      - matcher:
+          version: ["1.2.3"]
+        dependency:
+          archive_path: bar
+`)
+		err := cfg.CheckStrict()
+		var validationErr *ConfigValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []ConfigProblem{
+			{
+				Path:    "/dependencies/foo/overrides/0/dependency/substitutions/verison",
+				Message: `substitution for unknown var "verison"`,
+			},
+			{
+				Path:    "/dependencies/foo/overrides/0/matcher/ossss",
+				Message: `matcher key "ossss" is not os, arch or a known var`,
+			},
+			{
+				Path:    "/templates/tmpl/substitutions/archh",
+				Message: `substitution for unknown var "archh"`,
+			},
+			{
+				Path:    "/templates/unused/overrides/0/matcher/version",
+				Message: `matcher key "version" is not os, arch or a known var`,
+			},
+		}, validationErr.Problems)
+	})
+}
@@ -0,0 +1,85 @@
+package bindown
+
+import "fmt"
+
+// SystemDiff is a resolved value of a dependency that differs between two systems.
+type SystemDiff struct {
+	Dependency string
+	// Field is the property that differs like "url" or "archive_path". It is "systems" when only one of the systems is
+	// supported.
+	Field string
+	A     string
+	B     string
+}
+
+// DiffSystemsOpts provides options for Config.DiffSystems
+type DiffSystemsOpts struct {
+	// Dependencies to compare. Default is all dependencies.
+	Dependencies []string
+}
+
+// DiffSystems builds each dependency for systems a and b and returns the url, bin and archive_path values that differ.
+// It makes mistakes in overrides easy to spot, like an override that was copied for another system and not updated.
+func (c *Config) DiffSystems(a, b System, opts *DiffSystemsOpts) ([]SystemDiff, error) {
+	if opts == nil {
+		opts = &DiffSystemsOpts{}
+	}
+	deps := opts.Dependencies
+	if len(deps) == 0 {
+		deps = c.DependencyNames()
+	}
+	var diffs []SystemDiff
+	for _, depName := range deps {
+		depA, err := c.BuildDependency(depName, a)
+		if err != nil {
+			return nil, err
+		}
+		depB, err := c.BuildDependency(depName, b)
+		if err != nil {
+			return nil, err
+		}
+		supportsA, supportsB := checkSystem(depA) == nil, checkSystem(depB) == nil
+		if supportsA != supportsB {
+			diffs = append(diffs, SystemDiff{
+				Dependency: depName,
+				Field:      "systems",
+				A:          supportedString(supportsA),
+				B:          supportedString(supportsB),
+			})
+			continue
+		}
+		for _, field := range []struct {
+			name string
+			a, b *string
+		}{
+			{"url", depA.URL, depB.URL},
+			{"archive_path", depA.ArchivePath, depB.ArchivePath},
+			{"bin", depA.BinName, depB.BinName},
+		} {
+			valA, valB := stringValue(field.a), stringValue(field.b)
+			if valA != valB {
+				diffs = append(diffs, SystemDiff{Dependency: depName, Field: field.name, A: valA, B: valB})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+func supportedString(supported bool) string {
+	if supported {
+		return "supported"
+	}
+	return "unsupported"
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// String formats the diff with the value for a on a "-" line and the value for b on a "+" line.
+func (d SystemDiff) String() string {
+	return fmt.Sprintf("%s %s\n  - %s\n  + %s", d.Dependency, d.Field, d.A, d.B)
+}
@@ -27,3 +27,38 @@ func (s System) Arch() string {
 	s.validate()
 	return strings.Split(string(s), "/")[1]
 }
+
+// systemOSAliases and systemArchAliases map names other tools use for operating systems and architectures to their
+// GOOS and GOARCH values.
+var (
+	systemOSAliases = map[string]string{
+		"macos": "darwin",
+		"osx":   "darwin",
+		"mac":   "darwin",
+		"win":   "windows",
+	}
+	systemArchAliases = map[string]string{
+		"x86_64":  "amd64",
+		"x64":     "amd64",
+		"aarch64": "arm64",
+		"i386":    "386",
+		"i686":    "386",
+		"x86":     "386",
+	}
+)
+
+// ParseSystem parses a system in the form os/arch. Common aliases like macos, x86_64 and aarch64 are normalized to
+// their GOOS and GOARCH values, so "macos/x86_64" is "darwin/amd64".
+func ParseSystem(s string) (System, error) {
+	osName, arch, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "/")
+	if !ok || osName == "" || arch == "" || strings.Contains(arch, "/") {
+		return "", fmt.Errorf("invalid system %q. systems are in the form os/arch like linux/amd64", s)
+	}
+	if alias, ok := systemOSAliases[osName]; ok {
+		osName = alias
+	}
+	if alias, ok := systemArchAliases[arch]; ok {
+		arch = alias
+	}
+	return System(osName + "/" + arch), nil
+}
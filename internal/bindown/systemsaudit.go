@@ -0,0 +1,57 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// SystemGap is a supported system a dependency can't be installed on.
+type SystemGap struct {
+	Dependency string
+	System     System
+	Message    string
+}
+
+func (g SystemGap) String() string {
+	return fmt.Sprintf("%s %s: %s", g.Dependency, g.System, g.Message)
+}
+
+// AuditSystems checks that every dependency resolves to a url with a checksum on every system in c.Systems. It returns
+// a gap for each dependency and system that doesn't.
+func (c *Config) AuditSystems() ([]SystemGap, error) {
+	if len(c.Systems) == 0 {
+		return nil, errors.New("the config has no supported systems to audit")
+	}
+	var gaps []SystemGap
+	for _, name := range c.DependencyNames() {
+		if c.Dependencies[name] == nil {
+			continue
+		}
+		dep := c.Dependencies[name].clone()
+		err := dep.applyTemplate(c.Templates, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, system := range c.Systems {
+			gap := SystemGap{Dependency: name, System: system}
+			if len(dep.Systems) > 0 && !slices.Contains(dep.Systems, system) {
+				gap.Message = fmt.Sprintf("%s has no %s artifact", name, system)
+				gaps = append(gaps, gap)
+				continue
+			}
+			var built *Dependency
+			built, err = c.BuildDependency(name, system)
+			switch {
+			case err != nil:
+				gap.Message = err.Error()
+			case built.checksum == "":
+				gap.Message = "no checksum for " + built.url
+			default:
+				continue
+			}
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
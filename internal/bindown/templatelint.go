@@ -0,0 +1,316 @@
+package bindown
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// LintTemplatesOpts options for Config.LintTemplates
+type LintTemplatesOpts struct {
+	// Source is the template source to lint instead of this config's templates. It is the name of a template source
+	// in the config or "builtin".
+	Source string
+	// Templates are the templates to lint. Default is all of them.
+	Templates []string
+}
+
+// templateField is a value in a template that is executed as a go template.
+type templateField struct {
+	path  string
+	value string
+	// extraVars are the variables bindown adds for this field in addition to os, arch and the dependency's vars
+	extraVars []string
+}
+
+// LintTemplates checks templates for mistakes that only show up when a dependency uses them: go templates that use
+// vars the template doesn't set or require, vars that are set only by overrides without being in required_vars, vars
+// that nothing uses and os or arch substitutions that don't match any of the template's systems.
+func (c *Config) LintTemplates(ctx context.Context, opts *LintTemplatesOpts) ([]ConfigProblem, error) {
+	if opts == nil {
+		opts = &LintTemplatesOpts{}
+	}
+	cfg := c
+	if opts.Source != "" {
+		var err error
+		cfg, err = c.templateSourceConfig(ctx, opts.Source)
+		if err != nil {
+			return nil, err
+		}
+	}
+	names := opts.Templates
+	if len(names) == 0 {
+		names = sortedKeys(cfg.Templates)
+	}
+	var problems []ConfigProblem
+	for _, name := range names {
+		if cfg.Templates[name] == nil {
+			return nil, fmt.Errorf("no template named %q", name)
+		}
+		problems = append(problems, cfg.lintTemplate(name)...)
+	}
+	return problems, nil
+}
+
+func (c *Config) lintTemplate(name string) []ConfigProblem {
+	tmpl := c.Templates[name]
+	path := "/templates/" + escapePointer(name)
+	chain := []*Dependency{tmpl}
+	for _, parent := range c.templateChain(tmpl) {
+		if c.Templates[parent] != nil {
+			chain = append(chain, c.Templates[parent])
+		}
+	}
+
+	// vars with a value on every system, vars that only some overrides set and vars that are used anywhere
+	defined := map[string]bool{"os": true, "arch": true}
+	known := map[string]bool{"os": true, "arch": true}
+	used := map[string]bool{}
+	for _, dep := range chain {
+		for _, v := range dep.RequiredVars {
+			defined[v] = true
+		}
+		for k := range dep.Vars {
+			defined[k] = true
+		}
+		addKnownVars(known, dep)
+		addMatcherVars(used, &dep.Overrideable)
+		for _, field := range dependencyTemplateFields("", dep) {
+			refs, err := templateVarRefs(field.value)
+			if err == nil {
+				for _, ref := range refs {
+					used[ref] = true
+				}
+			}
+		}
+	}
+
+	var problems []ConfigProblem
+	for _, field := range dependencyTemplateFields(path, tmpl) {
+		refs, err := templateVarRefs(field.value)
+		if err != nil {
+			problems = append(problems, ConfigProblem{
+				Path:    field.path,
+				Message: fmt.Sprintf("%q is not a valid template", field.value),
+			})
+			continue
+		}
+		for _, ref := range refs {
+			switch {
+			case defined[ref] || slices.Contains(field.extraVars, ref):
+			case known[ref]:
+				problems = append(problems, ConfigProblem{
+					Path:    field.path,
+					Message: fmt.Sprintf("var %q is only set by overrides. add it to vars or required_vars", ref),
+				})
+			default:
+				problems = append(problems, ConfigProblem{
+					Path:    field.path,
+					Message: fmt.Sprintf("undefined var %q", ref),
+				})
+			}
+		}
+	}
+
+	for _, k := range sortedKeys(tmpl.Vars) {
+		if !used[k] {
+			problems = append(problems, ConfigProblem{
+				Path:    path + "/vars/" + escapePointer(k),
+				Message: fmt.Sprintf("var %q is never used", k),
+			})
+		}
+	}
+	for i, v := range tmpl.RequiredVars {
+		if !used[v] {
+			problems = append(problems, ConfigProblem{
+				Path:    fmt.Sprintf("%s/required_vars/%d", path, i),
+				Message: fmt.Sprintf("required var %q is never used", v),
+			})
+		}
+	}
+
+	systems := tmpl.clone()
+	err := systems.applyTemplate(c.Templates, 0)
+	if err == nil {
+		problems = append(problems, unmatchedSubstitutions(path, &tmpl.Overrideable, c.templateSystems(systems))...)
+	}
+	return problems
+}
+
+// templateSystems returns the systems a resolved template supports. It is nil when neither the template nor the
+// config lists any.
+func (c *Config) templateSystems(tmpl *Dependency) []System {
+	if len(tmpl.Systems) > 0 {
+		return tmpl.Systems
+	}
+	return c.Systems
+}
+
+// unmatchedSubstitutions returns a problem for each os or arch substitution key in o that isn't the os or arch of one
+// of systems.
+func unmatchedSubstitutions(path string, o *Overrideable, systems []System) []ConfigProblem {
+	if len(systems) == 0 {
+		return nil
+	}
+	values := map[string]map[string]bool{"os": {}, "arch": {}}
+	for _, system := range systems {
+		values["os"][system.OS()] = true
+		values["arch"][system.Arch()] = true
+	}
+	var problems []ConfigProblem
+	for _, varName := range []string{"os", "arch"} {
+		for _, key := range sortedKeys(o.Substitutions[varName]) {
+			if !values[varName][key] {
+				problems = append(problems, ConfigProblem{
+					Path:    path + "/substitutions/" + varName + "/" + escapePointer(key),
+					Message: fmt.Sprintf("%s %q doesn't match any of the template's systems", varName, key),
+				})
+			}
+		}
+	}
+	for i := range o.Overrides {
+		overridePath := fmt.Sprintf("%s/overrides/%d/dependency", path, i)
+		problems = append(problems, unmatchedSubstitutions(overridePath, &o.Overrides[i].Dependency, systems)...)
+	}
+	return problems
+}
+
+// addMatcherVars adds the keys of the override matchers in o to vars.
+func addMatcherVars(vars map[string]bool, o *Overrideable) {
+	for i := range o.Overrides {
+		for k := range o.Overrides[i].OverrideMatcher {
+			vars[k] = true
+		}
+		addMatcherVars(vars, &o.Overrides[i].Dependency)
+	}
+}
+
+// dependencyTemplateFields returns the values in dep that are executed as go templates. path is the json pointer to
+// dep.
+func dependencyTemplateFields(path string, dep *Dependency) []templateField {
+	fields := overrideableTemplateFields(path, &dep.Overrideable, nil)
+	add := func(name, value string, extraVars ...string) {
+		fields = append(fields, templateField{path: path + "/" + name, value: value, extraVars: extraVars})
+	}
+	if dep.InstallPath != nil {
+		add("install_path", *dep.InstallPath, "name", "bin")
+	}
+	if dep.ZsyncURL != nil {
+		add("zsync_url", *dep.ZsyncURL)
+	}
+	if dep.Attestation != nil {
+		add("attestation/repository", dep.Attestation.Repository)
+		add("attestation/signer_workflow", dep.Attestation.SignerWorkflow)
+	}
+	for _, k := range sortedKeys(dep.Env) {
+		add("env/"+escapePointer(k), dep.Env[k])
+	}
+	for i, arg := range dep.PresignCommand {
+		add("presign_command/"+strconv.Itoa(i), arg, "url")
+	}
+	for i, arg := range dep.ExtractCommand {
+		add("extract_command/"+strconv.Itoa(i), arg, "download", "dir")
+	}
+	for i, arg := range dep.ValidateCommand {
+		add("validate_command/"+strconv.Itoa(i), arg, "path")
+	}
+	if dep.ValidateOutput != nil {
+		add("validate_output", *dep.ValidateOutput)
+	}
+	for i, arg := range dep.CompletionCommand {
+		add("completion_command/"+strconv.Itoa(i), arg, "path", "shell")
+	}
+	return fields
+}
+
+// overrideableTemplateFields returns the values in o that are executed as go templates. overrideVars are the vars set
+// by the overrides o is in, which are always set when o's values are used.
+func overrideableTemplateFields(path string, o *Overrideable, overrideVars []string) []templateField {
+	var fields []templateField
+	add := func(name string, value *string) {
+		if value != nil {
+			fields = append(fields, templateField{path: path + "/" + name, value: *value, extraVars: overrideVars})
+		}
+	}
+	add("url", o.URL)
+	add("archive_path", o.ArchivePath)
+	add("bin", o.BinName)
+	for i := range o.Entrypoints {
+		add("entrypoints/"+strconv.Itoa(i), &o.Entrypoints[i])
+	}
+	for _, k := range sortedKeys(o.Vars) {
+		// only vars computed from other vars are templates
+		if strings.Contains(o.Vars[k], "{{") {
+			value := o.Vars[k]
+			add("vars/"+escapePointer(k), &value)
+		}
+	}
+	for i := range o.Overrides {
+		override := &o.Overrides[i].Dependency
+		vars := append(slices.Clone(overrideVars), MapKeys(override.Vars)...)
+		fields = append(fields, overrideableTemplateFields(
+			fmt.Sprintf("%s/overrides/%d/dependency", path, i), override, vars,
+		)...)
+	}
+	return fields
+}
+
+// templateVarRefs returns the names of the vars the go template s uses, like "version" for "{{.version}}".
+func templateVarRefs(s string) ([]string, error) {
+	tmpl, err := template.New("").Funcs(templateFuncs).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if !slices.Contains(refs, node.Ident[0]) {
+				refs = append(refs, node.Ident[0])
+			}
+		case *parse.ChainNode:
+			walk(node.Node)
+		case *parse.IfNode:
+			walk(&node.BranchNode)
+		case *parse.RangeNode:
+			walk(&node.BranchNode)
+		case *parse.WithNode:
+			walk(&node.BranchNode)
+		case *parse.BranchNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		}
+	}
+	if tmpl.Tree != nil {
+		walk(tmpl.Tree.Root)
+	}
+	return refs, nil
+}
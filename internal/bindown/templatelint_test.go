@@ -0,0 +1,83 @@
+package bindown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_LintTemplates(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+systems: [linux/amd64, windows/amd64]
+templates:
+  tmpl:
+    url: https://example.com/v{{.major}}/foo-{{.os}}-{{.arch}}-{{.version}}{{.suffix}}
+    install_path: bin/{{.bin}}
+    required_vars: [version]
+    vars:
+      suffix: ""
+      major: "{{ semverMajor .version }}"
+    overrides:
+      - matcher:
+          os: [windows]
+        dependency:
+          vars:
+            ext: .zip
+          url: https://example.com/foo-{{.os}}-{{.version}}{{.ext}}
+    substitutions:
+      arch:
+        amd64: x86_64
+`)
+		problems, err := cfg.LintTemplates(context.Background(), nil)
+		require.NoError(t, err)
+		require.Empty(t, problems)
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+systems: [linux/amd64, darwin/arm64]
+templates:
+  parent:
+    vars:
+      unused: x
+  tmpl:
+    template: parent
+    url: https://example.com/foo-{{.os}}-{{.verison}}{{.suffix}}
+    archive_path: "{{.bad"
+    required_vars: [version]
+    overrides:
+      - matcher:
+          os: [darwin]
+        dependency:
+          vars:
+            suffix: .tgz
+    substitutions:
+      os:
+        windows: Windows
+      arch:
+        arm64: aarch64
+        x86: "386"
+`)
+		problems, err := cfg.LintTemplates(context.Background(), &LintTemplatesOpts{
+			Templates: []string{"tmpl", "parent"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []ConfigProblem{
+			{Path: "/templates/tmpl/url", Message: `undefined var "verison"`},
+			{Path: "/templates/tmpl/url", Message: `var "suffix" is only set by overrides. add it to vars or required_vars`},
+			{Path: "/templates/tmpl/archive_path", Message: `"{{.bad" is not a valid template`},
+			{Path: "/templates/tmpl/required_vars/0", Message: `required var "version" is never used`},
+			{Path: "/templates/tmpl/substitutions/os/windows", Message: `os "windows" doesn't match any of the template's systems`},
+			{Path: "/templates/tmpl/substitutions/arch/x86", Message: `arch "x86" doesn't match any of the template's systems`},
+			{Path: "/templates/parent/vars/unused", Message: `var "unused" is never used`},
+		}, problems)
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `{}`)
+		_, err := cfg.LintTemplates(context.Background(), &LintTemplatesOpts{Templates: []string{"nope"}})
+		require.EqualError(t, err, `no template named "nope"`)
+	})
+}
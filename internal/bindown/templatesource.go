@@ -0,0 +1,286 @@
+package bindown
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// loadTemplateSource loads the config at src, which is a path, an http(s) url, an oci:// reference or
+// BuiltinTemplateSource. When src belongs to a template source with a mirror in TemplateSourceMirrors, the mirror is
+// loaded instead. When src belongs to a template source with a digest in TemplateSourceDigests, the content must match
+// the digest.
+func (c *Config) loadTemplateSource(ctx context.Context, src string) (*Config, error) {
+	if src == BuiltinTemplateSource {
+		return builtinTemplates(ctx)
+	}
+	var data []byte
+	var err error
+	mirrored := false
+	for _, name := range sortedKeys(c.TemplateSourceMirrors) {
+		if c.TemplateSources[name] == src {
+			data, err = os.ReadFile(c.templateSourceMirrorPath(name))
+			mirrored = true
+			break
+		}
+	}
+	if !mirrored {
+		data, err = configSourceData(ctx, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(c.TemplateSources) {
+		if c.TemplateSources[name] != src {
+			continue
+		}
+		err = c.checkTemplateSourceDigest(name, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ConfigFromYAML(ctx, data)
+}
+
+// checkTemplateSourceDigest returns a *ConfigError when the template source name is pinned to a digest data doesn't
+// match.
+func (c *Config) checkTemplateSourceDigest(name string, data []byte) error {
+	want := c.TemplateSourceDigests[name]
+	if want == "" {
+		return nil
+	}
+	got := contentDigest(data)
+	if got != want {
+		return &ConfigError{Err: fmt.Errorf(
+			"template source %q has changed. its digest is %s, but %s is pinned. "+
+				`if the change is expected, run "bindown template-source pin %s"`,
+			name, got, want, name,
+		)}
+	}
+	return nil
+}
+
+// configSourceData returns the content of the config at src.
+func configSourceData(ctx context.Context, src string) ([]byte, error) {
+	srcURL, err := url.Parse(src)
+	if err == nil {
+		switch srcURL.Scheme {
+		case "http", "https":
+			return httpConfigData(ctx, src)
+		case "oci":
+			return ociConfigData(ctx, src)
+		}
+	}
+	return os.ReadFile(src)
+}
+
+// contentDigest returns the digest of data in the form used by TemplateSourceDigests.
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// PinTemplateSourceOpts options for Config.PinTemplateSource
+type PinTemplateSourceOpts struct {
+	// Commit changes the ref of a raw.githubusercontent.com url to the commit sha it currently points to before the
+	// digest is recorded.
+	Commit bool
+	// GitHubToken is used to look up the commit.
+	GitHubToken string
+}
+
+// PinTemplateSource records the digest of a template source's current content in TemplateSourceDigests and returns
+// it. Templates are only copied from the source while its content matches the digest.
+func (c *Config) PinTemplateSource(ctx context.Context, name string, opts *PinTemplateSourceOpts) (string, error) {
+	if opts == nil {
+		opts = &PinTemplateSourceOpts{}
+	}
+	src := c.TemplateSources[name]
+	if src == "" {
+		return "", fmt.Errorf("no template source named %q", name)
+	}
+	if opts.Commit {
+		var err error
+		src, err = c.pinGitHubCommit(ctx, src, opts.GitHubToken)
+		if err != nil {
+			return "", err
+		}
+	}
+	data, err := configSourceData(ctx, src)
+	if err != nil {
+		return "", err
+	}
+	// make sure it is a config before trusting it
+	_, err = ConfigFromYAML(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	digest := contentDigest(data)
+	c.TemplateSources[name] = src
+	if c.TemplateSourceDigests == nil {
+		c.TemplateSourceDigests = map[string]string{}
+	}
+	c.TemplateSourceDigests[name] = digest
+	return digest, nil
+}
+
+var (
+	rawGitHubURLExp = regexp.MustCompile(`^https://raw\.githubusercontent\.com/([^/]+)/([^/]+)/([^/]+)/(.+)$`)
+	commitSHAExp    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// pinGitHubCommit returns src with its ref replaced by the commit sha the ref points to. src must be a
+// raw.githubusercontent.com url or a https://<host>/raw/<owner>/<repo>/<ref>/<path> url on one of the config's
+// GitHubEnterpriseHosts. It is returned unchanged when its ref is already a commit sha.
+func (c *Config) pinGitHubCommit(ctx context.Context, src, token string) (_ string, errOut error) {
+	apiURL := githubAPIURL
+	rawURLPrefix := "https://raw.githubusercontent.com/"
+	m := rawGitHubURLExp.FindStringSubmatch(src)
+	if m == nil {
+		m, apiURL, rawURLPrefix = c.githubEnterpriseRawURL(src)
+		// never send the github.com token to another host
+		token = githubEnterpriseToken()
+	}
+	if m == nil {
+		if len(c.GitHubEnterpriseHosts) > 0 {
+			return "", fmt.Errorf("can only pin a commit for raw.githubusercontent.com urls or raw urls on github_enterprise_hosts, not %s", src)
+		}
+		return "", fmt.Errorf("can only pin a commit for raw.githubusercontent.com urls, not %s", src)
+	}
+	owner, repo, ref, filePath := m[1], m[2], m[3], m[4]
+	if commitSHAExp.MatchString(ref) {
+		return src, nil
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/commits/%s", apiURL, owner, repo, url.PathEscape(ref))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.downloader().httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return "", &downloadError{err: fmt.Errorf("failed looking up %s in %s/%s: %s", ref, owner, repo, resp.Status)}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	sha := strings.TrimSpace(string(body))
+	if !commitSHAExp.MatchString(sha) {
+		return "", fmt.Errorf("unexpected commit sha for %s in %s/%s: %q", ref, owner, repo, sha)
+	}
+	return fmt.Sprintf("%s%s/%s/%s/%s", rawURLPrefix, owner, repo, sha, filePath), nil
+}
+
+// githubEnterpriseRawURL matches src against https://<host>/raw/<owner>/<repo>/<ref>/<path> for the config's
+// GitHubEnterpriseHosts. It returns the submatches like rawGitHubURLExp's along with the host's api url and the prefix
+// of its raw urls. The submatches are nil when src isn't one.
+func (c *Config) githubEnterpriseRawURL(src string) (m []string, apiURL, rawURLPrefix string) {
+	u, err := url.Parse(src)
+	if err != nil || !githubEnterpriseHost(u, c.GitHubEnterpriseHosts) {
+		return nil, "", ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 5)
+	if len(parts) < 5 || parts[0] != "raw" || slices.Contains(parts, "") {
+		return nil, "", ""
+	}
+	apiURL, _ = githubAPIURLFor(u, c.GitHubEnterpriseHosts)
+	return []string{src, parts[1], parts[2], parts[3], parts[4]}, apiURL, u.Scheme + "://" + u.Host + "/raw/"
+}
+
+// templateSourceMirrorPath returns the path of the mirror of the template source name.
+func (c *Config) templateSourceMirrorPath(name string) string {
+	file := filepath.FromSlash(c.TemplateSourceMirrors[name])
+	if !filepath.IsAbs(file) && c.Filename != "" {
+		file = filepath.Join(filepath.Dir(c.Filename), file)
+	}
+	return file
+}
+
+// ExportTemplateSource writes the current content of the template source name to file and records file in
+// TemplateSourceMirrors, so templates are copied from it from now on. file is relative to the working directory. It is
+// recorded relative to the config file when it is in the config file's directory.
+func (c *Config) ExportTemplateSource(ctx context.Context, name, file string) error {
+	if c.TemplateSources[name] == "" {
+		return fmt.Errorf("no template source named %q", name)
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	mirror := absFile
+	if c.Filename != "" {
+		configDir, err := filepath.Abs(filepath.Dir(c.Filename))
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(configDir, absFile)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			mirror = filepath.ToSlash(rel)
+		}
+	}
+	_, err = c.writeTemplateSourceMirror(ctx, name, absFile)
+	if err != nil {
+		return err
+	}
+	if c.TemplateSourceMirrors == nil {
+		c.TemplateSourceMirrors = map[string]string{}
+	}
+	c.TemplateSourceMirrors[name] = mirror
+	return nil
+}
+
+// SyncTemplateSource refreshes the mirror of the template source name from the source. It returns whether the
+// mirror's content changed.
+func (c *Config) SyncTemplateSource(ctx context.Context, name string) (bool, error) {
+	if c.TemplateSources[name] == "" {
+		return false, fmt.Errorf("no template source named %q", name)
+	}
+	if c.TemplateSourceMirrors[name] == "" {
+		return false, fmt.Errorf(`template source %q has no mirror. create one with "bindown template-source export"`, name)
+	}
+	return c.writeTemplateSourceMirror(ctx, name, c.templateSourceMirrorPath(name))
+}
+
+// writeTemplateSourceMirror writes the content of the template source name to file. It returns whether file changed.
+func (c *Config) writeTemplateSourceMirror(ctx context.Context, name, file string) (bool, error) {
+	data, err := configSourceData(ctx, c.TemplateSources[name])
+	if err != nil {
+		return false, err
+	}
+	// make sure it is a config before writing it
+	_, err = ConfigFromYAML(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	err = c.checkTemplateSourceDigest(name, data)
+	if err != nil {
+		return false, err
+	}
+	existing, err := os.ReadFile(file)
+	if err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0o755)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,118 @@
+package bindown
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_PinTemplateSource(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "source.yaml")
+	data, err := os.ReadFile(filepath.Join("testdata", "configs", "ex1.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(src, data, 0o600))
+	cfg := &Config{TemplateSources: map[string]string{"origin": src}}
+
+	digest, err := cfg.PinTemplateSource(ctx, "origin", nil)
+	require.NoError(t, err)
+	require.Equal(t, contentDigest(data), digest)
+	require.Equal(t, map[string]string{"origin": digest}, cfg.TemplateSourceDigests)
+
+	_, _, err = cfg.addOrGetTemplate(ctx, "goreleaser", "origin")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(src, append(data, "\n# changed\n"...), 0o600))
+	_, _, err = cfg.addOrGetTemplate(ctx, "golangci-lint", "origin")
+	require.ErrorContains(t, err, `template source "origin" has changed`)
+	_, err = cfg.ListTemplates(ctx, "origin")
+	require.ErrorContains(t, err, `template source "origin" has changed`)
+
+	_, err = cfg.PinTemplateSource(ctx, "missing", nil)
+	require.EqualError(t, err, `no template source named "missing"`)
+}
+
+func TestConfig_ExportTemplateSource(t *testing.T) {
+	ctx := context.Background()
+	data, err := os.ReadFile(filepath.Join("testdata", "configs", "ex1.yaml"))
+	require.NoError(t, err)
+	content := data
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	dir := t.TempDir()
+	cfg := &Config{
+		Filename:        filepath.Join(dir, ".bindown.yaml"),
+		TemplateSources: map[string]string{"origin": ts.URL + "/source.yaml"},
+	}
+	mirror := filepath.Join(dir, "templates", "origin.yaml")
+
+	require.NoError(t, cfg.ExportTemplateSource(ctx, "origin", mirror))
+	require.Equal(t, map[string]string{"origin": "templates/origin.yaml"}, cfg.TemplateSourceMirrors)
+	got, err := os.ReadFile(mirror)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	changed, err := cfg.SyncTemplateSource(ctx, "origin")
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	content = append(data, "\n# changed\n"...)
+	changed, err = cfg.SyncTemplateSource(ctx, "origin")
+	require.NoError(t, err)
+	require.True(t, changed)
+	got, err = os.ReadFile(mirror)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+
+	// templates come from the mirror once the source is gone
+	ts.Close()
+	templates, err := cfg.ListTemplates(ctx, "origin")
+	require.NoError(t, err)
+	require.Equal(t, []string{"golangci-lint", "goreleaser"}, templates)
+
+	cfg.TemplateSourceDigests = map[string]string{"origin": contentDigest(data)}
+	_, err = cfg.ListTemplates(ctx, "origin")
+	require.ErrorContains(t, err, `template source "origin" has changed`)
+
+	cfg.TemplateSources["other"] = ts.URL + "/other.yaml"
+	_, err = cfg.SyncTemplateSource(ctx, "other")
+	require.EqualError(t, err, `template source "other" has no mirror. create one with "bindown template-source export"`)
+}
+
+func TestConfig_pinGitHubCommit(t *testing.T) {
+	ctx := context.Background()
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/commits/main" || r.Header.Get("Accept") != "application/vnd.github.sha" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(sha))
+	}))
+	t.Cleanup(ts.Close)
+	orig := githubAPIURL
+	githubAPIURL = ts.URL
+	t.Cleanup(func() { githubAPIURL = orig })
+	cfg := &Config{}
+
+	got, err := cfg.pinGitHubCommit(ctx, "https://raw.githubusercontent.com/owner/repo/main/templates/bindown.yaml", "")
+	require.NoError(t, err)
+	require.Equal(t, "https://raw.githubusercontent.com/owner/repo/"+sha+"/templates/bindown.yaml", got)
+
+	pinned := "https://raw.githubusercontent.com/owner/repo/" + sha + "/bindown.yaml"
+	got, err = cfg.pinGitHubCommit(ctx, pinned, "")
+	require.NoError(t, err)
+	require.Equal(t, pinned, got)
+
+	_, err = cfg.pinGitHubCommit(ctx, "https://raw.githubusercontent.com/owner/repo/nope/bindown.yaml", "")
+	require.EqualError(t, err, "failed looking up nope in owner/repo: 404 Not Found")
+
+	_, err = cfg.pinGitHubCommit(ctx, "https://example.com/bindown.yaml", "")
+	require.EqualError(t, err, "can only pin a commit for raw.githubusercontent.com urls, not https://example.com/bindown.yaml")
+}
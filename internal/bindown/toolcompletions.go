@@ -0,0 +1,159 @@
+package bindown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CompletionShells are the shells InstallToolCompletions writes completion scripts for.
+var CompletionShells = []string{"bash", "zsh", "fish"}
+
+// InstallToolCompletionsOpts options for Config.InstallToolCompletions
+type InstallToolCompletionsOpts struct {
+	// Shell is the shell to write completion scripts for. It is one of CompletionShells.
+	Shell string
+	// Dir is where completion scripts are written.
+	Dir string
+	// Stdout gets a line for each completion script written.
+	Stdout io.Writer
+	// Color colors the status at the start of each line written to Stdout.
+	Color bool
+	// Stderr gets warnings about missing checksums.
+	Stderr io.Writer
+	// AllowMissingChecksum installs dependencies that have no checksum for the current system.
+	AllowMissingChecksum bool
+}
+
+// InstallToolCompletions installs deps for the current system and writes the completion script each one's
+// completion_command prints to opts.Dir, named the way opts.Shell looks for it. When deps is empty, all the
+// dependencies with a completion_command that support the current system are used.
+func (c *Config) InstallToolCompletions(deps []string, opts *InstallToolCompletionsOpts) error {
+	if opts == nil {
+		opts = &InstallToolCompletionsOpts{}
+	}
+	if !slices.Contains(CompletionShells, opts.Shell) {
+		return fmt.Errorf("unsupported shell %q. must be one of %s", opts.Shell, strings.Join(CompletionShells, ", "))
+	}
+	if opts.Dir == "" {
+		return fmt.Errorf("no completions directory")
+	}
+	if len(deps) == 0 {
+		var err error
+		deps, err = c.completionDependencies()
+		if err != nil {
+			return err
+		}
+	}
+	installOpts := &ConfigInstallDependenciesOpts{
+		AllowMissingChecksum: opts.AllowMissingChecksum,
+		Stderr:               opts.Stderr,
+	}
+	for _, name := range deps {
+		dep, err := c.buildSupported(name, CurrentSystem, nil)
+		if err != nil {
+			return err
+		}
+		if len(dep.CompletionCommand) == 0 {
+			return fmt.Errorf("dependency %q has no completion_command", name)
+		}
+		binPath, _, err := c.installDependency(name, CurrentSystem, c.InstallDir, true, nil, installOpts)
+		if err != nil {
+			return err
+		}
+		script, err := dep.completionScript(binPath, opts.Shell)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(opts.Dir, 0o755)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(opts.Dir, completionFileName(opts.Shell, dep.binName()))
+		err = os.WriteFile(target, script, 0o644)
+		if err != nil {
+			return err
+		}
+		if opts.Stdout != nil {
+			err = writeInstallStatus(opts.Stdout, opts.Color, false, name+" completions", target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// completionDependencies returns the names of the dependencies that have a completion_command and support the
+// current system.
+func (c *Config) completionDependencies() ([]string, error) {
+	var names []string
+	for _, name := range c.DependencyNames() {
+		systems, err := c.DependencySystems(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(systems, CurrentSystem) {
+			continue
+		}
+		dep, err := c.BuildDependency(name, CurrentSystem)
+		if err != nil {
+			return nil, err
+		}
+		if len(dep.CompletionCommand) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// completionScript runs the dependency's completion_command for shell against the bin installed at binPath and returns
+// what it writes to stdout. Only stdout is used so warnings on stderr don't end up in the script.
+func (d *Dependency) completionScript(binPath, shell string) ([]byte, error) {
+	binPath, err := filepath.Abs(binPath)
+	if err != nil {
+		return nil, err
+	}
+	vars := maps.Clone(d.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["path"] = binPath
+	vars["shell"] = shell
+	args, err := executeCommandTemplates(d.CompletionCommand, vars)
+	if err != nil {
+		return nil, fmt.Errorf("completion_command for %s: %w", d.name, err)
+	}
+	ctx, cancel := d.downloader.context()
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("completion_command for %s failed: %w\n%s", d.name, err, strings.TrimSpace(stderr.String()))
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, fmt.Errorf("completion_command for %s printed nothing", d.name)
+	}
+	return out, nil
+}
+
+// completionFileName returns the name shell looks for bin's completion script under in its completions directory.
+func completionFileName(shell, bin string) string {
+	bin = strings.TrimSuffix(bin, ".exe")
+	switch shell {
+	case "zsh":
+		return "_" + bin
+	case "fish":
+		return bin + ".fish"
+	default:
+		return bin
+	}
+}
@@ -0,0 +1,75 @@
+package bindown
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_InstallToolCompletions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script")
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"tool": "#!/bin/sh\necho warning >&2\n[ \"$1\" = completion ] || exit 0\necho \"complete -F _tool $2\"\n",
+	})
+	sum, err := fileChecksum(archive)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, archive, "/tool.tar.gz", "")
+	depURL := ts.URL + "/tool.tar.gz"
+	config := mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+dependencies:
+  tool:
+    url: %q
+    completion_command: ["{{ .path }}", completion, "{{ .shell }}"]
+  broken:
+    url: %[3]q
+    bin: broken
+    archive_path: tool
+    completion_command: ["{{ .path }}", help]
+  other:
+    url: %[3]q
+    archive_path: tool
+url_checksums:
+  %[3]q: %s
+`, filepath.Join(dir, "bin"), filepath.Join(dir, "cache"), depURL, sum))
+	completionsDir := filepath.Join(dir, "completions")
+
+	var stdout bytes.Buffer
+	err = config.InstallToolCompletions([]string{"tool"}, &InstallToolCompletionsOpts{
+		Shell:  "zsh",
+		Dir:    completionsDir,
+		Stdout: &stdout,
+	})
+	require.NoError(t, err)
+	target := filepath.Join(completionsDir, "_tool")
+	require.Equal(t, fmt.Sprintf("installed tool completions to %s\n", target), stdout.String())
+	testutil.AssertFile(t, target, false, false)
+	require.FileExists(t, filepath.Join(dir, "bin", "tool"))
+	got, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, "complete -F _tool zsh\n", string(got))
+
+	err = config.InstallToolCompletions([]string{"broken"}, &InstallToolCompletionsOpts{Shell: "bash", Dir: completionsDir})
+	require.EqualError(t, err, "completion_command for broken printed nothing")
+
+	err = config.InstallToolCompletions([]string{"other"}, &InstallToolCompletionsOpts{Shell: "bash", Dir: completionsDir})
+	require.EqualError(t, err, `dependency "other" has no completion_command`)
+
+	err = config.InstallToolCompletions(nil, &InstallToolCompletionsOpts{Shell: "tcsh", Dir: completionsDir})
+	require.EqualError(t, err, `unsupported shell "tcsh". must be one of bash, zsh, fish`)
+
+	deps, err := config.completionDependencies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"broken", "tool"}, deps)
+}
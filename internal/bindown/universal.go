@@ -0,0 +1,155 @@
+package bindown
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// universalSystems are the systems whose bins are merged into a macOS universal binary.
+var universalSystems = []System{"darwin/amd64", "darwin/arm64"}
+
+// universalAlign is the log2 alignment of each architecture in a universal binary. It is the arm64 page size, which
+// also satisfies amd64.
+const universalAlign = 14
+
+// universalDependencies returns dep built for each of universalSystems. It returns nil when dep isn't for darwin or
+// doesn't support both systems.
+func (c *Config) universalDependencies(dep *Dependency) ([]*Dependency, error) {
+	dep.mustBeBuilt()
+	if dep.system.OS() != "darwin" || len(dep.Entrypoints) > 0 || (dep.Link != nil && *dep.Link) {
+		return nil, nil
+	}
+	deps := make([]*Dependency, 0, len(universalSystems))
+	for _, system := range universalSystems {
+		built, err := c.BuildDependency(dep.name, system)
+		if err != nil {
+			return nil, err
+		}
+		if checkSystem(built) != nil {
+			return nil, nil
+		}
+		deps = append(deps, built)
+	}
+	return deps, nil
+}
+
+// installUniversal installs a universal binary made from the bins of deps to targetPath.
+func (c *Config) installUniversal(deps []*Dependency, targetPath string, opts *ConfigInstallDependenciesOpts) (_ string, skipped bool, errOut error) {
+	bins := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		allowMissing, err := c.missingChecksumAllowed(dep, opts.AllowMissingChecksum, opts.Stderr)
+		if err != nil {
+			return "", false, err
+		}
+		extractDir, unlock, err := downloadAndExtract(dep, c.dependencyCacheDir(dep, c.Cache), opts.Force, allowMissing, opts.Stream)
+		if err != nil {
+			return "", false, dep.downloader.wrapTimeout(err)
+		}
+		defer deferErr(&errOut, unlock)
+		extractBin := dep.extractedBin(extractDir)
+		err = verifyBinChecksum(dep, extractBin)
+		if err != nil {
+			return "", false, err
+		}
+		bins = append(bins, extractBin)
+	}
+	err := os.MkdirAll(filepath.Dir(targetPath), 0o755)
+	if err != nil {
+		return "", false, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(targetPath), ".bindown-universal-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if errOut != nil || skipped {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	err = errors.Join(mergeUniversal(tmp, bins), tmp.Close())
+	if err != nil {
+		return "", false, err
+	}
+	err = os.Chmod(tmp.Name(), 0o755)
+	if err != nil {
+		return "", false, err
+	}
+	if !opts.Force {
+		skipped, err = isInstalled(targetPath, tmp.Name())
+		if err != nil || skipped {
+			return targetPath, skipped, err
+		}
+	}
+	err = os.RemoveAll(targetPath)
+	if err != nil {
+		return "", false, err
+	}
+	err = os.Rename(tmp.Name(), targetPath)
+	if err != nil {
+		return "", false, err
+	}
+	for _, dep := range deps {
+		c.trustChecksum(dep)
+	}
+	return targetPath, false, nil
+}
+
+// mergeUniversal writes a universal binary made from the single architecture Mach-O files in bins to w like
+// "lipo -create". When every bin has the same content, such as a release that is already universal, it is copied as is.
+func mergeUniversal(w io.Writer, bins []string) error {
+	contents := make([][]byte, len(bins))
+	for i, bin := range bins {
+		content, err := os.ReadFile(bin)
+		if err != nil {
+			return err
+		}
+		contents[i] = content
+	}
+	same := true
+	for _, content := range contents[1:] {
+		same = same && bytes.Equal(content, contents[0])
+	}
+	if same {
+		_, err := w.Write(contents[0])
+		return err
+	}
+	header := []uint32{macho.MagicFat, uint32(len(contents))}
+	offset := alignUniversal(uint32(8 + 20*len(contents)))
+	for i, content := range contents {
+		f, err := macho.NewFile(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("%s is not a single architecture Mach-O file: %w", bins[i], err)
+		}
+		header = append(header, uint32(f.Cpu), f.SubCpu, offset, uint32(len(content)), universalAlign)
+		offset = alignUniversal(offset + uint32(len(content)))
+	}
+	err := binary.Write(w, binary.BigEndian, header)
+	if err != nil {
+		return err
+	}
+	written := uint32(4 * len(header))
+	for _, content := range contents {
+		start := alignUniversal(written)
+		_, err = w.Write(make([]byte, start-written))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		if err != nil {
+			return err
+		}
+		written = start + uint32(len(content))
+	}
+	return nil
+}
+
+func alignUniversal(offset uint32) uint32 {
+	const size = 1 << universalAlign
+	return (offset + size - 1) &^ (size - 1)
+}
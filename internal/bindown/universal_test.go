@@ -0,0 +1,91 @@
+package bindown
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+// writeThinMachO writes a Mach-O header with no load commands for cpu followed by payload.
+func writeThinMachO(t *testing.T, filename string, cpu macho.Cpu, payload string) {
+	t.Helper()
+	header := []uint32{macho.Magic64, uint32(cpu), 0, uint32(macho.TypeExec), 0, 0, 0, 0}
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, header))
+	_, err = f.WriteString(payload)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestConfig_InstallDependencies_universal(t *testing.T) {
+	dir := t.TempDir()
+	writeThinMachO(t, filepath.Join(dir, "foo-amd64"), macho.CpuAmd64, "amd64 payload")
+	writeThinMachO(t, filepath.Join(dir, "foo-arm64"), macho.CpuArm64, "arm64 payload")
+	ts := testutil.ServeFiles(t, map[string]string{
+		"/foo-amd64": filepath.Join(dir, "foo-amd64"),
+		"/foo-arm64": filepath.Join(dir, "foo-arm64"),
+	})
+	setup := func(t *testing.T, systems string) *Config {
+		t.Helper()
+		dir := t.TempDir()
+		return mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+install_dir: %q
+dependencies:
+  foo:
+    url: %s/foo-{{.arch}}
+    archive_path: foo-{{.arch}}
+    bin: foo
+    systems: %s
+`, filepath.Join(dir, "cache"), filepath.Join(dir, "bin"), ts.URL, systems))
+	}
+
+	t.Run("universal", func(t *testing.T) {
+		cfg := setup(t, "[darwin/amd64, darwin/arm64]")
+		opts := &ConfigInstallDependenciesOpts{AllowMissingChecksum: true, Universal: true}
+		err := cfg.InstallDependencies([]string{"foo"}, "darwin/arm64", opts)
+		require.NoError(t, err)
+		target := filepath.Join(cfg.InstallDir, "foo")
+		testutil.AssertFile(t, target, true, false)
+		fat, err := macho.OpenFat(target)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, fat.Close()) })
+		require.Len(t, fat.Arches, 2)
+		require.Equal(t, macho.CpuAmd64, fat.Arches[0].Cpu)
+		require.Equal(t, macho.CpuArm64, fat.Arches[1].Cpu)
+
+		var stdout bytes.Buffer
+		opts.Stdout = &stdout
+		err = cfg.InstallDependencies([]string{"foo"}, "darwin/amd64", opts)
+		require.NoError(t, err)
+		require.Contains(t, stdout.String(), "is up to date")
+	})
+
+	t.Run("one darwin system", func(t *testing.T) {
+		cfg := setup(t, "[darwin/arm64]")
+		err := cfg.InstallDependencies([]string{"foo"}, "darwin/arm64", &ConfigInstallDependenciesOpts{
+			AllowMissingChecksum: true,
+			Universal:            true,
+		})
+		require.NoError(t, err)
+		f, err := macho.Open(filepath.Join(cfg.InstallDir, "foo"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.Equal(t, macho.CpuArm64, f.Cpu)
+	})
+
+	t.Run("not mach-o", func(t *testing.T) {
+		bin := filepath.Join(t.TempDir(), "bin")
+		require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755))
+		err := mergeUniversal(&bytes.Buffer{}, []string{filepath.Join(dir, "foo-amd64"), bin})
+		require.ErrorContains(t, err, "is not a single architecture Mach-O file")
+	})
+}
@@ -0,0 +1,39 @@
+package bindown
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultUserAgent returns the User-Agent header bindown sends when the config doesn't set user_agent.
+func DefaultUserAgent() string {
+	version := RunningVersion
+	if version == "" {
+		version = "dev"
+	}
+	return fmt.Sprintf("bindown/%s (%s; +https://github.com/willabides/bindown)", version, CurrentSystem)
+}
+
+// userAgentTransport sets the User-Agent header on requests that don't already have one.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		userAgent := t.userAgent
+		if userAgent == "" {
+			userAgent = DefaultUserAgent()
+		}
+		req.Header.Set("User-Agent", userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// defaultHTTPClient returns a client for requests that aren't made for a config, like loading a config from a url.
+func defaultHTTPClient() *http.Client {
+	var dl *downloader
+	return dl.httpClient()
+}
@@ -0,0 +1,54 @@
+package bindown
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgent(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "downloadables", "rawfile", "foo"))
+	require.NoError(t, err)
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(ts.Close)
+	download := func(t *testing.T, userAgent string) {
+		t.Helper()
+		depURL := ts.URL + "/foo"
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+user_agent: %q
+dependencies:
+  foo:
+    url: %s
+url_checksums:
+  %s: f044ff8b6007c74bcc1b5a5c92776e5d49d6014f5ff2d551fab115c17f48ac41
+`, userAgent, depURL, depURL))
+		cfg.Cache = filepath.Join(t.TempDir(), "cache")
+		t.Cleanup(func() { require.NoError(t, cfg.ClearCache()) })
+		err := cfg.DownloadDependencies([]string{"foo"}, "linux/amd64", &ConfigDownloadDependenciesOpts{
+			Output: filepath.Join(t.TempDir(), "foo"),
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		orig := RunningVersion
+		t.Cleanup(func() { RunningVersion = orig })
+		RunningVersion = "4.9.0"
+		download(t, "")
+		require.Equal(t, fmt.Sprintf("bindown/4.9.0 (%s; +https://github.com/willabides/bindown)", CurrentSystem), got)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		download(t, "corp-tools/1.0")
+		require.Equal(t, "corp-tools/1.0", got)
+	})
+}
@@ -1,10 +1,12 @@
 package bindown
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash"
 	"hash/fnv"
@@ -33,7 +35,7 @@ func executeTemplate(tmplString, goos, arch string, vars map[string]string) (str
 		"arch": arch,
 	}
 	maps.Copy(tmplData, vars)
-	tmpl, err := template.New("").Option("missingkey=error").Parse(tmplString)
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(templateFuncs).Parse(tmplString)
 	if err != nil {
 		return "", fmt.Errorf("%q is not a valid template", tmplString)
 	}
@@ -104,14 +106,22 @@ func mustWriteToHash(hasher hash.Hash, data []byte) {
 	}
 }
 
-// fileChecksum returns the hex checksum of a file
-func fileChecksum(filename string) (string, error) {
-	fileBytes, err := os.ReadFile(filename)
+// checksumBufferSize is how much of a file fileChecksum reads at a time.
+const checksumBufferSize = 1 << 20
+
+// fileChecksum returns the hex checksum of a file. The file is read in chunks, so large downloads aren't held in
+// memory.
+func fileChecksum(filename string) (_ string, errOut error) {
+	file, err := os.Open(filename)
 	if err != nil {
 		return "", err
 	}
+	defer deferErr(&errOut, file.Close)
 	hasher := sha256.New()
-	mustWriteToHash(hasher, fileBytes)
+	_, err = io.Copy(hasher, bufio.NewReaderSize(file, checksumBufferSize))
+	if err != nil {
+		return "", err
+	}
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
@@ -171,6 +181,80 @@ func copyFile(src, dst string) (errOut error) {
 	return err
 }
 
+// moveFile moves src to dst. It falls back to copying when they are on different filesystems.
+func moveFile(src, dst string) error {
+	if os.Rename(src, dst) == nil {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+// replaceFile atomically replaces dst with a copy of src that has the given mode. The copy is written to a temporary
+// file next to dst so it can be renamed into place without crossing filesystems.
+func replaceFile(src, dst string, mode os.FileMode) (errOut error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if errOut != nil {
+			errOut = errors.Join(errOut, os.Remove(tmpName))
+		}
+	}()
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = copyFile(src, tmpName)
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600
+	err = os.Chmod(tmpName, mode)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpName, dst)
+}
+
+// copyDir copies the contents of src to dst, replacing any files that are already there. Symlinks are recreated rather
+// than followed.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&os.ModeSymlink != 0:
+			var link string
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			err = os.Remove(target)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			// remove first because the existing file may not be writable
+			err = os.Remove(target)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return copyFile(path, target)
+		}
+	})
+}
+
 func deferErr(errOut *error, fn func() error) {
 	deferredErr := fn()
 	if *errOut == nil {
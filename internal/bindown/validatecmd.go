@@ -0,0 +1,62 @@
+package bindown
+
+import (
+	"fmt"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// runValidateCommand runs dep's validate_command against the bin installed at binPath and checks its output against
+// validate_output.
+func runValidateCommand(dep *Dependency, binPath string) error {
+	if len(dep.ValidateCommand) == 0 {
+		return nil
+	}
+	out, err := dep.runBinCommand(dep.ValidateCommand, binPath)
+	if err != nil {
+		return fmt.Errorf("validate_command for %s failed: %w", dep.name, err)
+	}
+	if dep.ValidateOutput == nil || *dep.ValidateOutput == "" {
+		return nil
+	}
+	pattern, err := executeCommandTemplates([]string{*dep.ValidateOutput}, dep.Vars)
+	if err != nil {
+		return fmt.Errorf("validate_output for %s: %w", dep.name, err)
+	}
+	exp, err := regexp.Compile(pattern[0])
+	if err != nil {
+		return fmt.Errorf("validate_output for %s: %w", dep.name, err)
+	}
+	if !exp.Match(out) {
+		return fmt.Errorf("output of validate_command for %s doesn't match %q:\n%s", dep.name, pattern[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// runBinCommand runs command with "path" set to binPath in addition to dep's vars and returns its combined output. When
+// the command fails, the error includes the output.
+func (d *Dependency) runBinCommand(command []string, binPath string) ([]byte, error) {
+	binPath, err := filepath.Abs(binPath)
+	if err != nil {
+		return nil, err
+	}
+	vars := maps.Clone(d.Vars)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["path"] = binPath
+	args, err := executeCommandTemplates(command, vars)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := d.downloader.context()
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
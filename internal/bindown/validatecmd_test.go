@@ -0,0 +1,82 @@
+package bindown
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/testutil"
+)
+
+func TestConfig_InstallDependencies_validateCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script")
+	}
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "tool.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"tool": "#!/bin/sh\necho \"tool version 1.2.0\"\n[ \"$1\" = --version ]\n",
+	})
+	sum, err := fileChecksum(archive)
+	require.NoError(t, err)
+	ts := testutil.ServeFile(t, archive, "/tool.tar.gz", "")
+	depURL := ts.URL + "/tool.tar.gz"
+
+	newConfig := func(validate string) *Config {
+		t.Helper()
+		return mustConfigFromYAML(t, fmt.Sprintf(`
+install_dir: %q
+cache: %q
+dependencies:
+  tool:
+    url: %q
+    vars:
+      version: 1.2.0
+%s
+url_checksums:
+  %q: %s
+`, filepath.Join(dir, "bin"), filepath.Join(dir, "cache"), depURL, validate, depURL, sum))
+	}
+
+	t.Run("passes", func(t *testing.T) {
+		config := newConfig(`
+    validate_command: ["{{ .path }}", "--version"]
+    validate_output: 'version {{ .version }}'`)
+		err := config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.NoError(t, err)
+		require.NoError(t, config.Validate("tool", []System{CurrentSystem}, nil))
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		config := newConfig(`
+    validate_command: ["{{ .path }}", "--help"]`)
+		err := config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.ErrorContains(t, err, "validate_command for tool failed: exit status 1\ntool version 1.2.0")
+		// the bin that failed isn't left behind to be skipped as up to date by the next install
+		require.NoFileExists(t, filepath.Join(dir, "bin", "tool"))
+		err = config.InstallDependencies([]string{"tool"}, CurrentSystem, nil)
+		require.ErrorContains(t, err, "validate_command for tool failed")
+		err = config.Validate("tool", []System{CurrentSystem}, nil)
+		require.ErrorContains(t, err, "validate_command for tool failed")
+	})
+
+	t.Run("output", func(t *testing.T) {
+		config := newConfig(`
+    validate_command: ["{{ .path }}", "--version"]
+    validate_output: 'version 2\.'`)
+		err := config.InstallDependencies([]string{"tool"}, CurrentSystem, &ConfigInstallDependenciesOpts{Force: true})
+		require.EqualError(t, err, "output of validate_command for tool doesn't match \"version 2\\\\.\":\ntool version 1.2.0")
+	})
+
+	t.Run("other system", func(t *testing.T) {
+		config := newConfig(`
+    validate_command: ["{{ .path }}", "--help"]`)
+		other := System("plan9/arm")
+		config.Dependencies["tool"].Systems = []System{other}
+		config.URLChecksums[depURL] = sum
+		err := config.InstallDependencies([]string{"tool"}, other, &ConfigInstallDependenciesOpts{Force: true})
+		require.NoError(t, err)
+	})
+}
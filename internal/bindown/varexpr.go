@@ -0,0 +1,77 @@
+package bindown
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// templateFuncs are the functions available in the go templates in a dependency, like
+// "{{ semverMajor .version }}" or `{{ .version | trimPrefix "v" }}`.
+var templateFuncs = template.FuncMap{
+	"semverMajor": semverPart(func(v *semver.Version) uint64 { return v.Major() }),
+	"semverMinor": semverPart(func(v *semver.Version) uint64 { return v.Minor() }),
+	"semverPatch": semverPart(func(v *semver.Version) uint64 { return v.Patch() }),
+	"trimPrefix":  func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix":  func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":     func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+}
+
+func semverPart(part func(v *semver.Version) uint64) func(string) (string, error) {
+	return func(s string) (string, error) {
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a semver version", s)
+		}
+		return strconv.FormatUint(part(v), 10), nil
+	}
+}
+
+// resolveVarExpressions replaces the values of vars that are templates, like "{{ semverMajor .version }}", with the
+// result of executing them with the other vars. They can use vars that are templates too, but not in a loop.
+func resolveVarExpressions(vars map[string]string) error {
+	resolving := map[string]bool{}
+	var resolve func(name string) error
+	resolve = func(name string) error {
+		value := vars[name]
+		if !strings.Contains(value, "{{") {
+			return nil
+		}
+		if resolving[name] {
+			return fmt.Errorf("var %q depends on itself", name)
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+		refs, err := templateVarRefs(value)
+		if err != nil {
+			return fmt.Errorf("var %q: %q is not a valid template", name, value)
+		}
+		for _, ref := range refs {
+			if _, ok := vars[ref]; !ok {
+				continue
+			}
+			err = resolve(ref)
+			if err != nil {
+				return err
+			}
+		}
+		resolved, err := executeTemplate(value, vars["os"], vars["arch"], vars)
+		if err != nil {
+			return fmt.Errorf("var %q: %w", name, err)
+		}
+		vars[name] = resolved
+		return nil
+	}
+	for _, name := range sortedKeys(vars) {
+		err := resolve(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
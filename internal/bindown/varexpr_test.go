@@ -0,0 +1,57 @@
+package bindown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveVarExpressions(t *testing.T) {
+	vars := map[string]string{
+		"os":      "linux",
+		"arch":    "amd64",
+		"version": "v2.5.1",
+		"major":   "{{ semverMajor .version }}",
+		"series":  "{{ .major }}.{{ semverMinor .version }}",
+		"plain":   `{{ .version | trimPrefix "v" }}`,
+		"file":    `tool-{{ .plain | replace "." "_" }}-{{ upper .os }}`,
+	}
+	require.NoError(t, resolveVarExpressions(vars))
+	require.Equal(t, map[string]string{
+		"os":      "linux",
+		"arch":    "amd64",
+		"version": "v2.5.1",
+		"major":   "2",
+		"series":  "2.5",
+		"plain":   "2.5.1",
+		"file":    "tool-2_5_1-LINUX",
+	}, vars)
+
+	err := resolveVarExpressions(map[string]string{"a": "{{ .b }}", "b": "{{ .a }}"})
+	require.EqualError(t, err, `var "a" depends on itself`)
+
+	err = resolveVarExpressions(map[string]string{"major": "{{ semverMajor .version }}", "version": "latest"})
+	require.EqualError(t, err, `var "major": error applying template: template: :1:3: executing "" at <semverMajor .version>: error calling semverMajor: "latest" is not a semver version`)
+}
+
+func TestConfig_BuildDependency_varExpressions(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+templates:
+  tool:
+    url: https://example.com/v{{.major}}/{{.version}}/tool-{{.os}}.tar.gz
+    vars:
+      major: "{{ semverMajor .version }}"
+    substitutions:
+      os:
+        darwin: macos
+dependencies:
+  tool:
+    template: tool
+    vars:
+      version: 3.1.0
+`)
+	dep, err := cfg.BuildDependency("tool", "darwin/arm64")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/v3/3.1.0/tool-macos.tar.gz", dep.url)
+	require.Equal(t, "3", dep.Vars["major"])
+}
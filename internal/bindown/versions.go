@@ -0,0 +1,447 @@
+package bindown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// githubAPIURL is the GitHub api used to list tags. It is a var so tests can point it at a test server.
+var githubAPIURL = "https://api.github.com"
+
+// maxTagPages limits how many pages of tags are requested from an upstream repository.
+const maxTagPages = 10
+
+var (
+	versionVarExp   = regexp.MustCompile(`\{\{-?\s*\.version\s*-?\}\}`)
+	templateExprExp = regexp.MustCompile(`\{\{.*?\}\}`)
+)
+
+// DependencyVersionsOpts options for Config.DependencyVersions
+type DependencyVersionsOpts struct {
+	// Prereleases includes semver prerelease versions.
+	Prereleases bool
+	// GitHubToken is used to authenticate to the GitHub api.
+	GitHubToken string
+	// GitLabToken is used to authenticate to the GitLab api.
+	GitLabToken string
+}
+
+// DependencyVersions lists the versions that can be used for a dependency's "version" var. The versions come from the
+// tags of the GitHub or GitLab repository the dependency's url downloads from, so only dependencies with urls in the
+// form of https://github.com/<owner>/<repo>/releases/download/<tag>/... or GitLab's equivalent are supported. Tags
+// that aren't semantic versions are skipped. The result is sorted newest first.
+func (c *Config) DependencyVersions(ctx context.Context, depName string, opts *DependencyVersionsOpts) ([]string, error) {
+	_, releases, err := c.dependencyReleases(ctx, depName, opts)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(releases))
+	for i := range releases {
+		versions[i] = releases[i].version
+	}
+	return versions, nil
+}
+
+// dependencyUpstreamRepo returns the repository depName is released from or nil if none of its urls are GitHub or
+// GitLab release urls.
+func (c *Config) dependencyUpstreamRepo(depName string) (*upstreamRepo, error) {
+	if c.Dependencies == nil || c.Dependencies[depName] == nil {
+		return nil, c.unknownDependencyError(depName)
+	}
+	dep := c.Dependencies[depName].clone()
+	err := dep.applyTemplate(c.Templates, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range dependencyURLTemplates(dep) {
+		repo := parseUpstreamRepo(u, c.GitHubEnterpriseHosts)
+		if repo != nil {
+			return repo, nil
+		}
+	}
+	return nil, nil
+}
+
+// upstreamRelease is a version and the tag it was released as.
+type upstreamRelease struct {
+	version string
+	tag     string
+}
+
+// dependencyReleases returns depName's upstream repository and its releases sorted newest first.
+func (c *Config) dependencyReleases(ctx context.Context, depName string, opts *DependencyVersionsOpts) (*upstreamRepo, []upstreamRelease, error) {
+	if opts == nil {
+		opts = &DependencyVersionsOpts{}
+	}
+	repo, err := c.dependencyUpstreamRepo(depName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if repo == nil {
+		return nil, nil, fmt.Errorf("can't find a GitHub or GitLab release url for %q", depName)
+	}
+	repo.setToken(opts.GitHubToken, opts.GitLabToken)
+	repo.apiCache, err = c.apiCache()
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, err := repo.tags(ctx, c.downloader().httpClient())
+	if err != nil {
+		return nil, nil, err
+	}
+	tagsByVersion := map[string]string{}
+	var versions []string
+	for _, tag := range tags {
+		m := repo.tagExp.FindStringSubmatch(tag)
+		if m == nil {
+			continue
+		}
+		ver, verErr := semver.NewVersion(m[1])
+		if verErr != nil || (ver.Prerelease() != "" && !opts.Prereleases) {
+			continue
+		}
+		if _, ok := tagsByVersion[m[1]]; !ok {
+			tagsByVersion[m[1]] = tag
+			versions = append(versions, m[1])
+		}
+	}
+	SortBySemverOrString(versions)
+	releases := make([]upstreamRelease, len(versions))
+	for i, v := range versions {
+		releases[i] = upstreamRelease{version: v, tag: tagsByVersion[v]}
+	}
+	return repo, releases, nil
+}
+
+// DependencyUpdate is a version change made by Config.UpdateDependencies.
+type DependencyUpdate struct {
+	Name       string
+	OldVersion string
+	NewVersion string
+	// ReleaseURL is the release page for NewVersion.
+	ReleaseURL string
+	// CompareURL shows the changes between OldVersion and NewVersion. It is empty when OldVersion has no tag.
+	CompareURL string
+}
+
+// UpdateDependenciesOpts options for Config.UpdateDependencies
+type UpdateDependenciesOpts struct {
+	DependencyVersionsOpts
+	// SkipChecksums leaves url_checksums alone. By default checksums are added for the new versions and the old
+	// versions' checksums are removed.
+	SkipChecksums bool
+}
+
+// UpdateDependencies sets the "version" var of each of deps to the newest version from DependencyVersions. When deps
+// is empty, every dependency that has a "version" var and a GitHub or GitLab release url is updated and the others
+// are skipped. Dependencies that are already up to date are left out of the result.
+func (c *Config) UpdateDependencies(ctx context.Context, deps []string, opts *UpdateDependenciesOpts) ([]DependencyUpdate, error) {
+	if opts == nil {
+		opts = &UpdateDependenciesOpts{}
+	}
+	all := len(deps) == 0
+	if all {
+		deps = sortedKeys(c.Dependencies)
+	}
+	var updates []DependencyUpdate
+	var oldURLs []string
+	for _, name := range deps {
+		dep := c.Dependencies[name]
+		if dep == nil {
+			return nil, c.unknownDependencyError(name)
+		}
+		current := dep.Vars["version"]
+		if current == "" {
+			if all {
+				continue
+			}
+			return nil, fmt.Errorf("dependency %q has no version var", name)
+		}
+		if current == LatestVersion {
+			if all {
+				continue
+			}
+			return nil, fmt.Errorf(`dependency %q tracks the latest version. use "bindown lock" to update it`, name)
+		}
+		if all {
+			repo, err := c.dependencyUpstreamRepo(name)
+			if err != nil {
+				return nil, err
+			}
+			if repo == nil {
+				continue
+			}
+		}
+		repo, releases, err := c.dependencyReleases(ctx, name, &opts.DependencyVersionsOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(releases) == 0 || !isNewerVersion(releases[0].version, current) {
+			continue
+		}
+		latest := releases[0]
+		update := DependencyUpdate{
+			Name:       name,
+			OldVersion: current,
+			NewVersion: latest.version,
+			ReleaseURL: repo.releaseURL(latest.tag),
+		}
+		for _, r := range releases {
+			if r.version == current {
+				update.CompareURL = repo.compareURL(r.tag, latest.tag)
+				break
+			}
+		}
+		if !opts.SkipChecksums {
+			urls, err := c.dependencyURLs(name)
+			if err != nil {
+				return nil, err
+			}
+			oldURLs = append(oldURLs, urls...)
+		}
+		err = c.SetDependencyVars(name, map[string]string{"version": latest.version})
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, update)
+	}
+	if opts.SkipChecksums || len(updates) == 0 {
+		return updates, nil
+	}
+	updated := make([]string, len(updates))
+	for i := range updates {
+		updated[i] = updates[i].Name
+	}
+	err := c.AddChecksums(updated, nil)
+	if err != nil {
+		return nil, err
+	}
+	inUse := map[string]bool{}
+	for name := range c.Dependencies {
+		urls, err := c.dependencyURLs(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range urls {
+			inUse[u] = true
+		}
+	}
+	for _, u := range oldURLs {
+		if !inUse[u] {
+			delete(c.URLChecksums, u)
+		}
+	}
+	return updates, nil
+}
+
+// isNewerVersion returns true when candidate is a greater semver than current or current isn't a semver.
+func isNewerVersion(candidate, current string) bool {
+	candidateVer, err := semver.NewVersion(candidate)
+	if err != nil {
+		return false
+	}
+	currentVer, err := semver.NewVersion(current)
+	if err != nil {
+		return candidate != current
+	}
+	return candidateVer.GreaterThan(currentVer)
+}
+
+// dependencyURLTemplates returns the unrendered urls from dep and its overrides.
+func dependencyURLTemplates(dep *Dependency) []string {
+	var urls []string
+	var add func(o *Overrideable)
+	add = func(o *Overrideable) {
+		if o.URL != nil {
+			urls = append(urls, *o.URL)
+		}
+		for i := range o.Overrides {
+			add(&o.Overrides[i].Dependency)
+		}
+	}
+	add(&dep.Overrideable)
+	return urls
+}
+
+type upstreamRepo struct {
+	kind    string
+	apiURL  string
+	webURL  string
+	project string
+	token   string
+	// enterprise is set for repositories on GitHub Enterprise Server.
+	enterprise bool
+	// tagExp matches the tags for releases. The first submatch is the version.
+	tagExp *regexp.Regexp
+	// apiCache caches api responses. It is nil when responses aren't cached.
+	apiCache *apiCache
+}
+
+// parseUpstreamRepo returns the repository a release download url template comes from or nil if it isn't a GitHub
+// or GitLab release url. Urls on githubHosts are GitHub Enterprise Server release urls.
+func parseUpstreamRepo(urlTmpl string, githubHosts []string) *upstreamRepo {
+	u, err := url.Parse(urlTmpl)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	repo := &upstreamRepo{}
+	var tagTmpl string
+	apiURL, isGitHub := githubAPIURLFor(u, githubHosts)
+	switch {
+	case isGitHub:
+		// /<owner>/<repo>/releases/download/<tag>/<file>
+		if len(segments) < 6 || segments[2] != "releases" || segments[3] != "download" {
+			return nil
+		}
+		repo.kind = "github"
+		repo.apiURL = apiURL
+		repo.enterprise = u.Host != "github.com"
+		repo.project = segments[0] + "/" + segments[1]
+		repo.webURL = u.Scheme + "://" + u.Host + "/" + repo.project
+		tagTmpl = segments[4]
+	default:
+		// /<group>/<project>/-/releases/<tag>/downloads/<file>
+		idx := strings.Index(u.Path, "/-/releases/")
+		if idx == -1 {
+			return nil
+		}
+		tagSegments := strings.Split(u.Path[idx+len("/-/releases/"):], "/")
+		repo.kind = "gitlab"
+		repo.apiURL = u.Scheme + "://" + u.Host + "/api/v4"
+		repo.project = strings.Trim(u.Path[:idx], "/")
+		repo.webURL = u.Scheme + "://" + u.Host + "/" + repo.project
+		tagTmpl = tagSegments[0]
+	}
+	if templateExprExp.MatchString(repo.project) {
+		return nil
+	}
+	repo.tagExp = tagTemplateExp(tagTmpl)
+	if repo.tagExp == nil {
+		return nil
+	}
+	return repo
+}
+
+// tagTemplateExp converts a tag template like "v{{.version}}" to a regular expression that matches tags and captures
+// the version.
+func tagTemplateExp(tagTmpl string) *regexp.Regexp {
+	loc := versionVarExp.FindStringIndex(tagTmpl)
+	if loc == nil {
+		return nil
+	}
+	quote := func(s string) string {
+		parts := templateExprExp.Split(s, -1)
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		return strings.Join(parts, ".*?")
+	}
+	return regexp.MustCompile("^" + quote(tagTmpl[:loc[0]]) + "(.+?)" + quote(tagTmpl[loc[1]:]) + "$")
+}
+
+// setToken sets the token for the repository's api. Repositories on GitHub Enterprise Server use
+// GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN, so a github.com token is never sent to another host.
+func (r *upstreamRepo) setToken(githubToken, gitlabToken string) {
+	switch {
+	case r.enterprise:
+		r.token = githubEnterpriseToken()
+	case r.kind == "github":
+		r.token = githubToken
+	case r.kind == "gitlab":
+		r.token = gitlabToken
+	}
+}
+
+func (r *upstreamRepo) releaseURL(tag string) string {
+	if r.kind == "github" {
+		return r.webURL + "/releases/tag/" + url.PathEscape(tag)
+	}
+	return r.webURL + "/-/releases/" + url.PathEscape(tag)
+}
+
+func (r *upstreamRepo) compareURL(fromTag, toTag string) string {
+	if r.kind == "github" {
+		return r.webURL + "/compare/" + url.PathEscape(fromTag) + "..." + url.PathEscape(toTag)
+	}
+	return r.webURL + "/-/compare/" + url.PathEscape(fromTag) + "..." + url.PathEscape(toTag)
+}
+
+// tags lists the repository's tags.
+func (r *upstreamRepo) tags(ctx context.Context, client *http.Client) ([]string, error) {
+	var tags []string
+	for page := 1; page <= maxTagPages; page++ {
+		var endpoint string
+		switch r.kind {
+		case "github":
+			endpoint = fmt.Sprintf("%s/repos/%s/tags?per_page=100&page=%d", r.apiURL, r.project, page)
+		default:
+			endpoint = fmt.Sprintf("%s/projects/%s/repository/tags?per_page=100&page=%d", r.apiURL, url.PathEscape(r.project), page)
+		}
+		pageTags, err := r.getTags(ctx, client, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, pageTags...)
+		if len(pageTags) < 100 {
+			break
+		}
+	}
+	return tags, nil
+}
+
+func (r *upstreamRepo) getTags(ctx context.Context, client *http.Client, endpoint string) ([]string, error) {
+	// the token is part of the key so responses for private repositories aren't shared across tokens
+	data, err := r.apiCache.get(endpoint+"\n"+r.token, func() ([]byte, error) {
+		return r.apiGet(ctx, client, endpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var body []struct {
+		Name string `json:"name"`
+	}
+	err = json.Unmarshal(data, &body)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed listing tags for %s", r.project), err)
+	}
+	tags := make([]string, len(body))
+	for i := range body {
+		tags[i] = body[i].Name
+	}
+	return tags, nil
+}
+
+// apiGet returns the body of a successful response from the repository's api.
+func (r *upstreamRepo) apiGet(ctx context.Context, client *http.Client, endpoint string) (_ []byte, errOut error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		switch r.kind {
+		case "github":
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		default:
+			req.Header.Set("PRIVATE-TOKEN", r.token)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed listing tags for %s: %s", r.project, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
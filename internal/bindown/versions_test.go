@@ -0,0 +1,196 @@
+package bindown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveTags(t *testing.T, wantPath, wantToken string, tags ...string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != wantPath {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Contains(t, []string{r.Header.Get("Authorization"), r.Header.Get("PRIVATE-TOKEN")}, wantToken)
+		body := make([]map[string]string, len(tags))
+		for i, tag := range tags {
+			body[i] = map[string]string{"name": tag}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(body))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestConfig_DependencyVersions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("github", func(t *testing.T) {
+		ts := serveTags(t, "/repos/jqlang/jq/tags", "Bearer tkn",
+			"jq-1.6", "jq-1.7.1", "jq-1.8.0-rc1", "jq-1.7", "v1.8.0", "nightly",
+		)
+		orig := githubAPIURL
+		githubAPIURL = ts.URL
+		t.Cleanup(func() { githubAPIURL = orig })
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  jq:
+    template: jq
+    vars:
+      version: "1.7"
+templates:
+  jq:
+    url: https://github.com/jqlang/jq/releases/download/jq-{{ .version }}/jq-{{.os}}-{{.arch}}
+`)
+		got, err := cfg.DependencyVersions(ctx, "jq", &DependencyVersionsOpts{GitHubToken: "tkn"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.7.1", "1.7", "1.6"}, got)
+
+		got, err = cfg.DependencyVersions(ctx, "jq", &DependencyVersionsOpts{GitHubToken: "tkn", Prereleases: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.8.0-rc1", "1.7.1", "1.7", "1.6"}, got)
+	})
+
+	t.Run("api cache", func(t *testing.T) {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			assert.NoError(t, json.NewEncoder(w).Encode([]map[string]string{{"name": "v1.0.0"}}))
+		}))
+		t.Cleanup(ts.Close)
+		orig := githubAPIURL
+		githubAPIURL = ts.URL
+		t.Cleanup(func() { githubAPIURL = orig })
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+cache: %q
+dependencies:
+  tool:
+    url: https://github.com/example/tool/releases/download/v{{.version}}/tool.tar.gz
+    vars:
+      version: 1.0.0
+`, t.TempDir()))
+		for i := 0; i < 2; i++ {
+			got, err := cfg.DependencyVersions(ctx, "tool", nil)
+			require.NoError(t, err)
+			require.Equal(t, []string{"1.0.0"}, got)
+		}
+		require.Equal(t, 1, requests)
+
+		// a different token doesn't share cached responses
+		_, err := cfg.DependencyVersions(ctx, "tool", &DependencyVersionsOpts{GitHubToken: "tkn"})
+		require.NoError(t, err)
+		require.Equal(t, 2, requests)
+
+		cfg.APICacheTTL = "0s"
+		_, err = cfg.DependencyVersions(ctx, "tool", nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, requests)
+	})
+
+	t.Run("gitlab", func(t *testing.T) {
+		ts := serveTags(t, "/api/v4/projects/group%2Ftool/repository/tags", "", "v2.0.0", "v10.1.0", "2.1.0")
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+dependencies:
+  tool:
+    url: %s/group/tool/-/releases/v{{.version}}/downloads/tool.tar.gz
+    vars:
+      version: 2.0.0
+`, ts.URL))
+		got, err := cfg.DependencyVersions(ctx, "tool", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.1.0", "2.0.0"}, got)
+	})
+
+	t.Run("unsupported url", func(t *testing.T) {
+		cfg := mustConfigFromYAML(t, `
+dependencies:
+  go:
+    url: https://dl.google.com/go/go{{.version}}.{{.os}}-{{.arch}}.tar.gz
+`)
+		_, err := cfg.DependencyVersions(ctx, "go", nil)
+		require.EqualError(t, err, `can't find a GitHub or GitLab release url for "go"`)
+	})
+}
+
+func Test_tagTemplateExp(t *testing.T) {
+	for _, td := range []struct {
+		tmpl, tag, want string
+	}{
+		{tmpl: "v{{.version}}", tag: "v1.2.3", want: "1.2.3"},
+		{tmpl: "v{{.version}}", tag: "1.2.3"},
+		{tmpl: "{{.version}}", tag: "1.2.3", want: "1.2.3"},
+		{tmpl: "cli/v{{ .version }}", tag: "cli/v0.1.0", want: "0.1.0"},
+		{tmpl: "{{.name}}-{{.version}}", tag: "foo-1.0.0", want: "1.0.0"},
+	} {
+		t.Run(td.tmpl+" "+td.tag, func(t *testing.T) {
+			m := tagTemplateExp(td.tmpl).FindStringSubmatch(td.tag)
+			if td.want == "" {
+				require.Nil(t, m)
+				return
+			}
+			require.Equal(t, td.want, m[1])
+		})
+	}
+	require.Nil(t, tagTemplateExp("latest"))
+}
+
+func TestConfig_UpdateDependencies(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Ftool/repository/tags":
+			body = `[{"name": "v1.1.0"}, {"name": "v1.0.0"}]`
+		case "/group/tool/-/releases/v1.1.0/downloads/tool":
+			body = "tool 1.1.0"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(body))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+	cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+systems: [linux/amd64]
+dependencies:
+  tool:
+    url: %s/group/tool/-/releases/v{{.version}}/downloads/tool
+    vars:
+      version: 1.0.0
+  other:
+    url: https://example.com/other
+url_checksums:
+  %s/group/tool/-/releases/v1.0.0/downloads/tool: deadbeef
+  https://example.com/other: beef
+`, ts.URL, ts.URL))
+	got, err := cfg.UpdateDependencies(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []DependencyUpdate{{
+		Name:       "tool",
+		OldVersion: "1.0.0",
+		NewVersion: "1.1.0",
+		ReleaseURL: ts.URL + "/group/tool/-/releases/v1.1.0",
+		CompareURL: ts.URL + "/group/tool/-/compare/v1.0.0...v1.1.0",
+	}}, got)
+	require.Equal(t, "1.1.0", cfg.Dependencies["tool"].Vars["version"])
+	require.Equal(t, map[string]string{
+		ts.URL + "/group/tool/-/releases/v1.1.0/downloads/tool": "5c2f2da60d19618cebf87972bd4fe076c91f9e220362048f9e56fdf2eed82e59",
+		"https://example.com/other":                             "beef",
+	}, cfg.URLChecksums)
+
+	got, err = cfg.UpdateDependencies(ctx, []string{"tool"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = cfg.UpdateDependencies(ctx, []string{"other"}, nil)
+	require.EqualError(t, err, `dependency "other" has no version var`)
+}
@@ -0,0 +1,111 @@
+package bindown
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// buildSupported builds depName for system and checks that the dependency supports system. Windows on arm64 runs
+// amd64 programs, so a dependency that supports windows/amd64 but not windows/arm64 is built for windows/amd64 instead
+// of failing. A warning is written to w when that happens. w may be nil.
+func (c *Config) buildSupported(depName string, system System, w io.Writer) (*Dependency, error) {
+	dep, err := c.BuildDependency(depName, system)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSystem(dep)
+	if err == nil {
+		return dep, nil
+	}
+	if system != "windows/arm64" || !slices.Contains(dep.Systems, "windows/amd64") {
+		return nil, err
+	}
+	dep, err = c.BuildDependency(depName, "windows/amd64")
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		_, err = fmt.Fprintf(w, "warning: %s doesn't support windows/arm64. using its windows/amd64 build, which runs with emulation\n", depName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dep, nil
+}
+
+func isMSI(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".msi")
+}
+
+// msiExtractCommand returns the command that extracts the files an msi installer would install to dir. msiexec's
+// administrative install does that on windows. Elsewhere it takes msiextract from msitools.
+var msiExtractCommand = func(msiPath, dir string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("msiexec", "/a", msiPath, "/qn", "TARGETDIR="+dir)
+	}
+	return exec.Command("msiextract", "-C", dir, msiPath)
+}
+
+// extractMSI extracts the payload of the msi installer at msiPath to extractDir without installing it.
+func extractMSI(msiPath, extractDir string) error {
+	// msiexec wants absolute paths
+	msiPath, err := filepath.Abs(msiPath)
+	if err != nil {
+		return err
+	}
+	extractDir, err = filepath.Abs(extractDir)
+	if err != nil {
+		return err
+	}
+	cmd := msiExtractCommand(msiPath, extractDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("extracting %s requires %s. on linux and macos it is part of msitools", filepath.Base(msiPath), filepath.Base(cmd.Path))
+	}
+	return fmt.Errorf("failed extracting %s with %s: %w\n%s", filepath.Base(msiPath), filepath.Base(cmd.Path), err, out)
+}
+
+// caseInsensitiveBin returns the path of the dependency's bin in extractDir. Windows release archives don't always use
+// the case archive_path has, so when there is no file at the exact path, a path that differs only in case is used. The
+// exact path is returned when there is no such path or more than one.
+func (d *Dependency) caseInsensitiveBin(extractDir string) string {
+	exact := filepath.Join(extractDir, filepath.FromSlash(d.archivePath()))
+	if _, err := os.Lstat(exact); err == nil {
+		return exact
+	}
+	dir := extractDir
+	for _, part := range strings.Split(strings.Trim(d.archivePath(), "/"), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return exact
+		}
+		var match string
+		for _, entry := range entries {
+			if !strings.EqualFold(entry.Name(), part) {
+				continue
+			}
+			if match != "" {
+				return exact
+			}
+			match = entry.Name()
+		}
+		if match == "" {
+			return exact
+		}
+		dir = filepath.Join(dir, match)
+	}
+	return dir
+}
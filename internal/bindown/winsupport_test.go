@@ -0,0 +1,82 @@
+package bindown
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_buildSupported(t *testing.T) {
+	cfg := mustConfigFromYAML(t, `
+dependencies:
+  foo:
+    url: https://example.com/foo-{{ .os }}-{{ .arch }}.zip
+    systems: [windows/amd64, linux/amd64]
+  bar:
+    url: https://example.com/bar-{{ .os }}-{{ .arch }}.zip
+    systems: [linux/amd64]
+`)
+	var stderr bytes.Buffer
+	dep, err := cfg.buildSupported("foo", "windows/arm64", &stderr)
+	require.NoError(t, err)
+	require.Equal(t, System("windows/amd64"), dep.system)
+	require.Equal(t, "https://example.com/foo-windows-amd64.zip", dep.url)
+	require.Equal(t, "warning: foo doesn't support windows/arm64. using its windows/amd64 build, which runs with emulation\n", stderr.String())
+
+	_, err = cfg.buildSupported("foo", "darwin/arm64", nil)
+	require.ErrorAs(t, err, new(*UnsupportedSystemError))
+
+	_, err = cfg.buildSupported("bar", "windows/arm64", nil)
+	require.ErrorAs(t, err, new(*UnsupportedSystemError))
+}
+
+func TestDependency_extractedBin(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Foo-1.0", "Bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Foo-1.0", "Bin", "FOO.EXE"), []byte("foo"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exact.exe"), []byte("foo"), 0o644))
+
+	dep := &Dependency{built: true, name: "foo"}
+	dep.ArchivePath = ptr("foo-1.0/bin/foo.exe")
+	require.Equal(t, filepath.Join(dir, "Foo-1.0", "Bin", "FOO.EXE"), dep.extractedBin(dir))
+
+	dep.ArchivePath = ptr("exact.exe")
+	require.Equal(t, filepath.Join(dir, "exact.exe"), dep.extractedBin(dir))
+
+	dep.ArchivePath = ptr("foo-1.0/bin/missing.exe")
+	require.Equal(t, filepath.Join(dir, "foo-1.0", "bin", "missing.exe"), dep.extractedBin(dir))
+}
+
+func Test_extractMSI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	msiPath := filepath.Join(dir, "foo.msi")
+	require.NoError(t, os.WriteFile(msiPath, []byte("msi"), 0o644))
+	var gotArgs []string
+	orig := msiExtractCommand
+	t.Cleanup(func() { msiExtractCommand = orig })
+	msiExtractCommand = func(msiPath, dir string) *exec.Cmd {
+		gotArgs = []string{msiPath, dir}
+		return exec.Command("sh", "-c", `mkdir -p "$1/PFiles/Foo" && echo foo > "$1/PFiles/Foo/foo.exe"`, "sh", dir)
+	}
+	extractDir := filepath.Join(dir, "extracted")
+	require.NoError(t, extract(msiPath, extractDir))
+	require.Equal(t, []string{msiPath, extractDir}, gotArgs)
+	require.FileExists(t, filepath.Join(extractDir, "PFiles", "Foo", "foo.exe"))
+
+	msiExtractCommand = func(msiPath, dir string) *exec.Cmd {
+		return exec.Command("bindown-msiextract-does-not-exist", dir, msiPath)
+	}
+	err := extract(msiPath, extractDir)
+	require.EqualError(t, err, "extracting foo.msi requires bindown-msiextract-does-not-exist. on linux and macos it is part of msitools")
+
+	require.Equal(t, "msi", extractOptions("foo.MSI"))
+	require.EqualError(t, checkMagic("foo.msi", []byte("<html>")), "got an HTML page instead of a msi archive for foo.msi")
+}
@@ -0,0 +1,336 @@
+package bindown
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/cache"
+	"golang.org/x/crypto/md4"
+)
+
+// maxZsyncBlockSize is the largest Blocksize accepted from a control file. zsyncmake picks a few KiB.
+const maxZsyncBlockSize = 1 << 20
+
+// zsyncControl is the part of a zsync control file needed to rebuild the target from a seed file.
+type zsyncControl struct {
+	blockSize    int
+	length       int64
+	rsumBytes    int
+	checksumSize int
+	// rsums and checksums are the truncated weak and strong checksums of each block
+	rsums     []uint32
+	checksums [][]byte
+}
+
+func (z *zsyncControl) blockCount() int {
+	return int((z.length + int64(z.blockSize) - 1) / int64(z.blockSize))
+}
+
+// parseZsyncControl parses a control file made by zsyncmake.
+func parseZsyncControl(r io.Reader) (*zsyncControl, error) {
+	br := bufio.NewReader(r)
+	z := &zsyncControl{}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync control file: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, val, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("invalid zsync control file header %q", line)
+		}
+		switch name {
+		case "Blocksize":
+			z.blockSize, err = strconv.Atoi(val)
+		case "Length":
+			z.length, err = strconv.ParseInt(val, 10, 64)
+		case "Hash-Lengths":
+			parts := strings.Split(val, ",")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid zsync Hash-Lengths %q", val)
+			}
+			z.rsumBytes, err = strconv.Atoi(parts[1])
+			if err == nil {
+				z.checksumSize, err = strconv.Atoi(parts[2])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync %s %q", name, val)
+		}
+	}
+	if z.blockSize <= 0 || z.length < 0 || z.rsumBytes < 1 || z.rsumBytes > 4 || z.checksumSize < 1 || z.checksumSize > md4.Size {
+		return nil, errors.New("invalid zsync control file: missing or invalid Blocksize, Length or Hash-Lengths")
+	}
+	if z.blockSize > maxZsyncBlockSize {
+		return nil, fmt.Errorf("invalid zsync control file: Blocksize %d is larger than %d", z.blockSize, maxZsyncBlockSize)
+	}
+	// the block count comes from the header, so the slices grow as blocks are read instead of being sized from it up
+	// front. A control file can't make bindown allocate much more than its own size.
+	n := z.blockCount()
+	rsum := make([]byte, 4)
+	for i := 0; i < n; i++ {
+		// the rsum is stored as the last rsumBytes of a big-endian uint32
+		clear(rsum)
+		_, err := io.ReadFull(br, rsum[4-z.rsumBytes:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync control file: %w", err)
+		}
+		checksum := make([]byte, z.checksumSize)
+		_, err = io.ReadFull(br, checksum)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync control file: %w", err)
+		}
+		z.rsums = append(z.rsums, binary.BigEndian.Uint32(rsum))
+		z.checksums = append(z.checksums, checksum)
+	}
+	return z, nil
+}
+
+// rsumMask is the part of an rsum that is stored in the control file.
+func (z *zsyncControl) rsumMask() uint32 {
+	return uint32(uint64(1)<<(8*z.rsumBytes) - 1)
+}
+
+// rollingSum is the weak checksum zsync uses for blocks. It can be updated a byte at a time as the window moves.
+type rollingSum struct {
+	a, b uint16
+}
+
+func newRollingSum(block []byte) rollingSum {
+	var s rollingSum
+	n := len(block)
+	for i, c := range block {
+		s.a += uint16(c)
+		s.b += uint16(n-i) * uint16(c)
+	}
+	return s
+}
+
+// roll moves the window one byte by removing out and adding in. blockSize is the window size.
+func (s *rollingSum) roll(out, in byte, blockSize int) {
+	s.a += uint16(in) - uint16(out)
+	s.b += s.a - uint16(blockSize)*uint16(out)
+}
+
+func (s rollingSum) value() uint32 {
+	return uint32(s.a)<<16 | uint32(s.b)
+}
+
+// copySeedBlocks writes the blocks of the target that are found in seed to out. It returns which blocks were written.
+func (z *zsyncControl) copySeedBlocks(seed io.Reader, out io.WriterAt) ([]bool, error) {
+	mask := z.rsumMask()
+	byRsum := make(map[uint32][]int, len(z.rsums))
+	for i, rsum := range z.rsums {
+		byRsum[rsum&mask] = append(byRsum[rsum&mask], i)
+	}
+	found := make([]bool, len(z.rsums))
+	bs := z.blockSize
+	br := bufio.NewReaderSize(seed, 1<<20)
+	window := make([]byte, 2*bs)
+	// the current block is window[start : start+bs]. Bytes are appended at start+bs until the window is full, then the
+	// block is copied back to the beginning.
+	start := 0
+	fill := func() (bool, error) {
+		start = 0
+		_, err := io.ReadFull(br, window[:bs])
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	ok, err := fill()
+	if err != nil || !ok {
+		return found, err
+	}
+	sum := newRollingSum(window[:bs])
+	for {
+		matched := false
+		for _, idx := range byRsum[sum.value()&mask] {
+			if found[idx] {
+				continue
+			}
+			block := window[start : start+bs]
+			h := md4.New()
+			h.Write(block)
+			if !bytes.Equal(h.Sum(nil)[:z.checksumSize], z.checksums[idx]) {
+				continue
+			}
+			// the last block is padded with zeros in the control file but not in the target
+			size := min(int64(bs), z.length-int64(idx)*int64(bs))
+			_, err = out.WriteAt(block[:size], int64(idx)*int64(bs))
+			if err != nil {
+				return nil, err
+			}
+			found[idx] = true
+			matched = true
+		}
+		if matched {
+			ok, err = fill()
+			if err != nil || !ok {
+				return found, err
+			}
+			sum = newRollingSum(window[:bs])
+			continue
+		}
+		c, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return found, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start+bs == len(window) {
+			copy(window, window[start:])
+			start = 0
+		}
+		window[start+bs] = c
+		sum.roll(window[start], c, bs)
+		start++
+	}
+}
+
+// zsyncDownload rebuilds the file at dep.url in targetPath from the blocks it shares with seedPath and range requests
+// for the rest. It returns the checksum of the file. It errors when the server doesn't support range requests or the
+// seed has nothing in common with the target so the caller can fall back to a full download.
+func zsyncDownload(targetPath, seedPath string, dep *Dependency) (_ string, errOut error) {
+	dep.mustBeBuilt()
+	dl := dep.downloader
+	resp, err := dl.get(dep.zsyncURL)
+	if err != nil {
+		return "", err
+	}
+	z, err := parseZsyncControl(resp.Body)
+	err = errors.Join(err, resp.Body.Close())
+	if err != nil {
+		return "", err
+	}
+	if dl != nil && dl.maxSize > 0 && z.length > dl.maxSize {
+		return "", dl.tooLargeErr(dep.url)
+	}
+	seed, err := os.Open(seedPath)
+	if err != nil {
+		return "", err
+	}
+	defer deferErr(&errOut, seed.Close)
+	err = os.MkdirAll(filepath.Dir(targetPath), 0o750)
+	if err != nil {
+		return "", err
+	}
+	out, err := os.Create(targetPath)
+	if err != nil {
+		return "", err
+	}
+	defer deferErr(&errOut, out.Close)
+	err = out.Truncate(z.length)
+	if err != nil {
+		return "", err
+	}
+	found, err := z.copySeedBlocks(seed, out)
+	if err != nil {
+		return "", err
+	}
+	missing := 0
+	for _, ok := range found {
+		if !ok {
+			missing++
+		}
+	}
+	if missing == len(found) && missing > 0 {
+		return "", errors.New("zsync seed has no blocks in common with the download")
+	}
+	for first := 0; first < len(found); first++ {
+		if found[first] {
+			continue
+		}
+		last := first
+		for last+1 < len(found) && !found[last+1] {
+			last++
+		}
+		offset := int64(first) * int64(z.blockSize)
+		end := min(int64(last+1)*int64(z.blockSize), z.length)
+		err = fetchRange(dl, dep.url, offset, end, out)
+		if err != nil {
+			return "", err
+		}
+		first = last
+	}
+	_, err = out.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, out)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// fetchRange writes the bytes from offset to end of the file at url to the same offset in out.
+func fetchRange(dl *downloader, url string, offset, end int64, out io.WriterAt) (errOut error) {
+	ctx, cancel := dl.context()
+	defer cancel()
+	req, err := dl.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end-1))
+	resp, err := dl.do(req, url)
+	if err != nil {
+		return err
+	}
+	defer deferErr(&errOut, resp.Body.Close)
+	if resp.StatusCode != http.StatusPartialContent {
+		return &downloadError{err: fmt.Errorf("range request for %s failed: %s", url, resp.Status)}
+	}
+	_, err = io.Copy(io.NewOffsetWriter(out, offset), io.LimitReader(resp.Body, end-offset))
+	return err
+}
+
+// deltaSeedRecord is the file in dlCache that holds the path of the last download of dep, which is the seed for its
+// next zsync download.
+func deltaSeedRecord(dlCache *cache.Cache, dep *Dependency) string {
+	return filepath.Join(dlCache.Root, ".delta-seeds", cacheKey(dep.name+" "+string(dep.system)))
+}
+
+// deltaSeed returns the last download of dep or "" when there isn't one.
+func deltaSeed(dlCache *cache.Cache, dep *Dependency) string {
+	rel, err := os.ReadFile(deltaSeedRecord(dlCache, dep))
+	if err != nil {
+		return ""
+	}
+	seed := filepath.Join(dlCache.Root, filepath.FromSlash(string(rel)))
+	if !FileExists(seed) {
+		return ""
+	}
+	return seed
+}
+
+// recordDeltaSeed makes dlFile the seed for the next zsync download of dep.
+func recordDeltaSeed(dlCache *cache.Cache, dep *Dependency, dlFile string) error {
+	rel, err := filepath.Rel(dlCache.Root, dlFile)
+	if err != nil {
+		return err
+	}
+	record := deltaSeedRecord(dlCache, dep)
+	err = os.MkdirAll(filepath.Dir(record), 0o750)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(record, []byte(filepath.ToSlash(rel)), 0o640)
+}
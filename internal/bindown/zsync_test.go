@@ -0,0 +1,199 @@
+package bindown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/md4"
+)
+
+// makeZsyncControl makes a control file for content like zsyncmake does.
+func makeZsyncControl(content []byte, blockSize, rsumBytes, checksumBytes int) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "zsync: 0.6.2\nFilename: file\nBlocksize: %d\nLength: %d\nHash-Lengths: 1,%d,%d\nURL: file\n\n",
+		blockSize, len(content), rsumBytes, checksumBytes)
+	for offset := 0; offset < len(content); offset += blockSize {
+		block := make([]byte, blockSize)
+		copy(block, content[offset:])
+		rsum := make([]byte, 4)
+		binary.BigEndian.PutUint32(rsum, newRollingSum(block).value())
+		buf.Write(rsum[4-rsumBytes:])
+		h := md4.New()
+		h.Write(block)
+		buf.Write(h.Sum(nil)[:checksumBytes])
+	}
+	return buf.Bytes()
+}
+
+func Test_parseZsyncControl(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		content := []byte("hello zsync world")
+		z, err := parseZsyncControl(bytes.NewReader(makeZsyncControl(content, 4, 2, 3)))
+		require.NoError(t, err)
+		require.Len(t, z.rsums, 5)
+		require.Len(t, z.checksums, 5)
+	})
+
+	t.Run("length larger than body", func(t *testing.T) {
+		// a header claiming an enormous file must fail at the end of the body instead of allocating for every block
+		control := "Blocksize: 1\nLength: 9000000000000000000\nHash-Lengths: 1,2,3\n\n" + "12345"
+		_, err := parseZsyncControl(bytes.NewReader([]byte(control)))
+		require.ErrorContains(t, err, "invalid zsync control file")
+	})
+
+	t.Run("block size too large", func(t *testing.T) {
+		control := "Blocksize: 1073741824\nLength: 1\nHash-Lengths: 1,2,3\n\n"
+		_, err := parseZsyncControl(bytes.NewReader([]byte(control)))
+		require.EqualError(t, err, "invalid zsync control file: Blocksize 1073741824 is larger than 1048576")
+	})
+}
+
+func Test_rollingSum(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	bs := 64
+	sum := newRollingSum(data[:bs])
+	for i := 1; i+bs <= len(data); i++ {
+		sum.roll(data[i-1], data[i+bs-1], bs)
+		require.Equal(t, newRollingSum(data[i:i+bs]), sum, "offset %d", i)
+	}
+}
+
+func Test_zsyncControl_copySeedBlocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	seed := make([]byte, 10_000)
+	rng.Read(seed)
+	// the target moves the seed's content by inserting bytes, changes a block and has a partial last block
+	target := append([]byte("inserted"), seed[:5000]...)
+	changed := make([]byte, 100)
+	rng.Read(changed)
+	target = append(target, changed...)
+	target = append(target, seed[5100:]...)
+	target = append(target, []byte("tail")...)
+
+	for _, rsumBytes := range []int{2, 3, 4} {
+		t.Run(fmt.Sprintf("rsum bytes %d", rsumBytes), func(t *testing.T) {
+			z, err := parseZsyncControl(bytes.NewReader(makeZsyncControl(target, 512, rsumBytes, 8)))
+			require.NoError(t, err)
+			require.Equal(t, 20, z.blockCount())
+			out := make([]byte, len(target))
+			found, err := z.copySeedBlocks(bytes.NewReader(seed), &sliceWriterAt{out})
+			require.NoError(t, err)
+			foundCount := 0
+			for i, ok := range found {
+				if !ok {
+					continue
+				}
+				foundCount++
+				end := min((i+1)*512, len(target))
+				require.Equal(t, target[i*512:end], out[i*512:end], "block %d", i)
+			}
+			// only the blocks with inserted, changed or tail bytes are missing
+			require.Equal(t, 17, foundCount)
+		})
+	}
+}
+
+type sliceWriterAt struct {
+	b []byte
+}
+
+func (s *sliceWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(s.b[off:], p), nil
+}
+
+func TestZsyncDownload(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	v1 := make([]byte, 200_000)
+	rng.Read(v1)
+	v2 := append(bytes.Clone(v1[:100_000]), []byte("new in v2")...)
+	v2 = append(v2, v1[100_000:]...)
+	files := map[string][]byte{
+		"/v1/sdk.bin":       v1,
+		"/v1/sdk.bin.zsync": makeZsyncControl(v1, 2048, 3, 8),
+		"/v2/sdk.bin":       v2,
+		"/v2/sdk.bin.zsync": makeZsyncControl(v2, 2048, 3, 8),
+	}
+	var served atomic.Int64
+	var rangeSupport atomic.Bool
+	rangeSupport.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if !rangeSupport.Load() {
+			r.Header.Del("Range")
+		}
+		cw := &countingResponseWriter{ResponseWriter: w, n: &served}
+		http.ServeContent(cw, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(ts.Close)
+	sum := func(b []byte) string {
+		s := sha256.Sum256(b)
+		return hex.EncodeToString(s[:])
+	}
+	cacheDir := t.TempDir()
+	download := func(version string) []byte {
+		t.Helper()
+		cfg := mustConfigFromYAML(t, fmt.Sprintf(`
+dependencies:
+  sdk:
+    url: %[1]s/{{ .version }}/sdk.bin
+    zsync_url: %[1]s/{{ .version }}/sdk.bin.zsync
+    vars:
+      version: %[2]s
+url_checksums:
+  %[1]s/v1/sdk.bin: %[3]s
+  %[1]s/v2/sdk.bin: %[4]s
+`, ts.URL, version, sum(v1), sum(v2)))
+		cfg.Cache = cacheDir
+		out := filepath.Join(t.TempDir(), "sdk.bin")
+		err := cfg.DownloadDependencies([]string{"sdk"}, "linux/amd64", &ConfigDownloadDependenciesOpts{Output: out})
+		require.NoError(t, err)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		return got
+	}
+
+	require.Equal(t, v1, download("v1"))
+	require.Equal(t, int64(len(v1)), served.Load())
+
+	served.Store(0)
+	require.Equal(t, v2, download("v2"))
+	// the control file and the changed blocks are downloaded instead of the whole file
+	require.Less(t, served.Load(), int64(len(v2)/10))
+
+	t.Run("falls back without range requests", func(t *testing.T) {
+		rangeSupport.Store(false)
+		require.NoError(t, os.RemoveAll(filepath.Join(cacheDir, "downloads")))
+		require.Equal(t, v1, download("v1"))
+		served.Store(0)
+		require.Equal(t, v2, download("v2"))
+		require.Greater(t, served.Load(), int64(len(v2)))
+	})
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n.Add(int64(n))
+	return n, err
+}
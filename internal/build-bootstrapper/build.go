@@ -21,6 +21,15 @@ type BuildOpts struct {
 	Wrap    bool
 }
 
+// ShellLib returns the portable posix shell functions the bootstrap scripts are built with.
+func ShellLib() (string, error) {
+	content, err := assets.ReadFile("assets/shlib.sh")
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
 // Build builds a bootstrapper for the given tag
 func Build(tag string, opts *BuildOpts) (_ string, errOut error) {
 	if opts == nil {
@@ -66,7 +66,7 @@ func addDependency(
 	if err != nil {
 		return err
 	}
-	err = built.Validate(name, built.Systems)
+	err = built.Validate(name, built.Systems, nil)
 	if err != nil {
 		b, e := yaml.Marshal(&bindown.Config{
 			Dependencies: built.Dependencies,
@@ -70,6 +70,7 @@ func (f *dlFile) setArchiveFiles(ctx context.Context, binName, version string) e
 	if err != nil {
 		return err
 	}
+	req.Header.Set("User-Agent", bindown.DefaultUserAgent())
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
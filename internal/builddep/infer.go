@@ -0,0 +1,293 @@
+package builddep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+// InferredURL is a url template inferred from a single release url.
+type InferredURL struct {
+	// Template is the url with its version, os and arch replaced by {{.version}}, {{.os}} and {{.arch}}.
+	Template string
+	Version  string
+	// Name is a guess at the dependency name from the url's filename.
+	Name string
+	// System is the system the given url is for.
+	System bindown.System
+	// Substitutions map the os and arch of each system to the values in its url when they differ.
+	Substitutions map[string]map[string]string
+	// URLs are the release urls found for each system, including the given url.
+	URLs map[bindown.System]string
+}
+
+// SortedURLs returns URLs sorted by system.
+func (u *InferredURL) SortedURLs() []string {
+	systems := bindown.MapKeys(u.URLs)
+	slices.Sort(systems)
+	urls := make([]string, len(systems))
+	for i, system := range systems {
+		urls[i] = u.URLs[system]
+	}
+	return urls
+}
+
+var versionRegexp = regexp.MustCompile(`\d+(?:\.\d+)+(?:-(?:rc|alpha|beta|pre)[.\d]*)?`)
+
+// inferSystems are the systems InferURLTemplate looks for when none are given. It would take too many requests to look
+// for every go dist.
+var inferSystems = []bindown.System{
+	"darwin/amd64",
+	"darwin/arm64",
+	"linux/amd64",
+	"linux/arm64",
+	"windows/amd64",
+	"windows/arm64",
+}
+
+// os and arch values to try for other systems in the order they are tried
+var (
+	osAliases = map[string][]string{
+		"linux":   {"linux", "unknown-linux-musl", "unknown-linux-gnu"},
+		"darwin":  {"darwin", "macos", "osx", "apple-darwin", "mac"},
+		"windows": {"windows", "win64", "pc-windows-msvc", "pc-windows-gnu", "win"},
+	}
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x64", "64bit"},
+		"arm64": {"arm64", "aarch64"},
+		"386":   {"386", "i386", "x86", "32bit"},
+	}
+)
+
+// InferURLTemplate finds the version, os and arch in dlURL and replaces them with template vars. It then looks for
+// release urls for the rest of systems by trying the os and arch values projects commonly use. When version is empty
+// it is taken from the url. When systems is nil, it looks for the most common systems.
+func InferURLTemplate(ctx context.Context, dlURL, version string, systems []bindown.System) (*InferredURL, error) {
+	u, err := url.Parse(dlURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute url", dlURL)
+	}
+	if systems == nil {
+		systems = inferSystems
+	}
+	pathStart := strings.Index(dlURL, u.Host) + len(u.Host)
+	filename := urlFilename(dlURL[pathStart:])
+	if version == "" {
+		version = versionRegexp.FindString(filename)
+		if version == "" {
+			version = versionRegexp.FindString(dlURL[pathStart:])
+		}
+		if version == "" {
+			return nil, fmt.Errorf("could not find a version in %s", dlURL)
+		}
+	}
+	tmpl := dlURL[:pathStart] + strings.ReplaceAll(dlURL[pathStart:], version, "{{.version}}")
+	if tmpl == dlURL {
+		return nil, fmt.Errorf("version %q is not in %s", version, dlURL)
+	}
+
+	osSub := findSub(tmpl, pathStart, func(s string) *systemSub { return parseOs(s, systems) })
+	if osSub == nil {
+		return nil, fmt.Errorf("could not find an os in %s", dlURL)
+	}
+	if osSub.idx != -1 {
+		tmpl = tmpl[:osSub.idx] + "{{.os}}" + tmpl[osSub.idx+len(osSub.val):]
+	}
+	archSub := findSub(tmpl, pathStart, func(s string) *systemSub { return parseArch(s, systems) })
+	if archSub.idx != -1 {
+		tmpl = tmpl[:archSub.idx] + "{{.arch}}" + tmpl[archSub.idx+len(archSub.val):]
+	}
+	system := bindown.System(osSub.normalized + "/" + archSub.normalized)
+
+	result := &InferredURL{
+		Template:      tmpl,
+		Version:       version,
+		Name:          inferName(urlFilename(dlURL[pathStart:]), version, osSub, archSub),
+		System:        system,
+		Substitutions: map[string]map[string]string{},
+		URLs:          map[bindown.System]string{system: dlURL},
+	}
+	result.addSubstitution("os", osSub.normalized, osSub.val)
+	result.addSubstitution("arch", archSub.normalized, archSub.val)
+
+	suffix := urlSuffix(tmpl)
+	for _, sys := range systems {
+		if sys == system {
+			continue
+		}
+		if osSub.idx == -1 && sys.OS() != osSub.normalized || archSub.idx == -1 && sys.Arch() != archSub.normalized {
+			continue
+		}
+		osVals := tokenCandidates(sys.OS(), osSub, osAliases)
+		archVals := tokenCandidates(sys.Arch(), archSub, archAliases)
+		suffixes := []string{suffix}
+		if sys.OS() == "windows" && osSub.normalized != "windows" {
+			switch suffix {
+			case ".zip", ".exe":
+			case "":
+				suffixes = append(suffixes, ".exe")
+			default:
+				suffixes = append(suffixes, ".zip")
+			}
+		}
+		found := false
+		for _, osVal := range osVals {
+			for _, archVal := range archVals {
+				for _, sfx := range suffixes {
+					candidate := strings.NewReplacer(
+						"{{.version}}", version,
+						"{{.os}}", osVal,
+						"{{.arch}}", archVal,
+					).Replace(strings.TrimSuffix(tmpl, suffix) + sfx)
+					ok, err := urlExists(ctx, candidate)
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						continue
+					}
+					result.URLs[sys] = candidate
+					result.addSubstitution("os", sys.OS(), osVal)
+					result.addSubstitution("arch", sys.Arch(), archVal)
+					found = true
+					break
+				}
+				if found {
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (u *InferredURL) addSubstitution(varName, normalized, val string) {
+	if val == "" || val == normalized {
+		return
+	}
+	if u.Substitutions[varName] == nil {
+		u.Substitutions[varName] = map[string]string{}
+	}
+	u.Substitutions[varName][normalized] = val
+}
+
+// urlFilename returns the part of a url path after the last slash.
+func urlFilename(urlPath string) string {
+	urlPath, _, _ = strings.Cut(urlPath, "?")
+	return urlPath[strings.LastIndex(urlPath, "/")+1:]
+}
+
+// findSub finds a sub with parse in the filename of tmpl or anywhere in its path when it isn't in the filename. The
+// returned idx is relative to tmpl.
+func findSub(tmpl string, pathStart int, parse func(string) *systemSub) *systemSub {
+	filenameStart := strings.LastIndex(tmpl, "/") + 1
+	for _, start := range []int{filenameStart, pathStart} {
+		sub := parse(tmpl[start:])
+		if sub == nil {
+			continue
+		}
+		if sub.idx != -1 {
+			sub.idx += start
+			return sub
+		}
+		if start == pathStart {
+			return sub
+		}
+	}
+	return nil
+}
+
+// urlSuffix returns the archive, compression or .exe suffix of tmpl.
+func urlSuffix(tmpl string) string {
+	for _, s := range append(append(slices.Clone(archiveSuffixes), compressSuffixes...), ".exe") {
+		if strings.HasSuffix(tmpl, s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// tokenCandidates returns the values to try for normalized. The value found in the original url is tried first when
+// it is for the same os or arch. Otherwise, aliases are tried with the same case as the value found in the original url.
+func tokenCandidates(normalized string, found *systemSub, aliases map[string][]string) []string {
+	if found.normalized == normalized && found.val != "" {
+		return []string{found.val}
+	}
+	candidates := aliases[normalized]
+	if len(candidates) == 0 {
+		candidates = []string{normalized}
+	}
+	result := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		switch {
+		case found.val != "" && found.val == strings.ToUpper(found.val) && found.val != strings.ToLower(found.val):
+			c = strings.ToUpper(c)
+		case found.val != "" && found.val[:1] == strings.ToUpper(found.val[:1]) && found.val != strings.ToLower(found.val):
+			c = strings.ToUpper(c[:1]) + c[1:]
+		}
+		if !slices.Contains(result, c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// inferName guesses the dependency name from the part of filename before the version, os and arch.
+func inferName(filename, version string, osSub, archSub *systemSub) string {
+	end := len(filename)
+	lower := strings.ToLower(filename)
+	for _, token := range []string{version, strings.ToLower(osSub.val), strings.ToLower(archSub.val)} {
+		if token == "" {
+			continue
+		}
+		idx := strings.Index(lower, token)
+		if idx == -1 || idx >= end {
+			continue
+		}
+		end = idx
+		// a v prefix belongs to the version
+		if token == version && idx > 0 && lower[idx-1] == 'v' {
+			end--
+		}
+	}
+	name := strings.TrimRight(filename[:end], "-_.")
+	if end == len(filename) {
+		name = strings.TrimSuffix(name, urlSuffix(name))
+	}
+	return name
+}
+
+// urlExists checks whether a HEAD request for dlURL succeeds. Errors are only returned for requests that can't be made,
+// not for failed responses.
+func urlExists(ctx context.Context, dlURL string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, dlURL, http.NoBody)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", bindown.DefaultUserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, err
+		}
+		return false, nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 300, nil
+}
@@ -0,0 +1,104 @@
+package builddep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/bindown/v4/internal/bindown"
+)
+
+func TestInferURLTemplate(t *testing.T) {
+	serve := func(t *testing.T, paths ...string) string {
+		t.Helper()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range paths {
+				if r.URL.Path == p {
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}))
+		t.Cleanup(ts.Close)
+		return ts.URL
+	}
+
+	t.Run("go style", func(t *testing.T) {
+		srv := serve(t,
+			"/releases/download/v1.2.3/tool_1.2.3_linux_amd64.tar.gz",
+			"/releases/download/v1.2.3/tool_1.2.3_darwin_arm64.tar.gz",
+			"/releases/download/v1.2.3/tool_1.2.3_windows_amd64.zip",
+		)
+		got, err := InferURLTemplate(context.Background(), srv+"/releases/download/v1.2.3/tool_1.2.3_linux_amd64.tar.gz", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, &InferredURL{
+			Template:      srv + "/releases/download/v{{.version}}/tool_{{.version}}_{{.os}}_{{.arch}}.tar.gz",
+			Version:       "1.2.3",
+			Name:          "tool",
+			System:        "linux/amd64",
+			Substitutions: map[string]map[string]string{},
+			URLs: map[bindown.System]string{
+				"linux/amd64":   srv + "/releases/download/v1.2.3/tool_1.2.3_linux_amd64.tar.gz",
+				"darwin/arm64":  srv + "/releases/download/v1.2.3/tool_1.2.3_darwin_arm64.tar.gz",
+				"windows/amd64": srv + "/releases/download/v1.2.3/tool_1.2.3_windows_amd64.zip",
+			},
+		}, got)
+	})
+
+	t.Run("rust style", func(t *testing.T) {
+		srv := serve(t,
+			"/dl/14.0.0/ripgrep-14.0.0-x86_64-unknown-linux-musl.tar.gz",
+			"/dl/14.0.0/ripgrep-14.0.0-aarch64-apple-darwin.tar.gz",
+			"/dl/14.0.0/ripgrep-14.0.0-x86_64-pc-windows-msvc.zip",
+		)
+		got, err := InferURLTemplate(context.Background(), srv+"/dl/14.0.0/ripgrep-14.0.0-x86_64-unknown-linux-musl.tar.gz", "", []bindown.System{
+			"linux/amd64", "darwin/arm64", "windows/amd64", "windows/386",
+		})
+		require.NoError(t, err)
+		require.Equal(t, srv+"/dl/{{.version}}/ripgrep-{{.version}}-{{.arch}}-{{.os}}.tar.gz", got.Template)
+		require.Equal(t, "ripgrep", got.Name)
+		require.Equal(t, bindown.System("linux/amd64"), got.System)
+		require.Equal(t, map[string]map[string]string{
+			"os": {
+				"linux":   "unknown-linux-musl",
+				"darwin":  "apple-darwin",
+				"windows": "pc-windows-msvc",
+			},
+			"arch": {
+				"amd64": "x86_64",
+				"arm64": "aarch64",
+			},
+		}, got.Substitutions)
+		require.Equal(t, []string{
+			srv + "/dl/14.0.0/ripgrep-14.0.0-aarch64-apple-darwin.tar.gz",
+			srv + "/dl/14.0.0/ripgrep-14.0.0-x86_64-unknown-linux-musl.tar.gz",
+			srv + "/dl/14.0.0/ripgrep-14.0.0-x86_64-pc-windows-msvc.zip",
+		}, got.SortedURLs())
+	})
+
+	t.Run("capitalized", func(t *testing.T) {
+		srv := serve(t,
+			"/v2.0.1/Tool-Linux-x86_64",
+			"/v2.0.1/Tool-Darwin-x86_64",
+		)
+		got, err := InferURLTemplate(context.Background(), srv+"/v2.0.1/Tool-Linux-x86_64", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, "Tool", got.Name)
+		require.Equal(t, map[bindown.System]string{
+			"linux/amd64":  srv + "/v2.0.1/Tool-Linux-x86_64",
+			"darwin/amd64": srv + "/v2.0.1/Tool-Darwin-x86_64",
+		}, got.URLs)
+	})
+
+	t.Run("no version", func(t *testing.T) {
+		_, err := InferURLTemplate(context.Background(), "https://example.com/latest/tool_linux_amd64", "", nil)
+		require.EqualError(t, err, "could not find a version in https://example.com/latest/tool_linux_amd64")
+	})
+
+	t.Run("no os", func(t *testing.T) {
+		_, err := InferURLTemplate(context.Background(), "https://example.com/1.0.0/tool.tar.gz", "", nil)
+		require.EqualError(t, err, "could not find an os in https://example.com/1.0.0/tool.tar.gz")
+	})
+}